@@ -91,6 +91,12 @@ func (am *AccountManager) GetPrivateKey(addr common.Address, password string) (p
 
 // PromptAccount get account private key by input password or password stored in file
 func PromptAccount(adviceAddress common.Address, keystorePath, passwordfile string) (addr common.Address, keybin []byte, err error) {
+	addr, keybin, _, err = PromptAccountWithPassword(adviceAddress, keystorePath, passwordfile)
+	return
+}
+
+// PromptAccountWithPassword is the same as PromptAccount, and returns the password which unlocks the account
+func PromptAccountWithPassword(adviceAddress common.Address, keystorePath, passwordfile string) (addr common.Address, keybin []byte, password string, err error) {
 	am := NewAccountManager(keystorePath)
 	if len(am.Accounts) == 0 {
 		err = fmt.Errorf("No Ethereum accounts found in the directory %s", keystorePath)
@@ -131,7 +137,7 @@ func PromptAccount(adviceAddress common.Address, keystorePath, passwordfile stri
 		if err != nil {
 			data = []byte(passwordfile)
 		}
-		password := string(data)
+		password = string(data)
 		log.Trace(fmt.Sprintf("password is %s", password))
 		keybin, err = am.GetPrivateKey(addr, password)
 		if err != nil {
@@ -146,7 +152,7 @@ func PromptAccount(adviceAddress common.Address, keystorePath, passwordfile stri
 			if err != nil {
 				return
 			}
-			password := string(pb) // getpass.Prompt("Enter the password to unlock:")
+			password = string(pb) // getpass.Prompt("Enter the password to unlock:")
 			keybin, err = am.GetPrivateKey(addr, password)
 			if err != nil && i == 3 {
 				log.Error(fmt.Sprintf("Exhausted passphrase unlock attempts for %s. Aborting ...", addr))
@@ -0,0 +1,188 @@
+package photon
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/channel"
+	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/notify"
+	"github.com/SmartMeshFoundation/Photon/params"
+)
+
+//通道相关的告警,每个块都重新检查
+var channelAlertTypes = []int{
+	notify.InfoTypeAlertPartnerClosed,
+	notify.InfoTypeAlertLockNearRevealTimeout,
+	notify.InfoTypeAlertSettleWindowClosing,
+}
+
+/*
+checkAlerts 每个块检查一次需要用户处理的情况,在主循环中调用.
+余额需要查询公链,每params.AlertBalanceCheckBlocks个块在单独的线程中检查一次
+*/
+func (rs *Service) checkAlerts(blockNumber int64) {
+	alerts := make(map[string]*notify.Alert)
+	for _, g := range rs.Token2ChannelGraph {
+		for _, c := range g.ChannelIdentifier2Channel {
+			for _, a := range rs.channelAlerts(c, blockNumber) {
+				alerts[a.Key] = a
+			}
+		}
+	}
+	rs.updateAlerts(channelAlertTypes, alerts, blockNumber)
+	if rs.Config.AlertMinBalance == nil || rs.Config.AlertMinBalance.Sign() <= 0 ||
+		blockNumber%params.AlertBalanceCheckBlocks != 0 {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&rs.alertBalanceChecking, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&rs.alertBalanceChecking, 0)
+		rs.checkBalanceAlert(blockNumber)
+	}()
+}
+
+func (rs *Service) channelAlerts(c *channel.Channel, blockNumber int64) (alerts []*notify.Alert) {
+	newAlert := func(typ int, level notify.Level, key, message string) *notify.Alert {
+		a := &notify.Alert{
+			Key:               fmt.Sprintf("%d-%s-%s", typ, c.ChannelIdentifier.ChannelIdentifier.String(), key),
+			Type:              typ,
+			Level:             level,
+			Message:           message,
+			ChannelIdentifier: c.ChannelIdentifier.ChannelIdentifier,
+			TokenAddress:      c.TokenAddress,
+			PartnerAddress:    c.PartnerState.Address,
+		}
+		alerts = append(alerts, a)
+		return a
+	}
+	// 剩余的块数不超过两倍的RevealTimeout警告,不超过RevealTimeout是错误
+	deadlineLevel := func(deadline int64) (level notify.Level, ok bool) {
+		remaining := deadline - blockNumber
+		if remaining > 2*int64(c.RevealTimeout) {
+			return
+		}
+		if remaining > int64(c.RevealTimeout) {
+			return notify.LevelWarn, true
+		}
+		return notify.LevelError, true
+	}
+	if c.State == channeltype.StateClosed || c.State == channeltype.StateSettling {
+		if c.ExternState.ClosingAddress == c.PartnerState.Address {
+			newAlert(notify.InfoTypeAlertPartnerClosed, notify.LevelWarn, "",
+				fmt.Sprintf("partner %s closed the channel at block %d", c.PartnerState.Address.String(), c.ExternState.ClosedBlock))
+		}
+		deadline := c.GetSettleExpiration(blockNumber)
+		if level, ok := deadlineLevel(deadline); ok {
+			a := newAlert(notify.InfoTypeAlertSettleWindowClosing, level, "",
+				fmt.Sprintf("settle window of the channel closes at block %d", deadline))
+			a.DeadlineBlock = deadline
+		}
+	}
+	for lockSecretHash, proof := range c.PartnerState.Lock2UnclaimedLocks {
+		// 已经在链上注册的密码不会过期
+		if proof.IsRegisteredOnChain {
+			continue
+		}
+		if level, ok := deadlineLevel(proof.Lock.Expiration); ok {
+			a := newAlert(notify.InfoTypeAlertLockNearRevealTimeout, level, lockSecretHash.String(),
+				fmt.Sprintf("lock of %s expires at block %d and partner has not unlocked it", proof.Lock.Amount, proof.Lock.Expiration))
+			a.LockSecretHash = lockSecretHash
+			a.DeadlineBlock = proof.Lock.Expiration
+		}
+	}
+	return
+}
+
+func (rs *Service) checkBalanceAlert(blockNumber int64) {
+	if !rs.Chain.Client.IsConnected() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), params.DefaultTxTimeout)
+	defer cancel()
+	balance, err := rs.Chain.Client.BalanceAt(ctx, rs.NodeAddress, nil)
+	if err != nil {
+		log.Warn(fmt.Sprintf("check balance for alert err %s", err))
+		return
+	}
+	alerts := make(map[string]*notify.Alert)
+	if balance.Cmp(rs.Config.AlertMinBalance) < 0 {
+		a := &notify.Alert{
+			Key:     fmt.Sprintf("%d", notify.InfoTypeAlertLowBalance),
+			Type:    notify.InfoTypeAlertLowBalance,
+			Level:   notify.LevelError,
+			Message: fmt.Sprintf("balance %s is less than %s, there may be not enough gas to close or settle channels", balance, rs.Config.AlertMinBalance),
+			Balance: new(big.Int).Set(balance),
+		}
+		alerts[a.Key] = a
+	}
+	rs.updateAlerts([]int{notify.InfoTypeAlertLowBalance}, alerts, blockNumber)
+}
+
+/*
+updateAlerts 用最新的检查结果替换types类型的告警,
+新出现的告警和级别升高的告警通知上层,已经不满足条件的告警删除
+*/
+func (rs *Service) updateAlerts(types []int, alerts map[string]*notify.Alert, blockNumber int64) {
+	rs.alertLock.Lock()
+	defer rs.alertLock.Unlock()
+	if rs.alerts == nil {
+		rs.alerts = make(map[string]*notify.Alert)
+	}
+	checked := make(map[int]bool)
+	for _, t := range types {
+		checked[t] = true
+	}
+	for key, a := range rs.alerts {
+		if checked[a.Type] && alerts[key] == nil {
+			log.Info(fmt.Sprintf("alert %s cleared", key))
+			delete(rs.alerts, key)
+		}
+	}
+	for key, a := range alerts {
+		old := rs.alerts[key]
+		if old != nil {
+			a.BlockNumber = old.BlockNumber
+			a.Time = old.Time
+		} else {
+			a.BlockNumber = blockNumber
+			a.Time = time.Now().Unix()
+		}
+		rs.alerts[key] = a
+		if old != nil && a.Level <= old.Level {
+			continue
+		}
+		log.Warn(fmt.Sprintf("alert %s level=%d %s", key, a.Level, a.Message))
+		if rs.NotifyHandler != nil {
+			rs.NotifyHandler.NotifyAlert(a)
+		}
+	}
+}
+
+//GetAlerts 当前需要处理的告警,按级别从高到低排序
+func (r *API) GetAlerts() []*notify.Alert {
+	rs := r.Photon
+	rs.alertLock.Lock()
+	defer rs.alertLock.Unlock()
+	alerts := make([]*notify.Alert, 0, len(rs.alerts))
+	for _, a := range rs.alerts {
+		alerts = append(alerts, a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Level != alerts[j].Level {
+			return alerts[i].Level > alerts[j].Level
+		}
+		if alerts[i].BlockNumber != alerts[j].BlockNumber {
+			return alerts[i].BlockNumber < alerts[j].BlockNumber
+		}
+		return alerts[i].Key < alerts[j].Key
+	})
+	return alerts
+}
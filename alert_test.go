@@ -0,0 +1,41 @@
+package photon
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateAlerts(t *testing.T) {
+	rs := &Service{
+		NotifyHandler: notify.NewNotifyHandler(),
+	}
+	api := NewPhotonAPI(rs)
+	newAlert := func(key string, typ int, level notify.Level) map[string]*notify.Alert {
+		return map[string]*notify.Alert{key: {Key: key, Type: typ, Level: level}}
+	}
+	noticeCount := func() int {
+		return len(rs.NotifyHandler.GetNoticeChan())
+	}
+	rs.updateAlerts(channelAlertTypes, newAlert("a", notify.InfoTypeAlertSettleWindowClosing, notify.LevelWarn), 10)
+	rs.updateAlerts([]int{notify.InfoTypeAlertLowBalance}, newAlert("b", notify.InfoTypeAlertLowBalance, notify.LevelError), 11)
+	assert.Equal(t, 2, noticeCount())
+	//相同级别不重复通知
+	rs.updateAlerts(channelAlertTypes, newAlert("a", notify.InfoTypeAlertSettleWindowClosing, notify.LevelWarn), 12)
+	assert.Equal(t, 2, noticeCount())
+	//级别升高再通知一次,保留第一次发现的块
+	rs.updateAlerts(channelAlertTypes, newAlert("a", notify.InfoTypeAlertSettleWindowClosing, notify.LevelError), 13)
+	assert.Equal(t, 3, noticeCount())
+	alerts := api.GetAlerts()
+	if assert.Len(t, alerts, 2) {
+		assert.Equal(t, int64(10), alerts[0].BlockNumber)
+		assert.EqualValues(t, notify.LevelError, alerts[0].Level)
+	}
+	//余额的检查结果不影响通道的告警
+	rs.updateAlerts(channelAlertTypes, nil, 14)
+	alerts = api.GetAlerts()
+	if assert.Len(t, alerts, 1) {
+		assert.Equal(t, "b", alerts[0].Key)
+	}
+}
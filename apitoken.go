@@ -0,0 +1,153 @@
+package photon
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+GetAPIToken 根据调用方提供的bearer token查找对应的API token,不存在或者已经吊销的返回ErrUnauthorized
+*/
+func (r *API) GetAPIToken(token string) (t *models.APIToken, err error) {
+	t, err = r.Photon.dao.GetAPIToken(models.NewAPITokenKey(token))
+	if err == rerr.ErrNotFound || (err == nil && t.Revoked) {
+		return nil, rerr.ErrUnauthorized.Append("invalid api token")
+	}
+	return
+}
+
+/*
+HasAPIToken 是否创建过API token,没有API token并且没有指定http-username的时候REST接口不需要认证,和以前保持一致.
+每个请求都会调用,所以使用启动时加载的结果
+*/
+func (r *API) HasAPIToken() bool {
+	return r.Photon.hasAPIToken
+}
+
+/*
+SpendAPIToken 发起交易之前预留API token的支付金额,amounts中任何一种token超过支付上限都不预留.
+交易没有发起的时候用RefundAPIToken退还,发起以后用ChargeAPIToken记录,交易失败或者取消的时候自动退还
+*/
+func (r *API) SpendAPIToken(key string, amounts map[common.Address]*big.Int) error {
+	return r.Photon.spendAPIToken(key, amounts)
+}
+
+// RefundAPIToken 退还没有发起的交易预留的金额
+func (r *API) RefundAPIToken(key string, tokenAddress common.Address, amount *big.Int) {
+	r.Photon.refundAPIToken(key, tokenAddress, amount)
+}
+
+// ChargeAPIToken 交易已经发起,等待交易结果,失败或者取消的时候退还预留的金额
+func (r *API) ChargeAPIToken(key string, tokenAddress common.Address, lockSecretHash common.Hash, amount *big.Int) {
+	r.Photon.chargeAPIToken(key, &models.APITokenCharge{
+		TokenAddress:   tokenAddress,
+		LockSecretHash: lockSecretHash,
+		Amount:         amount,
+	})
+}
+
+func (rs *Service) spendAPIToken(key string, amounts map[common.Address]*big.Int) error {
+	rs.apiTokenLock.Lock()
+	defer rs.apiTokenLock.Unlock()
+	t, err := rs.dao.GetAPIToken(key)
+	if err != nil {
+		return err
+	}
+	if t.SpendingCap == nil {
+		return nil
+	}
+	err = t.Spend(amounts)
+	if err != nil {
+		return err
+	}
+	return rs.dao.SaveAPIToken(t)
+}
+
+func (rs *Service) refundAPIToken(key string, tokenAddress common.Address, amount *big.Int) {
+	rs.finishAPITokenCharge(key, &models.APITokenCharge{TokenAddress: tokenAddress, Amount: amount}, true)
+}
+
+func (rs *Service) chargeAPIToken(key string, c *models.APITokenCharge) {
+	rs.apiTokenLock.Lock()
+	t, err := rs.dao.GetAPIToken(key)
+	if err == nil {
+		t.Charges = append(t.Charges, c)
+		err = rs.dao.SaveAPIToken(t)
+	}
+	rs.apiTokenLock.Unlock()
+	if err != nil {
+		log.Error(fmt.Sprintf("save api token charge lockSecretHash=%s err %s", c.LockSecretHash.String(), err))
+		return
+	}
+	go rs.watchAPITokenCharge(key, c)
+}
+
+/*
+watchAPITokenCharge 等待交易结果,交易失败或者取消以后退还金额.
+节点退出的时候还没有结果的交易保存在API token中,重启以后继续等待
+*/
+func (rs *Service) watchAPITokenCharge(key string, c *models.APITokenCharge) {
+	for {
+		std, err := rs.dao.GetSentTransferDetail(c.TokenAddress, c.LockSecretHash)
+		if err == rerr.ErrNotFound {
+			// 交易记录已经被归档,无法知道结果,不退还
+			log.Warn(fmt.Sprintf("api token charge lockSecretHash=%s,transfer not found", c.LockSecretHash.String()))
+			rs.finishAPITokenCharge(key, c, false)
+			return
+		}
+		if err == nil {
+			switch std.Status {
+			case models.TransferStatusSuccess:
+				rs.finishAPITokenCharge(key, c, false)
+				return
+			case models.TransferStatusCanceled, models.TransferStatusFailed:
+				rs.finishAPITokenCharge(key, c, true)
+				return
+			}
+		}
+		select {
+		case <-time.After(5 * time.Second):
+		case <-rs.quitChan:
+			return
+		}
+	}
+}
+
+//finishAPITokenCharge 删除这笔交易的记录,refund为true的时候退还金额
+func (rs *Service) finishAPITokenCharge(key string, c *models.APITokenCharge, refund bool) {
+	rs.apiTokenLock.Lock()
+	defer rs.apiTokenLock.Unlock()
+	t, err := rs.dao.GetAPIToken(key)
+	if err != nil {
+		log.Error(fmt.Sprintf("refund api token err %s", err))
+		return
+	}
+	for i, c2 := range t.Charges {
+		if c2.TokenAddress == c.TokenAddress && c2.LockSecretHash == c.LockSecretHash {
+			t.Charges = append(t.Charges[:i], t.Charges[i+1:]...)
+			break
+		}
+	}
+	if refund {
+		t.Refund(c.TokenAddress, c.Amount)
+	}
+	err = rs.dao.SaveAPIToken(t)
+	if err != nil {
+		log.Error(fmt.Sprintf("refund api token err %s", err))
+	}
+}
+
+// resumeAPITokenCharges 继续等待上次退出时还没有结果的交易
+func (rs *Service) resumeAPITokenCharges(ts []*models.APIToken) {
+	for _, t := range ts {
+		for _, c := range t.Charges {
+			go rs.watchAPITokenCharge(t.Key, c)
+		}
+	}
+}
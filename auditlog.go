@@ -0,0 +1,195 @@
+package photon
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// 审计日志中不记录这些参数的值
+var auditSecretParams = []string{"secret", "password"}
+
+func isAuditSecretParam(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range auditSecretParams {
+		if key == s || strings.HasSuffix(key, "_"+s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactAuditValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, v2 := range x {
+			if isAuditSecretParam(k) {
+				if s, ok := v2.(string); !ok || s != "" {
+					x[k] = "***"
+				}
+				continue
+			}
+			x[k] = redactAuditValue(v2)
+		}
+	case []interface{}:
+		for i, v2 := range x {
+			x[i] = redactAuditValue(v2)
+		}
+	}
+	return v
+}
+
+/*
+redactAuditParams 隐藏json参数中的secret和密码,不是json的参数原样保存
+*/
+func redactAuditParams(params string) string {
+	var v interface{}
+	if params == "" || json.Unmarshal([]byte(params), &v) != nil {
+		return params
+	}
+	buf, err := json.Marshal(redactAuditValue(v))
+	if err != nil {
+		return params
+	}
+	return string(buf)
+}
+
+func auditHash(m map[string]interface{}, keys ...string) common.Hash {
+	for _, k := range keys {
+		if s, ok := m[k].(string); ok && s != "" {
+			return common.HexToHash(s)
+		}
+	}
+	return utils.EmptyHash
+}
+
+/*
+fillAuditResult 从接口返回的data中找到交易的lockSecretHash和通道,
+通道相关的tx是异步发出的,只能记录调用之后已经发出的第一个tx
+*/
+func (r *API) fillAuditResult(l *models.AuditLog, data json.RawMessage) {
+	var m map[string]interface{}
+	if len(data) == 0 || json.Unmarshal(data, &m) != nil {
+		return
+	}
+	l.LockSecretHash = auditHash(m, "lockSecretHash", "lock_secret_hash")
+	l.ChannelIdentifier = auditHash(m, "channel_identifier")
+	l.TXHash = auditHash(m, "tx_hash")
+	if l.TXHash != utils.EmptyHash || l.ChannelIdentifier == utils.EmptyHash {
+		return
+	}
+	list, err := r.Photon.dao.GetTXInfoList(l.ChannelIdentifier, 0, utils.EmptyAddress, "", "")
+	if err != nil {
+		log.Error(fmt.Sprintf("GetTXInfoList err %s", err))
+		return
+	}
+	var first *models.TXInfo
+	for _, txInfo := range list {
+		if txInfo.IsSelfCall && txInfo.CallTime >= l.Time && (first == nil || txInfo.CallTime < first.CallTime) {
+			first = txInfo
+		}
+	}
+	if first != nil {
+		l.TXHash = first.TXHash
+	}
+}
+
+/*
+AppendAuditLog 记录一次改变状态的接口调用,l中需要设置Time,Source,Caller,Operation,Params和错误,
+data是接口返回的结果.Seq和Hash在这里生成,和上一条记录连接起来
+*/
+func (r *API) AppendAuditLog(l *models.AuditLog, data json.RawMessage) error {
+	l.Params = redactAuditParams(l.Params)
+	r.fillAuditResult(l, data)
+	rs := r.Photon
+	rs.auditLogLock.Lock()
+	defer rs.auditLogLock.Unlock()
+	last, err := rs.dao.GetLastAuditLog()
+	if err == nil {
+		l.Seq = last.Seq + 1
+		l.PrevHash = last.Hash
+	} else if err == rerr.ErrNotFound {
+		l.Seq = 1
+		l.PrevHash = utils.EmptyHash
+	} else {
+		return err
+	}
+	l.Hash = l.ComputeHash()
+	return rs.dao.AppendAuditLog(l)
+}
+
+// GetAuditLogList :
+func (r *API) GetAuditLogList(fromSeq, toSeq int64) (ls []*models.AuditLog, err error) {
+	if fromSeq <= 0 {
+		fromSeq = 1
+	}
+	if toSeq <= 0 {
+		last, err2 := r.Photon.dao.GetLastAuditLog()
+		if err2 == rerr.ErrNotFound {
+			return
+		}
+		if err2 != nil {
+			err = err2
+			return
+		}
+		toSeq = last.Seq
+	}
+	return r.Photon.dao.GetAuditLogList(fromSeq, toSeq)
+}
+
+//AuditLogVerifyResult 校验审计日志的结果
+type AuditLogVerifyResult struct {
+	Count    int64       `json:"count"`
+	LastHash common.Hash `json:"last_hash"` // 保存在节点之外,可以发现整条日志被重新生成
+	Valid    bool        `json:"valid"`
+	BadSeq   int64       `json:"bad_seq,omitempty"`
+	Reason   string      `json:"reason,omitempty"`
+}
+
+// 校验时每次读取的记录数
+const auditLogVerifyBatch = 1000
+
+/*
+VerifyAuditLog 从第一条开始校验所有的审计日志
+*/
+func (r *API) VerifyAuditLog() (result *AuditLogVerifyResult, err error) {
+	result = &AuditLogVerifyResult{Valid: true}
+	last, err := r.Photon.dao.GetLastAuditLog()
+	if err == rerr.ErrNotFound {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	var prev *models.AuditLog
+	for from := int64(1); from <= last.Seq; from += auditLogVerifyBatch {
+		var ls []*models.AuditLog
+		ls, err = r.Photon.dao.GetAuditLogList(from, from+auditLogVerifyBatch-1)
+		if err != nil {
+			return
+		}
+		badSeq, err2 := models.VerifyAuditLogs(prev, ls)
+		if err2 == nil && int64(len(ls)) < auditLogVerifyBatch && from+int64(len(ls)) <= last.Seq {
+			badSeq, err2 = from+int64(len(ls)), fmt.Errorf("audit log %d is missing", from+int64(len(ls)))
+		}
+		if err2 != nil {
+			result.Valid = false
+			result.BadSeq = badSeq
+			result.Reason = err2.Error()
+			return
+		}
+		if len(ls) > 0 {
+			prev = ls[len(ls)-1]
+		}
+	}
+	result.Count = last.Seq
+	result.LastHash = last.Hash
+	return
+}
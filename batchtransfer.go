@@ -0,0 +1,243 @@
+package photon
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+batchTransferRunner 通过transfer请求队列执行一个批量转账,同时进行的交易数不超过Concurrency,
+每一笔交易的状态变化都会保存到db,节点重启之后会继续执行没有完成的交易
+*/
+type batchTransferRunner struct {
+	rs    *Service
+	lock  sync.Mutex // 保护batch,可能同时有多笔交易在更新状态
+	batch *models.BatchTransfer
+}
+
+func (br *batchTransferRunner) run() {
+	b := br.batch
+	log.Info(fmt.Sprintf("start batch transfer key=%s,items=%d,concurrency=%d", b.Key, len(b.Items), b.Concurrency))
+	sem := make(chan struct{}, b.Concurrency)
+	wg := sync.WaitGroup{}
+	for _, item := range b.Items {
+		if item.Finished {
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-br.rs.quitChan:
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func(item *models.BatchTransferItem) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			br.transfer(item)
+		}(item)
+	}
+	wg.Wait()
+	br.lock.Lock()
+	defer br.lock.Unlock()
+	if b.SuccessCount+b.FailedCount != len(b.Items) {
+		// 节点正在退出,还有交易没有结果
+		return
+	}
+	b.FinishTime = time.Now().Unix()
+	br.save()
+	br.rs.NotifyHandler.NotifyBatchTransferProgress(b, nil)
+	log.Info(fmt.Sprintf("batch transfer key=%s finished,success=%d,failed=%d", b.Key, b.SuccessCount, b.FailedCount))
+}
+
+func (br *batchTransferRunner) transfer(item *models.BatchTransferItem) {
+	var err error
+	if item.LockSecretHash == utils.EmptyHash {
+		result := br.rs.transferAsyncClient(item.TokenAddress, item.Amount, item.TargetAddress, utils.EmptyHash, false, item.Data, nil)
+		if result.LockSecretHash != utils.EmptyHash {
+			if br.batch.APITokenKey != "" {
+				br.rs.chargeAPIToken(br.batch.APITokenKey, &models.APITokenCharge{
+					TokenAddress:   item.TokenAddress,
+					LockSecretHash: result.LockSecretHash,
+					Amount:         item.Amount,
+				})
+			}
+			br.lock.Lock()
+			item.LockSecretHash = result.LockSecretHash
+			br.save()
+			br.lock.Unlock()
+		}
+		select {
+		case err = <-result.Result:
+		case <-time.After(params.MaxRequestTimeout):
+			err = rerr.ErrTransferTimeout
+		case <-br.rs.quitChan:
+			return
+		}
+	} else {
+		// 重启之前已经发出的交易,拿不到结果了,只能等待SentTransferDetail的状态
+		err = br.waitSentTransferDetail(item)
+		if err == errBatchTransferQuit {
+			return
+		}
+	}
+	br.lock.Lock()
+	defer br.lock.Unlock()
+	item.Finished = true
+	if err == nil {
+		item.Status = models.TransferStatusSuccess
+		br.batch.SuccessCount++
+	} else {
+		item.Status = models.TransferStatusFailed
+		if item.LockSecretHash != utils.EmptyHash {
+			std, err2 := br.rs.dao.GetSentTransferDetail(item.TokenAddress, item.LockSecretHash)
+			if err2 == nil {
+				item.Status = std.Status
+			}
+		}
+		item.StatusMessage = err.Error()
+		br.batch.FailedCount++
+		// 已经发出的交易由chargeAPIToken等待结果
+		if item.LockSecretHash == utils.EmptyHash && br.batch.APITokenKey != "" {
+			br.rs.refundAPIToken(br.batch.APITokenKey, item.TokenAddress, item.Amount)
+		}
+	}
+	br.save()
+	br.rs.NotifyHandler.NotifyBatchTransferProgress(br.batch, item)
+}
+
+var errBatchTransferQuit = errors.New("photon quit")
+
+func (br *batchTransferRunner) waitSentTransferDetail(item *models.BatchTransferItem) error {
+	timeoutCh := time.After(params.MaxRequestTimeout)
+	for {
+		std, err := br.rs.dao.GetSentTransferDetail(item.TokenAddress, item.LockSecretHash)
+		if err != nil {
+			return err
+		}
+		switch std.Status {
+		case models.TransferStatusSuccess:
+			return nil
+		case models.TransferStatusCanceled, models.TransferStatusFailed:
+			return fmt.Errorf("transfer %s", std.StatusMessage)
+		}
+		select {
+		case <-time.After(5 * time.Second):
+		case <-timeoutCh:
+			return rerr.ErrTransferTimeout
+		case <-br.rs.quitChan:
+			return errBatchTransferQuit
+		}
+	}
+}
+
+func (br *batchTransferRunner) save() {
+	err := br.rs.dao.SaveBatchTransfer(br.batch)
+	if err != nil {
+		log.Error(fmt.Sprintf("SaveBatchTransfer key=%s err %s", br.batch.Key, err))
+	}
+}
+
+// resumeBatchTransfers 继续执行上次退出时没有完成的批量转账
+func (rs *Service) resumeBatchTransfers() {
+	bs, err := rs.dao.GetBatchTransferList()
+	if err != nil {
+		log.Error(fmt.Sprintf("GetBatchTransferList err %s", err))
+		return
+	}
+	for _, b := range bs {
+		if b.IsFinished() {
+			continue
+		}
+		br := &batchTransferRunner{rs: rs, batch: b}
+		go br.run()
+	}
+}
+
+/*
+BatchTransfer 发起批量转账,立即返回,通过GetBatchTransfer或者notify.Handler查询进度.
+concurrency为0时使用默认值.
+apiTokenKey不为空时,所有交易检查通过以后一次性预留这个API token的支付金额,任何一种token超过上限都拒绝整个批量转账
+*/
+func (r *API) BatchTransfer(items []*models.BatchTransferItem, concurrency int, apiTokenKey string) (b *models.BatchTransfer, err error) {
+	if len(items) == 0 || len(items) > params.MaxBatchTransferItems {
+		err = rerr.ErrArgumentError.Printf("batch transfer must have 1 to %d items", params.MaxBatchTransferItems)
+		return
+	}
+	if concurrency <= 0 {
+		concurrency = params.DefaultBatchTransferConcurrency
+	}
+	if concurrency > params.MaxBatchTransferConcurrency {
+		err = rerr.ErrArgumentError.Printf("concurrency must < %d", params.MaxBatchTransferConcurrency)
+		return
+	}
+	for i, item := range items {
+		if item.Amount == nil || item.Amount.Cmp(utils.BigInt0) <= 0 {
+			err = rerr.ErrInvalidAmount.Printf("item %d", i)
+			return
+		}
+		if len(item.Data) > params.MaxTransferDataLen {
+			err = rerr.ErrArgumentError.Printf("item %d invalid data, length must < %d", i, params.MaxTransferDataLen)
+			return
+		}
+		item.LockSecretHash = utils.EmptyHash
+		item.Status = models.TransferStatusInit
+		item.StatusMessage = ""
+		item.Finished = false
+	}
+	amounts := make(map[common.Address]*big.Int)
+	for _, item := range items {
+		if amounts[item.TokenAddress] == nil {
+			amounts[item.TokenAddress] = new(big.Int)
+		}
+		amounts[item.TokenAddress].Add(amounts[item.TokenAddress], item.Amount)
+	}
+	if apiTokenKey != "" {
+		err = r.Photon.spendAPIToken(apiTokenKey, amounts)
+		if err != nil {
+			return
+		}
+	}
+	b = &models.BatchTransfer{
+		Key:         utils.NewRandomHash().String(),
+		Concurrency: concurrency,
+		Items:       items,
+		CreateTime:  time.Now().Unix(),
+		APITokenKey: apiTokenKey,
+	}
+	err = r.Photon.dao.SaveBatchTransfer(b)
+	if err != nil {
+		if apiTokenKey != "" {
+			for token, amount := range amounts {
+				r.Photon.refundAPIToken(apiTokenKey, token, amount)
+			}
+		}
+		return
+	}
+	br := &batchTransferRunner{rs: r.Photon, batch: b}
+	go br.run()
+	// runner会修改b,返回db中的副本
+	return r.Photon.dao.GetBatchTransfer(b.Key)
+}
+
+// GetBatchTransfer 查询批量转账的进度以及每一笔交易的状态
+func (r *API) GetBatchTransfer(key string) (b *models.BatchTransfer, err error) {
+	return r.Photon.dao.GetBatchTransfer(key)
+}
+
+// GetBatchTransferList :
+func (r *API) GetBatchTransferList() (bs []*models.BatchTransfer, err error) {
+	return r.Photon.dao.GetBatchTransferList()
+}
@@ -252,10 +252,7 @@ func (be *Events) startAlarmTask() {
 		if lastSendBlockNumber != currentBlock {
 			be.StateChangeChannel <- &transfer.BlockStateChange{BlockNumber: currentBlock}
 		}
-		//// 每5倍确认块清除一次过期流水
-		//if fromBlockNumber%(5*params.ForkConfirmNumber) == 0 {
-		//	be.chainEventRecordDao.ClearOldChainEventRecord(uint64(fromBlockNumber))
-		//}
+		// 过期的链上事件记录由归档任务按照--retention-chain-event-blocks清除
 		// 清除过期流水
 		for key, blockNumber := range be.txDone {
 			if blockNumber <= uint64(fromBlockNumber) {
@@ -32,8 +32,9 @@ type ExternalState struct {
 	auth                           *bind.TransactOpts
 	privKey                        *ecdsa.PrivateKey
 	Client                         *helper.SafeEthClient
-	ClosedBlock                    int64 //通道被强制关闭的block,
-	SettledBlock                   int64 //初始为0,通道被强制关闭以后则是可以进行settle的块数,通道被settle以后,则是通道被settle的块数
+	ClosedBlock                    int64          //通道被强制关闭的block,
+	ClosingAddress                 common.Address //关闭通道的一方
+	SettledBlock                   int64          //初始为0,通道被强制关闭以后则是可以进行settle的块数,通道被settle以后,则是通道被settle的块数
 	ChannelIdentifier              contracts.ChannelUniqueID
 	MyAddress                      common.Address
 	PartnerAddress                 common.Address
@@ -379,7 +379,7 @@ func (c *Channel) RegisterSecret(secret common.Hash) error {
 		log.Debug(fmt.Sprintf("secret registered node=%s,from=%s,to=%s,token=%s,hashlock=%s, secret=%s, amount=%s",
 			utils.Pex(c.OurState.Address[:]), utils.Pex(c.OurState.Address[:]),
 			utils.Pex(c.PartnerState.Address[:]), utils.APex(c.TokenAddress),
-			utils.Pex(hashlock[:]), utils.Pex(secret[:]), lock.Amount))
+			utils.Pex(hashlock[:]), utils.Pex(secret[:]), lock.Amount), c.transferLogCtx(hashlock)...)
 		err := c.OurState.RegisterSecret(secret)
 		return err
 	}
@@ -388,7 +388,7 @@ func (c *Channel) RegisterSecret(secret common.Hash) error {
 		log.Debug(fmt.Sprintf("secret registered node=%s,from=%s,to=%s,token=%s,hashlock=%s, secret=%s, amount=%s",
 			utils.Pex(c.OurState.Address[:]), utils.Pex(c.PartnerState.Address[:]),
 			utils.Pex(c.OurState.Address[:]), utils.APex(c.TokenAddress),
-			utils.Pex(hashlock[:]), utils.Pex(secret[:]), lock.Amount))
+			utils.Pex(hashlock[:]), utils.Pex(secret[:]), lock.Amount), c.transferLogCtx(hashlock)...)
 		err := c.PartnerState.RegisterSecret(secret)
 		if err != nil {
 			return err
@@ -397,6 +397,11 @@ func (c *Channel) RegisterSecret(secret common.Hash) error {
 	return nil
 }
 
+//transferLogCtx 通道中一个交易的日志字段
+func (c *Channel) transferLogCtx(lockSecretHash common.Hash) []interface{} {
+	return utils.TransferLogCtx(lockSecretHash, c.ChannelIdentifier.ChannelIdentifier)
+}
+
 //RegisterRevealedSecretHash 链上对应的密码注册了
 // RegisterRevealedSecretHash : secret has been registered on chain.
 func (c *Channel) RegisterRevealedSecretHash(lockSecretHash, secret common.Hash, blockNumber int64) error {
@@ -411,7 +416,7 @@ func (c *Channel) RegisterRevealedSecretHash(lockSecretHash, secret common.Hash,
 		log.Debug(fmt.Sprintf("lockSecretHash registered node=%s,from=%s,to=%s,token=%s,lockSecretHash=%s,amount=%s",
 			utils.Pex(c.OurState.Address[:]), utils.Pex(c.OurState.Address[:]),
 			utils.Pex(c.PartnerState.Address[:]), utils.APex(c.TokenAddress),
-			utils.Pex(lockSecretHash[:]), lock.Amount))
+			utils.Pex(lockSecretHash[:]), lock.Amount), c.transferLogCtx(lockSecretHash)...)
 		err := c.OurState.RegisterRevealedSecretHash(lockSecretHash, secret, blockNumber)
 		if err == nil {
 			//一旦注册成功,在事件处理流程中,相应的statemanager会进行处理
@@ -423,7 +428,7 @@ func (c *Channel) RegisterRevealedSecretHash(lockSecretHash, secret common.Hash,
 		log.Debug(fmt.Sprintf("lockSecretHash registered node=%s,from=%s,to=%s,token=%s,lockSecretHash=%s,amount=%s",
 			utils.Pex(c.OurState.Address[:]), utils.Pex(c.PartnerState.Address[:]),
 			utils.Pex(c.OurState.Address[:]), utils.APex(c.TokenAddress),
-			utils.Pex(lockSecretHash[:]), lock.Amount))
+			utils.Pex(lockSecretHash[:]), lock.Amount), c.transferLogCtx(lockSecretHash)...)
 		return c.PartnerState.RegisterRevealedSecretHash(lockSecretHash, secret, blockNumber)
 	}
 	return nil
@@ -658,7 +663,7 @@ func (c *Channel) registerMediatedTranser(tr *encoding.MediatedTransfer, blockNu
 	if expiresAfterSettle { //After receiving this lock, the party can close or updatetransfer on the chain, so that if the party does not have a password, he still can't get the money.
 		log.Error(fmt.Sprintf("Lock expires after the settlement period. node=%s,from=%s,to=%s,lockexpiration=%d,currentblock=%d,end_settle_period=%d",
 			utils.Pex(c.OurState.Address[:]), utils.Pex(fromState.Address[:]), utils.Pex(toState.Address[:]),
-			tr.Expiration, blockNumber, endSettlePeriod))
+			tr.Expiration, blockNumber, endSettlePeriod), c.transferLogCtx(tr.LockSecretHash)...)
 		return rerr.ErrChannelLockExpirationTooLarge
 	}
 	err = fromState.registerMediatedMessage(tr)
@@ -1503,6 +1508,7 @@ func NewChannelSerialization(c *Channel) *channeltype.Serialization {
 		OurContractBalance:     c.OurState.ContractBalance,
 		PartnerContractBalance: c.PartnerState.ContractBalance,
 		ClosedBlock:            c.ExternState.ClosedBlock,
+		ClosingAddress:         c.ExternState.ClosingAddress,
 		SettledBlock:           c.ExternState.SettledBlock,
 	}
 	return s
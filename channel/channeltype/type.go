@@ -61,6 +61,7 @@ type Serialization struct {
 	OurContractBalance     *big.Int
 	PartnerContractBalance *big.Int
 	ClosedBlock            int64
+	ClosingAddress         common.Address
 	SettledBlock           int64
 	SettleTimeout          int
 }
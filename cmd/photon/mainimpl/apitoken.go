@@ -0,0 +1,217 @@
+package mainimpl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/models/sqlitedb"
+	"github.com/SmartMeshFoundation/Photon/models/stormdb"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var apiTokenDBFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "address",
+		Usage: "the ethereum address photon uses",
+	},
+	cli.StringFlag{
+		Name:  "datadir",
+		Usage: "directory for storing photon data.",
+	},
+	cli.StringFlag{
+		Name:  "password-file",
+		Usage: "file of the keystore password,needed when the database is encrypted",
+	},
+}
+
+/*
+apiTokenCommand 管理REST接口的API token,直接修改数据库,所以必须在photon停止的时候运行
+*/
+var apiTokenCommand = cli.Command{
+	Name:  "apitoken",
+	Usage: "manage scoped bearer tokens of the http api,photon must be stopped",
+	Subcommands: []cli.Command{
+		{
+			Name:  "create",
+			Usage: "create a new api token,the token is only printed once",
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "name",
+					Usage: "unique name of the token",
+				},
+				cli.StringFlag{
+					Name:  "scope",
+					Usage: "read,pay or admin",
+					Value: string(models.APITokenScopeRead),
+				},
+				cli.StringFlag{
+					Name:  "spending-cap",
+					Usage: "max amount a pay token can spend of each token,empty means no limit",
+				},
+			}, apiTokenDBFlags...),
+			Action: createAPIToken,
+		},
+		{
+			Name:   "list",
+			Usage:  "list all api tokens",
+			Flags:  apiTokenDBFlags,
+			Action: listAPIToken,
+		},
+		{
+			Name:  "revoke",
+			Usage: "revoke an api token",
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "name",
+					Usage: "name of the token",
+				},
+			}, apiTokenDBFlags...),
+			Action: revokeAPIToken,
+		},
+	},
+}
+
+func openAPITokenDB(ctx *cli.Context) (dao models.Dao, err error) {
+	var password string
+	if ctx.IsSet("password-file") {
+		password, err = readDBPassword(ctx.String("password-file"), "")
+		if err != nil {
+			return
+		}
+	}
+	return openOfflineDB(ctx, password)
+}
+
+//openOfflineDB 打开address对应的数据库,和compactdb一样根据.info文件选择数据库类型,photon运行的时候会失败
+func openOfflineDB(ctx *cli.Context, password string) (dao models.Dao, err error) {
+	address, err := utils.HexToAddress(ctx.String("address"))
+	if err != nil {
+		return
+	}
+	dbPath := getDatabasePath(getDataDir(ctx.String("datadir")), address)
+	if !common.FileExist(dbPath) {
+		err = fmt.Errorf("database %s not found", dbPath)
+		return
+	}
+	if getDBType(dbPath) == "sqlite" {
+		var sqliteDao *sqlitedb.SQLiteDB
+		sqliteDao, err = sqlitedb.OpenDbWithPassword(dbPath, password, false)
+		if err == nil {
+			dao = sqliteDao
+		}
+	} else {
+		var stormDao *stormdb.StormDB
+		stormDao, err = stormdb.OpenDbWithPassword(dbPath, password, false)
+		if err == nil {
+			dao = stormDao
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("open db error %s,photon must be stopped", err)
+	}
+	return
+}
+
+func findAPIToken(dao models.Dao, name string) (t *models.APIToken, err error) {
+	ts, err := dao.GetAPITokenList()
+	if err != nil {
+		return
+	}
+	for _, t = range ts {
+		if t.Name == name {
+			return
+		}
+	}
+	return nil, nil
+}
+
+func createAPIToken(ctx *cli.Context) (err error) {
+	name := ctx.String("name")
+	if name == "" {
+		return errors.New("name is needed")
+	}
+	scope := models.APITokenScope(ctx.String("scope"))
+	if !scope.IsValid() {
+		return fmt.Errorf("unkown scope %s", scope)
+	}
+	var spendingCap *big.Int
+	if s := ctx.String("spending-cap"); s != "" {
+		var ok bool
+		spendingCap, ok = new(big.Int).SetString(s, 0)
+		if !ok || spendingCap.Sign() < 0 {
+			return fmt.Errorf("invalid spending-cap %s", s)
+		}
+	}
+	dao, err := openAPITokenDB(ctx)
+	if err != nil {
+		return
+	}
+	defer dao.CloseDB()
+	old, err := findAPIToken(dao, name)
+	if err != nil {
+		return
+	}
+	if old != nil {
+		return fmt.Errorf("api token %s already exists", name)
+	}
+	token := utils.NewRandomHash().String()[2:]
+	t := &models.APIToken{
+		Key:         models.NewAPITokenKey(token),
+		Name:        name,
+		Scope:       scope,
+		SpendingCap: spendingCap,
+		CreateTime:  time.Now().Unix(),
+	}
+	err = dao.SaveAPIToken(t)
+	if err != nil {
+		return
+	}
+	fmt.Printf("api token %s created,scope=%s,use it with header\nAuthorization: Bearer %s\n", name, scope, token)
+	return
+}
+
+func listAPIToken(ctx *cli.Context) (err error) {
+	dao, err := openAPITokenDB(ctx)
+	if err != nil {
+		return
+	}
+	defer dao.CloseDB()
+	ts, err := dao.GetAPITokenList()
+	if err != nil {
+		return
+	}
+	buf, err := json.MarshalIndent(ts, "", "\t")
+	if err != nil {
+		return
+	}
+	fmt.Println(string(buf))
+	return
+}
+
+func revokeAPIToken(ctx *cli.Context) (err error) {
+	dao, err := openAPITokenDB(ctx)
+	if err != nil {
+		return
+	}
+	defer dao.CloseDB()
+	t, err := findAPIToken(dao, ctx.String("name"))
+	if err != nil {
+		return
+	}
+	if t == nil {
+		return fmt.Errorf("api token %s not found", ctx.String("name"))
+	}
+	t.Revoked = true
+	err = dao.SaveAPIToken(t)
+	if err != nil {
+		return
+	}
+	fmt.Printf("api token %s revoked\n", t.Name)
+	return
+}
@@ -0,0 +1,64 @@
+package mainimpl
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/SmartMeshFoundation/Photon/models/sqlitedb"
+	"github.com/SmartMeshFoundation/Photon/models/stormdb"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/urfave/cli.v1"
+)
+
+/*
+compactDBCommand 归档删除记录以后数据库文件不会变小,离线压缩.
+boltdb不能在运行的时候压缩,所以必须在photon停止的时候运行
+*/
+var compactDBCommand = cli.Command{
+	Name:  "compactdb",
+	Usage: "compact the database after history records are archived,photon must be stopped",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "address",
+			Usage: "the ethereum address photon uses",
+		},
+		cli.StringFlag{
+			Name:  "datadir",
+			Usage: "directory for storing photon data.",
+		},
+	},
+	Action: compactDB,
+}
+
+//getDBType 和启动时一样,数据库的类型记录在.info文件中
+func getDBType(dbPath string) string {
+	//#nosec
+	info, err := ioutil.ReadFile(dbPath + ".info")
+	if err != nil {
+		return "boltdb"
+	}
+	return string(info)
+}
+
+func compactDB(ctx *cli.Context) (err error) {
+	address, err := utils.HexToAddress(ctx.String("address"))
+	if err != nil {
+		return
+	}
+	dbPath := getDatabasePath(getDataDir(ctx.String("datadir")), address)
+	if !common.FileExist(dbPath) {
+		return fmt.Errorf("database %s not found", dbPath)
+	}
+	var before, after int64
+	if getDBType(dbPath) == "sqlite" {
+		before, after, err = sqlitedb.CompactDb(dbPath)
+	} else {
+		before, after, err = stormdb.CompactDb(dbPath)
+	}
+	if err != nil {
+		return
+	}
+	fmt.Printf("database compacted from %d to %d bytes\n", before, after)
+	return
+}
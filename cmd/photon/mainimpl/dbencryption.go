@@ -0,0 +1,130 @@
+package mainimpl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/howeyc/gopass"
+	"gopkg.in/urfave/cli.v1"
+)
+
+/*
+dbEncryptionCommand 离线加密,更换key或者取消加密数据库,必须在photon停止的时候运行.
+key从keystore的密码派生,修改keystore密码以后需要用rotate设置新的密码
+*/
+var dbEncryptionCommand = cli.Command{
+	Name:  "dbencryption",
+	Usage: "encrypt the database, rotate its key or decrypt it,photon must be stopped",
+	Subcommands: []cli.Command{
+		{
+			Name:  "rotate",
+			Usage: "encrypt the database with a new key derived from the new password,a plain database is encrypted",
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "new-password-file",
+					Usage: "file of the new keystore password,default is the current password",
+				},
+			}, apiTokenDBFlags...),
+			Action: rotateDBEncryption,
+		},
+		{
+			Name:   "decrypt",
+			Usage:  "decrypt all values in the database",
+			Flags:  apiTokenDBFlags,
+			Action: decryptDB,
+		},
+	},
+}
+
+//encryptedDao stormdb和sqlitedb都支持加密
+type encryptedDao interface {
+	models.Dao
+	IsEncrypted() bool
+	SetEncryptionPassword(password string) error
+}
+
+//openEncryptedDB 打开数据库,只有支持加密的数据库才能运行这些命令
+func openEncryptedDB(ctx *cli.Context, password string) (dao encryptedDao, err error) {
+	d, err := openOfflineDB(ctx, password)
+	if err != nil {
+		return
+	}
+	dao, ok := d.(encryptedDao)
+	if !ok {
+		d.CloseDB()
+		return nil, fmt.Errorf("database does not support encryption")
+	}
+	return
+}
+
+/*
+readDBPassword 读取密码文件,和启动时一样不去掉空白.没有指定文件的时候提示输入
+*/
+func readDBPassword(passwordFile, prompt string) (password string, err error) {
+	if passwordFile != "" {
+		var data []byte
+		//#nosec
+		data, err = ioutil.ReadFile(passwordFile)
+		if err != nil {
+			return
+		}
+		return string(data), nil
+	}
+	pb, err := gopass.GetPasswdPrompt(prompt, false, os.Stdin, os.Stdout)
+	if err != nil {
+		return
+	}
+	return string(pb), nil
+}
+
+func rotateDBEncryption(ctx *cli.Context) (err error) {
+	password, err := readDBPassword(ctx.String("password-file"), "Enter the keystore password:")
+	if err != nil {
+		return
+	}
+	newPassword := password
+	if ctx.IsSet("new-password-file") {
+		newPassword, err = readDBPassword(ctx.String("new-password-file"), "")
+		if err != nil {
+			return
+		}
+	}
+	if newPassword == "" {
+		return fmt.Errorf("password is empty")
+	}
+	dao, err := openEncryptedDB(ctx, password)
+	if err != nil {
+		return
+	}
+	defer dao.CloseDB()
+	err = dao.SetEncryptionPassword(newPassword)
+	if err != nil {
+		return
+	}
+	fmt.Println("database encrypted with the new key")
+	return
+}
+
+func decryptDB(ctx *cli.Context) (err error) {
+	password, err := readDBPassword(ctx.String("password-file"), "Enter the keystore password:")
+	if err != nil {
+		return
+	}
+	dao, err := openEncryptedDB(ctx, password)
+	if err != nil {
+		return
+	}
+	defer dao.CloseDB()
+	if !dao.IsEncrypted() {
+		fmt.Println("database is not encrypted")
+		return
+	}
+	err = dao.SetEncryptionPassword("")
+	if err != nil {
+		return
+	}
+	fmt.Println("database decrypted")
+	return
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/SmartMeshFoundation/Photon/internal/rpanic"
 	"github.com/SmartMeshFoundation/Photon/log"
 	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/models/sqlitedb"
 	"github.com/SmartMeshFoundation/Photon/models/stormdb"
 	"github.com/SmartMeshFoundation/Photon/network"
 	"github.com/SmartMeshFoundation/Photon/network/helper"
@@ -182,9 +183,89 @@ func StartMain() (*photon.API, error) {
 			Name:  "http-password",
 			Usage: "the password needed when call http api,only work with http-username",
 		},
+		cli.StringFlag{
+			Name:  "api-tls-cert",
+			Usage: "certificate file of the http api,the api uses https when both api-tls-cert and api-tls-key are set",
+		},
+		cli.StringFlag{
+			Name:  "api-tls-key",
+			Usage: "private key file of the http api certificate",
+		},
+		cli.StringFlag{
+			Name:  "api-client-ca",
+			Usage: "ca certificate file,when set,clients of the https api must present a certificate signed by it",
+		},
+		cli.DurationFlag{
+			Name:  "idempotency-retention",
+			Usage: "how long the result of a request with Idempotency-Key is kept, duplicated requests in this window return the first result",
+			Value: params.DefaultIdempotencyRetention,
+		},
+		cli.BoolFlag{
+			Name:  "encrypt-db",
+			Usage: "encrypt values in the database with a key derived from the keystore password, an existing plain database is migrated at startup. an encrypted database is always opened with the keystore password",
+		},
+		cli.DurationFlag{
+			Name:  "reconcile-interval",
+			Usage: "how often channels are compared with the contract, divergences are logged and can be queried by /api/1/reconcile. 0 disables the periodic reconciliation",
+			Value: params.DefaultReconcileInterval,
+		},
+		cli.BoolFlag{
+			Name:  "reconcile-repair",
+			Usage: "repair deposits missed by the event processing when reconciliation confirms them",
+		},
+		cli.DurationFlag{
+			Name:  "retention-interval",
+			Usage: "how often history records older than their retention are moved to compressed files in the archive directory of the database. 0 disables archiving",
+			Value: params.DefaultRetentionInterval,
+		},
+		cli.DurationFlag{
+			Name:  "retention-received-transfer",
+			Usage: "how long received transfers are kept, income statistics are kept as hourly aggregates. 0 keeps them forever",
+		},
+		cli.DurationFlag{
+			Name:  "retention-sent-transfer",
+			Usage: "how long finished sent transfers are kept. 0 keeps them forever",
+		},
+		cli.DurationFlag{
+			Name:  "retention-txinfo",
+			Usage: "how long packed contract call transactions are kept. 0 keeps them forever",
+		},
+		cli.DurationFlag{
+			Name:  "retention-fee-charge",
+			Usage: "how long mediation fee records are kept, income statistics are kept as hourly aggregates. 0 keeps them forever",
+		},
+		cli.DurationFlag{
+			Name:  "retention-ack",
+			Usage: "how long acks of received messages are kept. 0 keeps them forever",
+		},
+		cli.DurationFlag{
+			Name:  "retention-transfer-memo",
+			Usage: "how long received encrypted memos are kept, the memo of a received transfer is also in its data. 0 keeps them forever",
+		},
+		cli.Int64Flag{
+			Name:  "retention-chain-event-blocks",
+			Usage: "how many recent blocks of delivered chain event records are kept. 0 keeps them forever",
+		},
+		cli.BoolFlag{
+			Name:  "retention-compact",
+			Usage: "compact the database after archiving, only sqlite can be compacted online, use photon compactdb for boltdb",
+		},
+		cli.StringFlag{
+			Name:  "trace-file",
+			Usage: "export trace spans of transfers to this file, each line is an OpenTelemetry OTLP/JSON request",
+		},
+		cli.StringFlag{
+			Name:  "trace-collector",
+			Usage: "export trace spans of transfers to an OpenTelemetry OTLP/HTTP collector, for example http://127.0.0.1:4318/v1/traces",
+		},
+		cli.StringFlag{
+			Name:  "alert-min-balance",
+			Usage: "alert when the balance of the account in wei is less than this value, it should pay the gas of closing and settling channels. 0 disables the check",
+			Value: strconv.FormatInt(params.DefaultAlertMinBalance, 10),
+		},
 		cli.StringFlag{
 			Name:  "db",
-			Usage: "use --db=gkv when need photon run with gkvdb,default db is boltdb,photon doesn't support change db type once db is created.",
+			Usage: "use --db=sqlite when need photon run with sqlite,default db is boltdb,photon doesn't support change db type once db is created.",
 		},
 		cli.StringFlag{
 			Name:  "debug-mdns-interval",
@@ -208,6 +289,7 @@ func StartMain() (*photon.API, error) {
 	}
 	app.Flags = append(app.Flags, debug.Flags...)
 	app.Action = mainCtx
+	app.Commands = []cli.Command{apiTokenCommand, dbEncryptionCommand, replayCommand, compactDBCommand, migrateDBCommand}
 	app.Name = "photon"
 	app.Version = Version
 	app.Before = func(ctx *cli.Context) error {
@@ -243,12 +325,19 @@ func mainCtx(ctx *cli.Context) (err error) {
 	}
 	// open db
 	var dao models.Dao
-	err = checkDbMeta(cfg.DataBasePath, "boltdb")
+	dbType := "boltdb"
+	if ctx.String("db") == "sqlite" {
+		dbType = "sqlite"
+	}
+	err = checkDbMeta(cfg.DataBasePath, dbType)
 	if err != nil {
 		return
 	}
-	dao, err = stormdb.OpenDb(cfg.DataBasePath)
-	//}
+	if dbType == "sqlite" {
+		dao, err = sqlitedb.OpenDbWithPassword(cfg.DataBasePath, cfg.DBPassword, cfg.EncryptDB)
+	} else {
+		dao, err = stormdb.OpenDbWithPassword(cfg.DataBasePath, cfg.DBPassword, cfg.EncryptDB)
+	}
 	if err != nil {
 		err = fmt.Errorf("open db error %s", err)
 		client.Close()
@@ -431,7 +520,7 @@ func config(ctx *cli.Context) (config *params.Config, err error) {
 	if err != nil {
 		return
 	}
-	config.PrivateKey, err = getPrivateKey(ctx)
+	config.PrivateKey, config.DBPassword, err = getPrivateKey(ctx)
 	if err != nil {
 		err = fmt.Errorf("privkey error: %s", err)
 		return
@@ -443,11 +532,7 @@ func config(ctx *cli.Context) (config *params.Config, err error) {
 	if len(registAddrStr) > 0 {
 		config.RegistryAddress = common.HexToAddress(registAddrStr)
 	}
-	dataDir := ctx.String("datadir")
-	if len(dataDir) == 0 {
-		dataDir = path.Join(utils.GetHomePath(), ".photon")
-	}
-	config.DataDir = dataDir
+	config.DataDir = getDataDir(ctx.String("datadir"))
 	if !utils.Exists(config.DataDir) {
 		err = os.MkdirAll(config.DataDir, os.ModePerm)
 		if err != nil {
@@ -455,9 +540,8 @@ func config(ctx *cli.Context) (config *params.Config, err error) {
 			return
 		}
 	}
-	userDbPath := hex.EncodeToString(config.MyAddress[:])
-	userDbPath = userDbPath[:8]
-	userDbPath = filepath.Join(config.DataDir, userDbPath)
+	databasePath := getDatabasePath(config.DataDir, config.MyAddress)
+	userDbPath := filepath.Dir(databasePath)
 	if !utils.Exists(userDbPath) {
 		err = os.MkdirAll(userDbPath, os.ModePerm)
 		if err != nil {
@@ -465,9 +549,13 @@ func config(ctx *cli.Context) (config *params.Config, err error) {
 			return
 		}
 	}
-	databasePath := filepath.Join(userDbPath, "log.db")
 	config.Debug = ctx.Bool("debug")
 	config.DataBasePath = databasePath
+	config.EncryptDB = ctx.Bool("encrypt-db")
+	if config.EncryptDB && config.DBPassword == "" {
+		err = errors.New("encrypt-db needs the keystore password")
+		return
+	}
 	if ctx.Bool("debugcrash") {
 		config.DebugCrash = true
 		conditionquit := ctx.String("conditionquit")
@@ -513,10 +601,43 @@ func config(ctx *cli.Context) (config *params.Config, err error) {
 		log.Info("fork-confirm enable...")
 		params.EnableForkConfirm = true
 	}
+	config.IdempotencyRetention = ctx.Duration("idempotency-retention")
+	config.ReconcileInterval = ctx.Duration("reconcile-interval")
+	config.ReconcileRepair = ctx.Bool("reconcile-repair")
+	config.Retention = params.RetentionConfig{
+		Interval:           ctx.Duration("retention-interval"),
+		ReceivedTransfer:   ctx.Duration("retention-received-transfer"),
+		SentTransferDetail: ctx.Duration("retention-sent-transfer"),
+		TXInfo:             ctx.Duration("retention-txinfo"),
+		FeeChargeRecord:    ctx.Duration("retention-fee-charge"),
+		Ack:                ctx.Duration("retention-ack"),
+		TransferMemo:       ctx.Duration("retention-transfer-memo"),
+		ChainEventBlocks:   ctx.Int64("retention-chain-event-blocks"),
+		Compact:            ctx.Bool("retention-compact"),
+	}
+	config.TraceFile = ctx.String("trace-file")
+	config.TraceCollector = ctx.String("trace-collector")
+	alertMinBalance, ok := new(big.Int).SetString(ctx.String("alert-min-balance"), 10)
+	if !ok || alertMinBalance.Sign() < 0 {
+		err = fmt.Errorf("invalid alert-min-balance %s", ctx.String("alert-min-balance"))
+		return
+	}
+	config.AlertMinBalance = alertMinBalance
 	if ctx.IsSet("http-username") && ctx.IsSet("http-password") {
 		config.HTTPUsername = ctx.String("http-username")
 		config.HTTPPassword = ctx.String("http-password")
 	}
+	config.APITLSCert = ctx.String("api-tls-cert")
+	config.APITLSKey = ctx.String("api-tls-key")
+	config.APIClientCA = ctx.String("api-client-ca")
+	if (config.APITLSCert == "") != (config.APITLSKey == "") {
+		err = errors.New("api-tls-cert and api-tls-key must be set together")
+		return
+	}
+	if config.APIClientCA != "" && config.APITLSCert == "" {
+		err = errors.New("api-client-ca only works with api-tls-cert and api-tls-key")
+		return
+	}
 	mi := ctx.String("debug-mdns-interval")
 	dur, err := time.ParseDuration(mi)
 	if err != nil {
@@ -536,7 +657,22 @@ func config(ctx *cli.Context) (config *params.Config, err error) {
 	return
 }
 
-func getPrivateKey(ctx *cli.Context) (privateKey *ecdsa.PrivateKey, err error) {
+func getDataDir(dataDir string) string {
+	if len(dataDir) == 0 {
+		dataDir = path.Join(utils.GetHomePath(), ".photon")
+	}
+	return dataDir
+}
+
+//getDatabasePath 每个账户使用datadir下单独的目录
+func getDatabasePath(dataDir string, address common.Address) string {
+	userDbPath := hex.EncodeToString(address[:])
+	userDbPath = userDbPath[:8]
+	return filepath.Join(dataDir, userDbPath, "log.db")
+}
+
+//getPrivateKey 同时返回keystore的密码,meshbox从插件获取私钥,没有密码
+func getPrivateKey(ctx *cli.Context) (privateKey *ecdsa.PrivateKey, password string, err error) {
 	if os.Getenv("IS_MESH_BOX") == "true" || os.Getenv("IS_MESH_BOX") == "TRUE" {
 		// load photon_plugin.so
 		var plug *plugin.Plugin
@@ -558,15 +694,17 @@ func getPrivateKey(ctx *cli.Context) (privateKey *ecdsa.PrivateKey, err error) {
 			err = fmt.Errorf("privateKeyGetter fail err %s", err)
 			return
 		}
-		return crypto.ToECDSA(privateKeyBytes)
+		privateKey, err = crypto.ToECDSA(privateKeyBytes)
+		return
 	}
 	var keyBin []byte
 	address := common.HexToAddress(ctx.String("address"))
-	address, keyBin, err = accounts.PromptAccount(address, ctx.String("keystore-path"), ctx.String("password-file"))
+	address, keyBin, password, err = accounts.PromptAccountWithPassword(address, ctx.String("keystore-path"), ctx.String("password-file"))
 	if err != nil {
 		return
 	}
-	return crypto.ToECDSA(keyBin)
+	privateKey, err = crypto.ToECDSA(keyBin)
+	return
 }
 
 func getRegistryAddress(config *params.Config, dao models.Dao, client *helper.SafeEthClient) (registryAddress common.Address, isFirstStartUp, hasConnectedChain bool, err error) {
@@ -0,0 +1,74 @@
+package mainimpl
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/models/sqlitedb"
+	"github.com/SmartMeshFoundation/Photon/models/stormdb"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/urfave/cli.v1"
+)
+
+/*
+migrateDBCommand 离线升级数据库,启动的时候也会自动升级.
+--dry-run执行所有的升级步骤然后回滚,只报告会修改多少记录
+*/
+var migrateDBCommand = cli.Command{
+	Name:  "migratedb",
+	Usage: "migrate the database to the schema version of this photon,photon must be stopped",
+	Flags: append([]cli.Flag{
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "run all migration steps and roll back, only report what would change",
+		},
+	}, apiTokenDBFlags...),
+	Action: migrateDB,
+}
+
+func migrateDB(ctx *cli.Context) (err error) {
+	address, err := utils.HexToAddress(ctx.String("address"))
+	if err != nil {
+		return
+	}
+	dbPath := getDatabasePath(getDataDir(ctx.String("datadir")), address)
+	if !common.FileExist(dbPath) {
+		return fmt.Errorf("database %s not found", dbPath)
+	}
+	var password string
+	if ctx.IsSet("password-file") {
+		password, err = readDBPassword(ctx.String("password-file"), "")
+		if err != nil {
+			return
+		}
+	}
+	var report *models.MigrationReport
+	if getDBType(dbPath) == "sqlite" {
+		var dao *sqlitedb.SQLiteDB
+		dao, err = sqlitedb.OpenDbForMigration(dbPath, password)
+		if err != nil {
+			return
+		}
+		defer dao.CloseDB()
+		report, err = dao.Migrate(ctx.Bool("dry-run"))
+	} else {
+		var dao *stormdb.StormDB
+		dao, err = stormdb.OpenDbForMigration(dbPath, password)
+		if err != nil {
+			return fmt.Errorf("open db error %s,photon must be stopped", err)
+		}
+		defer dao.CloseDB()
+		report, err = dao.Migrate(ctx.Bool("dry-run"))
+	}
+	if err != nil {
+		return
+	}
+	buf, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return
+	}
+	fmt.Println(string(buf))
+	return
+}
@@ -0,0 +1,81 @@
+package mainimpl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	photon "github.com/SmartMeshFoundation/Photon"
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/urfave/cli.v1"
+)
+
+/*
+replayCommand 离线重放交易状态机的状态变化,用来重现交易卡住的问题.
+记录来自调试接口/api/1/debug/state-managers/:key/record,或者停止的photon数据库中的快照和write-ahead log
+*/
+var replayCommand = cli.Command{
+	Name:  "replay",
+	Usage: "replay recorded state changes of a transfer state machine offline,print every step as json",
+	Flags: append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "record",
+			Usage: "file of the record exported by /api/1/debug/state-managers/:key/record",
+		},
+		cli.StringFlag{
+			Name:  "key",
+			Usage: "key of the state manager,replay its snapshot and write-ahead log in the database,photon must be stopped",
+		},
+	}, apiTokenDBFlags...),
+	Action: replayStateManager,
+}
+
+/*
+readStateManagerRecord 文件可以是接口的完整返回,也可以只是其中的data
+*/
+func readStateManagerRecord(file string) (r *photon.StateManagerRecord, err error) {
+	//#nosec
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	var resp struct {
+		Data *photon.StateManagerRecord `json:"data"`
+	}
+	err = json.Unmarshal(data, &resp)
+	if err == nil && resp.Data != nil {
+		return resp.Data, nil
+	}
+	r = &photon.StateManagerRecord{}
+	err = json.Unmarshal(data, r)
+	return
+}
+
+func replayStateManager(ctx *cli.Context) (err error) {
+	var r *photon.StateManagerRecord
+	switch {
+	case ctx.IsSet("record"):
+		r, err = readStateManagerRecord(ctx.String("record"))
+	case ctx.IsSet("key"):
+		dao, err2 := openAPITokenDB(ctx)
+		if err2 != nil {
+			return err2
+		}
+		defer dao.CloseDB()
+		r, err = photon.NewStateManagerRecordFromDB(dao, common.HexToHash(ctx.String("key")))
+	default:
+		err = fmt.Errorf("--record or --key is needed")
+	}
+	if err != nil {
+		return
+	}
+	steps, err := photon.ReplayStateManagerRecord(r)
+	for _, step := range steps {
+		data, err2 := json.MarshalIndent(step, "", "  ")
+		if err2 != nil {
+			return err2
+		}
+		fmt.Println(string(data))
+	}
+	return
+}
@@ -12,6 +12,7 @@ import (
 
 	accountModule "github.com/SmartMeshFoundation/Photon/accounts"
 	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/models/sqlitedb"
 	"github.com/SmartMeshFoundation/Photon/models/stormdb"
 	"github.com/SmartMeshFoundation/Photon/network/helper"
 	"github.com/SmartMeshFoundation/Photon/network/rpc/contracts"
@@ -124,23 +125,23 @@ func NewTestDB(dbPath string) (dao models.Dao) {
 		dbPath = path.Join(os.TempDir(), "testxxxx.db")
 		err := os.RemoveAll(dbPath)
 		err = os.RemoveAll(dbPath + ".lock")
+		//sqlite的wal文件
+		err = os.RemoveAll(dbPath + "-wal")
+		err = os.RemoveAll(dbPath + "-shm")
 		if err != nil {
 			fmt.Println(err)
 		}
 	}
 	var err error
-	//if os.Getenv("PHOTON_DB") == "gkv" {
-	//	fmt.Println("use gkv db")
-	//	dao, err = gkvdb.OpenDb(dbPath)
-	//	if err != nil {
-	//		panic(err)
-	//	}
-	//} else {
-	fmt.Println("use storm db")
-	dao, err = stormdb.OpenDb(dbPath)
+	if os.Getenv("PHOTON_DB") == "sqlite" {
+		fmt.Println("use sqlite db")
+		dao, err = sqlitedb.OpenDb(dbPath)
+	} else {
+		fmt.Println("use storm db")
+		dao, err = stormdb.OpenDb(dbPath)
+	}
 	if err != nil {
 		panic(err)
 	}
-	//}
 	return
 }
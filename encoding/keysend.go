@@ -0,0 +1,90 @@
+package encoding
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+)
+
+/*
+keysend交易中,发起方自己生成密码,用target的公钥加密以后放在MediatedTransfer中,
+target收到以后解密,不需要SecretRequest就可以直接完成交易.
+加密内容为 secret(32字节)+data
+*/
+
+var errInvalidKeysendPayload = errors.New("invalid keysend payload")
+
+// eciesOverhead ecies加密增加的长度,公钥,iv和mac
+const eciesOverhead = 65 + 16 + 32
+
+//EncryptSecret 用target的公钥加密secret和交易附加信息
+func EncryptSecret(pubkey []byte, secret common.Hash, data string) ([]byte, error) {
+	pub, err := UnmarshalPublicKey(pubkey)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(secret)+len(data))
+	copy(plain, secret[:])
+	copy(plain[len(secret):], data)
+	return ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(pub), plain, nil, nil)
+}
+
+//DecryptSecret target用自己的私钥解密keysend交易的secret和附加信息
+func DecryptSecret(privKey *ecdsa.PrivateKey, encryptedSecret []byte) (secret common.Hash, data string, err error) {
+	plain, err := ecies.ImportECDSA(privKey).Decrypt(rand.Reader, encryptedSecret, nil, nil)
+	if err != nil {
+		return
+	}
+	if len(plain) < len(secret) {
+		err = errInvalidKeysendPayload
+		return
+	}
+	secret = common.BytesToHash(plain[:len(secret)])
+	data = string(plain[len(secret):])
+	return
+}
+
+//UnmarshalPublicKey 校验并解析未压缩格式的公钥
+func UnmarshalPublicKey(pubkey []byte) (*ecdsa.PublicKey, error) {
+	pub := crypto.ToECDSAPub(pubkey)
+	if pub == nil || pub.X == nil {
+		return nil, fmt.Errorf("invalid public key %s", utils.BPex(pubkey))
+	}
+	return pub, nil
+}
+
+/*
+RecoverSenderPublicKey 从收到的消息签名中恢复发送方的公钥,
+公钥对应的地址必须是消息的发送方,这样才能用来给对方发送keysend交易
+*/
+func RecoverSenderPublicKey(msg SignedMessager, data []byte) (pubkey []byte, err error) {
+	if len(data) < signatureLength {
+		err = errPacketLength
+		return
+	}
+	var hash common.Hash
+	if em, ok := msg.(EnvelopMessager); ok {
+		datahash := utils.Sha3(data[:len(data)-signatureLength])
+		hash = utils.Sha3(em.GetEnvelopMessage().signData(datahash))
+	} else {
+		hash = utils.Sha3(data[:len(data)-signatureLength])
+	}
+	signature := make([]byte, signatureLength)
+	copy(signature, data[len(data)-signatureLength:])
+	signature[len(signature)-1] -= 27
+	pubkey, err = crypto.Ecrecover(hash[:], signature)
+	if err != nil {
+		return
+	}
+	if utils.PubkeyToAddress(pubkey) != msg.GetSender() {
+		pubkey = nil
+		err = fmt.Errorf("public key does not match sender %s", utils.APex2(msg.GetSender()))
+	}
+	return
+}
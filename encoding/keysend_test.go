@@ -0,0 +1,88 @@
+package encoding
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/transfer/mtree"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptSecret(t *testing.T) {
+	privkey := GetTestPrivKey()
+	pubkey := crypto.FromECDSAPub(&privkey.PublicKey)
+	secret := utils.NewRandomHash()
+	ct, err := EncryptSecret(pubkey, secret, "for coffee")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	secret2, data, err := DecryptSecret(privkey, ct)
+	assert.Nil(t, err)
+	assert.Equal(t, secret, secret2)
+	assert.Equal(t, "for coffee", data)
+
+	otherKey, _ := crypto.GenerateKey()
+	_, _, err = DecryptSecret(otherKey, ct)
+	assert.NotNil(t, err)
+
+	_, err = EncryptSecret([]byte{1, 2, 3}, secret, "")
+	assert.NotNil(t, err)
+}
+
+func TestMediatedTransferKeysend(t *testing.T) {
+	bp := &BalanceProof{
+		Nonce:             11,
+		ChannelIdentifier: utils.Sha3([]byte("123")),
+		TransferAmount:    big.NewInt(12),
+		OpenBlockNumber:   3,
+		Locksroot:         utils.EmptyHash,
+	}
+	lock := &mtree.Lock{
+		Amount:         big.NewInt(34),
+		Expiration:     4589895,
+		LockSecretHash: utils.ShaSecret([]byte("hashlock")),
+	}
+	m1 := NewMediatedTransfer(bp, lock, utils.NewRandomAddress(), utils.NewRandomAddress(), big.NewInt(33), []common.Address{utils.NewRandomAddress()})
+	m1.SetEncryptedSecret([]byte("encrypted secret"))
+	assert.Equal(t, MediatedTransferKeysendVersion, m1.Version)
+	err := m1.Sign(GetTestPrivKey(), m1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	data := m1.Pack()
+	m2 := new(MediatedTransfer)
+	err = m2.UnPack(data)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, m1, m2)
+
+	pubkey, err := RecoverSenderPublicKey(m2, data)
+	assert.Nil(t, err)
+	assert.Equal(t, crypto.FromECDSAPub(&GetTestPrivKey().PublicKey), pubkey)
+}
+
+func TestRecoverSenderPublicKey(t *testing.T) {
+	p := NewPing(32)
+	err := p.Sign(GetTestPrivKey(), p)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	data := p.Pack()
+	p2 := new(Ping)
+	err = p2.UnPack(data)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	pubkey, err := RecoverSenderPublicKey(p2, data)
+	assert.Nil(t, err)
+	assert.Equal(t, p2.Sender, utils.PubkeyToAddress(pubkey))
+}
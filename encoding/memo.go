@@ -0,0 +1,154 @@
+package encoding
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+)
+
+/*
+Memo 交易附言,发起方用target的公钥加密以后直接发送给target,
+中间节点和传输层的中继都看不到附言的内容.
+加密以后的附言可能比较长,按照params.MemoChunkSize拆分成多个Memo消息,target收齐以后再解密.
+*/
+type Memo struct {
+	SignedMessage
+	MemoID common.Hash // 普通交易是LockSecretHash,直接交易是DirectTransferMemoID
+	Index  uint16
+	Total  uint16
+	Chunk  []byte
+}
+
+// MaxMemoChunks 一个附言最多拆分成多少个Memo消息
+func MaxMemoChunks() int {
+	return (params.MaxMemoLen+eciesOverhead)/params.MemoChunkSize + 1
+}
+
+var errInvalidMemo = errors.New("invalid memo")
+
+//NewMemo create Memo
+func NewMemo(memoID common.Hash, index, total int, chunk []byte) *Memo {
+	m := &Memo{
+		MemoID: memoID,
+		Index:  uint16(index),
+		Total:  uint16(total),
+		Chunk:  chunk,
+	}
+	m.CmdID = MemoCmdID
+	return m
+}
+
+//Pack is MessagePacker
+func (m *Memo) Pack() []byte {
+	var err error
+	buf := new(bytes.Buffer)
+	err = m.WriteCmdStructToBuf(buf)
+	_, err = buf.Write(m.MemoID[:])
+	err = binary.Write(buf, binary.BigEndian, m.Index)
+	err = binary.Write(buf, binary.BigEndian, m.Total)
+	err = utils.WriteVarInt(buf, uint64(len(m.Chunk)))
+	_, err = buf.Write(m.Chunk)
+	_, err = buf.Write(m.Signature)
+	if err != nil {
+		log.Crit(fmt.Sprintf("Memo Pack err %s", err))
+	}
+	return buf.Bytes()
+}
+
+//UnPack is MessageUnPacker
+func (m *Memo) UnPack(data []byte) error {
+	var err error
+	buf := bytes.NewBuffer(data)
+	err = m.ReadCmdStructFromBuf(buf)
+	if err != nil {
+		return err
+	}
+	if MemoCmdID != m.CmdID {
+		return fmt.Errorf("Memo Unpack cmdid should be %d,but get %d", MemoCmdID, m.CmdID)
+	}
+	_, err = buf.Read(m.MemoID[:])
+	err = binary.Read(buf, binary.BigEndian, &m.Index)
+	err = binary.Read(buf, binary.BigEndian, &m.Total)
+	if err != nil {
+		return err
+	}
+	chunkLen, err := utils.ReadVarInt(buf)
+	if err != nil {
+		return err
+	}
+	if chunkLen > params.MemoChunkSize || int(chunkLen)+signatureLength != buf.Len() {
+		return errPacketLength
+	}
+	m.Chunk = make([]byte, chunkLen)
+	_, err = buf.Read(m.Chunk)
+	m.Signature = make([]byte, signatureLength)
+	_, err = buf.Read(m.Signature)
+	if err != nil {
+		return err
+	}
+	return m.verifySignature(data)
+}
+
+//String fmt.Stringer
+func (m *Memo) String() string {
+	return fmt.Sprintf("Message{type=Memo memoID=%s,index=%d,total=%d,chunk=%d,sender=%s,has signature=%v}",
+		utils.HPex(m.MemoID), m.Index, m.Total, len(m.Chunk), utils.APex2(m.Sender), len(m.Signature) != 0)
+}
+
+//DirectTransferMemoID 直接交易没有LockSecretHash,双方都可以根据通道和nonce计算出附言的ID
+func DirectTransferMemoID(channelIdentifier common.Hash, openBlockNumber int64, nonce uint64) common.Hash {
+	buf := new(bytes.Buffer)
+	buf.Write(channelIdentifier[:])
+	binary.Write(buf, binary.BigEndian, openBlockNumber)
+	binary.Write(buf, binary.BigEndian, nonce)
+	return utils.Sha3(buf.Bytes())
+}
+
+//NewMemos 用target的公钥加密附言,并拆分成多个Memo消息,消息需要发送方签名
+func NewMemos(pubkey []byte, memoID common.Hash, memo string) (memos []*Memo, err error) {
+	if len(memo) == 0 || len(memo) > params.MaxMemoLen {
+		return nil, fmt.Errorf("memo length must be 1 to %d", params.MaxMemoLen)
+	}
+	pub, err := UnmarshalPublicKey(pubkey)
+	if err != nil {
+		return
+	}
+	ct, err := ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(pub), []byte(memo), nil, nil)
+	if err != nil {
+		return
+	}
+	total := (len(ct) + params.MemoChunkSize - 1) / params.MemoChunkSize
+	for i := 0; i < total; i++ {
+		end := (i + 1) * params.MemoChunkSize
+		if end > len(ct) {
+			end = len(ct)
+		}
+		memos = append(memos, NewMemo(memoID, i, total, ct[i*params.MemoChunkSize:end]))
+	}
+	return
+}
+
+//DecryptMemo 收齐所有的Memo消息以后,用自己的私钥解密附言
+func DecryptMemo(privKey *ecdsa.PrivateKey, chunks [][]byte) (memo string, err error) {
+	var ct []byte
+	for _, c := range chunks {
+		if len(c) == 0 {
+			return "", errInvalidMemo
+		}
+		ct = append(ct, c...)
+	}
+	plain, err := ecies.ImportECDSA(privKey).Decrypt(rand.Reader, ct, nil, nil)
+	if err != nil {
+		return
+	}
+	return string(plain), nil
+}
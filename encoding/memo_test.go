@@ -0,0 +1,52 @@
+package encoding
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemo(t *testing.T) {
+	senderKey, _ := crypto.GenerateKey()
+	targetKey, _ := crypto.GenerateKey()
+	memo := strings.Repeat("memo", params.MaxMemoLen/4)
+	memos, err := NewMemos(crypto.FromECDSAPub(&targetKey.PublicKey), utils.NewRandomHash(), memo)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.True(t, len(memos) > 1)
+	assert.True(t, len(memos) <= MaxMemoChunks())
+	var chunks [][]byte
+	for _, m := range memos {
+		err = m.Sign(senderKey, m)
+		assert.Nil(t, err)
+		data := m.Pack()
+		assert.True(t, len(data) <= params.UDPMaxMessageSize)
+		m2 := new(Memo)
+		err = m2.UnPack(data)
+		assert.Nil(t, err)
+		assert.Equal(t, m.MemoID, m2.MemoID)
+		assert.Equal(t, m.Index, m2.Index)
+		assert.Equal(t, m.Total, m2.Total)
+		assert.Equal(t, crypto.PubkeyToAddress(senderKey.PublicKey), m2.Sender)
+		chunks = append(chunks, m2.Chunk)
+	}
+	memo2, err := DecryptMemo(targetKey, chunks)
+	assert.Nil(t, err)
+	assert.Equal(t, memo, memo2)
+	//其他节点无法解密
+	_, err = DecryptMemo(senderKey, chunks)
+	assert.NotNil(t, err)
+	//缺少一部分
+	chunks[1] = nil
+	_, err = DecryptMemo(targetKey, chunks)
+	assert.NotNil(t, err)
+
+	_, err = NewMemos(crypto.FromECDSAPub(&targetKey.PublicKey), utils.NewRandomHash(), memo+"a")
+	assert.NotNil(t, err)
+}
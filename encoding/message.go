@@ -31,6 +31,21 @@ var MessageVersionControlMap = map[int16]int16{
 	MediatedTransferCmdID: int16(1), // 2019-03 MediatedTransfer消息升级,带上了Path,不兼容verison<1的版本
 }
 
+// MediatedTransferKeysendVersion 带有EncryptedSecret的MediatedTransfer版本号,普通交易仍然使用最低版本,保持兼容
+const MediatedTransferKeysendVersion = int16(2)
+
+// MediatedTransferOnionVersion 带有Onion路由信息的MediatedTransfer版本号,此时Initiator,Target和Path都不再明文传输
+const MediatedTransferOnionVersion = int16(3)
+
+// PingKeysendVersion 支持keysend交易的节点发送的Ping版本号,只有对方声明过支持才会发送MediatedTransferKeysendVersion的消息
+const PingKeysendVersion = int16(1)
+
+// PingOnionVersion Ping消息的版本号,用来告诉对方本节点可以处理Onion交易,老版本节点不检查Ping的版本号
+const PingOnionVersion = int16(1)
+
+// PingEncryptedMemoVersion 支持接收Memo消息的节点发送的Ping版本号,同时也支持onion交易
+const PingEncryptedMemoVersion = int16(2)
+
 //MessageType is the type of message for receive and send
 type MessageType int
 
@@ -79,6 +94,21 @@ const (
 	*/
 	// Respond Refund
 	AnnounceDisposedTransferResponseCmdID
+	/*
+		用target公钥加密的交易附言
+	*/
+	// encrypted memo of a transfer
+	MemoCmdID
+	/*
+		数据库丢失以后请求对方恢复通道数据
+	*/
+	// ask partner for the latest balance proofs after losing the database
+	RecoveryRequestCmdID
+	/*
+		恢复通道数据的响应
+	*/
+	// Respond Recovery Request
+	RecoveryResponseCmdID
 )
 
 const signatureLength = 65
@@ -214,6 +244,12 @@ func (t MessageType) String() string {
 		return "WithdrawRequest"
 	case WithdrawResponseCmdID:
 		return "WithdrawResponse"
+	case MemoCmdID:
+		return "Memo"
+	case RecoveryRequestCmdID:
+		return "RecoveryRequest"
+	case RecoveryResponseCmdID:
+		return "RecoveryResponse"
 	default:
 		return "<unknown>"
 	}
@@ -357,6 +393,7 @@ func NewPing(nonce int64) *Ping {
 		Nonce: nonce,
 	}
 	p.CmdID = PingCmdID
+	p.Version = PingEncryptedMemoVersion
 	return p
 }
 
@@ -954,13 +991,39 @@ type MediatedTransfer struct {
 	Initiator      common.Address
 	Fee            *big.Int
 	Path           []common.Address // 2019-03 消息升级后,带全路径信息
+	/*
+		keysend交易,发起方用target的公钥加密的密码和附加信息,中间节点原样转发,
+		只有Version>=MediatedTransferKeysendVersion才会打包
+	*/
+	EncryptedSecret []byte
+	/*
+		onion路由信息,每一跳只能解密出自己的下一跳和手续费,
+		只有Version>=MediatedTransferOnionVersion才会打包
+	*/
+	Onion []byte
 }
 
 //String is fmt.Stringer
 func (m *MediatedTransfer) String() string {
-	return fmt.Sprintf("Message{type=MediatedTransfer expiration=%d,target=%s,initiator=%s,hashlock=%s,amount=%s,fee=%s,path=%s,%s}",
+	return fmt.Sprintf("Message{type=MediatedTransfer expiration=%d,target=%s,initiator=%s,hashlock=%s,amount=%s,fee=%s,path=%s,keysend=%v,onion=%v,%s}",
 		m.Expiration, utils.APex2(m.Target), utils.APex2(m.Initiator),
-		utils.HPex(m.LockSecretHash), m.PaymentAmount, m.Fee, m.GetPathStr(), m.EnvelopMessage.String())
+		utils.HPex(m.LockSecretHash), m.PaymentAmount, m.Fee, m.GetPathStr(), len(m.EncryptedSecret) > 0, len(m.Onion) > 0, m.EnvelopMessage.String())
+}
+
+//SetEncryptedSecret 设置keysend加密的密码,必须在签名之前调用
+func (m *MediatedTransfer) SetEncryptedSecret(encryptedSecret []byte) {
+	m.EncryptedSecret = encryptedSecret
+	if len(encryptedSecret) > 0 && m.Version < MediatedTransferKeysendVersion {
+		m.Version = MediatedTransferKeysendVersion
+	}
+}
+
+//SetOnion 设置onion路由信息,必须在签名之前调用
+func (m *MediatedTransfer) SetOnion(onion []byte) {
+	m.Onion = onion
+	if len(onion) > 0 {
+		m.Version = MediatedTransferOnionVersion
+	}
 }
 
 //NewMediatedTransfer create MediatedTransfer
@@ -1010,6 +1073,14 @@ func (m *MediatedTransfer) Pack() []byte {
 	for _, addr := range m.Path {
 		_, err = buf.Write(addr[:])
 	}
+	if m.Version >= MediatedTransferKeysendVersion {
+		err = binary.Write(buf, binary.BigEndian, int32(len(m.EncryptedSecret)))
+		_, err = buf.Write(m.EncryptedSecret)
+	}
+	if m.Version >= MediatedTransferOnionVersion {
+		err = binary.Write(buf, binary.BigEndian, int32(len(m.Onion)))
+		_, err = buf.Write(m.Onion)
+	}
 	m.EnvelopMessage.pack(buf)
 	if err != nil {
 		log.Crit(fmt.Sprintf("MediatedTransfer Pack err %s", err))
@@ -1046,6 +1117,30 @@ func (m *MediatedTransfer) UnPack(data []byte) error {
 		_, err = buf.Read(addr[:])
 		m.Path = append(m.Path, addr)
 	}
+	if m.Version >= MediatedTransferKeysendVersion {
+		var secretLen int32
+		err = binary.Read(buf, binary.BigEndian, &secretLen)
+		if err != nil {
+			return err
+		}
+		if secretLen < 0 || int(secretLen) > buf.Len() {
+			return fmt.Errorf("MediatedTransfer unpack error, invalid encrypted secret length %d", secretLen)
+		}
+		m.EncryptedSecret = make([]byte, secretLen)
+		_, err = buf.Read(m.EncryptedSecret)
+	}
+	if m.Version >= MediatedTransferOnionVersion {
+		var onionLen int32
+		err = binary.Read(buf, binary.BigEndian, &onionLen)
+		if err != nil {
+			return err
+		}
+		if onionLen < 0 || int(onionLen) > buf.Len() {
+			return fmt.Errorf("MediatedTransfer unpack error, invalid onion length %d", onionLen)
+		}
+		m.Onion = make([]byte, onionLen)
+		_, err = buf.Read(m.Onion)
+	}
 	err = m.EnvelopMessage.unpack(buf)
 	if err != nil {
 		return err
@@ -1975,6 +2070,9 @@ var MessageMap = map[int]Messager{
 	WithdrawResponseCmdID:                 new(WithdrawResponse),
 	SettleRequestCmdID:                    new(SettleRequest),
 	SettleResponseCmdID:                   new(SettleResponse),
+	MemoCmdID:                             new(Memo),
+	RecoveryRequestCmdID:                  new(RecoveryRequest),
+	RecoveryResponseCmdID:                 new(RecoveryResponse),
 }
 
 func init() {
@@ -1993,4 +2091,7 @@ func init() {
 	gob.Register(&WithdrawResponse{})
 	gob.Register(&SettleRequest{})
 	gob.Register(&SettleResponse{})
+	gob.Register(&Memo{})
+	gob.Register(&RecoveryRequest{})
+	gob.Register(&RecoveryResponse{})
 }
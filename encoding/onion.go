@@ -0,0 +1,121 @@
+package encoding
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+)
+
+/*
+onion路由,发起方从target开始一层一层的用每一跳的公钥加密,
+每一层的内容为 下一跳地址(20字节)+手续费长度(1字节)+手续费+内层onion,
+下一跳为空地址表示自己就是target.
+中间节点只能解密出自己这一层,然后把内层onion原样转发给下一跳,
+所以除了发起方,没有节点知道完整的路径,initiator和target.
+最内层会填充随机长度的数据,中间节点不能根据onion的长度判断自己离target还有几跳.
+*/
+
+// onionNoFee 表示发起方没有指定手续费,由中间节点按照自己的收费策略收取
+const onionNoFee = 0xff
+
+// onionLayerSize 每一层增加的长度,下一跳地址+手续费长度+ecies加密增加的长度
+const onionLayerSize = 20 + 1 + eciesOverhead
+
+var errInvalidOnion = errors.New("invalid onion")
+
+//OnionHop 从onion中解密出来的本节点的路由信息
+type OnionHop struct {
+	NextHop common.Address // 空地址表示本节点就是target
+	Fee     *big.Int       // 本节点可以收取的手续费,nil表示按照自己的收费策略收取
+	Inner   []byte         // 需要转发给下一跳的onion
+}
+
+//IsTarget returns true if i'm the target of this transfer
+func (h *OnionHop) IsTarget() bool {
+	return h.NextHop == utils.EmptyAddress
+}
+
+/*
+NewOnion 为路径hops创建onion,hops不包含发起方,最后一个是target.
+pubkeys是每一跳的公钥,fees是每一个中间节点可以收取的手续费,为nil表示由中间节点自己决定
+*/
+func NewOnion(hops []common.Address, pubkeys [][]byte, fees []*big.Int) (onion []byte, err error) {
+	if len(hops) == 0 || len(hops) > params.MaxOnionHops {
+		return nil, fmt.Errorf("onion hops must be 1 to %d", params.MaxOnionHops)
+	}
+	if len(pubkeys) != len(hops) || len(fees) != len(hops) {
+		return nil, errors.New("onion hops,pubkeys and fees length mismatch")
+	}
+	padLayers, err := rand.Int(rand.Reader, big.NewInt(int64(params.MaxOnionHops-len(hops)+1)))
+	if err != nil {
+		return
+	}
+	onion = make([]byte, int(padLayers.Int64())*onionLayerSize)
+	_, err = rand.Read(onion)
+	if err != nil {
+		return
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		var pub *ecdsa.PublicKey
+		pub, err = UnmarshalPublicKey(pubkeys[i])
+		if err != nil {
+			return
+		}
+		if utils.PubkeyToAddress(pubkeys[i]) != hops[i] {
+			return nil, fmt.Errorf("public key does not belong to %s", hops[i].String())
+		}
+		next := utils.EmptyAddress
+		if i < len(hops)-1 {
+			next = hops[i+1]
+		}
+		plain := append([]byte{}, next[:]...)
+		fee := fees[i]
+		if fee == nil || i == len(hops)-1 {
+			plain = append(plain, onionNoFee)
+		} else {
+			if fee.Sign() < 0 || fee.BitLen() > 256 {
+				return nil, fmt.Errorf("invalid onion fee %s", fee)
+			}
+			plain = append(plain, byte(len(fee.Bytes())))
+			plain = append(plain, fee.Bytes()...)
+		}
+		plain = append(plain, onion...)
+		onion, err = ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(pub), plain, nil, nil)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+//PeelOnion 用自己的私钥解密onion最外面的一层
+func PeelOnion(privKey *ecdsa.PrivateKey, onion []byte) (hop *OnionHop, err error) {
+	plain, err := ecies.ImportECDSA(privKey).Decrypt(rand.Reader, onion, nil, nil)
+	if err != nil {
+		return
+	}
+	if len(plain) < len(common.Address{})+1 {
+		return nil, errInvalidOnion
+	}
+	hop = &OnionHop{}
+	copy(hop.NextHop[:], plain)
+	plain = plain[len(hop.NextHop):]
+	feeLen := int(plain[0])
+	plain = plain[1:]
+	if feeLen != onionNoFee {
+		if feeLen > 32 || len(plain) < feeLen {
+			return nil, errInvalidOnion
+		}
+		hop.Fee = new(big.Int).SetBytes(plain[:feeLen])
+		plain = plain[feeLen:]
+	}
+	hop.Inner = plain
+	return
+}
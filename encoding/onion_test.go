@@ -0,0 +1,108 @@
+package encoding
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/transfer/mtree"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newOnionHops(n int) (keys []*ecdsa.PrivateKey, hops []common.Address, pubkeys [][]byte) {
+	for i := 0; i < n; i++ {
+		key, _ := crypto.GenerateKey()
+		keys = append(keys, key)
+		hops = append(hops, crypto.PubkeyToAddress(key.PublicKey))
+		pubkeys = append(pubkeys, crypto.FromECDSAPub(&key.PublicKey))
+	}
+	return
+}
+
+func TestOnion(t *testing.T) {
+	keys, hops, pubkeys := newOnionHops(3)
+	fees := []*big.Int{big.NewInt(0), nil, big.NewInt(7)}
+	onion, err := NewOnion(hops, pubkeys, fees)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	//中间节点
+	hop, err := PeelOnion(keys[0], onion)
+	assert.Nil(t, err)
+	assert.Equal(t, hops[1], hop.NextHop)
+	assert.Equal(t, int64(0), hop.Fee.Int64())
+	assert.False(t, hop.IsTarget())
+	_, err = PeelOnion(keys[1], onion)
+	assert.NotNil(t, err)
+
+	hop, err = PeelOnion(keys[1], hop.Inner)
+	assert.Nil(t, err)
+	assert.Equal(t, hops[2], hop.NextHop)
+	assert.Nil(t, hop.Fee)
+
+	//target的手续费被忽略
+	hop, err = PeelOnion(keys[2], hop.Inner)
+	assert.Nil(t, err)
+	assert.True(t, hop.IsTarget())
+	assert.Nil(t, hop.Fee)
+
+	_, err = NewOnion(hops, pubkeys[:2], fees)
+	assert.NotNil(t, err)
+	_, err = NewOnion(hops, [][]byte{pubkeys[1], pubkeys[0], pubkeys[2]}, fees)
+	assert.NotNil(t, err)
+	_, _, longHops := newOnionHops(params.MaxOnionHops + 1)
+	_, err = NewOnion(make([]common.Address, len(longHops)), longHops, make([]*big.Int, len(longHops)))
+	assert.NotNil(t, err)
+}
+
+func TestMediatedTransferOnion(t *testing.T) {
+	_, hops, pubkeys := newOnionHops(params.MaxOnionHops)
+	onion, err := NewOnion(hops, pubkeys, make([]*big.Int, len(hops)))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	encryptedSecret, err := EncryptSecret(pubkeys[len(pubkeys)-1], utils.NewRandomHash(), strings.Repeat("a", params.MaxTransferDataLen))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	bp := &BalanceProof{
+		Nonce:             11,
+		ChannelIdentifier: utils.Sha3([]byte("123")),
+		TransferAmount:    big.NewInt(12),
+		OpenBlockNumber:   3,
+		Locksroot:         utils.EmptyHash,
+	}
+	lock := &mtree.Lock{
+		Amount:         big.NewInt(34),
+		Expiration:     4589895,
+		LockSecretHash: utils.ShaSecret([]byte("hashlock")),
+	}
+	m1 := NewMediatedTransfer(bp, lock, utils.EmptyAddress, utils.EmptyAddress, big.NewInt(33), nil)
+	m1.SetOnion(onion)
+	m1.SetEncryptedSecret(encryptedSecret)
+	assert.Equal(t, MediatedTransferOnionVersion, m1.Version)
+	err = m1.Sign(GetTestPrivKey(), m1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	data := m1.Pack()
+	assert.True(t, len(data) <= params.UDPMaxMessageSize, "onion mediated transfer too large %d", len(data))
+	m2 := new(MediatedTransfer)
+	err = m2.UnPack(data)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Equal(t, m1.Onion, m2.Onion)
+	assert.Equal(t, m1.EncryptedSecret, m2.EncryptedSecret)
+	assert.Equal(t, m1.Sender, m2.Sender)
+}
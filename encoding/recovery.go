@@ -0,0 +1,352 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/transfer/mtree"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// 恢复消息中错误信息的最大长度
+const maxRecoveryErrorMsgLen = 128
+
+/*
+RecoveryRequest 数据库丢失以后,请求通道的对方发送双方签名过的最新balance proof和锁
+*/
+type RecoveryRequest struct {
+	SignedMessage
+	ChannelIdentifier common.Hash
+	OpenBlockNumber   int64
+}
+
+//NewRecoveryRequest create RecoveryRequest
+func NewRecoveryRequest(channelIdentifier common.Hash, openBlockNumber int64) *RecoveryRequest {
+	m := &RecoveryRequest{
+		ChannelIdentifier: channelIdentifier,
+		OpenBlockNumber:   openBlockNumber,
+	}
+	m.CmdID = RecoveryRequestCmdID
+	return m
+}
+
+//Pack is MessagePacker
+func (m *RecoveryRequest) Pack() []byte {
+	var err error
+	buf := new(bytes.Buffer)
+	err = m.WriteCmdStructToBuf(buf)
+	_, err = buf.Write(m.ChannelIdentifier[:])
+	err = binary.Write(buf, binary.BigEndian, m.OpenBlockNumber)
+	_, err = buf.Write(m.Signature)
+	if err != nil {
+		log.Crit(fmt.Sprintf("RecoveryRequest Pack err %s", err))
+	}
+	return buf.Bytes()
+}
+
+//UnPack is MessageUnPacker
+func (m *RecoveryRequest) UnPack(data []byte) error {
+	var err error
+	buf := bytes.NewBuffer(data)
+	err = m.ReadCmdStructFromBuf(buf)
+	if err != nil {
+		return err
+	}
+	if RecoveryRequestCmdID != m.CmdID {
+		return fmt.Errorf("RecoveryRequest Unpack cmdid should be %d,but get %d", RecoveryRequestCmdID, m.CmdID)
+	}
+	_, err = buf.Read(m.ChannelIdentifier[:])
+	err = binary.Read(buf, binary.BigEndian, &m.OpenBlockNumber)
+	if err != nil {
+		return err
+	}
+	if buf.Len() != signatureLength {
+		return errPacketLength
+	}
+	m.Signature = make([]byte, signatureLength)
+	_, err = buf.Read(m.Signature)
+	if err != nil {
+		return err
+	}
+	return m.verifySignature(data)
+}
+
+//String fmt.Stringer
+func (m *RecoveryRequest) String() string {
+	return fmt.Sprintf("Message{type=RecoveryRequest channel=%s,openBlockNumber=%d,sender=%s,has signature=%v}",
+		utils.HPex(m.ChannelIdentifier), m.OpenBlockNumber, utils.APex2(m.Sender), len(m.Signature) != 0)
+}
+
+/*
+RecoveryBalanceProof 一方签名的balance proof,和提交到合约的数据一样,
+Nonce为0表示这一方还没有发送过balance proof
+*/
+type RecoveryBalanceProof struct {
+	Nonce          uint64
+	TransferAmount *big.Int
+	Locksroot      common.Hash
+	MessageHash    common.Hash
+	Signature      []byte
+}
+
+func (p *RecoveryBalanceProof) pack(buf *bytes.Buffer) {
+	var err error
+	err = binary.Write(buf, binary.BigEndian, p.Nonce)
+	_, err = buf.Write(utils.BigIntTo32Bytes(p.TransferAmount))
+	_, err = buf.Write(p.Locksroot[:])
+	_, err = buf.Write(p.MessageHash[:])
+	signature := make([]byte, signatureLength)
+	copy(signature, p.Signature)
+	_, err = buf.Write(signature)
+	if err != nil {
+		log.Error(fmt.Sprintf("RecoveryBalanceProof pack err %s", err))
+	}
+}
+
+func (p *RecoveryBalanceProof) unpack(buf *bytes.Buffer) error {
+	err := binary.Read(buf, binary.BigEndian, &p.Nonce)
+	if err != nil {
+		return err
+	}
+	if buf.Len() < 32*3+signatureLength {
+		return errPacketLength
+	}
+	p.TransferAmount = utils.ReadBigInt(buf)
+	_, err = buf.Read(p.Locksroot[:])
+	_, err = buf.Read(p.MessageHash[:])
+	p.Signature = make([]byte, signatureLength)
+	_, err = buf.Read(p.Signature)
+	if p.Nonce == 0 {
+		p.Signature = nil
+	}
+	return err
+}
+
+//Equal 分页的消息中balance proof必须相同
+func (p *RecoveryBalanceProof) Equal(p2 *RecoveryBalanceProof) bool {
+	return p.Nonce == p2.Nonce && p.TransferAmount.Cmp(p2.TransferAmount) == 0 &&
+		p.Locksroot == p2.Locksroot && p.MessageHash == p2.MessageHash && bytes.Equal(p.Signature, p2.Signature)
+}
+
+/*
+Signer 恢复balance proof的签名者,签名的数据和EnvelopMessage相同
+*/
+func (p *RecoveryBalanceProof) Signer(channelIdentifier common.Hash, openBlockNumber int64) (signer common.Address, err error) {
+	if len(p.Signature) != signatureLength {
+		return utils.EmptyAddress, errors.New("balance proof has no signature")
+	}
+	m := &EnvelopMessage{
+		BalanceProof: BalanceProof{
+			Nonce:             p.Nonce,
+			ChannelIdentifier: channelIdentifier,
+			OpenBlockNumber:   openBlockNumber,
+			TransferAmount:    p.TransferAmount,
+			Locksroot:         p.Locksroot,
+		},
+	}
+	hash := utils.Sha3(m.signData(p.MessageHash))
+	signature := make([]byte, signatureLength)
+	copy(signature, p.Signature)
+	signature[len(signature)-1] -= 27
+	pubkey, err := crypto.Ecrecover(hash[:], signature)
+	if err != nil {
+		return
+	}
+	signer = utils.PubkeyToAddress(pubkey)
+	return
+}
+
+/*
+RecoveryResponse 回复RecoveryRequest,
+RequesterProof是请求方签名的balance proof,ResponderProof是回复方签名的.
+锁比较多的时候按照params.RecoveryLocksPerMessage分成多个消息,每个消息都带着完整的balance proof
+*/
+type RecoveryResponse struct {
+	SignedMessage
+	ChannelIdentifier common.Hash
+	OpenBlockNumber   int64
+	Index             uint16
+	Total             uint16
+	ErrorCode         int32
+	ErrorMsg          string
+	RequesterProof    *RecoveryBalanceProof
+	ResponderProof    *RecoveryBalanceProof
+	RequesterLocks    []*mtree.Lock
+	ResponderLocks    []*mtree.Lock
+}
+
+func newRecoveryResponse(channelIdentifier common.Hash, openBlockNumber int64) *RecoveryResponse {
+	m := &RecoveryResponse{
+		ChannelIdentifier: channelIdentifier,
+		OpenBlockNumber:   openBlockNumber,
+		Total:             1,
+		RequesterProof:    &RecoveryBalanceProof{TransferAmount: new(big.Int)},
+		ResponderProof:    &RecoveryBalanceProof{TransferAmount: new(big.Int)},
+	}
+	m.CmdID = RecoveryResponseCmdID
+	return m
+}
+
+//NewErrorRecoveryResponse 不能回复请求的原因
+func NewErrorRecoveryResponse(req *RecoveryRequest, errorCode int, errorMsg string) *RecoveryResponse {
+	m := newRecoveryResponse(req.ChannelIdentifier, req.OpenBlockNumber)
+	m.ErrorCode = int32(errorCode)
+	if len(errorMsg) > maxRecoveryErrorMsgLen {
+		errorMsg = errorMsg[:maxRecoveryErrorMsgLen]
+	}
+	m.ErrorMsg = errorMsg
+	return m
+}
+
+/*
+NewRecoveryResponses 按照锁的数量分成多个消息,需要回复方分别签名
+*/
+func NewRecoveryResponses(channelIdentifier common.Hash, openBlockNumber int64, requesterProof, responderProof *RecoveryBalanceProof,
+	requesterLocks, responderLocks []*mtree.Lock) (ms []*RecoveryResponse) {
+	total := (len(requesterLocks) + len(responderLocks) + params.RecoveryLocksPerMessage - 1) / params.RecoveryLocksPerMessage
+	if total == 0 {
+		total = 1
+	}
+	for i := 0; i < total; i++ {
+		m := newRecoveryResponse(channelIdentifier, openBlockNumber)
+		m.Index = uint16(i)
+		m.Total = uint16(total)
+		m.RequesterProof = requesterProof
+		m.ResponderProof = responderProof
+		ms = append(ms, m)
+	}
+	for i, l := range requesterLocks {
+		m := ms[i/params.RecoveryLocksPerMessage]
+		m.RequesterLocks = append(m.RequesterLocks, l)
+	}
+	for i, l := range responderLocks {
+		m := ms[(len(requesterLocks)+i)/params.RecoveryLocksPerMessage]
+		m.ResponderLocks = append(m.ResponderLocks, l)
+	}
+	return
+}
+
+func packRecoveryLocks(buf *bytes.Buffer, locks []*mtree.Lock) (err error) {
+	err = utils.WriteVarInt(buf, uint64(len(locks)))
+	for _, l := range locks {
+		_, err = buf.Write(l.AsBytes())
+	}
+	return
+}
+
+func unpackRecoveryLocks(buf *bytes.Buffer) (locks []*mtree.Lock, err error) {
+	n, err := utils.ReadVarInt(buf)
+	if err != nil {
+		return
+	}
+	if n > uint64(params.RecoveryLocksPerMessage) {
+		return nil, errPacketLength
+	}
+	for i := uint64(0); i < n; i++ {
+		l := new(mtree.Lock)
+		err = l.FromReader(buf)
+		if err != nil {
+			return
+		}
+		locks = append(locks, l)
+	}
+	return
+}
+
+//Pack is MessagePacker
+func (m *RecoveryResponse) Pack() []byte {
+	var err error
+	buf := new(bytes.Buffer)
+	err = m.WriteCmdStructToBuf(buf)
+	_, err = buf.Write(m.ChannelIdentifier[:])
+	err = binary.Write(buf, binary.BigEndian, m.OpenBlockNumber)
+	err = binary.Write(buf, binary.BigEndian, m.Index)
+	err = binary.Write(buf, binary.BigEndian, m.Total)
+	err = binary.Write(buf, binary.BigEndian, m.ErrorCode)
+	err = utils.WriteVarInt(buf, uint64(len(m.ErrorMsg)))
+	_, err = buf.WriteString(m.ErrorMsg)
+	m.RequesterProof.pack(buf)
+	m.ResponderProof.pack(buf)
+	err = packRecoveryLocks(buf, m.RequesterLocks)
+	err = packRecoveryLocks(buf, m.ResponderLocks)
+	_, err = buf.Write(m.Signature)
+	if err != nil {
+		log.Crit(fmt.Sprintf("RecoveryResponse Pack err %s", err))
+	}
+	return buf.Bytes()
+}
+
+//UnPack is MessageUnPacker
+func (m *RecoveryResponse) UnPack(data []byte) error {
+	var err error
+	buf := bytes.NewBuffer(data)
+	err = m.ReadCmdStructFromBuf(buf)
+	if err != nil {
+		return err
+	}
+	if RecoveryResponseCmdID != m.CmdID {
+		return fmt.Errorf("RecoveryResponse Unpack cmdid should be %d,but get %d", RecoveryResponseCmdID, m.CmdID)
+	}
+	_, err = buf.Read(m.ChannelIdentifier[:])
+	err = binary.Read(buf, binary.BigEndian, &m.OpenBlockNumber)
+	err = binary.Read(buf, binary.BigEndian, &m.Index)
+	err = binary.Read(buf, binary.BigEndian, &m.Total)
+	err = binary.Read(buf, binary.BigEndian, &m.ErrorCode)
+	if err != nil {
+		return err
+	}
+	if m.Index >= m.Total {
+		return fmt.Errorf("RecoveryResponse index %d out of total %d", m.Index, m.Total)
+	}
+	msgLen, err := utils.ReadVarInt(buf)
+	if err != nil {
+		return err
+	}
+	if msgLen > maxRecoveryErrorMsgLen || int(msgLen) > buf.Len() {
+		return errPacketLength
+	}
+	msg := make([]byte, msgLen)
+	_, err = buf.Read(msg)
+	m.ErrorMsg = string(msg)
+	m.RequesterProof = new(RecoveryBalanceProof)
+	err = m.RequesterProof.unpack(buf)
+	if err != nil {
+		return err
+	}
+	m.ResponderProof = new(RecoveryBalanceProof)
+	err = m.ResponderProof.unpack(buf)
+	if err != nil {
+		return err
+	}
+	m.RequesterLocks, err = unpackRecoveryLocks(buf)
+	if err != nil {
+		return err
+	}
+	m.ResponderLocks, err = unpackRecoveryLocks(buf)
+	if err != nil {
+		return err
+	}
+	if buf.Len() != signatureLength {
+		return errPacketLength
+	}
+	m.Signature = make([]byte, signatureLength)
+	_, err = buf.Read(m.Signature)
+	if err != nil {
+		return err
+	}
+	return m.verifySignature(data)
+}
+
+//String fmt.Stringer
+func (m *RecoveryResponse) String() string {
+	return fmt.Sprintf("Message{type=RecoveryResponse channel=%s,openBlockNumber=%d,index=%d,total=%d,errorCode=%d,errorMsg=%s,requesterNonce=%d,responderNonce=%d,locks=%d,sender=%s,has signature=%v}",
+		utils.HPex(m.ChannelIdentifier), m.OpenBlockNumber, m.Index, m.Total, m.ErrorCode, m.ErrorMsg,
+		m.RequesterProof.Nonce, m.ResponderProof.Nonce, len(m.RequesterLocks)+len(m.ResponderLocks), utils.APex2(m.Sender), len(m.Signature) != 0)
+}
@@ -0,0 +1,83 @@
+package encoding
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/network/rpc/contracts"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/transfer/mtree"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryResponse(t *testing.T) {
+	requesterKey, _ := crypto.GenerateKey()
+	responderKey, _ := crypto.GenerateKey()
+	channelID := &contracts.ChannelUniqueID{
+		ChannelIdentifier: utils.NewRandomHash(),
+		OpenBlockNumber:   3,
+	}
+	var locks []*mtree.Lock
+	for i := 0; i < params.RecoveryLocksPerMessage+2; i++ {
+		locks = append(locks, &mtree.Lock{
+			Expiration:     int64(100 + i),
+			Amount:         big.NewInt(int64(i + 1)),
+			LockSecretHash: utils.NewRandomHash(),
+		})
+	}
+	tree := mtree.NewMerkleTree(locks)
+	dt := NewDirectTransfer(NewBalanceProof(5, big.NewInt(20), tree.MerkleRoot(), channelID))
+	err := dt.Sign(requesterKey, dt)
+	assert.Nil(t, err)
+	requesterProof := &RecoveryBalanceProof{
+		Nonce:          dt.Nonce,
+		TransferAmount: dt.TransferAmount,
+		Locksroot:      dt.Locksroot,
+		MessageHash:    HashMessageWithoutSignature(dt),
+		Signature:      dt.Signature,
+	}
+	responderProof := &RecoveryBalanceProof{TransferAmount: new(big.Int)}
+	ms := NewRecoveryResponses(channelID.ChannelIdentifier, channelID.OpenBlockNumber, requesterProof, responderProof, locks, nil)
+	assert.Len(t, ms, 2)
+	var locks2 []*mtree.Lock
+	for _, m := range ms {
+		err = m.Sign(responderKey, m)
+		assert.Nil(t, err)
+		data := m.Pack()
+		assert.True(t, len(data) <= params.UDPMaxMessageSize)
+		m2 := new(RecoveryResponse)
+		err = m2.UnPack(data)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, crypto.PubkeyToAddress(responderKey.PublicKey), m2.Sender)
+		assert.Equal(t, m.Total, m2.Total)
+		assert.True(t, m2.RequesterProof.Equal(requesterProof))
+		assert.True(t, m2.ResponderProof.Equal(responderProof))
+		signer, err := m2.RequesterProof.Signer(m2.ChannelIdentifier, m2.OpenBlockNumber)
+		assert.Nil(t, err)
+		assert.Equal(t, crypto.PubkeyToAddress(requesterKey.PublicKey), signer)
+		_, err = m2.ResponderProof.Signer(m2.ChannelIdentifier, m2.OpenBlockNumber)
+		assert.NotNil(t, err)
+		locks2 = append(locks2, m2.RequesterLocks...)
+	}
+	assert.Equal(t, tree.MerkleRoot(), mtree.NewMerkleTree(locks2).MerkleRoot())
+
+	//另一个通道的签名无效
+	signer, err := requesterProof.Signer(utils.NewRandomHash(), channelID.OpenBlockNumber)
+	assert.NotEqual(t, crypto.PubkeyToAddress(requesterKey.PublicKey), signer)
+
+	req := NewRecoveryRequest(channelID.ChannelIdentifier, channelID.OpenBlockNumber)
+	err = req.Sign(requesterKey, req)
+	assert.Nil(t, err)
+	m := NewErrorRecoveryResponse(req, 5001, "channel is recovering")
+	err = m.Sign(responderKey, m)
+	assert.Nil(t, err)
+	m2 := new(RecoveryResponse)
+	err = m2.UnPack(m.Pack())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 5001, m2.ErrorCode)
+	assert.Equal(t, "channel is recovering", m2.ErrorMsg)
+}
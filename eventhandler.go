@@ -2,6 +2,7 @@ package photon
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/SmartMeshFoundation/Photon/params"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/SmartMeshFoundation/Photon/channel"
 	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
 	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/internal/tracing"
 	"github.com/SmartMeshFoundation/Photon/log"
 	"github.com/SmartMeshFoundation/Photon/models"
 	"github.com/SmartMeshFoundation/Photon/network/graph"
@@ -61,12 +63,34 @@ func (eh *stateMachineEventHandler) dispatchByPendingLocksInChannel(channel *cha
 }
 
 func (eh *stateMachineEventHandler) dispatch(stateManager *transfer.StateManager, stateChange transfer.StateChange) (events []transfer.Event) {
+	//每个块都会分发给所有的状态机,只有产生了事件的才记录到交易的root span中
+	_, isBlock := stateChange.(*transfer.BlockStateChange)
+	var span *tracing.Span
+	if !isBlock {
+		span = tracing.StartSpan(stateManager.Identifier, "dispatch "+strings.TrimPrefix(typeName(stateChange), "*"), tracing.KindInternal, "state_manager", stateManager.Name)
+	}
+	defer span.End()
 	eh.updateStateManagerFromStateChange(stateManager, stateChange)
-	events = stateManager.Dispatch(stateChange)
+	events = eh.photon.dispatchWithLog(stateManager, stateChange)
+	logCtx := utils.TransferLogCtx(stateManager.Identifier, utils.EmptyHash)
+	var eventNames []string
+	for _, e := range events {
+		eventNames = append(eventNames, fmt.Sprintf("%T", e))
+	}
+	log.Trace(fmt.Sprintf("state manager %s dispatch %T,events=%s", stateManager.Name, stateChange, eventNames), logCtx...)
+	if isBlock && len(events) > 0 {
+		tracing.TransferSpan(stateManager.Identifier).AddEvent("block", "block_number", stateChange.(*transfer.BlockStateChange).BlockNumber, "events", eventNames)
+	}
+	span.SetAttributes("events", eventNames)
 	for _, e := range events {
+		if e2, ok := e.(*mediatedtransfer.EventSendMediatedTransfer); ok {
+			//发起方和中间节点每次选择新的路由都会发送MediatedTransfer
+			span.AddEvent("try route", "receiver", e2.Receiver, "amount", e2.Amount, "fee", e2.Fee, "expiration", e2.Expiration)
+		}
 		err := eh.OnEvent(e, stateManager)
 		if err != nil {
-			log.Error(fmt.Sprintf("stateMachineEventHandler dispatch:%v\n", err))
+			span.SetError(err)
+			log.Error(fmt.Sprintf("stateMachineEventHandler dispatch:%v\n", err), logCtx...)
 		}
 	}
 	return
@@ -103,6 +127,9 @@ func (eh *stateMachineEventHandler) eventSendRevealSecret(event *mediatedtransfe
 		todo 暂时采用这种方式,后续token swap maker应该自行处理通知相关通道密码而不是放在这里.
 	*/
 	eh.photon.registerSecret(event.Secret)
+	if stateManager.Name == initiator.NameInitiatorTransition {
+		eh.photon.savePaymentReceipt(event, stateManager)
+	}
 
 	revealMessage := encoding.NewRevealSecret(event.Secret)
 	// 带上交易附加信息
@@ -148,10 +175,17 @@ func (eh *stateMachineEventHandler) eventSendMediatedTransfer(event *mediatedtra
 	}
 	//log.Trace(fmt.Sprintf("eventSendMediatedTransfer g=%s", utils.StringInterface(g, 3)))
 	//log.Trace(fmt.Sprintf("eventSendMediatedTransfer ch=%s", utils.StringInterface(ch, 2)))
-	mtr, err := ch.CreateMediatedTransfer(event.Initiator, event.Target, event.Fee, event.Amount, event.Expiration, event.LockSecretHash, event.Path)
+	initiatorAddr, targetAddr, path := event.Initiator, event.Target, event.Path
+	if len(event.Onion) > 0 {
+		//onion交易,下一跳只能从onion中解密出它自己的路由信息
+		initiatorAddr, targetAddr, path = utils.EmptyAddress, utils.EmptyAddress, nil
+	}
+	mtr, err := ch.CreateMediatedTransfer(initiatorAddr, targetAddr, event.Fee, event.Amount, event.Expiration, event.LockSecretHash, path)
 	if err != nil {
 		return
 	}
+	mtr.SetEncryptedSecret(event.EncryptedSecret)
+	mtr.SetOnion(event.Onion)
 	//log.Trace(fmt.Sprintf("mtr=%s", utils.StringInterface(mtr, 5)))
 	err = mtr.Sign(eh.photon.PrivateKey, mtr)
 	err = ch.RegisterTransfer(eh.photon.GetBlockNumber(), mtr)
@@ -368,16 +402,16 @@ func (eh *stateMachineEventHandler) eventUnlockFailed(e2 *mediatedtransfer.Event
 	}
 	ch, err := eh.photon.findChannelByIdentifier(e2.ChannelIdentifier)
 	if err != nil {
-		log.Error(fmt.Sprintf("payee's lock expired ,but cannot find channel %s, eh may happen long later restart after a stop", e2.ChannelIdentifier))
+		log.Error(fmt.Sprintf("payee's lock expired ,but cannot find channel %s, eh may happen long later restart after a stop", e2.ChannelIdentifier), utils.TransferLogCtx(e2.LockSecretHash, e2.ChannelIdentifier)...)
 		return
 	}
-	log.Info(fmt.Sprintf("remove expired hashlock channel=%s,hashlock=%s ", utils.HPex(e2.ChannelIdentifier), utils.HPex(e2.LockSecretHash)))
+	log.Info(fmt.Sprintf("remove expired hashlock channel=%s,hashlock=%s ", utils.HPex(e2.ChannelIdentifier), utils.HPex(e2.LockSecretHash)), utils.TransferLogCtx(e2.LockSecretHash, e2.ChannelIdentifier)...)
 	/*
 		unlock 失败,谨慎起见, 只有在对方不知道密码的情况下,才可能成功移除锁.
 	*/
 	tr, err := ch.CreateRemoveExpiredHashLockTransfer(e2.LockSecretHash, eh.photon.GetBlockNumber())
 	if err != nil {
-		log.Warn(fmt.Sprintf("Get Event UnlockFailed ,but hashlock cannot be removed err:%s", err))
+		log.Warn(fmt.Sprintf("Get Event UnlockFailed ,but hashlock cannot be removed err:%s", err), utils.TransferLogCtx(e2.LockSecretHash, e2.ChannelIdentifier)...)
 		return
 	}
 	err = tr.Sign(eh.photon.PrivateKey, tr)
@@ -464,11 +498,17 @@ func (eh *stateMachineEventHandler) OnEvent(event transfer.Event, stateManager *
 		if err != nil {
 			log.Error(fmt.Sprintf("UpdateChannelNoTx err %s", err))
 		}
-		rt := eh.photon.dao.NewReceivedTransfer(eh.photon.GetBlockNumber(), e2.ChannelIdentifier, ch.ChannelIdentifier.OpenBlockNumber, ch.TokenAddress, e2.Initiator, ch.PartnerState.BalanceProofState.Nonce, e2.Amount, e2.LockSecretHash, e2.Data)
+		memoID := e2.LockSecretHash
+		if memoID == utils.EmptyHash {
+			memoID = encoding.DirectTransferMemoID(e2.ChannelIdentifier, ch.ChannelIdentifier.OpenBlockNumber, ch.PartnerState.BalanceProofState.Nonce)
+		}
+		data, linkMemo := eh.photon.receivedTransferData(memoID, e2.Initiator, e2.Data)
+		rt := eh.photon.dao.NewReceivedTransfer(eh.photon.GetBlockNumber(), e2.ChannelIdentifier, ch.ChannelIdentifier.OpenBlockNumber, ch.TokenAddress, e2.Initiator, ch.PartnerState.BalanceProofState.Nonce, e2.Amount, e2.LockSecretHash, data)
+		linkMemo(rt)
 		eh.photon.NotifyHandler.NotifyReceiveTransfer(rt)
 	case *mediatedtransfer.EventUnlockSuccess:
 	case *mediatedtransfer.EventWithdrawFailed:
-		log.Error(fmt.Sprintf("EventWithdrawFailed hashlock=%s,reason=%s", utils.HPex(e2.LockSecretHash), e2.Reason))
+		log.Error(fmt.Sprintf("EventWithdrawFailed hashlock=%s,reason=%s", utils.HPex(e2.LockSecretHash), e2.Reason), utils.TransferLogCtx(e2.LockSecretHash, e2.ChannelIdentifier)...)
 		err = eh.eventWithdrawFailed(e2, stateManager)
 	case *mediatedtransfer.EventWithdrawSuccess:
 		/*
@@ -479,13 +519,15 @@ func (eh *stateMachineEventHandler) OnEvent(event transfer.Event, stateManager *
 		//do nothing for five events above
 		err = eh.eventContractSendUnlock(e2, stateManager)
 	case *mediatedtransfer.EventUnlockFailed:
-		log.Error(fmt.Sprintf("unlockfailed hashlock=%s,reason=%s", utils.HPex(e2.LockSecretHash), e2.Reason))
+		log.Error(fmt.Sprintf("unlockfailed hashlock=%s,reason=%s", utils.HPex(e2.LockSecretHash), e2.Reason), utils.TransferLogCtx(e2.LockSecretHash, e2.ChannelIdentifier)...)
 		err = eh.eventUnlockFailed(e2, stateManager)
 		eh.photon.conditionQuit("EventSendRemoveExpiredHashlockTransferAfter")
 	case *mediatedtransfer.EventContractSendRegisterSecret:
 		err = eh.eventContractSendRegisterSecret(e2)
 	case *mediatedtransfer.EventRemoveStateManager:
 		delete(eh.photon.Transfer2StateManager, e2.Key)
+		eh.photon.removeStateManagerLog(e2.Key)
+		tracing.EndTransfer(stateManager.Identifier, nil)
 	case *mediatedtransfer.EventSaveFeeChargeRecord:
 		err = eh.eventSaveFeeChargeRecord(e2)
 	default:
@@ -502,12 +544,15 @@ func (eh *stateMachineEventHandler) finishOneTransfer(ev transfer.Event) {
 	var tokenAddress common.Address
 	switch e2 := ev.(type) {
 	case *transfer.EventTransferSentSuccess:
-		log.Info(fmt.Sprintf("EventTransferSentSuccess for LockSecretHash %s ", e2.LockSecretHash.String()))
+		log.Info(fmt.Sprintf("EventTransferSentSuccess for LockSecretHash %s ", e2.LockSecretHash.String()), utils.TransferLogCtx(e2.LockSecretHash, utils.EmptyHash)...)
+		tracing.TransferSpan(e2.LockSecretHash).AddEvent("transfer sent success")
 		lockSecretHash = e2.LockSecretHash
 		tokenAddress = e2.Token
 		err = nil
 	case *transfer.EventTransferSentFailed:
-		log.Warn(fmt.Sprintf("EventTransferSentFailed for LockSecretHash %s,because of %s", e2.LockSecretHash.String(), e2.Reason))
+		log.Warn(fmt.Sprintf("EventTransferSentFailed for LockSecretHash %s,because of %s", e2.LockSecretHash.String(), e2.Reason), utils.TransferLogCtx(e2.LockSecretHash, utils.EmptyHash)...)
+		tracing.TransferSpan(e2.LockSecretHash).AddEvent("transfer sent failed", "reason", e2.Reason)
+		tracing.TransferSpan(e2.LockSecretHash).SetError(errors.New(e2.Reason))
 		lockSecretHash = e2.LockSecretHash
 		err = errors.New(e2.Reason)
 		tokenAddress = e2.Token
@@ -640,6 +685,7 @@ func (eh *stateMachineEventHandler) handleClosed(st *mediatedtransfer.ContractCl
 	if err != nil {
 		log.Error(fmt.Sprintf("handleBalance ChannelStateTransition err=%s", err))
 	}
+	ch.ExternState.ClosingAddress = st.ClosingAddress
 	err = eh.photon.UpdateChannelState(channel.NewChannelSerialization(ch))
 	return err
 }
@@ -27,6 +27,7 @@ require (
 	github.com/jackpal/go-nat-pmp v1.0.1 // indirect
 	github.com/karalabe/hid v0.0.0-20180420081245-2b4488a37358 // indirect
 	github.com/mattn/go-colorable v0.0.9
+	github.com/mattn/go-sqlite3 v1.14.0
 	github.com/mattn/go-xmpp v0.0.0-20180505113305-e543ad3fcd51
 	github.com/miekg/dns v1.0.13 // indirect
 	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
@@ -0,0 +1,178 @@
+package photon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/network/netshare"
+	"github.com/SmartMeshFoundation/Photon/params"
+)
+
+//健康检查的结果
+const (
+	HealthStatusOK      = "ok"
+	HealthStatusFail    = "fail"
+	HealthStatusSkipped = "skipped" // 没有配置,比如没有指定pfs
+)
+
+//健康检查的项目
+const (
+	HealthCheckStartup   = "startup"
+	HealthCheckChain     = "chain"
+	HealthCheckTransport = "transport"
+	HealthCheckDB        = "db"
+	HealthCheckPFS       = "pfs"
+)
+
+//HealthCheck 一项检查的结果
+type HealthCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+//HealthReport 所有检查项都不是fail的时候Status是ok
+type HealthReport struct {
+	Status string         `json:"status"`
+	Time   int64          `json:"time"`
+	Checks []*HealthCheck `json:"checks"`
+}
+
+type healthChecker func(rs *Service) *HealthCheck
+
+/*
+checkHealth 同时进行所有检查,查询公链和pfs可能需要几秒
+*/
+func (rs *Service) checkHealth(checkers []healthChecker) *HealthReport {
+	report := &HealthReport{
+		Status: HealthStatusOK,
+		Time:   time.Now().Unix(),
+		Checks: make([]*HealthCheck, len(checkers)),
+	}
+	wg := sync.WaitGroup{}
+	for i, checker := range checkers {
+		wg.Add(1)
+		go func(i int, checker healthChecker) {
+			defer wg.Done()
+			report.Checks[i] = checker(rs)
+		}(i, checker)
+	}
+	wg.Wait()
+	for _, c := range report.Checks {
+		if c.Status == HealthStatusFail {
+			report.Status = HealthStatusFail
+		}
+	}
+	return report
+}
+
+/*
+healthReportCache 健康检查的接口不需要认证,每次都检查的话,任何人都可以通过频繁调用写数据库,查询公链和pfs.
+缓存最近一次的结果params.HealthReportCacheTime,同时到达的请求只检查一次
+*/
+type healthReportCache struct {
+	lock   sync.Mutex
+	report *HealthReport
+	time   time.Time
+}
+
+func (c *healthReportCache) get(rs *Service, checkers []healthChecker) *HealthReport {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.report == nil || time.Since(c.time) >= params.HealthReportCacheTime {
+		c.report = rs.checkHealth(checkers)
+		c.time = time.Now()
+	}
+	return c.report
+}
+
+func newHealthCheck(name string, err error) *HealthCheck {
+	c := &HealthCheck{
+		Name:   name,
+		Status: HealthStatusOK,
+	}
+	if err != nil {
+		c.Status = HealthStatusFail
+		c.Message = err.Error()
+	}
+	return c
+}
+
+/*
+checkStartup Start完成以后才会启动api,
+但是启动时没有连上公链的话,积压的链上事件是在连上以后才处理的
+*/
+func checkStartup(rs *Service) *HealthCheck {
+	var err error
+	if atomic.LoadInt32(&rs.startupComplete) == 0 {
+		err = errors.New("starting")
+	} else if atomic.LoadInt32(&rs.historyEventsComplete) == 0 {
+		err = errors.New("processing history events of the chain")
+	}
+	return newHealthCheck(HealthCheckStartup, err)
+}
+
+func checkChain(rs *Service) *HealthCheck {
+	if !rs.Chain.Client.IsConnected() {
+		return newHealthCheck(HealthCheckChain, fmt.Errorf("%s is %s", rs.Config.EthRPCEndPoint, rs.Chain.Client.Status))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), params.HealthCheckTimeout)
+	defer cancel()
+	h, err := rs.Chain.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return newHealthCheck(HealthCheckChain, err)
+	}
+	blockNumber := rs.GetBlockNumber()
+	lag := h.Number.Int64() - blockNumber
+	if lag > params.HealthMaxBlockLag {
+		err = fmt.Errorf("processed block %d is %d blocks behind %d", blockNumber, lag, h.Number.Int64())
+	}
+	c := newHealthCheck(HealthCheckChain, err)
+	if err == nil {
+		c.Message = fmt.Sprintf("block %d, lag %d", blockNumber, lag)
+	}
+	return c
+}
+
+func checkTransport(rs *Service) *HealthCheck {
+	status := rs.Transport.Status()
+	var err error
+	if status != netshare.Connected {
+		err = errors.New(status.String())
+	}
+	return newHealthCheck(HealthCheckTransport, err)
+}
+
+func checkDB(rs *Service) *HealthCheck {
+	return newHealthCheck(HealthCheckDB, rs.dao.CheckWritable())
+}
+
+func checkPFS(rs *Service) *HealthCheck {
+	if rs.PfsProxy == nil {
+		return &HealthCheck{
+			Name:   HealthCheckPFS,
+			Status: HealthStatusSkipped,
+		}
+	}
+	return newHealthCheck(HealthCheckPFS, rs.PfsProxy.Ping(params.HealthCheckTimeout))
+}
+
+/*
+GetLiveness 进程是否还可以工作,只检查数据库是否可写,
+公链和网络断开会自动重连,重启节点没有帮助
+*/
+func (r *API) GetLiveness() *HealthReport {
+	return r.Photon.livenessCache.get(r.Photon, []healthChecker{checkDB})
+}
+
+/*
+GetReadiness 节点是否可以处理交易,启动完毕,公链,网络和数据库都正常,
+指定了pfs的时候pfs也必须可以访问
+*/
+func (r *API) GetReadiness() *HealthReport {
+	return r.Photon.readinessCache.get(r.Photon, []healthChecker{checkStartup, checkChain, checkTransport, checkDB, checkPFS})
+}
@@ -0,0 +1,55 @@
+package photon
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHealth(t *testing.T) {
+	db, err := newTestStormDb()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.CloseDB()
+	rs := &Service{
+		dao: db,
+	}
+	checkers := []healthChecker{checkStartup, checkDB, checkPFS}
+	report := rs.checkHealth(checkers)
+	assert.Equal(t, HealthStatusFail, report.Status)
+	if assert.Len(t, report.Checks, 3) {
+		assert.Equal(t, HealthStatusFail, report.Checks[0].Status)
+		assert.Equal(t, HealthStatusOK, report.Checks[1].Status)
+		//没有指定pfs的时候不检查
+		assert.Equal(t, HealthStatusSkipped, report.Checks[2].Status)
+	}
+	rs.startupComplete = 1
+	rs.historyEventsComplete = 1
+	report = rs.checkHealth(checkers)
+	assert.Equal(t, HealthStatusOK, report.Status)
+}
+
+func TestHealthReportCache(t *testing.T) {
+	db, err := newTestStormDb()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.CloseDB()
+	rs := &Service{
+		dao: db,
+	}
+	checkers := []healthChecker{checkStartup}
+	c := &healthReportCache{}
+	report := c.get(rs, checkers)
+	assert.Equal(t, HealthStatusFail, report.Status)
+	rs.startupComplete = 1
+	rs.historyEventsComplete = 1
+	//缓存期间返回上次的结果
+	assert.Equal(t, report, c.get(rs, checkers))
+	c.time = c.time.Add(-params.HealthReportCacheTime)
+	assert.Equal(t, HealthStatusOK, c.get(rs, checkers).Status)
+}
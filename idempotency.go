@@ -0,0 +1,163 @@
+package photon
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+idempotencyKeeper 记录正在处理中的幂等key,
+避免客户端在第一次请求还没有返回的时候重试导致同一个请求被执行两次.
+*/
+type idempotencyKeeper struct {
+	lock     sync.Mutex
+	inflight map[string]bool
+}
+
+func newIdempotencyKeeper() *idempotencyKeeper {
+	return &idempotencyKeeper{
+		inflight: make(map[string]bool),
+	}
+}
+
+func (k *idempotencyKeeper) begin(key string) bool {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if k.inflight[key] {
+		return false
+	}
+	k.inflight[key] = true
+	return true
+}
+
+func (k *idempotencyKeeper) end(key string) {
+	k.lock.Lock()
+	delete(k.inflight, key)
+	k.lock.Unlock()
+}
+
+// 每种操作对应的链上tx类型,用于补全IdempotencyRecord中的TXHash
+var idempotencyOperation2TXType = map[models.IdempotencyOperation]models.TXInfoType{
+	models.IdempotencyOperationDeposit:  models.TXInfoTypeDeposit,
+	models.IdempotencyOperationWithdraw: models.TXInfoTypeWithdraw,
+	models.IdempotencyOperationClose:    models.TXInfoTypeClose,
+	models.IdempotencyOperationSettle:   models.TXInfoTypeSettle,
+}
+
+/*
+idempotencyRequestHash 请求参数的hash,params按照json编码,
+调用方需要保证相同的请求得到相同的params,比如地址都已经解析过
+*/
+func idempotencyRequestHash(params interface{}) (h common.Hash, err error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	return utils.Sha3(data), nil
+}
+
+/*
+Idempotent 使用客户端提供的幂等key执行操作op.
+如果保留期内已经有相同key,相同参数的请求成功执行过,直接返回当时保存的结果,fn不会再被执行.
+相同key的参数不同返回ErrIdempotencyKeyReused.
+fn返回的record不为空就会被保存,即使同时返回了错误,比如同步交易超时,但是交易其实已经发出了.
+fn返回的record为空说明请求没有被执行,这时候可以使用同一个key重试.
+key为空时直接执行fn.
+Idempotent runs fn at most once per key within Config.IdempotencyRetention and returns the stored result for duplicates.
+*/
+func (r *API) Idempotent(key string, op models.IdempotencyOperation, params interface{}, fn func() (*models.IdempotencyRecord, error)) (record *models.IdempotencyRecord, duplicate bool, err error) {
+	if key == "" {
+		record, err = fn()
+		return
+	}
+	requestHash, err := idempotencyRequestHash(params)
+	if err != nil {
+		err = rerr.ErrArgumentError.AppendError(err)
+		return
+	}
+	keeper := r.Photon.idempotencyKeeper
+	if !keeper.begin(key) {
+		err = rerr.ErrIdempotencyKeyInProgress.Printf("key=%s", key)
+		return
+	}
+	defer keeper.end(key)
+	dao := r.Photon.dao
+	expireTime := time.Now().Add(-r.Photon.Config.IdempotencyRetention).Unix()
+	record, err = dao.GetIdempotencyRecord(key)
+	if err == nil && record.CreateTime >= expireTime {
+		if record.Operation != op {
+			err = rerr.ErrIdempotencyKeyReused.Printf("key=%s already used by %s", key, record.Operation)
+			return
+		}
+		if record.RequestHash != requestHash {
+			err = rerr.ErrIdempotencyKeyReused.Printf("key=%s already used by %s with different parameters", key, record.Operation)
+			return
+		}
+		if r.fillIdempotencyRecordTXHash(record) {
+			r.saveIdempotencyRecord(record)
+		}
+		log.Info(fmt.Sprintf("duplicate %s request with idempotency key=%s", op, key))
+		duplicate = true
+		return
+	}
+	startTime := time.Now().Unix()
+	record, err = fn()
+	if record == nil {
+		return
+	}
+	record.Key = key
+	record.Operation = op
+	record.RequestHash = requestHash
+	record.CreateTime = startTime
+	r.fillIdempotencyRecordTXHash(record)
+	// 操作已经成功了,不能因为保存失败而让客户端认为失败
+	r.saveIdempotencyRecord(record)
+	err2 := dao.RemoveIdempotencyRecordBefore(expireTime)
+	if err2 != nil {
+		log.Error(fmt.Sprintf("RemoveIdempotencyRecordBefore err %s", err2))
+	}
+	return
+}
+
+func (r *API) saveIdempotencyRecord(record *models.IdempotencyRecord) {
+	err := r.Photon.dao.SaveIdempotencyRecord(record)
+	if err != nil {
+		log.Error(fmt.Sprintf("SaveIdempotencyRecord key=%s err %s", record.Key, err))
+	}
+}
+
+/*
+channel相关的tx都是异步发出的,第一次请求返回的时候可能还查不到,
+所以每次返回结果之前都尝试补全一下,找到了返回true
+*/
+func (r *API) fillIdempotencyRecordTXHash(record *models.IdempotencyRecord) bool {
+	txType, ok := idempotencyOperation2TXType[record.Operation]
+	if !ok || record.TXHash != utils.EmptyHash || record.ChannelIdentifier == utils.EmptyHash {
+		return false
+	}
+	list, err := r.Photon.dao.GetTXInfoList(record.ChannelIdentifier, 0, utils.EmptyAddress, txType, "")
+	if err != nil {
+		log.Error(fmt.Sprintf("GetTXInfoList err %s", err))
+		return false
+	}
+	// 请求之后发出的第一个tx
+	var first *models.TXInfo
+	for _, txInfo := range list {
+		if txInfo.IsSelfCall && txInfo.CallTime >= record.CreateTime && (first == nil || txInfo.CallTime < first.CallTime) {
+			first = txInfo
+		}
+	}
+	if first == nil {
+		return false
+	}
+	record.TXHash = first.TXHash
+	return true
+}
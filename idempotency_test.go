@@ -0,0 +1,51 @@
+package photon
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotent(t *testing.T) {
+	db, err := newTestStormDb()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.CloseDB()
+	config := params.DefaultConfig
+	api := &API{Photon: &Service{
+		dao:               db,
+		Config:            &config,
+		idempotencyKeeper: newIdempotencyKeeper(),
+	}}
+	target := utils.NewRandomAddress()
+	lockSecretHash := utils.NewRandomHash()
+	runs := 0
+	transfer := func() (*models.IdempotencyRecord, error) {
+		runs++
+		return &models.IdempotencyRecord{LockSecretHash: lockSecretHash}, nil
+	}
+	record, duplicate, err := api.Idempotent("key", models.IdempotencyOperationTransfer, []interface{}{target, big.NewInt(10)}, transfer)
+	assert.Nil(t, err)
+	assert.False(t, duplicate)
+	assert.Equal(t, lockSecretHash, record.LockSecretHash)
+	//相同的参数直接返回第一次的结果
+	record, duplicate, err = api.Idempotent("key", models.IdempotencyOperationTransfer, []interface{}{target, big.NewInt(10)}, transfer)
+	assert.Nil(t, err)
+	assert.True(t, duplicate)
+	assert.Equal(t, lockSecretHash, record.LockSecretHash)
+	assert.Equal(t, 1, runs)
+	//金额不同
+	_, _, err = api.Idempotent("key", models.IdempotencyOperationTransfer, []interface{}{target, big.NewInt(11)}, transfer)
+	assert.Equal(t, rerr.ErrIdempotencyKeyReused.ErrorCode, err.(rerr.StandardError).ErrorCode)
+	//操作不同
+	_, _, err = api.Idempotent("key", models.IdempotencyOperationWithdraw, []interface{}{target, big.NewInt(10)}, transfer)
+	assert.Equal(t, rerr.ErrIdempotencyKeyReused.ErrorCode, err.(rerr.StandardError).ErrorCode)
+	assert.Equal(t, 1, runs)
+}
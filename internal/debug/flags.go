@@ -95,13 +95,18 @@ var (
 		Name:  "logfile",
 		Usage: "redirect log to this the given file",
 	}
+	logFormatFlag = cli.StringFlag{
+		Name:  "log-format",
+		Usage: "Log format of console and logfile: terminal or json, json records of a transfer have lockSecretHash and channel fields",
+		Value: "terminal",
+	}
 )
 
 // Flags holds all command-line flags required for debugging.
 var Flags = []cli.Flag{
 	verbosityFlag, vmoduleFlag, backtraceAtFlag, debugFlag,
 	pprofFlag, pprofAddrFlag, pprofPortFlag,
-	memprofilerateFlag, blockprofilerateFlag, cpuprofileFlag, traceFlag, logFileFlag,
+	memprofilerateFlag, blockprofilerateFlag, cpuprofileFlag, traceFlag, logFileFlag, logFormatFlag,
 }
 
 var glogger *log.GlogHandler
@@ -152,22 +157,43 @@ func Setup(ctx *cli.Context) (err error) {
 		httpHandler = log.HttpHandler(path, log.TerminalFormat(false))
 
 	}
+	var jsonFormat bool
+	switch ctx.GlobalString(logFormatFlag.Name) {
+	case "", "terminal":
+	case "json":
+		jsonFormat = true
+	default:
+		return fmt.Errorf("unknown log format %s", ctx.GlobalString(logFormatFlag.Name))
+	}
 	// file handler
 	if len(ctx.String(logFileFlag.Name)) > 0 {
 		fmt.Printf("log will be write to %s\n", ctx.String(logFileFlag.Name))
-		fileHandler, err = log.FileHandler(ctx.String(logFileFlag.Name), log.TerminalFormat(false))
+		fileFormat := log.TerminalFormat(false)
+		if jsonFormat {
+			fileFormat = log.JSONFormat()
+		}
+		fileHandler, err = log.FileHandler(ctx.String(logFileFlag.Name), fileFormat)
 		if err != nil {
 			return
 		}
 	}
 	// console handler
-	usecolor := term.IsTty(os.Stderr.Fd()) && os.Getenv("TERM") != "dumb"
+	usecolor := term.IsTty(os.Stderr.Fd()) && os.Getenv("TERM") != "dumb" && !jsonFormat
 	output := io.Writer(os.Stderr)
 	if usecolor {
 		output = colorable.NewColorableStderr()
 	}
-	consoleHandler := log.StreamHandler(output, log.TerminalFormat(usecolor))
-	glogger = log.NewGlogHandler(log.TeeHandler(consoleHandler, fileHandler, httpHandler))
+	consoleFormat := log.TerminalFormat(usecolor)
+	if jsonFormat {
+		consoleFormat = log.JSONFormat()
+	}
+	consoleHandler := log.StreamHandler(output, consoleFormat)
+	teeHandler := log.TeeHandler(consoleHandler, fileHandler, httpHandler)
+	if jsonFormat {
+		//json没有terminal格式中的代码位置
+		teeHandler = log.CallerFileHandler(teeHandler)
+	}
+	glogger = log.NewGlogHandler(teeHandler)
 
 	// logging
 	log.PrintOrigins(ctx.GlobalBool(debugFlag.Name))
@@ -180,7 +206,8 @@ func Setup(ctx *cli.Context) (err error) {
 	if err != nil {
 		//todo fixit ,return error when backtraceAtFlag is empty
 	}
-	log.Root().SetHandler(glogger)
+	//交易的日志不受verbosity的限制,可以通过api按照lockSecretHash查询
+	log.Root().SetHandler(log.MultiHandler(glogger, utils.TransferLogs.Handler()))
 
 	// profiling, tracing
 	runtime.MemProfileRate = ctx.GlobalInt(memprofilerateFlag.Name)
@@ -0,0 +1,212 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	exportInterval   = 5 * time.Second
+	exportBatchSize  = 512
+	maxPendingSpans  = 4096 // 来不及导出的span直接丢弃,不能影响交易
+	collectorTimeout = 5 * time.Second
+)
+
+/*
+OTLP/JSON 格式,见 https://github.com/open-telemetry/opentelemetry-proto
+*/
+type otlpRequest struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource      `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []*otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope   `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []*otlpKeyValue `json:"attributes,omitempty"`
+	Events            []*otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string          `json:"timeUnixNano"`
+	Name         string          `json:"name"`
+	Attributes   []*otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+/*
+exporter 定时批量导出结束的span,
+写文件的时候每行是一个OTLP/JSON的请求,发送给collector的时候POST到它的/v1/traces
+*/
+type exporter struct {
+	lock      sync.Mutex
+	pending   []*otlpSpan
+	resource  otlpResource
+	file      *os.File
+	collector string
+	client    *http.Client
+	quit      chan struct{}
+	done      chan struct{}
+	stopped   bool
+}
+
+func newExporter(file, collector string, node common.Address) (e *exporter, err error) {
+	e = &exporter{
+		collector: collector,
+		client:    &http.Client{Timeout: collectorTimeout},
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	e.resource.Attributes = toKeyValues([]interface{}{
+		"service.name", "photon",
+		"service.instance.id", node.String(),
+	})
+	if file != "" {
+		e.file, err = os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return
+		}
+	}
+	go e.loop()
+	return
+}
+
+func (e *exporter) add(s *otlpSpan) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.stopped || len(e.pending) >= maxPendingSpans {
+		return
+	}
+	e.pending = append(e.pending, s)
+}
+
+func (e *exporter) loop() {
+	defer close(e.done)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.flush()
+		case <-e.quit:
+			e.flush()
+			return
+		}
+	}
+}
+
+func (e *exporter) flush() {
+	e.lock.Lock()
+	spans := e.pending
+	e.pending = nil
+	e.lock.Unlock()
+	for len(spans) > 0 {
+		n := len(spans)
+		if n > exportBatchSize {
+			n = exportBatchSize
+		}
+		err := e.write(spans[:n])
+		if err != nil {
+			log.Warn(fmt.Sprintf("export %d spans err %s", n, err))
+		}
+		spans = spans[n:]
+	}
+}
+
+func (e *exporter) write(spans []*otlpSpan) error {
+	req := &otlpRequest{
+		ResourceSpans: []*otlpResourceSpans{
+			{
+				Resource: e.resource,
+				ScopeSpans: []*otlpScopeSpans{
+					{
+						Scope: otlpScope{Name: "github.com/SmartMeshFoundation/Photon"},
+						Spans: spans,
+					},
+				},
+			},
+		},
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if e.file != nil {
+		_, err = e.file.Write(append(data, '\n'))
+		if err != nil {
+			return err
+		}
+	}
+	if e.collector != "" {
+		resp, err := e.client.Post(e.collector, "application/json", bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("collector response %s", resp.Status)
+		}
+	}
+	return nil
+}
+
+func (e *exporter) stop() {
+	e.lock.Lock()
+	if e.stopped {
+		e.lock.Unlock()
+		return
+	}
+	e.stopped = true
+	e.lock.Unlock()
+	close(e.quit)
+	<-e.done
+	if e.file != nil {
+		e.file.Close()
+	}
+}
@@ -0,0 +1,298 @@
+/*
+Package tracing 记录交易经过本节点的各个阶段的耗时,导出OpenTelemetry的OTLP/JSON格式,
+可以写到文件,也可以发送给OTLP/HTTP的collector,然后用jaeger,zipkin等工具查看.
+
+一个交易的trace id取自lockSecretHash的前16个字节,所以路径上所有节点导出的span都属于同一个trace.
+每个节点有一个交易的root span,其他span都是它的子span.
+没有启用的时候所有函数都什么也不做,Span为nil也可以调用它的方法.
+*/
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//Span的种类,和OpenTelemetry的SpanKind相同
+const (
+	KindInternal = 1
+	KindServer   = 2 // 处理收到的消息
+	KindClient   = 3 // 发送消息,等待ack
+)
+
+//span出错的状态,和OpenTelemetry的StatusCode相同
+const statusError = 2
+
+//最多同时跟踪多少个交易,超过以后新交易的span没有root span
+const maxTransfers = 10000
+
+//Span 一个阶段的耗时,结束以后就不能再修改了
+type Span struct {
+	lock   sync.Mutex
+	tracer *Tracer
+	data   *otlpSpan
+	ended  bool
+}
+
+/*
+SetAttributes 设置属性,kv是成对的key和value
+*/
+func (s *Span) SetAttributes(kv ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.ended {
+		return
+	}
+	s.data.Attributes = append(s.data.Attributes, toKeyValues(kv)...)
+}
+
+//AddEvent 记录span中发生的事件,比如重发和切换路由
+func (s *Span) AddEvent(name string, kv ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.ended {
+		return
+	}
+	s.data.Events = append(s.data.Events, &otlpEvent{
+		TimeUnixNano: unixNano(time.Now()),
+		Name:         name,
+		Attributes:   toKeyValues(kv),
+	})
+}
+
+//SetError err不为nil的时候span的状态是错误
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.ended {
+		return
+	}
+	s.data.Status = otlpStatus{Code: statusError, Message: err.Error()}
+}
+
+//End 结束以后导出,重复调用只有第一次有效
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTimeUnixNano = unixNano(time.Now())
+	s.lock.Unlock()
+	s.tracer.export(s.data)
+}
+
+func (s *Span) spanID() string {
+	if s == nil {
+		return ""
+	}
+	return s.data.SpanID
+}
+
+type transferSpan struct {
+	span *Span
+	refs int // 同一个lockSecretHash可能有多个状态机,比如token swap和环路
+}
+
+//Tracer 保存进行中交易的root span,导出结束的span
+type Tracer struct {
+	lock      sync.Mutex
+	transfers map[common.Hash]*transferSpan
+	exporter  *exporter
+}
+
+var tracer *Tracer
+
+//Enabled 是否启用了tracing
+func Enabled() bool {
+	return tracer != nil
+}
+
+/*
+Start 启用tracing,file和collector至少指定一个,node是本节点的地址,作为resource的属性
+*/
+func Start(file, collector string, node common.Address) (err error) {
+	if file == "" && collector == "" {
+		return nil
+	}
+	e, err := newExporter(file, collector, node)
+	if err != nil {
+		return
+	}
+	tracer = &Tracer{
+		transfers: make(map[common.Hash]*transferSpan),
+		exporter:  e,
+	}
+	return nil
+}
+
+//Stop 导出所有结束的span,进行中交易的span会丢失,之后结束的span直接丢弃
+func Stop() {
+	if tracer == nil {
+		return
+	}
+	tracer.exporter.stop()
+}
+
+func (t *Tracer) export(s *otlpSpan) {
+	t.exporter.add(s)
+}
+
+func (t *Tracer) newSpan(lockSecretHash common.Hash, parentSpanID, name string, kind int, kv []interface{}) *Span {
+	return &Span{
+		tracer: t,
+		data: &otlpSpan{
+			TraceID:           hex.EncodeToString(lockSecretHash[:16]),
+			SpanID:            newSpanID(),
+			ParentSpanID:      parentSpanID,
+			Name:              name,
+			Kind:              kind,
+			StartTimeUnixNano: unixNano(time.Now()),
+			Attributes:        toKeyValues(append([]interface{}{"lock_secret_hash", lockSecretHash}, kv...)),
+		},
+	}
+}
+
+/*
+StartTransfer 交易开始经过本节点,创建root span,直到EndTransfer.
+同一个lockSecretHash已经有root span的时候只是增加引用计数,记录一个事件
+*/
+func StartTransfer(lockSecretHash common.Hash, name string, kv ...interface{}) {
+	t := tracer
+	if t == nil || lockSecretHash == (common.Hash{}) {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ts := t.transfers[lockSecretHash]
+	if ts != nil {
+		ts.refs++
+		ts.span.AddEvent(name, kv...)
+		return
+	}
+	if len(t.transfers) >= maxTransfers {
+		return
+	}
+	t.transfers[lockSecretHash] = &transferSpan{
+		span: t.newSpan(lockSecretHash, "", name, KindInternal, kv),
+		refs: 1,
+	}
+}
+
+/*
+EndTransfer 本节点上交易的一个状态机结束了,所有的状态机都结束以后导出root span.
+err不为nil的时候root span的状态是错误
+*/
+func EndTransfer(lockSecretHash common.Hash, err error) {
+	t := tracer
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	ts := t.transfers[lockSecretHash]
+	if ts == nil {
+		t.lock.Unlock()
+		return
+	}
+	ts.refs--
+	if ts.refs > 0 {
+		t.lock.Unlock()
+		return
+	}
+	delete(t.transfers, lockSecretHash)
+	t.lock.Unlock()
+	ts.span.SetError(err)
+	ts.span.End()
+}
+
+//TransferSpan 交易的root span,没有的时候返回nil
+func TransferSpan(lockSecretHash common.Hash) *Span {
+	t := tracer
+	if t == nil {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ts := t.transfers[lockSecretHash]
+	if ts == nil {
+		return nil
+	}
+	return ts.span
+}
+
+/*
+StartSpan 创建交易的一个阶段,是交易root span的子span,调用者负责End.
+lockSecretHash为空或者没有启用的时候返回nil
+*/
+func StartSpan(lockSecretHash common.Hash, name string, kind int, kv ...interface{}) *Span {
+	t := tracer
+	if t == nil || lockSecretHash == (common.Hash{}) {
+		return nil
+	}
+	return t.newSpan(lockSecretHash, TransferSpan(lockSecretHash).spanID(), name, kind, kv)
+}
+
+func newSpanID() string {
+	var b [8]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		panic(fmt.Sprintf("read random err %s", err))
+	}
+	return hex.EncodeToString(b[:])
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func toKeyValues(kv []interface{}) (kvs []*otlpKeyValue) {
+	for i := 0; i+1 < len(kv); i += 2 {
+		kvs = append(kvs, &otlpKeyValue{
+			Key:   fmt.Sprint(kv[i]),
+			Value: toAnyValue(kv[i+1]),
+		})
+	}
+	return
+}
+
+func toAnyValue(v interface{}) (a otlpAnyValue) {
+	switch v2 := v.(type) {
+	case bool:
+		a.BoolValue = &v2
+		return
+	case int:
+		s := strconv.FormatInt(int64(v2), 10)
+		a.IntValue = &s
+		return
+	case int64:
+		s := strconv.FormatInt(v2, 10)
+		a.IntValue = &s
+		return
+	case fmt.Stringer:
+		s := v2.String()
+		a.StringValue = &s
+		return
+	}
+	s := fmt.Sprint(v)
+	a.StringValue = &s
+	return
+}
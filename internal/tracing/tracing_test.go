@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/utils"
+)
+
+func TestExportToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "trace.json")
+	err = Start(file, "", utils.NewRandomAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockSecretHash := utils.NewRandomHash()
+	StartTransfer(lockSecretHash, "initiate transfer", "amount", 10)
+	StartTransfer(lockSecretHash, "receive transfer")
+	span := StartSpan(lockSecretHash, "send MediatedTransfer", KindClient, "peer", utils.NewRandomAddress())
+	span.AddEvent("retry", "attempt", 1)
+	span.End()
+	span.SetError(errors.New("ignored after end"))
+	if StartSpan(utils.EmptyHash, "no transfer", KindInternal) != nil {
+		t.Error("span without lockSecretHash should be nil")
+	}
+	EndTransfer(lockSecretHash, nil)
+	if TransferSpan(lockSecretHash) == nil {
+		t.Error("root span should be kept until all state managers end")
+	}
+	EndTransfer(lockSecretHash, errors.New("expired"))
+	Stop()
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var spans []*otlpSpan
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var req otlpRequest
+		err = json.Unmarshal(scanner.Bytes(), &req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	if len(spans) != 2 {
+		t.Fatalf("expect 2 spans, got %d", len(spans))
+	}
+	child, root := spans[0], spans[1]
+	traceID := hex.EncodeToString(lockSecretHash[:16])
+	if child.TraceID != traceID || root.TraceID != traceID {
+		t.Errorf("trace id should be the lockSecretHash")
+	}
+	if child.ParentSpanID != root.SpanID || root.ParentSpanID != "" {
+		t.Errorf("wrong parent %s %s", child.ParentSpanID, root.ParentSpanID)
+	}
+	if len(child.Events) != 1 || child.Status.Code != 0 {
+		t.Errorf("wrong child span %v", child)
+	}
+	if len(root.Events) != 1 || root.Status.Code != statusError || root.Status.Message != "expired" {
+		t.Errorf("wrong root span %v", root)
+	}
+}
@@ -0,0 +1,123 @@
+package photon
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/pfsproxy"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/transfer/route"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+keysend交易,发起方随机生成密码,连同data一起用target的公钥加密放在MediatedTransfer中,
+target收到以后直接解密领取,不需要再发送SecretRequest.
+targetPublicKey为空时使用从target的消息签名中恢复的公钥,onionPublicKeys不为nil时使用onion路由,
+onionAllowClearPath为true时不能创建onion的路由明文发送路径
+*/
+func (rs *Service) startKeysendTransfer(tokenAddress, target common.Address, amount *big.Int, data string, targetPublicKey []byte, routeInfo []pfsproxy.FindPathResponse, onionPublicKeys map[common.Address][]byte, onionAllowClearPath bool) (result *utils.AsyncResult) {
+	if len(targetPublicKey) == 0 {
+		targetPublicKey = rs.nodePublicKeys[target]
+	}
+	if len(targetPublicKey) == 0 {
+		result = utils.NewAsyncResult()
+		result.Result <- rerr.ErrKeysendPublicKeyUnknown.Printf("target=%s", target.String())
+		return
+	}
+	secret := utils.NewRandomHash()
+	lockSecretHash := utils.ShaSecret(secret[:])
+	encryptedSecret, err := encoding.EncryptSecret(targetPublicKey, secret, data)
+	if err != nil {
+		result = utils.NewAsyncResult()
+		result.Result <- rerr.ErrArgumentError.AppendError(err)
+		return
+	}
+	rs.dao.NewSentTransferDetail(tokenAddress, target, amount, data, false, lockSecretHash)
+	result, _ = rs.startMediatedTransferInternal(tokenAddress, target, amount, lockSecretHash, 0, secret, data, routeInfo, encryptedSecret, onionPublicKeys, onionAllowClearPath)
+	result.LockSecretHash = lockSecretHash
+	return
+}
+
+/*
+filterKeysendRoutes 带有EncryptedSecret的MediatedTransfer老版本节点无法处理,
+下一跳必须通过Ping声明过支持keysend.发起方知道路径的时候检查路径上的每一个节点
+*/
+func (rs *Service) filterKeysendRoutes(routes []*route.State, checkPath bool) []*route.State {
+	var result []*route.State
+	for _, r := range routes {
+		hops := []common.Address{r.HopNode()}
+		if checkPath {
+			hops = append(hops, r.Path...)
+		}
+		supported := true
+		for _, hop := range hops {
+			if hop != rs.NodeAddress && !rs.Protocol.SupportKeysend(hop) {
+				log.Info(fmt.Sprintf("ignore route to %s, %s does not support keysend", utils.APex2(r.HopNode()), utils.APex2(hop)))
+				supported = false
+				break
+			}
+		}
+		if supported {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+/*
+Keysend 发起keysend交易,target不需要事先提供密码或者交互就可以直接领取,适合打赏,捐赠等场景.
+targetPublicKey为空时使用从target消息签名中恢复的公钥,如果从来没有收到过target的消息,必须指定.
+timeout为0表示一直等待交易结果
+*/
+func (r *API) Keysend(tokenAddress common.Address, amount *big.Int, target common.Address, targetPublicKey []byte, timeout time.Duration, data string, routeInfo []pfsproxy.FindPathResponse) (result *utils.AsyncResult, err error) {
+	result, err = r.keysendInternal(tokenAddress, amount, target, targetPublicKey, data, routeInfo, nil, false)
+	if err != nil {
+		return
+	}
+	if timeout > 0 {
+		select {
+		case <-time.After(timeout):
+			return result, rerr.ErrTransferTimeout
+		case err = <-result.Result:
+		}
+	} else {
+		err = <-result.Result
+	}
+	return
+}
+
+// KeysendAsync :
+func (r *API) KeysendAsync(tokenAddress common.Address, amount *big.Int, target common.Address, targetPublicKey []byte, data string, routeInfo []pfsproxy.FindPathResponse) (result *utils.AsyncResult, err error) {
+	result, err = r.keysendInternal(tokenAddress, amount, target, targetPublicKey, data, routeInfo, nil, false)
+	if err != nil {
+		return
+	}
+	select {
+	case <-time.After(300 * time.Millisecond):
+	case err = <-result.Result:
+	}
+	return
+}
+
+func (r *API) keysendInternal(tokenAddress common.Address, amount *big.Int, target common.Address, targetPublicKey []byte, data string, routeInfo []pfsproxy.FindPathResponse, onionPublicKeys map[common.Address][]byte, onionAllowClearPath bool) (result *utils.AsyncResult, err error) {
+	if len(targetPublicKey) > 0 {
+		_, err = encoding.UnmarshalPublicKey(targetPublicKey)
+		if err != nil {
+			err = rerr.ErrArgumentError.AppendError(err)
+			return
+		}
+		if utils.PubkeyToAddress(targetPublicKey) != target {
+			err = rerr.ErrArgumentError.Printf("public key does not belong to target %s", target.String())
+			return
+		}
+	}
+	log.Debug(fmt.Sprintf("initiating keysend transfer initiator=%s target=%s token=%s amount=%d,currentblock=%d",
+		r.Photon.NodeAddress.String(), target.String(), tokenAddress.String(), amount, r.Photon.GetBlockNumber()))
+	result = r.Photon.keysendAsyncClient(tokenAddress, amount, target, targetPublicKey, data, routeInfo, onionPublicKeys, onionAllowClearPath)
+	return
+}
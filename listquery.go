@@ -0,0 +1,168 @@
+package photon
+
+import (
+	"math/big"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	defaultListLimit = 100  // 没有指定limit时每页的记录数
+	maxListLimit     = 1000 // 每页最多的记录数
+)
+
+/*
+ListQueryParams 列表接口的查询条件,restful的query参数和mobile的json使用同样的名字,
+条件的意义见models.ListQuery
+*/
+type ListQueryParams struct {
+	TokenAddress      string   `json:"token_address"`
+	FromTime          int64    `json:"from_time"`
+	ToTime            int64    `json:"to_time"`
+	FromBlock         int64    `json:"from_block"`
+	ToBlock           int64    `json:"to_block"`
+	Status            string   `json:"status"`
+	Type              string   `json:"type"`
+	Target            string   `json:"target_address"`
+	Initiator         string   `json:"initiator_address"`
+	ChannelIdentifier string   `json:"channel_identifier"`
+	MinAmount         *big.Int `json:"min_amount"`
+	MaxAmount         *big.Int `json:"max_amount"`
+	Order             string   `json:"order"` // asc或者desc,默认asc
+	Cursor            string   `json:"cursor"`
+	Limit             int      `json:"limit"`
+}
+
+//ListPage 一页记录,next_cursor为空表示没有下一页了
+type ListPage struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor"`
+}
+
+func parseListAddress(s string) (addr common.Address, err error) {
+	if s == "" {
+		return
+	}
+	addr, err = utils.HexToAddressWithoutValidation(s)
+	if err != nil {
+		err = rerr.ErrArgumentError.Printf("invalid address %s", s)
+	}
+	return
+}
+
+//toListQuery 检查参数,limit限制在maxListLimit之内
+func (p *ListQueryParams) toListQuery() (lq *models.ListQuery, err error) {
+	lq = &models.ListQuery{
+		FromTime:  p.FromTime,
+		ToTime:    p.ToTime,
+		FromBlock: p.FromBlock,
+		ToBlock:   p.ToBlock,
+		Status:    p.Status,
+		Type:      p.Type,
+		MinAmount: p.MinAmount,
+		MaxAmount: p.MaxAmount,
+		Cursor:    p.Cursor,
+		Limit:     p.Limit,
+	}
+	if lq.TokenAddress, err = parseListAddress(p.TokenAddress); err != nil {
+		return
+	}
+	if lq.Target, err = parseListAddress(p.Target); err != nil {
+		return
+	}
+	if lq.Initiator, err = parseListAddress(p.Initiator); err != nil {
+		return
+	}
+	if p.ChannelIdentifier != "" {
+		var b []byte
+		b, err = hexutil.Decode(p.ChannelIdentifier)
+		if err != nil || len(b) != len(lq.ChannelIdentifier) {
+			err = rerr.ErrArgumentError.Printf("invalid channel identifier %s", p.ChannelIdentifier)
+			return
+		}
+		lq.ChannelIdentifier = common.BytesToHash(b)
+	}
+	switch p.Order {
+	case "", "asc":
+	case "desc":
+		lq.Desc = true
+	default:
+		err = rerr.ErrArgumentError.Printf("order must be asc or desc")
+		return
+	}
+	if lq.Limit <= 0 {
+		lq.Limit = defaultListLimit
+	}
+	if lq.Limit > maxListLimit {
+		lq.Limit = maxListLimit
+	}
+	return
+}
+
+// QuerySentTransfers :
+func (r *API) QuerySentTransfers(p *ListQueryParams) (page *ListPage, err error) {
+	lq, err := p.toListQuery()
+	if err != nil {
+		return
+	}
+	transfers, next, err := r.Photon.dao.QuerySentTransferDetails(lq)
+	if err != nil {
+		return
+	}
+	if transfers == nil {
+		transfers = []*models.SentTransferDetail{}
+	}
+	return &ListPage{Items: transfers, NextCursor: next}, nil
+}
+
+// QueryReceivedTransfers :
+func (r *API) QueryReceivedTransfers(p *ListQueryParams) (page *ListPage, err error) {
+	lq, err := p.toListQuery()
+	if err != nil {
+		return
+	}
+	transfers, next, err := r.Photon.dao.QueryReceivedTransfers(lq)
+	if err != nil {
+		return
+	}
+	if transfers == nil {
+		transfers = []*models.ReceivedTransfer{}
+	}
+	return &ListPage{Items: transfers, NextCursor: next}, nil
+}
+
+// QueryFeeChargeRecords :
+func (r *API) QueryFeeChargeRecords(p *ListQueryParams) (page *ListPage, err error) {
+	lq, err := p.toListQuery()
+	if err != nil {
+		return
+	}
+	records, next, err := r.Photon.dao.QueryFeeChargeRecords(lq)
+	if err != nil {
+		return
+	}
+	if records == nil {
+		records = []*models.FeeChargeRecord{}
+	}
+	return &ListPage{Items: records, NextCursor: next}, nil
+}
+
+// QueryTXInfos :
+func (r *API) QueryTXInfos(p *ListQueryParams) (page *ListPage, err error) {
+	lq, err := p.toListQuery()
+	if err != nil {
+		return
+	}
+	list, next, err := r.Photon.dao.QueryTXInfos(lq)
+	if err != nil {
+		return
+	}
+	if list == nil {
+		list = []*models.TXInfo{}
+	}
+	return &ListPage{Items: list, NextCursor: next}, nil
+}
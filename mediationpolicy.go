@@ -0,0 +1,124 @@
+package photon
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/SmartMeshFoundation/Photon/channel"
+	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/transfer/route"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+getMediationPolicy token自己的中转策略,没有的话使用默认策略,都没有返回nil
+*/
+func (rs *Service) getMediationPolicy(tokenAddress common.Address) *models.MediationPolicy {
+	for _, addr := range []common.Address{tokenAddress, utils.EmptyAddress} {
+		p, err := rs.dao.GetMediationPolicy(addr)
+		if err == nil {
+			return p
+		}
+		if err != rerr.ErrNotFound {
+			log.Error(fmt.Sprintf("get mediation policy of %s err %s", utils.APex2(addr), err))
+		}
+	}
+	return nil
+}
+
+func channelLocks(end *channel.EndState) int {
+	return len(end.Lock2PendingLocks) + len(end.Lock2UnclaimedLocks)
+}
+
+func channelLockedAmount(end *channel.EndState) *big.Int {
+	sum := big.NewInt(0)
+	for _, l := range end.Lock2PendingLocks {
+		sum.Add(sum, l.Lock.Amount)
+	}
+	for _, l := range end.Lock2UnclaimedLocks {
+		sum.Add(sum, l.Lock.Amount)
+	}
+	return sum
+}
+
+/*
+filterMediationRoutes 按照中转策略过滤下一跳.
+上家,金额或者上家通道中的锁不符合策略的时候返回空,mediator找不到可用的路由,会通过AnnounceDisposed拒绝这笔交易
+*/
+func (rs *Service) filterMediationRoutes(msg *encoding.MediatedTransfer, fromChannel *channel.Channel, routes []*route.State) []*route.State {
+	p := rs.getMediationPolicy(fromChannel.TokenAddress)
+	if p == nil {
+		return routes
+	}
+	reject := func(reason string) []*route.State {
+		log.Warn(fmt.Sprintf("reject mediated transfer %s from %s by mediation policy, %s",
+			utils.HPex(msg.LockSecretHash), utils.APex2(msg.Sender), reason))
+		return nil
+	}
+	if !p.IsPayerAllowed(msg.Sender) {
+		return reject("payer is not allowed")
+	}
+	if !p.IsAmountAllowed(msg.PaymentAmount) {
+		return reject(fmt.Sprintf("amount %s exceeds %s", msg.PaymentAmount, p.MaxAmount))
+	}
+	//收到的锁已经注册到通道中了
+	if !p.IsLocksAllowed(channelLocks(fromChannel.PartnerState)) {
+		return reject(fmt.Sprintf("payer channel holds more than %d locks", p.MaxLocksPerChannel))
+	}
+	if !p.IsLockedAmountAllowed(channelLockedAmount(fromChannel.PartnerState)) {
+		return reject(fmt.Sprintf("payer channel locks more than %s", p.MaxLockedAmountPerChannel))
+	}
+	//和mediator中的getTimeoutBlocks一致,上家通道是打开的
+	timeoutBlocks := msg.Expiration - rs.GetBlockNumber()
+	if timeoutBlocks > int64(fromChannel.SettleTimeout) {
+		timeoutBlocks = int64(fromChannel.SettleTimeout)
+	}
+	var result []*route.State
+	for _, r := range routes {
+		nextHop := r.HopNode()
+		switch {
+		case !p.IsNextHopAllowed(nextHop):
+			log.Info(fmt.Sprintf("mediation policy ignore route to %s, next hop is not allowed", utils.APex2(nextHop)))
+		case !p.IsLocksAllowed(channelLocks(r.Channel().OurState) + 1):
+			log.Info(fmt.Sprintf("mediation policy ignore route to %s, channel holds more than %d locks", utils.APex2(nextHop), p.MaxLocksPerChannel))
+		case !p.IsLockedAmountAllowed(new(big.Int).Add(channelLockedAmount(r.Channel().OurState), msg.PaymentAmount)):
+			log.Info(fmt.Sprintf("mediation policy ignore route to %s, channel locks more than %s", utils.APex2(nextHop), p.MaxLockedAmountPerChannel))
+		case timeoutBlocks-int64(r.RevealTimeout()) < int64(p.MinRevealTimeoutMargin):
+			log.Info(fmt.Sprintf("mediation policy ignore route to %s, only %d blocks left after reveal timeout", utils.APex2(nextHop), timeoutBlocks-int64(r.RevealTimeout())))
+		default:
+			result = append(result, r)
+		}
+	}
+	if len(result) == 0 && len(routes) > 0 {
+		return reject("no route allowed")
+	}
+	return result
+}
+
+// SetMediationPolicy :
+func (r *API) SetMediationPolicy(p *models.MediationPolicy) error {
+	if p.MaxAmount != nil && p.MaxAmount.Sign() < 0 {
+		return rerr.ErrArgumentError.Append("max_amount must not be negative")
+	}
+	if p.MaxLockedAmountPerChannel != nil && p.MaxLockedAmountPerChannel.Sign() < 0 {
+		return rerr.ErrArgumentError.Append("max_locked_amount_per_channel must not be negative")
+	}
+	if p.MaxLocksPerChannel < 0 || p.MinRevealTimeoutMargin < 0 {
+		return rerr.ErrArgumentError.Append("limit must not be negative")
+	}
+	return r.Photon.dao.SaveMediationPolicy(p)
+}
+
+// GetMediationPolicyList :
+func (r *API) GetMediationPolicyList() (ps []*models.MediationPolicy, err error) {
+	return r.Photon.dao.GetMediationPolicyList()
+}
+
+// RemoveMediationPolicy :
+func (r *API) RemoveMediationPolicy(tokenAddress common.Address) error {
+	return r.Photon.dao.RemoveMediationPolicy(tokenAddress)
+}
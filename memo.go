@@ -0,0 +1,238 @@
+package photon
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+newEncryptedMemos 如果target支持加密附言并且知道它的公钥,用target的公钥加密附言,交易创建成功以后通过sendMemos直接发送给target,
+返回的clearData为空,交易消息中不再携带附言.
+否则退化为明文附言,长度不能超过params.MaxTransferDataLen,保持和老版本节点的兼容
+*/
+func (rs *Service) newEncryptedMemos(target common.Address, memoID common.Hash, data string) (memos []*encoding.Memo, clearData string, err error) {
+	if len(data) == 0 {
+		return
+	}
+	pubkey := rs.nodePublicKeys[target]
+	if len(pubkey) == 0 || !rs.Protocol.SupportEncryptedMemo(target) {
+		if len(data) > params.MaxTransferDataLen {
+			err = rerr.ErrArgumentError.Printf("%s does not support encrypted memo,data length must < %d", utils.APex2(target), params.MaxTransferDataLen)
+			return
+		}
+		return nil, data, nil
+	}
+	memos, err = encoding.NewMemos(pubkey, memoID, data)
+	if err != nil {
+		err = rerr.ErrArgumentError.AppendError(err)
+		return
+	}
+	for _, m := range memos {
+		err = m.Sign(rs.PrivateKey, m)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+/*
+sendMemos 交易创建成功以后才发送附言,没有发出的交易不会在target留下附言.
+交易已经发出,发送附言失败只记录日志
+*/
+func (rs *Service) sendMemos(target common.Address, memos []*encoding.Memo) {
+	for _, m := range memos {
+		err := rs.sendAsync(target, m)
+		if err != nil {
+			log.Error(fmt.Sprintf("send memo %s to %s err %s", m, utils.APex2(target), err))
+			return
+		}
+	}
+	if len(memos) > 0 {
+		log.Trace(fmt.Sprintf("send encrypted memo %s to %s in %d messages", utils.HPex(memos[0].MemoID), utils.APex2(target), len(memos)))
+	}
+}
+
+/*
+memoBuffer 保存还没有对应交易的附言,只在内存中,超过params.PendingMemoTimeout丢弃.
+任何节点都可以发送Memo,交易到达之前不写db,并且限制每个节点以及总的数量
+*/
+type memoBuffer struct {
+	lock        sync.Mutex
+	memos       map[string]*models.TransferMemo
+	senderCount map[common.Address]int
+}
+
+func newMemoBuffer() *memoBuffer {
+	return &memoBuffer{
+		memos:       make(map[string]*models.TransferMemo),
+		senderCount: make(map[common.Address]int),
+	}
+}
+
+func (b *memoBuffer) get(memoID common.Hash, sender common.Address) *models.TransferMemo {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.removeExpiredLocked()
+	return b.memos[models.NewTransferMemoKey(memoID, sender)]
+}
+
+//add 超过数量限制返回false
+func (b *memoBuffer) add(m *models.TransferMemo) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.removeExpiredLocked()
+	if len(b.memos) >= params.MaxPendingMemos || b.senderCount[m.Sender] >= params.MaxPendingMemosPerSender {
+		return false
+	}
+	b.memos[models.NewTransferMemoKey(m.MemoID, m.Sender)] = m
+	b.senderCount[m.Sender]++
+	return true
+}
+
+func (b *memoBuffer) remove(m *models.TransferMemo) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.removeLocked(models.NewTransferMemoKey(m.MemoID, m.Sender))
+}
+
+func (b *memoBuffer) removeLocked(key string) {
+	m, ok := b.memos[key]
+	if !ok {
+		return
+	}
+	delete(b.memos, key)
+	b.senderCount[m.Sender]--
+	if b.senderCount[m.Sender] <= 0 {
+		delete(b.senderCount, m.Sender)
+	}
+}
+
+func (b *memoBuffer) removeExpiredLocked() {
+	expireTime := time.Now().Add(-params.PendingMemoTimeout).Unix()
+	for key, m := range b.memos {
+		if m.CreateTime < expireTime {
+			b.removeLocked(key)
+		}
+	}
+}
+
+/*
+receiveMemo 收到加密附言的一部分,收齐以后用自己的私钥解密.
+如果交易已经先到达,更新对应ReceivedTransfer的Data,否则保存在memoBuffer中等交易到达时再使用.
+格式错误的Memo直接丢弃,不影响交易
+*/
+func (rs *Service) receiveMemo(msg *encoding.Memo) {
+	total := int(msg.Total)
+	if total <= 0 || total > encoding.MaxMemoChunks() || int(msg.Index) >= total {
+		log.Warn(fmt.Sprintf("receive invalid memo %s", msg))
+		return
+	}
+	// 只有交易已经到达的附言才会保存在db中
+	m, err := rs.dao.GetTransferMemo(msg.MemoID, msg.Sender)
+	persisted := err == nil
+	if err == rerr.ErrNotFound {
+		m = rs.memoBuffer.get(msg.MemoID, msg.Sender)
+		if m == nil {
+			m = &models.TransferMemo{
+				MemoID:     msg.MemoID,
+				Sender:     msg.Sender,
+				CreateTime: time.Now().Unix(),
+			}
+			if !rs.memoBuffer.add(m) {
+				log.Warn(fmt.Sprintf("receive memo %s,but too many pending memos,drop it", msg))
+				return
+			}
+		}
+	} else if err != nil {
+		log.Error(fmt.Sprintf("GetTransferMemo %s err %s", utils.HPex(msg.MemoID), err))
+		return
+	}
+	if m.Finished {
+		log.Info(fmt.Sprintf("receive duplicate memo %s", msg))
+		return
+	}
+	if m.Total == 0 {
+		m.Total = total
+		m.Chunks = make([][]byte, total)
+	}
+	if m.Total != total {
+		log.Warn(fmt.Sprintf("receive memo %s,but total mismatch,expect %d", msg, m.Total))
+		return
+	}
+	m.Chunks[msg.Index] = msg.Chunk
+	if m.Received() {
+		m.Memo, err = encoding.DecryptMemo(rs.PrivateKey, m.Chunks)
+		if err != nil {
+			log.Warn(fmt.Sprintf("decrypt memo %s from %s err %s", utils.HPex(m.MemoID), utils.APex2(m.Sender), err))
+		}
+		m.Finished = true
+		m.Chunks = nil
+	}
+	if !persisted {
+		return
+	}
+	err = rs.dao.SaveTransferMemo(m)
+	if err != nil {
+		log.Error(fmt.Sprintf("SaveTransferMemo %s err %s", utils.HPex(m.MemoID), err))
+		return
+	}
+	if m.Finished && m.ReceivedTransferKey != "" {
+		err = rs.dao.UpdateReceivedTransferData(m.ReceivedTransferKey, m.Memo)
+		if err != nil {
+			log.Error(fmt.Sprintf("UpdateReceivedTransferData %s err %s", m.ReceivedTransferKey, err))
+		}
+	}
+}
+
+/*
+receivedTransferData 交易到达时查找对应的加密附言,附言已经收齐的话返回解密后的内容.
+在ReceivedTransfer保存以后调用返回的link把附言从memoBuffer移到db,记录交易的key,
+没有收齐的附言收齐以后再更新交易
+*/
+func (rs *Service) receivedTransferData(memoID common.Hash, sender common.Address, data string) (memo string, link func(rt *models.ReceivedTransfer)) {
+	link = func(rt *models.ReceivedTransfer) {}
+	if len(data) > 0 || sender == utils.EmptyAddress {
+		return data, link
+	}
+	m, err := rs.dao.GetTransferMemo(memoID, sender)
+	if err == rerr.ErrNotFound {
+		m = rs.memoBuffer.get(memoID, sender)
+		if m == nil {
+			m = &models.TransferMemo{
+				MemoID:     memoID,
+				Sender:     sender,
+				CreateTime: time.Now().Unix(),
+			}
+		}
+	} else if err != nil {
+		log.Error(fmt.Sprintf("GetTransferMemo %s err %s", utils.HPex(memoID), err))
+		return data, link
+	} else if m.Finished {
+		return m.Memo, link
+	}
+	if m.Finished {
+		data = m.Memo
+	}
+	link = func(rt *models.ReceivedTransfer) {
+		if rt == nil {
+			return
+		}
+		m.ReceivedTransferKey = rt.Key
+		err = rs.dao.SaveTransferMemo(m)
+		if err != nil {
+			log.Error(fmt.Sprintf("SaveTransferMemo %s err %s", utils.HPex(m.MemoID), err))
+		}
+		rs.memoBuffer.remove(m)
+	}
+	return data, link
+}
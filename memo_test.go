@@ -0,0 +1,68 @@
+package photon
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceiveMemo(t *testing.T) {
+	db, err := newTestStormDb()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.CloseDB()
+	key, _ := crypto.GenerateKey()
+	rs := &Service{
+		dao:        db,
+		PrivateKey: key,
+		memoBuffer: newMemoBuffer(),
+	}
+	sender := utils.NewRandomAddress()
+	receive := func(memoID common.Hash, data string) {
+		memos, err := encoding.NewMemos(crypto.FromECDSAPub(&key.PublicKey), memoID, data)
+		if !assert.Nil(t, err) {
+			return
+		}
+		for _, m := range memos {
+			m.Sender = sender
+			rs.receiveMemo(m)
+		}
+	}
+	memoID := utils.NewRandomHash()
+	receive(memoID, "memo")
+	//交易到达之前不保存到db
+	_, err = db.GetTransferMemo(memoID, sender)
+	assert.Equal(t, rerr.ErrNotFound, err)
+	data, link := rs.receivedTransferData(memoID, sender, "")
+	assert.Equal(t, "memo", data)
+	link(&models.ReceivedTransfer{Key: "rt"})
+	m, err := db.GetTransferMemo(memoID, sender)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "rt", m.ReceivedTransferKey)
+	}
+	assert.Nil(t, rs.memoBuffer.get(memoID, sender))
+
+	//每个节点没有对应交易的附言数量有限制
+	old := params.MaxPendingMemosPerSender
+	params.MaxPendingMemosPerSender = 1
+	defer func() { params.MaxPendingMemosPerSender = old }()
+	memoID1, memoID2 := utils.NewRandomHash(), utils.NewRandomHash()
+	receive(memoID1, "memo1")
+	receive(memoID2, "memo2")
+	assert.NotNil(t, rs.memoBuffer.get(memoID1, sender))
+	assert.Nil(t, rs.memoBuffer.get(memoID2, sender))
+	//超时丢弃
+	rs.memoBuffer.get(memoID1, sender).CreateTime -= int64(params.PendingMemoTimeout.Seconds()) + 1
+	assert.Nil(t, rs.memoBuffer.get(memoID1, sender))
+	receive(memoID2, "memo2")
+	assert.NotNil(t, rs.memoBuffer.get(memoID2, sender))
+}
@@ -48,6 +48,10 @@ func (mh *photonMessageHandler) onMessage(msg encoding.SignedMessager, hash comm
 	msg.SetTag(&transfer.MessageTag{
 		EchoHash: hash,
 	})
+	err = mh.photon.checkRecoveringMessage(msg)
+	if err != nil {
+		return
+	}
 	switch m2 := msg.(type) {
 	case *encoding.SecretRequest:
 		f := mh.photon.SecretRequestPredictorMap[m2.LockSecretHash]
@@ -127,6 +131,12 @@ func (mh *photonMessageHandler) onMessage(msg encoding.SignedMessager, hash comm
 		}
 	case *encoding.WithdrawResponse:
 		err = mh.messageWithdrawResponse(m2)
+	case *encoding.Memo:
+		mh.photon.receiveMemo(m2)
+	case *encoding.RecoveryRequest:
+		err = mh.photon.handleRecoveryRequest(m2)
+	case *encoding.RecoveryResponse:
+		err = mh.photon.handleRecoveryResponse(m2)
 	default:
 		log.Error(fmt.Sprintf("photonMessageHandler unknown msg:%s", utils.StringInterface1(msg)))
 		return fmt.Errorf("unhandled message cmdid:%d", msg.Cmd())
@@ -144,7 +154,8 @@ func (mh *photonMessageHandler) balanceProof(msg *encoding.UnLock, smkey common.
 	}
 	sm := mh.photon.Transfer2StateManager[smkey]
 	if sm == nil {
-		log.Error(fmt.Sprintf("receive balanceProof,but have no state manager %s", utils.StringInterface(msg, 3)))
+		log.Error(fmt.Sprintf("receive balanceProof,but have no state manager %s", utils.StringInterface(msg, 3)),
+			utils.TransferLogCtx(msg.LockSecretHash(), msg.ChannelIdentifier)...)
 	} else {
 		mh.photon.StateMachineEventHandler.dispatch(sm, unlockStateChange)
 	}
@@ -226,10 +237,10 @@ func (mh *photonMessageHandler) messageUnlock(msg *encoding.UnLock) error {
 	var err error
 	ch, err = mh.photon.findChannelByIdentifier(msg.ChannelIdentifier)
 	if err != nil {
-		log.Info(fmt.Sprintf("Message for unknown channel: %s", err))
+		log.Info(fmt.Sprintf("Message for unknown channel: %s", err), utils.TransferLogCtx(lockSecretHash, msg.ChannelIdentifier)...)
 		return err
 	}
-	log.Trace(fmt.Sprintf("lockSecretHash=%s,nettingchannel=%s", utils.HPex(lockSecretHash), ch))
+	log.Trace(fmt.Sprintf("lockSecretHash=%s,nettingchannel=%s", utils.HPex(lockSecretHash), ch), utils.TransferLogCtx(lockSecretHash, msg.ChannelIdentifier)...)
 	/*
 		收到unlock时,需要判断下通道的状态,如果该通道的状态已经不为open了,就不应该处理这笔unlock,否则有可能会损失钱.
 		因为我已经提交过balance proof,如果不提交新的,我会损失钱,如果提交新的,那么之前在链上unlock过的锁,需要再unlock一遍,同样会损失gas
@@ -247,7 +258,7 @@ func (mh *photonMessageHandler) messageUnlock(msg *encoding.UnLock) error {
 	}
 	err = ch.RegisterTransfer(mh.photon.GetBlockNumber(), msg)
 	if err != nil {
-		log.Error(fmt.Sprintf("messageUnlock RegisterTransfer err=%s", err))
+		log.Error(fmt.Sprintf("messageUnlock RegisterTransfer err=%s", err), utils.TransferLogCtx(lockSecretHash, msg.ChannelIdentifier)...)
 		return err
 	}
 	/*
@@ -281,12 +292,12 @@ func (mh *photonMessageHandler) messageRemoveExpiredHashlockTransfer(msg *encodi
 		return fmt.Errorf("received  RemoveExpiredHashlockTransfer ,but relate channel cannot found %s", utils.StringInterface(msg, 7))
 	}
 	if !ch.CanContinueTransfer() {
-		log.Warn(fmt.Sprintf("receive msg %s, but channel cannot continue transfer", msg))
+		log.Warn(fmt.Sprintf("receive msg %s, but channel cannot continue transfer", msg), utils.TransferLogCtx(msg.LockSecretHash, msg.ChannelIdentifier)...)
 		return nil
 	}
 	err = ch.RegisterRemoveExpiredHashlockTransfer(msg, mh.photon.GetBlockNumber())
 	if err != nil {
-		log.Warn(fmt.Sprintf("RegisterRemoveExpiredHashlockTransfer err %s", err))
+		log.Warn(fmt.Sprintf("RegisterRemoveExpiredHashlockTransfer err %s", err), utils.TransferLogCtx(msg.LockSecretHash, msg.ChannelIdentifier)...)
 		/*
 			这里不能直接丢弃掉消息,因为存在双方当前块不同步的情况,此时如果我丢弃了该条消息(本来是正确的),那么双方状态永远不会再同步了
 			所以返回err让对方重发,如果是上诉情况,那么到时候会正常处理掉该消息,双方状态恢复正常.如果不是上诉情况,那么双方状态已经不同步了,
@@ -353,7 +364,7 @@ func (mh *photonMessageHandler) messageAnnounceDisposed(msg *encoding.AnnounceDi
 	if err != nil {
 		log.Error(fmt.Sprintf("receive AnnounceDisposed,but i don't know this lock. msg=%s,ch=%s",
 			utils.StringInterface(msg, 3), utils.StringInterface(ch, 4),
-		))
+		), utils.TransferLogCtx(msg.Lock.LockSecretHash, msg.ChannelIdentifier)...)
 		//种情况忽略即可
 		return nil
 	}
@@ -372,7 +383,8 @@ func (mh *photonMessageHandler) messageAnnounceDisposed(msg *encoding.AnnounceDi
 	smkey := utils.Sha3(msg.Lock.LockSecretHash[:], ch.TokenAddress[:])
 	sm := mh.photon.Transfer2StateManager[smkey]
 	if sm == nil {
-		log.Error(fmt.Sprintf("messageAnnounceDisposed cannot found state manager,msg=%s", utils.StringInterface(msg, 3)))
+		log.Error(fmt.Sprintf("messageAnnounceDisposed cannot found state manager,msg=%s", utils.StringInterface(msg, 3)),
+			utils.TransferLogCtx(msg.Lock.LockSecretHash, msg.ChannelIdentifier)...)
 	} else {
 		mh.photon.StateMachineEventHandler.dispatch(sm, stateChange)
 	}
@@ -472,7 +484,8 @@ func (mh *photonMessageHandler) messageDirectTransfer(msg *encoding.DirectTransf
 	amount = amount.Sub(msg.TransferAmount, ch.PartnerState.TransferAmount())
 	err := ch.RegisterTransfer(mh.photon.GetBlockNumber(), msg)
 	if err != nil {
-		log.Error(fmt.Sprintf("RegisterTransfer error %s\n", msg))
+		// 直接转账没有lockSecretHash,只能按照通道关联
+		log.Error(fmt.Sprintf("RegisterTransfer error %s\n", msg), utils.LogKeyChannel, msg.ChannelIdentifier.String())
 		return err
 	}
 	receiveSuccess := &transfer.EventTransferReceivedSuccess{
@@ -529,7 +542,24 @@ func (mh *photonMessageHandler) messageMediatedTransfer(msg *encoding.MediatedTr
 		return fmt.Errorf("receive mediated transfer,it's secret is zero")
 	}
 	token := mh.photon.getTokenForChannelIdentifier(msg.ChannelIdentifier)
-	if mh.photon.Config.IgnoreMediatedNodeRequest && msg.Target != mh.photon.NodeAddress {
+	isTarget := msg.Target == mh.photon.NodeAddress
+	var onionHop *encoding.OnionHop
+	if len(msg.Onion) > 0 {
+		/*
+			onion交易,msg中没有initiator,target和path,只能从onion中解密出下一跳.
+			target拿不到发起方的地址,所以只能是keysend交易
+		*/
+		var err error
+		onionHop, err = encoding.PeelOnion(mh.photon.PrivateKey, msg.Onion)
+		if err != nil {
+			return fmt.Errorf("receive onion mediated transfer,but peel onion err %s", err)
+		}
+		isTarget = onionHop.IsTarget()
+		if isTarget && len(msg.EncryptedSecret) == 0 {
+			return fmt.Errorf("receive onion mediated transfer without encrypted secret")
+		}
+	}
+	if mh.photon.Config.IgnoreMediatedNodeRequest && !isTarget {
 		//todo what about return a AnnounceDisposed Message ?
 		/*
 			需要考虑恶意攻击的情况,比如发送一个我已经知道密码,但是尚未 unlock 的锁
@@ -584,10 +614,10 @@ func (mh *photonMessageHandler) messageMediatedTransfer(msg *encoding.MediatedTr
 	buf, err := json.MarshalIndent(dataForDebug, "", "\t")
 	log.Trace(string(buf))
 	//mh.UpdateChannelAndSaveAck(ch, msg.Tag())
-	if msg.Target == mh.photon.NodeAddress {
+	if isTarget {
 		mh.photon.targetMediatedTransfer(msg, ch)
 	} else {
-		mh.photon.mediateMediatedTransfer(msg, ch)
+		mh.photon.mediateMediatedTransfer(msg, ch, onionHop)
 	}
 	/*
 		start  taker's tokenswap ,only if receive a valid mediated transfer
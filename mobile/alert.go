@@ -0,0 +1,16 @@
+package mobile
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+)
+
+// GetAlerts 当前需要处理的告警,按级别从高到低排序,新的告警同时通过notice通知
+func (a *API) GetAlerts() (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall GetAlerts result=%s", result))
+	}()
+	return dto.NewMobileResponse(nil, a.api.GetAlerts())
+}
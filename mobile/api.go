@@ -16,6 +16,7 @@ import (
 	"errors"
 
 	"strings"
+	"time"
 
 	photon "github.com/SmartMeshFoundation/Photon"
 	"github.com/SmartMeshFoundation/Photon/internal/rpanic"
@@ -190,6 +191,7 @@ example returns:
 }
 */
 func (a *API) Deposit(partnerAddress, tokenAddress string, settleTimeout int, balanceStr string, newChannel bool) (result string) {
+	defer a.audit("Deposit", time.Now(), &result, map[string]interface{}{"partner_address": partnerAddress, "token_address": tokenAddress, "settle_timeout": settleTimeout, "balance": balanceStr, "new_channel": newChannel})
 	r, err := a.deposit(partnerAddress, tokenAddress, settleTimeout, balanceStr, newChannel)
 	if err != nil {
 		result = dto.NewErrorMobileResponse(err)
@@ -246,6 +248,7 @@ example returns:
 }
 */
 func (a *API) CloseChannel(channelIdentifier string, force bool) (result string) {
+	defer a.audit("CloseChannel", time.Now(), &result, map[string]interface{}{"channel_identifier": channelIdentifier, "force": force})
 	c, err := a.closeChannel(channelIdentifier, force)
 	if err != nil {
 		result = dto.NewErrorMobileResponse(err)
@@ -304,6 +307,7 @@ example returns:
 }
 */
 func (a *API) SettleChannel(channelIdentifier string) (result string) {
+	defer a.audit("SettleChannel", time.Now(), &result, map[string]interface{}{"channel_identifier": channelIdentifier})
 	c, err := a.settleChannel(channelIdentifier)
 	if err != nil {
 		result = dto.NewErrorMobileResponse(err)
@@ -323,6 +327,7 @@ func (a *API) SettleChannel(channelIdentifier string) (result string) {
 	{"op": "cancelprepare"}
 */
 func (a *API) Withdraw(channelIdentifierHashStr, amountstr, op string) (result string) {
+	defer a.audit("Withdraw", time.Now(), &result, map[string]interface{}{"channel_identifier": channelIdentifierHashStr, "amount": amountstr, "op": op})
 	c, err := a.withdraw(channelIdentifierHashStr, amountstr, op)
 	if err != nil {
 		result = dto.NewErrorMobileResponse(err)
@@ -474,6 +479,7 @@ transfer:
 the caller should call GetSentTransferDetail periodically to query this transfer's latest status.
 */
 func (a *API) Transfers(tokenAddress, targetAddress string, amountstr string, secretStr string, isDirect bool, data string, routeInfoStr string) (result string) {
+	defer a.audit("Transfers", time.Now(), &result, map[string]interface{}{"token_address": tokenAddress, "target_address": targetAddress, "amount": amountstr, "secret": secretStr, "is_direct": isDirect, "data": data, "route_info": routeInfoStr})
 	defer func() {
 		log.Trace(fmt.Sprintf("Api Transfers tokenAddress=%s,targetAddress=%s,amountstr=%s,secretStr=%s,isDirect=%v, data=%s routeInfo=%s\nout transfer=\n%s ",
 			tokenAddress, targetAddress, amountstr, secretStr, isDirect, data, result, routeInfoStr,
@@ -494,8 +500,8 @@ func (a *API) Transfers(tokenAddress, targetAddress string, amountstr string, se
 		err = rerr.ErrArgumentError.AppendError(err)
 		return dto.NewErrorMobileResponse(err)
 	}
-	if len(data) > params.MaxTransferDataLen {
-		err = errors.New("invalid data, data len must < 256")
+	if len(data) > params.MaxMemoLen {
+		err = fmt.Errorf("invalid data, data len must < %d", params.MaxMemoLen)
 		err = rerr.ErrArgumentError.AppendError(err)
 		return dto.NewErrorMobileResponse(err)
 	}
@@ -534,6 +540,137 @@ func (a *API) Transfers(tokenAddress, targetAddress string, amountstr string, se
 	return dto.NewSuccessMobileResponse(req)
 }
 
+/*
+Keysend 发起keysend交易,密码由本节点生成并加密给target,target不需要事先交互就可以直接领取
+targetPublicKey target的公钥,为空时使用从target消息签名中恢复的公钥
+返回结果和Transfers相同
+*/
+func (a *API) Keysend(tokenAddress, targetAddress string, amountstr string, targetPublicKey string, data string, routeInfoStr string) (result string) {
+	defer a.audit("Keysend", time.Now(), &result, map[string]interface{}{"token_address": tokenAddress, "target_address": targetAddress, "amount": amountstr, "target_public_key": targetPublicKey, "data": data, "route_info": routeInfoStr})
+	defer func() {
+		log.Trace(fmt.Sprintf("Api Keysend tokenAddress=%s,targetAddress=%s,amountstr=%s,targetPublicKey=%s,data=%s,routeInfo=%s\nout transfer=\n%s ",
+			tokenAddress, targetAddress, amountstr, targetPublicKey, data, routeInfoStr, result,
+		))
+	}()
+	tokenAddr, err := utils.HexToAddressWithoutValidation(tokenAddress)
+	if err != nil {
+		err = rerr.ErrArgumentError.AppendError(err)
+		return dto.NewErrorMobileResponse(err)
+	}
+	targetAddr, err := utils.HexToAddressWithoutValidation(targetAddress)
+	if err != nil {
+		err = rerr.ErrArgumentError.AppendError(err)
+		return dto.NewErrorMobileResponse(err)
+	}
+	if len(data) > params.MaxTransferDataLen {
+		err = errors.New("invalid data, data len must < 256")
+		err = rerr.ErrArgumentError.AppendError(err)
+		return dto.NewErrorMobileResponse(err)
+	}
+	amount, ok := new(big.Int).SetString(amountstr, 0)
+	if !ok || amount.Cmp(utils.BigInt0) <= 0 {
+		err = errors.New("amount should be positive")
+		err = rerr.ErrArgumentError.AppendError(err)
+		return dto.NewErrorMobileResponse(err)
+	}
+	var routeInfo []pfsproxy.FindPathResponse
+	if routeInfoStr != "" {
+		err = json.Unmarshal([]byte(routeInfoStr), &routeInfo)
+		if err != nil {
+			err = fmt.Errorf("parse route info err=%s", err.Error())
+			err = rerr.ErrArgumentError.AppendError(err)
+			return dto.NewErrorMobileResponse(err)
+		}
+	}
+	tr, err := a.api.KeysendAsync(tokenAddr, amount, targetAddr, common.FromHex(targetPublicKey), data, routeInfo)
+	if err != nil {
+		log.Error(err.Error())
+		return dto.NewErrorMobileResponse(err)
+	}
+	req := &v1.TransferData{}
+	req.LockSecretHash = tr.LockSecretHash.String()
+	req.Initiator = a.api.Photon.NodeAddress.String()
+	req.Target = targetAddress
+	req.Token = tokenAddress
+	req.Amount = amount
+	req.Data = data
+	req.Keysend = true
+	return dto.NewSuccessMobileResponse(req)
+}
+
+/*
+OnionTransfer 发起onion路由的keysend交易,中间节点不知道发起方,target以及完整路径,必须通过routeInfoStr指定路由
+publicKeysStr 路径上节点的公钥,格式为{"address":"pubkey"}的json,没有指定的节点使用从它的消息中恢复的公钥
+allowClearPath 为true时不能创建onion的路由明文发送路径,否则这些路由被丢弃,没有可用路由时返回ErrOnionNotSupported
+返回结果和Transfers相同
+*/
+func (a *API) OnionTransfer(tokenAddress, targetAddress string, amountstr string, publicKeysStr string, allowClearPath bool, data string, routeInfoStr string) (result string) {
+	defer a.audit("OnionTransfer", time.Now(), &result, map[string]interface{}{"token_address": tokenAddress, "target_address": targetAddress, "amount": amountstr, "public_keys": publicKeysStr, "allow_clear_path": allowClearPath, "data": data, "route_info": routeInfoStr})
+	defer func() {
+		log.Trace(fmt.Sprintf("Api OnionTransfer tokenAddress=%s,targetAddress=%s,amountstr=%s,publicKeys=%s,allowClearPath=%v,data=%s,routeInfo=%s\nout transfer=\n%s ",
+			tokenAddress, targetAddress, amountstr, publicKeysStr, allowClearPath, data, routeInfoStr, result,
+		))
+	}()
+	tokenAddr, err := utils.HexToAddressWithoutValidation(tokenAddress)
+	if err != nil {
+		err = rerr.ErrArgumentError.AppendError(err)
+		return dto.NewErrorMobileResponse(err)
+	}
+	targetAddr, err := utils.HexToAddressWithoutValidation(targetAddress)
+	if err != nil {
+		err = rerr.ErrArgumentError.AppendError(err)
+		return dto.NewErrorMobileResponse(err)
+	}
+	if len(data) > params.MaxTransferDataLen {
+		err = errors.New("invalid data, data len must < 256")
+		err = rerr.ErrArgumentError.AppendError(err)
+		return dto.NewErrorMobileResponse(err)
+	}
+	amount, ok := new(big.Int).SetString(amountstr, 0)
+	if !ok || amount.Cmp(utils.BigInt0) <= 0 {
+		err = errors.New("amount should be positive")
+		err = rerr.ErrArgumentError.AppendError(err)
+		return dto.NewErrorMobileResponse(err)
+	}
+	publicKeys := make(map[common.Address][]byte)
+	if publicKeysStr != "" {
+		var keys map[string]string
+		err = json.Unmarshal([]byte(publicKeysStr), &keys)
+		if err != nil {
+			err = rerr.ErrArgumentError.AppendError(err)
+			return dto.NewErrorMobileResponse(err)
+		}
+		for addr, pubkey := range keys {
+			publicKeys[common.HexToAddress(addr)] = common.FromHex(pubkey)
+		}
+	}
+	var routeInfo []pfsproxy.FindPathResponse
+	if routeInfoStr != "" {
+		err = json.Unmarshal([]byte(routeInfoStr), &routeInfo)
+		if err != nil {
+			err = fmt.Errorf("parse route info err=%s", err.Error())
+			err = rerr.ErrArgumentError.AppendError(err)
+			return dto.NewErrorMobileResponse(err)
+		}
+	}
+	tr, err := a.api.OnionTransferAsync(tokenAddr, amount, targetAddr, publicKeys, allowClearPath, data, routeInfo)
+	if err != nil {
+		log.Error(err.Error())
+		return dto.NewErrorMobileResponse(err)
+	}
+	req := &v1.TransferData{}
+	req.LockSecretHash = tr.LockSecretHash.String()
+	req.Initiator = a.api.Photon.NodeAddress.String()
+	req.Target = targetAddress
+	req.Token = tokenAddress
+	req.Amount = amount
+	req.Data = data
+	req.Keysend = true
+	req.Onion = true
+	req.OnionAllowClear = allowClearPath
+	return dto.NewSuccessMobileResponse(req)
+}
+
 /*
 TokenSwap token swap for maker for two Photon nodes
 the role should only be  "maker" or "taker".
@@ -598,6 +735,8 @@ the role should only be  "maker" or "taker".
 //Stop stop Photon
 func (a *API) Stop() {
 	log.Info("Api Stop")
+	//数据库关闭之前记录
+	a.audit("Stop", time.Now(), nil, nil)
 	if v1.QuitChain != nil {
 		close(v1.QuitChain)
 	}
@@ -661,6 +800,7 @@ func (a *API) ChannelFor3rdParty(channelIdentifier, thirdPartyAddress string) (r
 SwitchNetwork  switch between mesh and internet
 */
 func (a *API) SwitchNetwork(isMesh bool) {
+	defer a.audit("SwitchNetwork", time.Now(), nil, map[string]interface{}{"is_mesh": isMesh})
 	log.Trace(fmt.Sprintf("Api SwitchNetwork isMesh=%v", isMesh))
 	a.api.Photon.Config.IsMeshNetwork = isMesh
 }
@@ -670,6 +810,7 @@ UpdateMeshNetworkNodes updates all nodes in MeshNetwork.
 Nodes within the same local network have higher priority.
 */
 func (a *API) UpdateMeshNetworkNodes(nodesstr string) (result string) {
+	defer a.audit("UpdateMeshNetworkNodes", time.Now(), &result, map[string]interface{}{"nodes": nodesstr})
 	defer func() {
 		log.Trace(fmt.Sprintf("Api UpdateMeshNetworkNodes nodesstr=%s,out result=%v", nodesstr, result))
 	}()
@@ -898,8 +1039,50 @@ func (a *API) GetTransferStatus(tokenAddressStr string, lockSecretHashStr string
 	return dto.NewSuccessMobileResponse(ts)
 }
 
+/*
+GetPaymentReceipt 导出已经成功的交易的支付凭证,可以交给第三方通过VerifyPaymentReceipt验证
+*/
+func (a *API) GetPaymentReceipt(tokenAddressStr string, lockSecretHashStr string) (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api GetPaymentReceipt tokenAddressStr=%s,lockSecretHashStr=%s, result=%s\n",
+			tokenAddressStr, lockSecretHashStr, result,
+		))
+	}()
+	tokenAddress, err := utils.HexToAddress(tokenAddressStr)
+	if err != nil {
+		err = rerr.ErrArgumentError.AppendError(err)
+		return dto.NewErrorMobileResponse(err)
+	}
+	receipt, err := a.api.GetPaymentReceipt(tokenAddress, common.HexToHash(lockSecretHashStr))
+	if err != nil {
+		return dto.NewErrorMobileResponse(err)
+	}
+	return dto.NewSuccessMobileResponse(receipt)
+}
+
+/*
+VerifyPaymentReceipt 离线验证GetPaymentReceipt导出的支付凭证
+*/
+func (a *API) VerifyPaymentReceipt(receiptStr string) (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api VerifyPaymentReceipt receipt=%s, result=%s\n", receiptStr, result))
+	}()
+	receipt := &models.PaymentReceipt{}
+	err := json.Unmarshal([]byte(receiptStr), receipt)
+	if err != nil {
+		err = rerr.ErrArgumentError.AppendError(err)
+		return dto.NewErrorMobileResponse(err)
+	}
+	err = a.api.VerifyPaymentReceipt(receipt)
+	if err != nil {
+		return dto.NewErrorMobileResponse(err)
+	}
+	return dto.NewSuccessMobileResponse(receipt)
+}
+
 // NotifyNetworkDown :
 func (a *API) NotifyNetworkDown() (result string) {
+	defer a.audit("NotifyNetworkDown", time.Now(), &result, nil)
 	defer func() {
 		log.Trace(fmt.Sprintf("ApiCall NotifyNetworkDown result=%s", result))
 	}()
@@ -1035,3 +1218,89 @@ func (a *API) ContractCallTXQuery(channelIdentifierStr string, openBlockNumber i
 func (a *API) Version() string {
 	return dto.NewSuccessMobileResponse(a.api.GetBuildInfo())
 }
+
+/*
+CreatePaymentPlan 创建定时支付计划
+startTime 第一次支付的时间戳,为0表示立即开始
+interval 支付间隔,单位秒,为0表示只在startTime支付一次
+endTime,maxTimes 为0表示不限制
+*/
+func (a *API) CreatePaymentPlan(tokenAddress, targetAddress string, amountstr string, data string, startTime, interval, endTime int64, maxTimes int) (result string) {
+	defer a.audit("CreatePaymentPlan", time.Now(), &result, map[string]interface{}{"token_address": tokenAddress, "target_address": targetAddress, "amount": amountstr, "data": data, "start_time": startTime, "interval": interval, "end_time": endTime, "max_times": maxTimes})
+	defer func() {
+		log.Trace(fmt.Sprintf("Api CreatePaymentPlan tokenAddress=%s,targetAddress=%s,amountstr=%s,startTime=%d,interval=%d,endTime=%d,maxTimes=%d\nout=%s",
+			tokenAddress, targetAddress, amountstr, startTime, interval, endTime, maxTimes, result,
+		))
+	}()
+	tokenAddr, err := utils.HexToAddressWithoutValidation(tokenAddress)
+	if err != nil {
+		err = rerr.ErrArgumentError.AppendError(err)
+		return dto.NewErrorMobileResponse(err)
+	}
+	targetAddr, err := utils.HexToAddressWithoutValidation(targetAddress)
+	if err != nil {
+		err = rerr.ErrArgumentError.AppendError(err)
+		return dto.NewErrorMobileResponse(err)
+	}
+	amount, ok := new(big.Int).SetString(amountstr, 0)
+	if !ok {
+		err = rerr.ErrArgumentError.Append("invalid amount")
+		return dto.NewErrorMobileResponse(err)
+	}
+	p, err := a.api.CreatePaymentPlan(tokenAddr, targetAddr, amount, data, startTime, interval, endTime, maxTimes, "")
+	if err != nil {
+		return dto.NewErrorMobileResponse(err)
+	}
+	return dto.NewSuccessMobileResponse(p)
+}
+
+// GetPaymentPlanList 查询所有的定时支付计划
+func (a *API) GetPaymentPlanList() (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall GetPaymentPlanList result=%s", result))
+	}()
+	ps, err := a.api.GetPaymentPlanList()
+	if err != nil {
+		return dto.NewErrorMobileResponse(err)
+	}
+	return dto.NewSuccessMobileResponse(ps)
+}
+
+// PausePaymentPlan 暂停定时支付计划
+func (a *API) PausePaymentPlan(key string) (result string) {
+	defer a.audit("PausePaymentPlan", time.Now(), &result, map[string]interface{}{"key": key})
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall PausePaymentPlan key=%s result=%s", key, result))
+	}()
+	p, err := a.api.PausePaymentPlan(key)
+	if err != nil {
+		return dto.NewErrorMobileResponse(err)
+	}
+	return dto.NewSuccessMobileResponse(p)
+}
+
+// ResumePaymentPlan 恢复暂停的定时支付计划
+func (a *API) ResumePaymentPlan(key string) (result string) {
+	defer a.audit("ResumePaymentPlan", time.Now(), &result, map[string]interface{}{"key": key})
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall ResumePaymentPlan key=%s result=%s", key, result))
+	}()
+	p, err := a.api.ResumePaymentPlan(key)
+	if err != nil {
+		return dto.NewErrorMobileResponse(err)
+	}
+	return dto.NewSuccessMobileResponse(p)
+}
+
+// CancelPaymentPlan 取消定时支付计划
+func (a *API) CancelPaymentPlan(key string) (result string) {
+	defer a.audit("CancelPaymentPlan", time.Now(), &result, map[string]interface{}{"key": key})
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall CancelPaymentPlan key=%s result=%s", key, result))
+	}()
+	p, err := a.api.CancelPaymentPlan(key)
+	if err != nil {
+		return dto.NewErrorMobileResponse(err)
+	}
+	return dto.NewSuccessMobileResponse(p)
+}
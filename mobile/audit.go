@@ -0,0 +1,56 @@
+package mobile
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+)
+
+/*
+audit 把改变状态的接口调用写入审计日志,在接口返回的时候调用.
+result是接口返回的json,没有返回值的接口为nil,params中的secret不会被记录
+*/
+func (a *API) audit(operation string, startTime time.Time, result *string, params map[string]interface{}) {
+	buf, err := json.Marshal(params)
+	if err != nil {
+		log.Error(fmt.Sprintf("marshal audit params of %s err %s", operation, err))
+	}
+	l := &models.AuditLog{
+		Time:      startTime.Unix(),
+		Source:    models.AuditSourceMobile,
+		Caller:    "mobile",
+		Operation: operation,
+		Params:    string(buf),
+	}
+	var resp dto.APIResponse
+	if result != nil && json.Unmarshal([]byte(*result), &resp) == nil {
+		l.ErrorCode = resp.ErrorCode
+		l.ErrorMessage = resp.ErrorMsg
+	}
+	err = a.api.AppendAuditLog(l, resp.Data)
+	if err != nil {
+		log.Error(fmt.Sprintf("append audit log of %s err %s", operation, err))
+	}
+}
+
+// GetAuditLogs 按照seq查询审计日志,from和to都包括在内,为0表示从第一条开始或者到最后一条
+func (a *API) GetAuditLogs(from, to int64) (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall GetAuditLogs from=%d,to=%d result=%s", from, to, result))
+	}()
+	ls, err := a.api.GetAuditLogList(from, to)
+	return dto.NewMobileResponse(err, ls)
+}
+
+// VerifyAuditLog 检查审计日志的hash链
+func (a *API) VerifyAuditLog() (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall VerifyAuditLog result=%s", result))
+	}()
+	r, err := a.api.VerifyAuditLog()
+	return dto.NewMobileResponse(err, r)
+}
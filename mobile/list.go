@@ -0,0 +1,80 @@
+package mobile
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon"
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+)
+
+//parseListQuery query是json格式的photon.ListQueryParams,为空表示没有条件
+func parseListQuery(query string) (p *photon.ListQueryParams, err error) {
+	p = &photon.ListQueryParams{}
+	if query == "" {
+		return
+	}
+	err = json.Unmarshal([]byte(query), p)
+	if err != nil {
+		err = rerr.ErrArgumentError.AppendError(err)
+	}
+	return
+}
+
+/*
+QuerySentTransfers 分页查询发出的交易,query的字段和restful的/api/1/list/sent_transfers的参数相同,例如
+{"token_address":"0x...","status":"3","order":"desc","limit":20,"cursor":"..."}
+返回{"items":[...],"next_cursor":"..."},next_cursor为空表示没有下一页了
+*/
+func (a *API) QuerySentTransfers(query string) (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall QuerySentTransfers query=%s result=%s", query, result))
+	}()
+	p, err := parseListQuery(query)
+	if err != nil {
+		return dto.NewErrorMobileResponse(err)
+	}
+	page, err := a.api.QuerySentTransfers(p)
+	return dto.NewMobileResponse(err, page)
+}
+
+// QueryReceivedTransfers 分页查询收到的交易,参数和返回值同QuerySentTransfers
+func (a *API) QueryReceivedTransfers(query string) (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall QueryReceivedTransfers query=%s result=%s", query, result))
+	}()
+	p, err := parseListQuery(query)
+	if err != nil {
+		return dto.NewErrorMobileResponse(err)
+	}
+	page, err := a.api.QueryReceivedTransfers(p)
+	return dto.NewMobileResponse(err, page)
+}
+
+// QueryFeeChargeRecords 分页查询收取的手续费,参数和返回值同QuerySentTransfers
+func (a *API) QueryFeeChargeRecords(query string) (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall QueryFeeChargeRecords query=%s result=%s", query, result))
+	}()
+	p, err := parseListQuery(query)
+	if err != nil {
+		return dto.NewErrorMobileResponse(err)
+	}
+	page, err := a.api.QueryFeeChargeRecords(p)
+	return dto.NewMobileResponse(err, page)
+}
+
+// QueryTXInfos 分页查询合约调用的tx,参数和返回值同QuerySentTransfers
+func (a *API) QueryTXInfos(query string) (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall QueryTXInfos query=%s result=%s", query, result))
+	}()
+	p, err := parseListQuery(query)
+	if err != nil {
+		return dto.NewErrorMobileResponse(err)
+	}
+	page, err := a.api.QueryTXInfos(p)
+	return dto.NewMobileResponse(err, page)
+}
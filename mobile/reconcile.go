@@ -0,0 +1,27 @@
+package mobile
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+)
+
+// GetReconcileReport 最近一次和合约对账的结果,还没有对账时data为null
+func (a *API) GetReconcileReport() (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall GetReconcileReport result=%s", result))
+	}()
+	return dto.NewMobileResponse(nil, a.api.GetReconcileReport())
+}
+
+// Reconcile 立即和合约对账一次,返回对账的结果
+func (a *API) Reconcile() (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall Reconcile result=%s", result))
+	}()
+	defer a.audit("Reconcile", time.Now(), &result, nil)
+	report, err := a.api.Reconcile()
+	return dto.NewMobileResponse(err, report)
+}
@@ -0,0 +1,29 @@
+package mobile
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// GetChannelRecoveries 数据库丢失以后从对方恢复通道的记录
+func (a *API) GetChannelRecoveries() (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall GetChannelRecoveries result=%s", result))
+	}()
+	rs, err := a.api.GetChannelRecoveryList()
+	return dto.NewMobileResponse(err, rs)
+}
+
+// AbandonChannelRecovery 放弃从对方恢复通道,通道使用链上事件重建的状态
+func (a *API) AbandonChannelRecovery(channelIdentifier string) (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall AbandonChannelRecovery channelIdentifier=%s,result=%s", channelIdentifier, result))
+	}()
+	defer a.audit("AbandonChannelRecovery", time.Now(), &result, map[string]interface{}{"channel_identifier": channelIdentifier})
+	err := a.api.AbandonChannelRecovery(common.HexToHash(channelIdentifier))
+	return dto.NewMobileResponse(err, nil)
+}
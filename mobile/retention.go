@@ -0,0 +1,26 @@
+package mobile
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+)
+
+// GetRetentionReport 最近一次归档历史记录的结果,还没有归档时data为null
+func (a *API) GetRetentionReport() (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall GetRetentionReport result=%s", result))
+	}()
+	return dto.NewMobileResponse(nil, a.api.GetRetentionReport())
+}
+
+// Archive 立即按照保留时间归档一次,返回归档的结果
+func (a *API) Archive() (result string) {
+	defer func() {
+		log.Trace(fmt.Sprintf("ApiCall Archive result=%s", result))
+	}()
+	defer a.audit("Archive", time.Now(), &result, nil)
+	return dto.NewMobileResponse(nil, a.api.Archive())
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"encoding/gob"
+	"math/big"
+
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//APITokenScope 权限范围,read < pay < admin,高级别包含低级别的所有权限
+type APITokenScope string
+
+const (
+	//APITokenScopeRead 只能查询
+	APITokenScopeRead APITokenScope = "read"
+	//APITokenScopePay 可以查询和发起交易,交易金额受SpendingCap限制
+	APITokenScopePay APITokenScope = "pay"
+	//APITokenScopeAdmin 所有接口,包括通道操作和debug接口
+	APITokenScopeAdmin APITokenScope = "admin"
+)
+
+var apiTokenScopeLevel = map[APITokenScope]int{
+	APITokenScopeRead:  1,
+	APITokenScopePay:   2,
+	APITokenScopeAdmin: 3,
+}
+
+//IsValid returns true if s is a known scope
+func (s APITokenScope) IsValid() bool {
+	return apiTokenScopeLevel[s] > 0
+}
+
+//Allows returns true if s includes the required scope
+func (s APITokenScope) Allows(required APITokenScope) bool {
+	return s.IsValid() && apiTokenScopeLevel[s] >= apiTokenScopeLevel[required]
+}
+
+/*
+APITokenCharge 通过有支付上限的API token发出的一笔还没有结果的交易,交易失败或者取消以后退还金额
+*/
+type APITokenCharge struct {
+	TokenAddress   common.Address
+	LockSecretHash common.Hash
+	Amount         *big.Int
+}
+
+/*
+APIToken 调用REST接口的bearer token,数据库中只保存token的hash,token本身只在创建的时候显示一次.
+SpendingCap对每种token分别生效,是这个API token累计可以支付的上限
+*/
+type APIToken struct {
+	Key         string                      `json:"-" storm:"id"`
+	Name        string                      `json:"name"`
+	Scope       APITokenScope               `json:"scope"`
+	SpendingCap *big.Int                    `json:"spending_cap"` // nil表示不限制
+	Spent       map[common.Address]*big.Int `json:"spent"`
+	Charges     []*APITokenCharge           `json:"-"`
+	Revoked     bool                        `json:"revoked"`
+	CreateTime  int64                       `json:"create_time"`
+}
+
+//NewAPITokenKey key of a bearer token
+func NewAPITokenKey(token string) string {
+	return utils.Sha3([]byte(token)).String()
+}
+
+/*
+Spend 记录这个API token的一组支付,任何一种token超过SpendingCap都返回错误,并且都不记录
+*/
+func (t *APIToken) Spend(amounts map[common.Address]*big.Int) error {
+	if t.Spent == nil {
+		t.Spent = make(map[common.Address]*big.Int)
+	}
+	spents := make(map[common.Address]*big.Int)
+	for tokenAddress, amount := range amounts {
+		spent := t.Spent[tokenAddress]
+		if spent == nil {
+			spent = big.NewInt(0)
+		}
+		spent = new(big.Int).Add(spent, amount)
+		if t.SpendingCap != nil && spent.Cmp(t.SpendingCap) > 0 {
+			return rerr.ErrSpendingCapExceeded.Printf("api token %s has spent %s of token %s,cap is %s", t.Name, t.Spent[tokenAddress], tokenAddress.String(), t.SpendingCap)
+		}
+		spents[tokenAddress] = spent
+	}
+	for tokenAddress, spent := range spents {
+		t.Spent[tokenAddress] = spent
+	}
+	return nil
+}
+
+//Refund 退还没有发出或者失败的交易的金额
+func (t *APIToken) Refund(tokenAddress common.Address, amount *big.Int) {
+	spent := t.Spent[tokenAddress]
+	if spent == nil {
+		return
+	}
+	spent = new(big.Int).Sub(spent, amount)
+	if spent.Sign() < 0 {
+		spent = big.NewInt(0)
+	}
+	t.Spent[tokenAddress] = spent
+}
+
+func init() {
+	gob.Register(&APIToken{})
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// AuditSource 接口调用的来源
+type AuditSource string
+
+/* #nosec */
+const (
+	AuditSourceREST   AuditSource = "rest"
+	AuditSourceMobile AuditSource = "mobile"
+)
+
+/*
+AuditLog 改变状态的接口调用记录,只追加,不修改也不删除.
+Seq从1开始连续递增,Hash覆盖上一条记录的Hash和本条记录的所有字段,
+修改或者删除任何一条记录,从这条记录开始的校验都会失败
+*/
+type AuditLog struct {
+	Seq               int64       `json:"seq" storm:"id"`
+	Time              int64       `json:"time"`
+	Source            AuditSource `json:"source"`
+	Caller            string      `json:"caller"` // API token的名字,http用户名,客户端证书或者anonymous
+	RemoteAddr        string      `json:"remote_addr,omitempty"`
+	Operation         string      `json:"operation"` // rest是method和path,mobile是函数名
+	Params            string      `json:"params"`    // 参数中的secret和密码不会被记录
+	ErrorCode         int         `json:"error_code"`
+	ErrorMessage      string      `json:"error_message"`
+	LockSecretHash    common.Hash `json:"lock_secret_hash"`   // 交易的结果
+	ChannelIdentifier common.Hash `json:"channel_identifier"` // 通道操作的结果
+	TXHash            common.Hash `json:"tx_hash"`            // 记录时已经发出的链上tx
+	PrevHash          common.Hash `json:"prev_hash"`
+	Hash              common.Hash `json:"hash"`
+}
+
+func writeAuditString(buf *bytes.Buffer, s string) {
+	err := binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	if err != nil {
+		panic(err)
+	}
+	buf.WriteString(s)
+}
+
+//ComputeHash 计算记录的hash,不包括Hash字段本身
+func (l *AuditLog) ComputeHash() common.Hash {
+	buf := new(bytes.Buffer)
+	buf.Write(l.PrevHash[:])
+	err := binary.Write(buf, binary.BigEndian, []int64{l.Seq, l.Time, int64(l.ErrorCode)})
+	if err != nil {
+		panic(err)
+	}
+	writeAuditString(buf, string(l.Source))
+	writeAuditString(buf, l.Caller)
+	writeAuditString(buf, l.RemoteAddr)
+	writeAuditString(buf, l.Operation)
+	writeAuditString(buf, l.Params)
+	writeAuditString(buf, l.ErrorMessage)
+	buf.Write(l.LockSecretHash[:])
+	buf.Write(l.ChannelIdentifier[:])
+	buf.Write(l.TXHash[:])
+	return crypto.Keccak256Hash(buf.Bytes())
+}
+
+/*
+VerifyAuditLogs 校验按照Seq排序的连续记录,prev是ls[0]之前的记录,从第一条开始校验时为nil.
+返回第一条校验失败的记录的Seq和原因
+*/
+func VerifyAuditLogs(prev *AuditLog, ls []*AuditLog) (badSeq int64, err error) {
+	for _, l := range ls {
+		var expectSeq int64 = 1
+		var prevHash common.Hash
+		if prev != nil {
+			expectSeq = prev.Seq + 1
+			prevHash = prev.Hash
+		}
+		switch {
+		case l.Seq != expectSeq:
+			return expectSeq, fmt.Errorf("audit log %d is missing", expectSeq)
+		case l.PrevHash != prevHash:
+			return l.Seq, fmt.Errorf("audit log %d does not link to the previous log", l.Seq)
+		case l.Hash != l.ComputeHash():
+			return l.Seq, fmt.Errorf("audit log %d has been modified", l.Seq)
+		}
+		prev = l
+	}
+	return 0, nil
+}
+
+func init() {
+	gob.Register(&AuditLog{})
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/gob"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// BatchTransferItem :
+// 批量转账中的一笔交易
+type BatchTransferItem struct {
+	TokenAddress   common.Address     `json:"token_address"`
+	TargetAddress  common.Address     `json:"target_address"`
+	Amount         *big.Int           `json:"amount"`
+	Data           string             `json:"data"`
+	LockSecretHash common.Hash        `json:"lock_secret_hash"` // 交易发出之后才有
+	Status         TransferStatusCode `json:"status"`
+	StatusMessage  string             `json:"status_message"`
+	Finished       bool               `json:"finished"`
+}
+
+// BatchTransfer :
+// 用户一次提交的批量转账,以及每一笔交易的状态
+type BatchTransfer struct {
+	Key          string               `json:"key" storm:"id"`
+	Concurrency  int                  `json:"concurrency"` // 同时进行的交易数
+	Items        []*BatchTransferItem `json:"items"`
+	SuccessCount int                  `json:"success_count"`
+	FailedCount  int                  `json:"failed_count"`
+	CreateTime   int64                `json:"create_time" storm:"index"`
+	FinishTime   int64                `json:"finish_time"` // 所有交易都结束之后才有
+	APITokenKey  string               `json:"-"`           // 通过有支付上限的API token发起时,没有发出的交易退还给它
+}
+
+// IsFinished :
+func (b *BatchTransfer) IsFinished() bool {
+	return b.FinishTime > 0
+}
+
+func init() {
+	gob.Register(&BatchTransfer{})
+}
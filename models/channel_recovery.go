@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/gob"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ChannelRecoveryStatus 从通道对方恢复数据的状态
+type ChannelRecoveryStatus string
+
+/* #nosec */
+const (
+	ChannelRecoveryStatusPending   ChannelRecoveryStatus = "pending"   // 等待对方回复,期间不能交易
+	ChannelRecoveryStatusRecovered ChannelRecoveryStatus = "recovered" // 已经用对方提供的数据恢复
+	ChannelRecoveryStatusAbandoned ChannelRecoveryStatus = "abandoned" // 用户放弃恢复,使用链上事件重建的状态
+)
+
+/*
+ChannelRecovery 数据库丢失以后,从通道对方恢复balance proof和锁的记录.
+ChainOurNonce和ChainPartnerNonce是链上记录的nonce,恢复的balance proof不能比它们旧,
+ChainNonceKnown为false表示还没有查到,这时候不能使用对方的回复
+*/
+type ChannelRecovery struct {
+	ChannelIdentifier common.Hash           `json:"channel_identifier" storm:"id"`
+	OpenBlockNumber   int64                 `json:"open_block_number"`
+	TokenAddress      common.Address        `json:"token_address"`
+	PartnerAddress    common.Address        `json:"partner_address"`
+	Status            ChannelRecoveryStatus `json:"status"`
+	StartTime         int64                 `json:"start_time"`
+	FinishTime        int64                 `json:"finish_time,omitempty"`
+	ChainOurNonce     uint64                `json:"chain_our_nonce"`
+	ChainPartnerNonce uint64                `json:"chain_partner_nonce"`
+	ChainNonceKnown   bool                  `json:"chain_nonce_known"`
+	OurNonce          uint64                `json:"our_nonce"`       // 恢复的自己的balance proof
+	PartnerNonce      uint64                `json:"partner_nonce"`   // 恢复的对方的balance proof
+	Locks             int                   `json:"locks"`           // 恢复的双方的锁
+	Error             string                `json:"error,omitempty"` // 最近一次对方回复的错误或者校验失败的原因
+}
+
+func init() {
+	gob.Register(&ChannelRecovery{})
+}
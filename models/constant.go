@@ -2,8 +2,8 @@ package models
 
 import "github.com/ethereum/go-ethereum/common"
 
-// DbVersion :
-const DbVersion = 1
+// DbVersion : 版本的升级步骤在Migrations中
+const DbVersion = 2
 
 // ChannelParticipantMap : used by BucketChannel
 type ChannelParticipantMap map[common.Hash][]byte
@@ -52,6 +52,21 @@ const (
 	BucketTXInfo                   = "TXInfo"
 	BucketSentTransferDetail       = "SentTransferDetail"
 	BucketChainEventRecord         = "ChainEventRecord"
+	BucketIdempotency              = "Idempotency"
+	BucketBatchTransfer            = "BatchTransfer"
+	BucketPaymentPlan              = "PaymentPlan"
+	BucketPaymentReceipt           = "PaymentReceipt"
+	BucketTransferMemo             = "TransferMemo"
+	BucketAPIToken                 = "APIToken"
+	BucketSpendingPolicy           = "SpendingPolicy"
+	BucketPendingApproval          = "PendingApproval"
+	BucketMediationPolicy          = "MediationPolicy"
+	BucketAuditLog                 = "AuditLog"
+	BucketChannelRecovery          = "ChannelRecovery"
+	BucketStateManagerSnapshot     = "StateManagerSnapshot"
+	BucketStateChangeLog           = "StateChangeLog"
+	BucketAckTime                  = "ackTime"
+	BucketIncomeAggregate          = "IncomeAggregate"
 )
 
 /*
@@ -63,6 +78,7 @@ const (
 	KeyCloseFlag      = "close"
 	KeyRegistry       = "registry"
 	KeySecretRegistry = "secretregistry"
+	KeyHealthCheck    = "healthcheck"
 
 	// keys of BucketBlockNumber
 	KeyBlockNumber     = "blocknumber"
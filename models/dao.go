@@ -86,6 +86,7 @@ type ExpiredLockDao interface {
 type DbStatusDao interface {
 	MarkDbOpenedStatus()
 	IsDbCrashedLastTime() bool
+	CheckWritable() error // 写入以后再读出来,数据库不能写的时候返回错误
 }
 
 // ContractStatusDao :
@@ -155,6 +156,7 @@ type TokenDao interface {
 type ReceivedTransferDao interface {
 	NewReceivedTransfer(blockNumber int64, channelIdentifier common.Hash, openBlockNumber int64, tokenAddr, fromAddr common.Address, nonce uint64, amount *big.Int, lockSecretHash common.Hash, data string) *ReceivedTransfer
 	GetReceivedTransfer(key string) (*ReceivedTransfer, error)
+	UpdateReceivedTransferData(key string, data string) error
 	GetReceivedTransferList(tokenAddress common.Address, fromBlock, toBlock, fromTime, toTime int64) (transfers []*ReceivedTransfer, err error)
 }
 
@@ -163,6 +165,7 @@ type SentTransferDetailDao interface {
 	NewSentTransferDetail(tokenAddress, target common.Address, amount *big.Int, data string, isDirect bool, lockSecretHash common.Hash)
 	UpdateSentTransferDetailStatus(tokenAddress common.Address, lockSecretHash common.Hash, status TransferStatusCode, statusMessage string, otherParams interface{}) (transfer *SentTransferDetail)
 	UpdateSentTransferDetailStatusMessage(tokenAddress common.Address, lockSecretHash common.Hash, statusMessage string) (transfer *SentTransferDetail)
+	UpdateSentTransferDetailPaymentPlan(tokenAddress common.Address, lockSecretHash common.Hash, planKey string, run int) (transfer *SentTransferDetail)
 	GetSentTransferDetail(tokenAddress common.Address, lockSecretHash common.Hash) (*SentTransferDetail, error)
 	GetSentTransferDetailList(tokenAddress common.Address, fromTime, toTime int64, fromBlock, toBlock int64) (transfers []*SentTransferDetail, err error)
 }
@@ -190,6 +193,113 @@ type ChainEventRecordDao interface {
 	MakeChainEventID(l *types.Log) ChainEventID
 }
 
+// IdempotencyDao :
+type IdempotencyDao interface {
+	SaveIdempotencyRecord(r *IdempotencyRecord) error
+	GetIdempotencyRecord(key string) (r *IdempotencyRecord, err error)
+	RemoveIdempotencyRecordBefore(createTime int64) error
+}
+
+// BatchTransferDao :
+type BatchTransferDao interface {
+	SaveBatchTransfer(b *BatchTransfer) error
+	GetBatchTransfer(key string) (b *BatchTransfer, err error)
+	GetBatchTransferList() (bs []*BatchTransfer, err error)
+}
+
+// PaymentPlanDao :
+type PaymentPlanDao interface {
+	SavePaymentPlan(p *PaymentPlan) error
+	GetPaymentPlan(key string) (p *PaymentPlan, err error)
+	GetPaymentPlanList() (ps []*PaymentPlan, err error)
+}
+
+// PaymentReceiptDao :
+type PaymentReceiptDao interface {
+	SavePaymentReceipt(r *PaymentReceipt) error
+	GetPaymentReceipt(tokenAddress common.Address, lockSecretHash common.Hash) (r *PaymentReceipt, err error)
+}
+
+// TransferMemoDao :
+type TransferMemoDao interface {
+	SaveTransferMemo(m *TransferMemo) error
+	GetTransferMemo(memoID common.Hash, sender common.Address) (m *TransferMemo, err error)
+}
+
+// APITokenDao :
+type APITokenDao interface {
+	SaveAPIToken(t *APIToken) error
+	GetAPIToken(key string) (t *APIToken, err error)
+	GetAPITokenList() (ts []*APIToken, err error)
+}
+
+// SpendingPolicyDao :
+type SpendingPolicyDao interface {
+	SaveSpendingPolicy(p *SpendingPolicy) error
+	GetSpendingPolicy(tokenAddress common.Address) (p *SpendingPolicy, err error)
+	GetSpendingPolicyList() (ps []*SpendingPolicy, err error)
+	RemoveSpendingPolicy(tokenAddress common.Address) error
+	SavePendingApproval(a *PendingApproval) error
+	GetPendingApproval(key string) (a *PendingApproval, err error)
+	GetPendingApprovalList() (as []*PendingApproval, err error)
+}
+
+// MediationPolicyDao :
+type MediationPolicyDao interface {
+	SaveMediationPolicy(p *MediationPolicy) error
+	GetMediationPolicy(tokenAddress common.Address) (p *MediationPolicy, err error)
+	GetMediationPolicyList() (ps []*MediationPolicy, err error)
+	RemoveMediationPolicy(tokenAddress common.Address) error
+}
+
+// AuditLogDao :
+type AuditLogDao interface {
+	AppendAuditLog(l *AuditLog) error
+	GetLastAuditLog() (l *AuditLog, err error)
+	GetAuditLogList(fromSeq, toSeq int64) (ls []*AuditLog, err error)
+}
+
+// ChannelRecoveryDao :
+type ChannelRecoveryDao interface {
+	SaveChannelRecovery(r *ChannelRecovery) error
+	GetChannelRecovery(channelIdentifier common.Hash) (r *ChannelRecovery, err error)
+	GetChannelRecoveryList() (rs []*ChannelRecovery, err error)
+}
+
+// StateManagerLogDao :
+type StateManagerLogDao interface {
+	AddStateChangeLog(l *StateChangeLog) error
+	GetStateChangeLogs(key string) (ls []*StateChangeLog, err error) // 按照Seq排序
+	SaveStateManagerSnapshot(s *StateManagerSnapshot) error          // 同时删除快照已经包含的状态变化
+	GetStateManagerSnapshotList() (ss []*StateManagerSnapshot, err error)
+	RemoveStateManagerLog(key string) error
+}
+
+/*
+RetentionDao 归档历史记录,归档的记录已经导出到文件,这里只负责删除.
+收款和手续费记录删除的同时累加收益汇总,必须在一个事务中完成
+*/
+type RetentionDao interface {
+	ArchiveIncomeRecords(receivedTransferKeys []string, feeChargeRecordKeys []common.Hash, aggregates []*IncomeAggregate) error
+	GetIncomeAggregateList(tokenAddress common.Address, fromTime, toTime int64) (as []*IncomeAggregate, err error)
+	RemoveSentTransferDetails(keys []string) error
+	RemoveTXInfos(txHashes []common.Hash) error
+	GetAckList(before int64) (as []*AckRecord, err error)
+	RemoveAcks(echoHashes []common.Hash) error
+	GetTransferMemoList(before int64) (ms []*TransferMemo, err error) // CreateTime < before的附言
+	RemoveTransferMemos(keys []string) error
+	GetChainEventRecordList(blockNumber uint64) (rs []*ChainEventRecord, err error) // BlockNumber <= blockNumber的记录
+	Compact() error
+}
+
+//ListDao 按照ListQuery过滤,排序和分页的列表,nextCursor为空表示没有下一页了
+type ListDao interface {
+	QuerySentTransferDetails(lq *ListQuery) (transfers []*SentTransferDetail, nextCursor string, err error)
+	QueryReceivedTransfers(lq *ListQuery) (transfers []*ReceivedTransfer, nextCursor string, err error)
+	QueryFeeChargeRecords(lq *ListQuery) (records []*FeeChargeRecord, nextCursor string, err error)
+	QueryTXInfos(lq *ListQuery) (list []*TXInfo, nextCursor string, err error)
+}
+
 // Dao :
 type Dao interface {
 	AckDao
@@ -213,6 +323,19 @@ type Dao interface {
 	TXInfoDao
 	SentTransferDetailDao
 	ChainEventRecordDao
+	IdempotencyDao
+	BatchTransferDao
+	PaymentPlanDao
+	PaymentReceiptDao
+	TransferMemoDao
+	APITokenDao
+	SpendingPolicyDao
+	MediationPolicyDao
+	AuditLogDao
+	ChannelRecoveryDao
+	StateManagerLogDao
+	RetentionDao
+	ListDao
 
 	StartTx() (tx TX)
 	CloseDB()
@@ -0,0 +1,71 @@
+package daotest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPITokenDao(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	tk := &models.APIToken{
+		Key:         models.NewAPITokenKey("secret"),
+		Name:        "shop",
+		Scope:       models.APITokenScopePay,
+		SpendingCap: big.NewInt(100),
+		Charges: []*models.APITokenCharge{
+			{TokenAddress: utils.NewRandomAddress(), LockSecretHash: utils.NewRandomHash(), Amount: big.NewInt(10)},
+		},
+	}
+	err := dao.SaveAPIToken(tk)
+	assert.Nil(t, err)
+	tk2, err := dao.GetAPIToken(models.NewAPITokenKey("secret"))
+	assert.Nil(t, err)
+	assert.Equal(t, tk.Name, tk2.Name)
+	assert.Equal(t, tk.Scope, tk2.Scope)
+	assert.Equal(t, tk.Charges, tk2.Charges)
+	ts, err := dao.GetAPITokenList()
+	assert.Nil(t, err)
+	assert.Len(t, ts, 1)
+	_, err = dao.GetAPIToken(models.NewAPITokenKey("other"))
+	assert.Equal(t, rerr.ErrNotFound, err)
+}
+
+func TestAPITokenSpend(t *testing.T) {
+	tk := &models.APIToken{
+		Name:        "shop",
+		Scope:       models.APITokenScopePay,
+		SpendingCap: big.NewInt(100),
+	}
+	token1 := utils.NewRandomAddress()
+	token2 := utils.NewRandomAddress()
+	spend := func(tokenAddress common.Address, amount int64) error {
+		return tk.Spend(map[common.Address]*big.Int{tokenAddress: big.NewInt(amount)})
+	}
+	assert.Nil(t, spend(token1, 60))
+	assert.NotNil(t, spend(token1, 50))
+	assert.Equal(t, int64(60), tk.Spent[token1].Int64())
+	//任何一种token超过上限都不记录
+	err := tk.Spend(map[common.Address]*big.Int{token1: big.NewInt(10), token2: big.NewInt(101)})
+	assert.NotNil(t, err)
+	assert.Equal(t, int64(60), tk.Spent[token1].Int64())
+	assert.Nil(t, tk.Spent[token2])
+	assert.Nil(t, spend(token1, 40))
+	//每种token分别计算
+	assert.Nil(t, spend(token2, 100))
+	tk.Refund(token1, big.NewInt(30))
+	assert.Equal(t, int64(70), tk.Spent[token1].Int64())
+	assert.Nil(t, spend(token1, 30))
+
+	assert.True(t, models.APITokenScopeAdmin.Allows(models.APITokenScopePay))
+	assert.True(t, models.APITokenScopePay.Allows(models.APITokenScopeRead))
+	assert.False(t, models.APITokenScopeRead.Allows(models.APITokenScopePay))
+	assert.False(t, models.APITokenScope("root").Allows(models.APITokenScopeRead))
+}
@@ -0,0 +1,63 @@
+package daotest
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLogDao(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	_, err := dao.GetLastAuditLog()
+	assert.Equal(t, rerr.ErrNotFound, err)
+	var prev *models.AuditLog
+	for i := int64(1); i <= 300; i++ {
+		l := &models.AuditLog{
+			Seq:            i,
+			Time:           i,
+			Source:         models.AuditSourceREST,
+			Caller:         "token:shop",
+			Operation:      "POST /api/1/transfers",
+			LockSecretHash: utils.NewRandomHash(),
+		}
+		if prev != nil {
+			l.PrevHash = prev.Hash
+		}
+		l.Hash = l.ComputeHash()
+		err = dao.AppendAuditLog(l)
+		assert.Nil(t, err)
+		prev = l
+	}
+	//只能追加
+	err = dao.AppendAuditLog(&models.AuditLog{Seq: 3})
+	assert.NotNil(t, err)
+	last, err := dao.GetLastAuditLog()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 300, last.Seq)
+	ls, err := dao.GetAuditLogList(1, 300)
+	assert.Nil(t, err)
+	assert.Len(t, ls, 300)
+	badSeq, err := models.VerifyAuditLogs(nil, ls)
+	assert.Nil(t, err)
+	ls, err = dao.GetAuditLogList(256, 258)
+	assert.Nil(t, err)
+	if assert.Len(t, ls, 3) {
+		assert.EqualValues(t, 256, ls[0].Seq)
+	}
+
+	all, _ := dao.GetAuditLogList(1, 300)
+	all[5].Caller = "anonymous"
+	badSeq, err = models.VerifyAuditLogs(nil, all)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, 6, badSeq)
+	all, _ = dao.GetAuditLogList(1, 300)
+	all = append(all[:9], all[10:]...)
+	badSeq, err = models.VerifyAuditLogs(nil, all)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, 10, badSeq)
+}
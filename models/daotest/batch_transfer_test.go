@@ -0,0 +1,59 @@
+package daotest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchTransfer(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	b := &models.BatchTransfer{
+		Key:         utils.NewRandomHash().String(),
+		Concurrency: 2,
+		Items: []*models.BatchTransferItem{
+			{
+				TokenAddress:  utils.NewRandomAddress(),
+				TargetAddress: utils.NewRandomAddress(),
+				Amount:        big.NewInt(10),
+				Data:          "first",
+			},
+			{
+				TokenAddress:  utils.NewRandomAddress(),
+				TargetAddress: utils.NewRandomAddress(),
+				Amount:        big.NewInt(20),
+			},
+		},
+		CreateTime: 100,
+	}
+	err := dao.SaveBatchTransfer(b)
+	assert.Nil(t, err)
+	b2, err := dao.GetBatchTransfer(b.Key)
+	assert.Nil(t, err)
+	assert.EqualValues(t, b, b2)
+
+	// update item status
+	b.Items[0].LockSecretHash = utils.NewRandomHash()
+	b.Items[0].Status = models.TransferStatusSuccess
+	b.Items[0].Finished = true
+	b.SuccessCount = 1
+	err = dao.SaveBatchTransfer(b)
+	assert.Nil(t, err)
+	b2, err = dao.GetBatchTransfer(b.Key)
+	assert.Nil(t, err)
+	assert.EqualValues(t, b, b2)
+	assert.False(t, b2.IsFinished())
+
+	bs, err := dao.GetBatchTransferList()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, len(bs))
+
+	_, err = dao.GetBatchTransfer(utils.NewRandomHash().String())
+	assert.Equal(t, rerr.ErrNotFound, err)
+}
@@ -0,0 +1,51 @@
+package daotest
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelRecoveryDao(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	r := &models.ChannelRecovery{
+		ChannelIdentifier: utils.NewRandomHash(),
+		OpenBlockNumber:   3,
+		TokenAddress:      utils.NewRandomAddress(),
+		PartnerAddress:    utils.NewRandomAddress(),
+		Status:            models.ChannelRecoveryStatusPending,
+		StartTime:         10,
+		ChainPartnerNonce: 2,
+		ChainNonceKnown:   true,
+	}
+	err := dao.SaveChannelRecovery(r)
+	assert.Nil(t, err)
+	r2, err := dao.GetChannelRecovery(r.ChannelIdentifier)
+	assert.Nil(t, err)
+	assert.EqualValues(t, r, r2)
+
+	r.Status = models.ChannelRecoveryStatusRecovered
+	r.PartnerNonce = 5
+	err = dao.SaveChannelRecovery(r)
+	assert.Nil(t, err)
+	err = dao.SaveChannelRecovery(&models.ChannelRecovery{
+		ChannelIdentifier: utils.NewRandomHash(),
+		Status:            models.ChannelRecoveryStatusPending,
+	})
+	assert.Nil(t, err)
+	rs, err := dao.GetChannelRecoveryList()
+	assert.Nil(t, err)
+	assert.Len(t, rs, 2)
+	for _, r3 := range rs {
+		if r3.ChannelIdentifier == r.ChannelIdentifier {
+			assert.EqualValues(t, r, r3)
+		}
+	}
+	_, err = dao.GetChannelRecovery(utils.NewRandomHash())
+	assert.Equal(t, rerr.ErrNotFound, err)
+}
@@ -43,6 +43,7 @@ func TestChannel1(t *testing.T) {
 		Key:                 h[:],
 		TokenAddressBytes:   a1[:],
 		PartnerAddressBytes: a2[:],
+		ClosingAddress:      a2,
 	}
 	c := ch1
 	h2 := utils.NewRandomHash()
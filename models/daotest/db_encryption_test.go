@@ -0,0 +1,151 @@
+package daotest
+
+import (
+	"math/big"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/models/gkvdb"
+	"github.com/SmartMeshFoundation/Photon/models/sqlitedb"
+	"github.com/SmartMeshFoundation/Photon/models/stormdb"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type encryptedDao interface {
+	models.Dao
+	IsEncrypted() bool
+	SetEncryptionPassword(password string) error
+}
+
+func TestDBEncryption(t *testing.T) {
+	dbPath := path.Join(os.TempDir(), "testencrypted.db")
+	os.RemoveAll(dbPath)
+	defer os.RemoveAll(dbPath)
+	testDBEncryption(t, func(password string, encrypt bool) (encryptedDao, error) {
+		return stormdb.OpenDbWithPassword(dbPath, password, encrypt)
+	})
+}
+
+func TestSQLiteDBEncryption(t *testing.T) {
+	dbPath := path.Join(os.TempDir(), "testencrypted.sqlite")
+	os.RemoveAll(dbPath)
+	defer func() {
+		os.RemoveAll(dbPath)
+		os.RemoveAll(dbPath + "-wal")
+		os.RemoveAll(dbPath + "-shm")
+	}()
+	testDBEncryption(t, func(password string, encrypt bool) (encryptedDao, error) {
+		return sqlitedb.OpenDbWithPassword(dbPath, password, encrypt)
+	})
+}
+
+func testDBEncryption(t *testing.T, open func(password string, encrypt bool) (encryptedDao, error)) {
+	dao, err := open("", false)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.False(t, dao.IsEncrypted())
+	token := utils.NewRandomAddress()
+	err = dao.SaveSpendingPolicy(&models.SpendingPolicy{TokenAddress: token, MaxPerDay: big.NewInt(10)})
+	assert.Nil(t, err)
+	echoHash := utils.NewRandomHash()
+	dao.SaveAckNoTx(echoHash, []byte("ack"))
+	dao.NewDeliveredChainEvent("event1", 3)
+	dao.CloseDB()
+
+	//没有加密的数据库迁移
+	dao, err = open("123", true)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, dao.IsEncrypted())
+	dao.CloseDB()
+
+	_, err = open("", false)
+	assert.Equal(t, rerr.ErrDBEncryption.ErrorCode, err.(rerr.StandardError).ErrorCode)
+	_, err = open("456", false)
+	assert.Equal(t, rerr.ErrDBEncryption.ErrorCode, err.(rerr.StandardError).ErrorCode)
+
+	dao, err = open("123", false)
+	if !assert.Nil(t, err) {
+		return
+	}
+	p, err := dao.GetSpendingPolicy(token)
+	assert.Nil(t, err)
+	assert.EqualValues(t, big.NewInt(10), p.MaxPerDay)
+	assert.Equal(t, []byte("ack"), dao.GetAck(echoHash))
+	blockNumber, delivered := dao.CheckChainEventDelivered("event1")
+	assert.True(t, delivered)
+	assert.EqualValues(t, 3, blockNumber)
+	err = dao.SaveSpendingPolicy(&models.SpendingPolicy{TokenAddress: utils.NewRandomAddress()})
+	assert.Nil(t, err)
+
+	//更换key
+	err = dao.SetEncryptionPassword("456")
+	assert.Nil(t, err)
+	dao.CloseDB()
+	_, err = open("123", false)
+	assert.NotNil(t, err)
+	dao, err = open("456", false)
+	if !assert.Nil(t, err) {
+		return
+	}
+	ps, err := dao.GetSpendingPolicyList()
+	assert.Nil(t, err)
+	assert.Len(t, ps, 2)
+
+	//取消加密
+	err = dao.SetEncryptionPassword("")
+	assert.Nil(t, err)
+	dao.CloseDB()
+	dao, err = open("", false)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.False(t, dao.IsEncrypted())
+	assert.Equal(t, []byte("ack"), dao.GetAck(echoHash))
+	p, err = dao.GetSpendingPolicy(token)
+	assert.Nil(t, err)
+	assert.EqualValues(t, big.NewInt(10), p.MaxPerDay)
+	dao.CloseDB()
+}
+
+func TestGkvDBEncryption(t *testing.T) {
+	dbPath := path.Join(os.TempDir(), "testencrypted.gkv")
+	os.RemoveAll(dbPath)
+	defer os.RemoveAll(dbPath)
+	dao, err := gkvdb.OpenDb(dbPath)
+	if !assert.Nil(t, err) {
+		return
+	}
+	var tokens []common.Address
+	for i := 0; i < 100; i++ {
+		token := utils.NewRandomAddress()
+		tokens = append(tokens, token)
+		err = dao.SaveSpendingPolicy(&models.SpendingPolicy{TokenAddress: token, MaxPerDay: big.NewInt(int64(i))})
+		assert.Nil(t, err)
+	}
+	check := func() {
+		for i, token := range tokens {
+			p, err := dao.GetSpendingPolicy(token)
+			if assert.Nil(t, err) {
+				assert.EqualValues(t, big.NewInt(int64(i)), p.MaxPerDay)
+			}
+		}
+	}
+	//写入的同时后台在同步binlog,每一个值都必须被重新加密
+	for _, password := range []string{"123", "456", ""} {
+		err = dao.SetEncryptionPassword(password)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, password != "", dao.IsEncrypted())
+		check()
+	}
+	dao.CloseDB()
+}
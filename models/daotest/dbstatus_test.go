@@ -0,0 +1,19 @@
+package daotest
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+)
+
+func TestCheckWritable(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	err := dao.CheckWritable()
+	if err != nil {
+		t.Error(err)
+	}
+	dao.CloseDB()
+	if dao.CheckWritable() == nil {
+		t.Error("closed db should not be writable")
+	}
+}
@@ -0,0 +1,60 @@
+package daotest
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyRecord(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	r1 := &models.IdempotencyRecord{
+		Key:            "key1",
+		Operation:      models.IdempotencyOperationTransfer,
+		RequestHash:    utils.NewRandomHash(),
+		TokenAddress:   utils.NewRandomAddress(),
+		LockSecretHash: utils.NewRandomHash(),
+		CreateTime:     100,
+	}
+	r2 := &models.IdempotencyRecord{
+		Key:               "key2",
+		Operation:         models.IdempotencyOperationDeposit,
+		TokenAddress:      utils.NewRandomAddress(),
+		PartnerAddress:    utils.NewRandomAddress(),
+		ChannelIdentifier: utils.NewRandomHash(),
+		CreateTime:        200,
+	}
+	err := dao.SaveIdempotencyRecord(r1)
+	assert.Nil(t, err)
+	err = dao.SaveIdempotencyRecord(r2)
+	assert.Nil(t, err)
+
+	r, err := dao.GetIdempotencyRecord("key1")
+	assert.Nil(t, err)
+	assert.EqualValues(t, r1, r)
+
+	_, err = dao.GetIdempotencyRecord("key3")
+	assert.Equal(t, rerr.ErrNotFound, err)
+
+	// update tx hash,tx failed
+	r2.TXHash = utils.NewRandomHash()
+	r2.ErrorCode = rerr.ErrTxReceiptStatus.ErrorCode
+	r2.ErrorMsg = rerr.ErrTxReceiptStatus.ErrorMsg
+	err = dao.SaveIdempotencyRecord(r2)
+	assert.Nil(t, err)
+	r, err = dao.GetIdempotencyRecord("key2")
+	assert.Nil(t, err)
+	assert.EqualValues(t, r2, r)
+
+	err = dao.RemoveIdempotencyRecordBefore(150)
+	assert.Nil(t, err)
+	_, err = dao.GetIdempotencyRecord("key1")
+	assert.Equal(t, rerr.ErrNotFound, err)
+	_, err = dao.GetIdempotencyRecord("key2")
+	assert.Nil(t, err)
+}
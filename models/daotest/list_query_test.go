@@ -0,0 +1,113 @@
+package daotest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryFeeChargeRecords(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	token := utils.NewRandomAddress()
+	from := utils.NewRandomAddress()
+	for i := 1; i <= 5; i++ {
+		r := &models.FeeChargeRecord{
+			Key:            utils.NewRandomHash(),
+			LockSecretHash: utils.NewRandomHash(),
+			TokenAddress:   token,
+			TransferFrom:   utils.NewRandomAddress(),
+			TransferAmount: big.NewInt(int64(i * 10)),
+			Fee:            big.NewInt(1),
+			Timestamp:      int64(1000 + i),
+		}
+		if i%2 == 1 {
+			r.TransferFrom = from
+		}
+		err := dao.SaveFeeChargeRecord(r)
+		assert.Nil(t, err)
+	}
+	//升序翻页
+	lq := &models.ListQuery{TokenAddress: token, Limit: 2}
+	var timestamps []int64
+	for i := 0; i < 3; i++ {
+		rs, next, err := dao.QueryFeeChargeRecords(lq)
+		assert.Nil(t, err)
+		for _, r := range rs {
+			timestamps = append(timestamps, r.Timestamp)
+		}
+		if next == "" {
+			break
+		}
+		lq.Cursor = next
+	}
+	assert.EqualValues(t, []int64{1001, 1002, 1003, 1004, 1005}, timestamps)
+	//倒序和过滤
+	lq = &models.ListQuery{TokenAddress: token, Initiator: from, MinAmount: big.NewInt(20), Desc: true, Limit: 1}
+	rs, next, err := dao.QueryFeeChargeRecords(lq)
+	assert.Nil(t, err)
+	if assert.Len(t, rs, 1) {
+		assert.EqualValues(t, 1005, rs[0].Timestamp)
+	}
+	lq.Cursor = next
+	rs, next, err = dao.QueryFeeChargeRecords(lq)
+	assert.Nil(t, err)
+	if assert.Len(t, rs, 1) {
+		assert.EqualValues(t, 1003, rs[0].Timestamp)
+	}
+	assert.Equal(t, "", next)
+	lq.Cursor = "invalid"
+	_, _, err = dao.QueryFeeChargeRecords(lq)
+	assert.NotNil(t, err)
+}
+
+func TestQueryTransfers(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	token := utils.NewRandomAddress()
+	channel := utils.NewRandomHash()
+	for i := 1; i <= 3; i++ {
+		rt := dao.NewReceivedTransfer(int64(i), channel, 1, token, utils.NewRandomAddress(), uint64(i), big.NewInt(int64(i)), utils.NewRandomHash(), "")
+		assert.NotNil(t, rt)
+	}
+	//同一秒内保存的记录按照key排序
+	lq := &models.ListQuery{TokenAddress: token, ChannelIdentifier: channel, Limit: 2}
+	rts, next, err := dao.QueryReceivedTransfers(lq)
+	assert.Nil(t, err)
+	assert.Len(t, rts, 2)
+	assert.NotEqual(t, "", next)
+	lq.Cursor = next
+	rts2, next, err := dao.QueryReceivedTransfers(lq)
+	assert.Nil(t, err)
+	assert.Len(t, rts2, 1)
+	assert.Equal(t, "", next)
+	keys := map[string]bool{}
+	for _, rt := range append(rts, rts2...) {
+		keys[rt.Key] = true
+	}
+	assert.Len(t, keys, 3)
+	rts, _, err = dao.QueryReceivedTransfers(&models.ListQuery{TokenAddress: token, FromBlock: 2, MaxAmount: big.NewInt(2)})
+	assert.Nil(t, err)
+	if assert.Len(t, rts, 1) {
+		assert.EqualValues(t, 2, rts[0].BlockNumber)
+	}
+
+	target := utils.NewRandomAddress()
+	lockSecretHash := utils.NewRandomHash()
+	dao.NewSentTransferDetail(token, target, big.NewInt(10), "", false, lockSecretHash)
+	dao.NewSentTransferDetail(token, utils.NewRandomAddress(), big.NewInt(10), "", false, utils.NewRandomHash())
+	dao.UpdateSentTransferDetailStatus(token, lockSecretHash, models.TransferStatusSuccess, "", nil)
+	sts, next, err := dao.QuerySentTransferDetails(&models.ListQuery{TokenAddress: token, Target: target, Status: "3,4", Limit: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, "", next)
+	if assert.Len(t, sts, 1) {
+		assert.Equal(t, models.TransferStatusCode(models.TransferStatusSuccess), sts[0].Status)
+	}
+	sts, _, err = dao.QuerySentTransferDetails(&models.ListQuery{TokenAddress: token, Status: "0"})
+	assert.Nil(t, err)
+	assert.Len(t, sts, 1)
+}
@@ -0,0 +1,57 @@
+package daotest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMediationPolicyDao(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	blocked := utils.NewRandomAddress()
+	p := &models.MediationPolicy{
+		TokenAddress:              utils.NewRandomAddress(),
+		BlockedPayers:             []common.Address{blocked},
+		MaxAmount:                 big.NewInt(100),
+		MaxLocksPerChannel:        3,
+		MinRevealTimeoutMargin:    5,
+		MaxLockedAmountPerChannel: big.NewInt(300),
+	}
+	err := dao.SaveMediationPolicy(p)
+	assert.Nil(t, err)
+	p2, err := dao.GetMediationPolicy(p.TokenAddress)
+	assert.Nil(t, err)
+	assert.EqualValues(t, p, p2)
+	assert.False(t, p2.IsPayerAllowed(blocked))
+	assert.True(t, p2.IsPayerAllowed(utils.NewRandomAddress()))
+	assert.True(t, p2.IsNextHopAllowed(blocked))
+	assert.False(t, p2.IsAmountAllowed(big.NewInt(101)))
+	assert.True(t, p2.IsLocksAllowed(3))
+	assert.False(t, p2.IsLocksAllowed(4))
+	assert.True(t, p2.IsLockedAmountAllowed(big.NewInt(300)))
+	assert.False(t, p2.IsLockedAmountAllowed(big.NewInt(301)))
+
+	err = dao.SaveMediationPolicy(&models.MediationPolicy{
+		AllowedNextHops: []common.Address{blocked},
+	})
+	assert.Nil(t, err)
+	ps, err := dao.GetMediationPolicyList()
+	assert.Nil(t, err)
+	assert.Len(t, ps, 2)
+	d, err := dao.GetMediationPolicy(utils.EmptyAddress)
+	assert.Nil(t, err)
+	assert.True(t, d.IsNextHopAllowed(blocked))
+	assert.False(t, d.IsNextHopAllowed(utils.NewRandomAddress()))
+
+	err = dao.RemoveMediationPolicy(p.TokenAddress)
+	assert.Nil(t, err)
+	_, err = dao.GetMediationPolicy(p.TokenAddress)
+	assert.Equal(t, rerr.ErrNotFound, err)
+}
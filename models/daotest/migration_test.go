@@ -0,0 +1,111 @@
+package daotest
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/models/sqlitedb"
+	"github.com/SmartMeshFoundation/Photon/models/stormdb"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type migratedDao interface {
+	models.Dao
+	GetDbVersion() (int, error)
+	Migrate(dryRun bool) (*models.MigrationReport, error)
+}
+
+func TestMigration(t *testing.T) {
+	dbPath := path.Join(os.TempDir(), "testmigration.db")
+	removeMigrationDB(dbPath)
+	defer removeMigrationDB(dbPath)
+	testMigration(t, func(migrate bool) (migratedDao, error) {
+		if migrate {
+			return stormdb.OpenDb(dbPath)
+		}
+		return stormdb.OpenDbForMigration(dbPath, "")
+	}, dbPath)
+}
+
+func TestSQLiteMigration(t *testing.T) {
+	dbPath := path.Join(os.TempDir(), "testmigration.sqlite")
+	removeMigrationDB(dbPath)
+	defer removeMigrationDB(dbPath)
+	testMigration(t, func(migrate bool) (migratedDao, error) {
+		if migrate {
+			return sqlitedb.OpenDb(dbPath)
+		}
+		return sqlitedb.OpenDbForMigration(dbPath, "")
+	}, dbPath)
+}
+
+func removeMigrationDB(dbPath string) {
+	files, _ := filepath.Glob(dbPath + "*")
+	for _, f := range files {
+		os.RemoveAll(f)
+	}
+}
+
+func testMigration(t *testing.T, open func(migrate bool) (migratedDao, error), dbPath string) {
+	dao, err := open(true)
+	if !assert.Nil(t, err) {
+		return
+	}
+	ver, err := dao.GetDbVersion()
+	assert.Nil(t, err)
+	assert.Equal(t, models.DbVersion, ver)
+	//模拟版本1的数据库,ack没有保存时间
+	echoHash := utils.NewRandomHash()
+	tx := dao.StartTx()
+	err = tx.Set(models.BucketAck, echoHash[:], []byte("ack"))
+	assert.Nil(t, err)
+	err = tx.Set(models.BucketMeta, models.KeyVersion, 1)
+	assert.Nil(t, err)
+	err = tx.Commit()
+	assert.Nil(t, err)
+	dao.CloseDB()
+
+	dao, err = open(false)
+	if !assert.Nil(t, err) {
+		return
+	}
+	report, err := dao.Migrate(true)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.FromVersion)
+	assert.Equal(t, "", report.BackupFile)
+	if assert.Len(t, report.Steps, 1) {
+		assert.Equal(t, 1, report.Steps[0].Changed)
+	}
+	//dry run不修改数据库
+	ver, err = dao.GetDbVersion()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, ver)
+	as, err := dao.GetAckList(time.Now().Unix() + 100)
+	assert.Nil(t, err)
+	assert.Len(t, as, 0)
+	dao.CloseDB()
+
+	//打开的时候自动备份并升级
+	dao, err = open(true)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer dao.CloseDB()
+	ver, err = dao.GetDbVersion()
+	assert.Nil(t, err)
+	assert.Equal(t, models.DbVersion, ver)
+	as, err = dao.GetAckList(time.Now().Unix() + 100)
+	assert.Nil(t, err)
+	assert.Len(t, as, 1)
+	assert.Equal(t, []byte("ack"), dao.GetAck(echoHash))
+	backups, _ := filepath.Glob(dbPath + ".v1-*.bak")
+	assert.Len(t, backups, 1)
+	report, err = dao.Migrate(false)
+	assert.Nil(t, err)
+	assert.Len(t, report.Steps, 0)
+}
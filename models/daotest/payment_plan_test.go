@@ -0,0 +1,86 @@
+package daotest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaymentPlan(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	p := &models.PaymentPlan{
+		Key:           utils.NewRandomHash().String(),
+		TokenAddress:  utils.NewRandomAddress(),
+		TargetAddress: utils.NewRandomAddress(),
+		Amount:        big.NewInt(10),
+		StartTime:     100,
+		Interval:      60,
+		NextTime:      100,
+		Status:        models.PaymentPlanStatusActive,
+	}
+	err := dao.SavePaymentPlan(p)
+	assert.Nil(t, err)
+	p2, err := dao.GetPaymentPlan(p.Key)
+	assert.Nil(t, err)
+	assert.EqualValues(t, p, p2)
+
+	p.Times++
+	p.AddAttempt(&models.PaymentPlanAttempt{Time: 100, LockSecretHash: utils.NewRandomHash()})
+	err = dao.SavePaymentPlan(p)
+	assert.Nil(t, err)
+	ps, err := dao.GetPaymentPlanList()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, len(ps))
+	assert.EqualValues(t, p, ps[0])
+
+	_, err = dao.GetPaymentPlan(utils.NewRandomHash().String())
+	assert.Equal(t, rerr.ErrNotFound, err)
+}
+
+func TestPaymentPlanScheduleNext(t *testing.T) {
+	p := &models.PaymentPlan{
+		Interval: 60,
+		NextTime: 100,
+		EndTime:  390,
+		Status:   models.PaymentPlanStatusActive,
+	}
+	assert.True(t, p.ScheduleNext(100))
+	assert.EqualValues(t, 160, p.NextTime)
+	// 离线期间错过的支付不补
+	assert.True(t, p.ScheduleNext(290))
+	assert.EqualValues(t, 340, p.NextTime)
+	assert.False(t, p.ScheduleNext(340))
+	assert.EqualValues(t, models.PaymentPlanStatusFinished, p.Status)
+
+	p = &models.PaymentPlan{
+		Interval: 60,
+		NextTime: 100,
+		MaxTimes: 2,
+		Times:    1,
+		Status:   models.PaymentPlanStatusActive,
+	}
+	assert.True(t, p.ScheduleNext(100))
+	p.Times++
+	assert.False(t, p.ScheduleNext(160))
+
+	// 只支付一次
+	p = &models.PaymentPlan{
+		NextTime: 100,
+		Times:    1,
+		Status:   models.PaymentPlanStatusActive,
+	}
+	assert.False(t, p.ScheduleNext(100))
+
+	p = &models.PaymentPlan{}
+	for i := 0; i < models.MaxPaymentPlanAttempts+10; i++ {
+		p.AddAttempt(&models.PaymentPlanAttempt{Time: int64(i)})
+	}
+	assert.EqualValues(t, models.MaxPaymentPlanAttempts, len(p.Attempts))
+	assert.EqualValues(t, 10, p.Attempts[0].Time)
+}
@@ -0,0 +1,72 @@
+package daotest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPaymentReceipt(t *testing.T) *models.PaymentReceipt {
+	initiatorKey, _ := crypto.GenerateKey()
+	targetKey, _ := crypto.GenerateKey()
+	secret := utils.NewRandomHash()
+	lockSecretHash := utils.ShaSecret(secret[:])
+	amount := big.NewInt(100)
+	sr := encoding.NewSecretRequest(lockSecretHash, amount)
+	err := sr.Sign(targetKey, sr)
+	assert.Nil(t, err)
+	r := &models.PaymentReceipt{
+		TokenAddress:     utils.NewRandomAddress(),
+		InitiatorAddress: crypto.PubkeyToAddress(initiatorKey.PublicKey),
+		TargetAddress:    crypto.PubkeyToAddress(targetKey.PublicKey),
+		Amount:           amount,
+		LockSecretHash:   lockSecretHash,
+		Secret:           secret,
+		SecretRequest:    sr.Pack(),
+		Data:             "order 1",
+		Timestamp:        100,
+	}
+	err = r.Sign(initiatorKey)
+	assert.Nil(t, err)
+	return r
+}
+
+func TestPaymentReceiptDao(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	r := newTestPaymentReceipt(t)
+	err := dao.SavePaymentReceipt(r)
+	assert.Nil(t, err)
+	r2, err := dao.GetPaymentReceipt(r.TokenAddress, r.LockSecretHash)
+	assert.Nil(t, err)
+	assert.EqualValues(t, r, r2)
+	_, err = dao.GetPaymentReceipt(r.TokenAddress, utils.NewRandomHash())
+	assert.Equal(t, rerr.ErrNotFound, err)
+}
+
+func TestPaymentReceiptVerify(t *testing.T) {
+	r := newTestPaymentReceipt(t)
+	assert.Nil(t, r.Verify())
+
+	r.Amount = big.NewInt(200)
+	assert.NotNil(t, r.Verify())
+
+	r = newTestPaymentReceipt(t)
+	r.Data = "order 2"
+	assert.NotNil(t, r.Verify())
+
+	r = newTestPaymentReceipt(t)
+	r.TargetAddress = utils.NewRandomAddress()
+	assert.NotNil(t, r.Verify())
+
+	r = newTestPaymentReceipt(t)
+	r.Secret = utils.NewRandomHash()
+	assert.NotNil(t, r.Verify())
+}
@@ -0,0 +1,130 @@
+package daotest
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveIncomeRecords(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	token := utils.NewRandomAddress()
+	rt := dao.NewReceivedTransfer(1, utils.NewRandomHash(), 1, token, utils.NewRandomAddress(), 1, big.NewInt(10), utils.NewRandomHash(), "data")
+	assert.NotNil(t, rt)
+	fee := &models.FeeChargeRecord{
+		Key:            utils.NewRandomHash(),
+		LockSecretHash: utils.NewRandomHash(),
+		TokenAddress:   token,
+		TransferAmount: big.NewInt(100),
+		Fee:            big.NewInt(3),
+		Timestamp:      rt.TimeStamp,
+	}
+	err := dao.SaveFeeChargeRecord(fee)
+	assert.Nil(t, err)
+
+	a := models.NewIncomeAggregate(token, rt.TimeStamp)
+	a.TransferAmount = big.NewInt(10)
+	a.TransferCount = 1
+	a.FeeAmount = big.NewInt(3)
+	a.FeeCount = 1
+	err = dao.ArchiveIncomeRecords([]string{rt.Key}, []common.Hash{fee.Key}, []*models.IncomeAggregate{a})
+	assert.Nil(t, err)
+	_, err = dao.GetReceivedTransfer(rt.Key)
+	assert.NotNil(t, err)
+	fees, err := dao.GetAllFeeChargeRecord(token, -1, -1)
+	assert.Nil(t, err)
+	assert.Len(t, fees, 0)
+
+	//同一个小时的汇总累加
+	err = dao.ArchiveIncomeRecords(nil, nil, []*models.IncomeAggregate{a})
+	assert.Nil(t, err)
+	as, err := dao.GetIncomeAggregateList(token, -1, -1)
+	assert.Nil(t, err)
+	if assert.Len(t, as, 1) {
+		assert.Equal(t, a.Hour, as[0].Hour)
+		assert.EqualValues(t, big.NewInt(20), as[0].TransferAmount)
+		assert.Equal(t, 2, as[0].TransferCount)
+		assert.EqualValues(t, big.NewInt(6), as[0].FeeAmount)
+	}
+	as, err = dao.GetIncomeAggregateList(token, a.Hour+3600, -1)
+	assert.Nil(t, err)
+	assert.Len(t, as, 0)
+	as, err = dao.GetIncomeAggregateList(utils.NewRandomAddress(), -1, -1)
+	assert.Nil(t, err)
+	assert.Len(t, as, 0)
+}
+
+func TestRemoveAcks(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	h1 := utils.NewRandomHash()
+	h2 := utils.NewRandomHash()
+	dao.SaveAckNoTx(h1, []byte{1})
+	tx := dao.StartTx()
+	dao.SaveAck(h2, []byte{2}, tx)
+	err := tx.Commit()
+	assert.Nil(t, err)
+
+	as, err := dao.GetAckList(time.Now().Unix() - 100)
+	assert.Nil(t, err)
+	assert.Len(t, as, 0)
+	as, err = dao.GetAckList(time.Now().Unix() + 100)
+	assert.Nil(t, err)
+	assert.Len(t, as, 2)
+	for _, a := range as {
+		assert.Equal(t, dao.GetAck(a.EchoHash), a.Ack)
+	}
+	err = dao.RemoveAcks([]common.Hash{h1})
+	assert.Nil(t, err)
+	assert.Nil(t, dao.GetAck(h1))
+	assert.Equal(t, []byte{2}, dao.GetAck(h2))
+}
+
+func TestRemoveHistoryRecords(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	token := utils.NewRandomAddress()
+	lockSecretHash := utils.NewRandomHash()
+	dao.NewSentTransferDetail(token, utils.NewRandomAddress(), big.NewInt(1), "", false, lockSecretHash)
+	std, err := dao.GetSentTransferDetail(token, lockSecretHash)
+	assert.Nil(t, err)
+	err = dao.RemoveSentTransferDetails([]string{std.Key})
+	assert.Nil(t, err)
+	_, err = dao.GetSentTransferDetail(token, lockSecretHash)
+	assert.NotNil(t, err)
+
+	dao.NewDeliveredChainEvent(models.ChainEventID("1"), 10)
+	dao.NewDeliveredChainEvent(models.ChainEventID("2"), 20)
+	rs, err := dao.GetChainEventRecordList(15)
+	assert.Nil(t, err)
+	if assert.Len(t, rs, 1) {
+		assert.EqualValues(t, 10, rs[0].BlockNumber)
+	}
+	dao.ClearOldChainEventRecord(15)
+	rs, err = dao.GetChainEventRecordList(100)
+	assert.Nil(t, err)
+	assert.Len(t, rs, 1)
+
+	m1 := &models.TransferMemo{MemoID: utils.NewRandomHash(), Sender: utils.NewRandomAddress(), CreateTime: 10}
+	m2 := &models.TransferMemo{MemoID: utils.NewRandomHash(), Sender: utils.NewRandomAddress(), CreateTime: 20}
+	assert.Nil(t, dao.SaveTransferMemo(m1))
+	assert.Nil(t, dao.SaveTransferMemo(m2))
+	ms, err := dao.GetTransferMemoList(15)
+	assert.Nil(t, err)
+	if assert.Len(t, ms, 1) {
+		assert.Equal(t, m1.Key, ms[0].Key)
+	}
+	err = dao.RemoveTransferMemos([]string{m1.Key})
+	assert.Nil(t, err)
+	_, err = dao.GetTransferMemo(m1.MemoID, m1.Sender)
+	assert.NotNil(t, err)
+	_, err = dao.GetTransferMemo(m2.MemoID, m2.Sender)
+	assert.Nil(t, err)
+}
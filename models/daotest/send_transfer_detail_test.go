@@ -37,6 +37,13 @@ func TestModelDB_SentTransferDetail(t *testing.T) {
 	assert.EqualValues(t, 1, len(list))
 	assert.EqualValues(t, list[0].Status, models.TransferStatusSuccess)
 
+	dao.UpdateSentTransferDetailPaymentPlan(tokenAddress, lockSecretHash, "plan", 3)
+	std, err = dao.GetSentTransferDetail(tokenAddress, lockSecretHash)
+	assert.Empty(t, err)
+	assert.EqualValues(t, "plan", std.PaymentPlanKey)
+	assert.EqualValues(t, 3, std.PaymentPlanRun)
+	assert.EqualValues(t, std.Status, models.TransferStatusSuccess)
+
 	lockSecretHash2 := utils.NewRandomHash()
 	dao.NewSentTransferDetail(tokenAddress, target, amount, data, false, lockSecretHash2)
 
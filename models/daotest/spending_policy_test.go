@@ -0,0 +1,65 @@
+package daotest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpendingPolicyDao(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	p := &models.SpendingPolicy{
+		TokenAddress:      utils.NewRandomAddress(),
+		MaxPerTransfer:    big.NewInt(100),
+		AllowedTargets:    []common.Address{utils.NewRandomAddress()},
+		ApprovalThreshold: big.NewInt(50),
+	}
+	err := dao.SaveSpendingPolicy(p)
+	assert.Nil(t, err)
+	p2, err := dao.GetSpendingPolicy(p.TokenAddress)
+	assert.Nil(t, err)
+	assert.EqualValues(t, p, p2)
+	ps, err := dao.GetSpendingPolicyList()
+	assert.Nil(t, err)
+	assert.Len(t, ps, 1)
+	err = dao.RemoveSpendingPolicy(p.TokenAddress)
+	assert.Nil(t, err)
+	_, err = dao.GetSpendingPolicy(p.TokenAddress)
+	assert.Equal(t, rerr.ErrNotFound, err)
+
+	a := &models.PendingApproval{
+		Key:          utils.NewRandomHash().String(),
+		Operation:    models.PendingApprovalOperationTransfer,
+		TokenAddress: p.TokenAddress,
+		Amount:       big.NewInt(60),
+		Status:       models.PendingApprovalStatusPending,
+	}
+	err = dao.SavePendingApproval(a)
+	assert.Nil(t, err)
+	a2, err := dao.GetPendingApproval(a.Key)
+	assert.Nil(t, err)
+	assert.Equal(t, a.Amount, a2.Amount)
+	as, err := dao.GetPendingApprovalList()
+	assert.Nil(t, err)
+	assert.Len(t, as, 1)
+}
+
+func TestSpendingPolicy(t *testing.T) {
+	target := utils.NewRandomAddress()
+	p := &models.SpendingPolicy{}
+	assert.True(t, p.IsTargetAllowed(target))
+	assert.False(t, p.NeedApproval(big.NewInt(1000)))
+	p.AllowedTargets = []common.Address{target}
+	p.ApprovalThreshold = big.NewInt(50)
+	assert.True(t, p.IsTargetAllowed(target))
+	assert.False(t, p.IsTargetAllowed(utils.NewRandomAddress()))
+	assert.False(t, p.NeedApproval(big.NewInt(49)))
+	assert.True(t, p.NeedApproval(big.NewInt(50)))
+}
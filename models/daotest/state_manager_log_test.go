@@ -0,0 +1,66 @@
+package daotest
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateManagerLogDao(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	key := utils.NewRandomHash().String()
+	s := &models.StateManagerSnapshot{
+		Key:            key,
+		Name:           "InitiatorTransition",
+		LockSecretHash: utils.NewRandomHash(),
+		TokenAddress:   utils.NewRandomAddress(),
+	}
+	err := dao.SaveStateManagerSnapshot(s)
+	assert.Nil(t, err)
+	for seq := 1; seq <= 12; seq++ {
+		err = dao.AddStateChangeLog(models.NewStateChangeLog(key, seq, []byte{byte(seq)}))
+		assert.Nil(t, err)
+	}
+	other := utils.NewRandomHash().String()
+	err = dao.AddStateChangeLog(models.NewStateChangeLog(other, 1, []byte{1}))
+	assert.Nil(t, err)
+	ls, err := dao.GetStateChangeLogs(key)
+	assert.Nil(t, err)
+	if assert.Len(t, ls, 12) {
+		for i, l := range ls {
+			assert.Equal(t, i+1, l.Seq)
+			assert.Equal(t, []byte{byte(i + 1)}, l.StateChange)
+		}
+	}
+
+	s.Seq = 10
+	s.State = []byte{10}
+	err = dao.SaveStateManagerSnapshot(s)
+	assert.Nil(t, err)
+	ls, err = dao.GetStateChangeLogs(key)
+	assert.Nil(t, err)
+	if assert.Len(t, ls, 2) {
+		assert.Equal(t, 11, ls[0].Seq)
+	}
+	ss, err := dao.GetStateManagerSnapshotList()
+	assert.Nil(t, err)
+	if assert.Len(t, ss, 1) {
+		assert.EqualValues(t, s, ss[0])
+	}
+
+	err = dao.RemoveStateManagerLog(key)
+	assert.Nil(t, err)
+	ls, err = dao.GetStateChangeLogs(key)
+	assert.Nil(t, err)
+	assert.Len(t, ls, 0)
+	ss, err = dao.GetStateManagerSnapshotList()
+	assert.Nil(t, err)
+	assert.Len(t, ss, 0)
+	ls, err = dao.GetStateChangeLogs(other)
+	assert.Nil(t, err)
+	assert.Len(t, ls, 1)
+}
@@ -0,0 +1,44 @@
+package daotest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/codefortest"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransferMemoDao(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	m := &models.TransferMemo{
+		MemoID: utils.NewRandomHash(),
+		Sender: utils.NewRandomAddress(),
+		Total:  2,
+		Chunks: [][]byte{[]byte("a"), nil},
+	}
+	err := dao.SaveTransferMemo(m)
+	assert.Nil(t, err)
+	m2, err := dao.GetTransferMemo(m.MemoID, m.Sender)
+	assert.Nil(t, err)
+	assert.False(t, m2.Received())
+	m2.Chunks[1] = []byte("b")
+	assert.True(t, m2.Received())
+	_, err = dao.GetTransferMemo(m.MemoID, utils.NewRandomAddress())
+	assert.Equal(t, rerr.ErrNotFound, err)
+}
+
+func TestUpdateReceivedTransferData(t *testing.T) {
+	dao := codefortest.NewTestDB("")
+	defer dao.CloseDB()
+	rt := dao.NewReceivedTransfer(1, utils.NewRandomHash(), 1, utils.NewRandomAddress(), utils.NewRandomAddress(), 1, big.NewInt(10), utils.NewRandomHash(), "")
+	assert.NotNil(t, rt)
+	err := dao.UpdateReceivedTransferData(rt.Key, "memo")
+	assert.Nil(t, err)
+	rt2, err := dao.GetReceivedTransfer(rt.Key)
+	assert.Nil(t, err)
+	assert.Equal(t, "memo", rt2.Data)
+}
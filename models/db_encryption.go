@@ -0,0 +1,201 @@
+package models
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"golang.org/x/crypto/scrypt"
+)
+
+/*
+加密的值以dbEncryptionMagic开头,gob编码的第一个字节是0xff的时候第二个字节一定不小于0x80,
+所以不会和没有加密的值混淆,没有加密的旧数据库可以直接读取
+*/
+var dbEncryptionMagic = []byte{0xff, 'P', 'E', '1'}
+
+// 从keystore密码派生key的scrypt参数
+const (
+	dbEncryptionScryptN = 1 << 15
+	dbEncryptionScryptR = 8
+	dbEncryptionScryptP = 1
+	dbEncryptionKeyLen  = 32
+	dbEncryptionSaltLen = 32
+)
+
+var dbEncryptionCheck = []byte("photon database encryption key check")
+
+/*
+DBEncryptionMeta 数据库加密的参数,不加密保存在数据库中.
+每次设置密码都会生成新的Salt,Check用来检查密码是否正确
+*/
+type DBEncryptionMeta struct {
+	Salt  []byte `json:"salt"`
+	Check []byte `json:"check"`
+}
+
+/*
+DBEncryptor 用从keystore密码派生的key加密数据库中的值,AES-256-GCM,每个值使用随机的nonce
+*/
+type DBEncryptor struct {
+	aead cipher.AEAD
+	key  []byte
+}
+
+func newDBEncryptor(password string, salt []byte) (e *DBEncryptor, err error) {
+	key, err := scrypt.Key([]byte(password), salt, dbEncryptionScryptN, dbEncryptionScryptR, dbEncryptionScryptP, dbEncryptionKeyLen)
+	if err != nil {
+		return
+	}
+	return newDBEncryptorWithKey(key)
+}
+
+func newDBEncryptorWithKey(key []byte) (e *DBEncryptor, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return
+	}
+	return &DBEncryptor{aead: aead, key: key}, nil
+}
+
+/*
+NewDBEncryptionMeta 使用新的salt从密码派生key
+*/
+func NewDBEncryptionMeta(password string) (meta *DBEncryptionMeta, e *DBEncryptor, err error) {
+	if password == "" {
+		err = rerr.ErrDBEncryption.Append("password is empty")
+		return
+	}
+	salt := make([]byte, dbEncryptionSaltLen)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return
+	}
+	e, err = newDBEncryptor(password, salt)
+	if err != nil {
+		return
+	}
+	meta = &DBEncryptionMeta{
+		Salt:  salt,
+		Check: e.Encrypt(dbEncryptionCheck),
+	}
+	return
+}
+
+//ParseDBEncryptionMeta parse meta saved in db
+func ParseDBEncryptionMeta(data []byte) (meta *DBEncryptionMeta, err error) {
+	meta = &DBEncryptionMeta{}
+	err = json.Unmarshal(data, meta)
+	return
+}
+
+//Bytes returns the data saved in db
+func (m *DBEncryptionMeta) Bytes() []byte {
+	data, err := json.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+/*
+Unlock 从密码派生key,密码错误返回ErrDBEncryption
+*/
+func (m *DBEncryptionMeta) Unlock(password string) (e *DBEncryptor, err error) {
+	e, err = newDBEncryptor(password, m.Salt)
+	if err != nil {
+		return
+	}
+	check, err := e.Decrypt(m.Check)
+	if err != nil || !bytes.Equal(check, dbEncryptionCheck) {
+		return nil, rerr.ErrDBEncryption.Append("wrong password for database")
+	}
+	return
+}
+
+//Encrypt encrypts a value
+func (e *DBEncryptor) Encrypt(plain []byte) []byte {
+	nonce := make([]byte, e.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		panic(err)
+	}
+	out := make([]byte, 0, len(dbEncryptionMagic)+len(nonce)+len(plain)+e.aead.Overhead())
+	out = append(out, dbEncryptionMagic...)
+	out = append(out, nonce...)
+	return e.aead.Seal(out, nonce, plain, nil)
+}
+
+//Decrypt decrypts a value encrypted by Encrypt
+func (e *DBEncryptor) Decrypt(data []byte) ([]byte, error) {
+	if !IsEncryptedDBValue(data) {
+		return nil, rerr.ErrDBEncryption.Append("value is not encrypted")
+	}
+	data = data[len(dbEncryptionMagic):]
+	if len(data) < e.aead.NonceSize() {
+		return nil, rerr.ErrDBEncryption.Append("encrypted value too short")
+	}
+	plain, err := e.aead.Open(nil, data[:e.aead.NonceSize()], data[e.aead.NonceSize():], nil)
+	if err != nil {
+		return nil, rerr.ErrDBEncryption.AppendError(err)
+	}
+	return plain, nil
+}
+
+/*
+WrapKey 用e加密other的key.
+更换key的过程中两个key互相加密保存,中断以后用任意一个密码都可以得到两个key
+*/
+func (e *DBEncryptor) WrapKey(other *DBEncryptor) []byte {
+	return e.Encrypt(other.key)
+}
+
+//UnwrapKey 解密WrapKey保存的key
+func (e *DBEncryptor) UnwrapKey(data []byte) (*DBEncryptor, error) {
+	key, err := e.Decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	return newDBEncryptorWithKey(key)
+}
+
+//IsEncryptedDBValue returns true if data is encrypted by a DBEncryptor
+func IsEncryptedDBValue(data []byte) bool {
+	return bytes.HasPrefix(data, dbEncryptionMagic)
+}
+
+/*
+DecryptDBValue 解密数据库中的值,没有加密的值原样返回.
+数据库已经加密的时候e不能为nil
+*/
+func DecryptDBValue(e *DBEncryptor, data []byte) ([]byte, error) {
+	if !IsEncryptedDBValue(data) {
+		return data, nil
+	}
+	if e == nil {
+		return nil, rerr.ErrDBEncryption.Append("database is encrypted,password needed")
+	}
+	return e.Decrypt(data)
+}
+
+/*
+ReencryptDBValue 迁移,更换key或者取消加密的时候,把from加密(from为nil表示没有加密)的值改为用to加密(to为nil表示不加密).
+changed为false表示不需要修改
+*/
+func ReencryptDBValue(data []byte, from, to *DBEncryptor) (out []byte, changed bool, err error) {
+	plain, err := DecryptDBValue(from, data)
+	if err != nil {
+		return
+	}
+	if to == nil {
+		return plain, IsEncryptedDBValue(data), nil
+	}
+	return to.Encrypt(plain), true, nil
+}
@@ -51,7 +51,7 @@ func (dao *GkvDB) GetAllOrderedSentEnvelopMessager() []*models.SentEnvelopMessag
 	}
 	for _, v := range buf {
 		var s models.SentEnvelopMessager
-		gobDecode(v, &s)
+		dao.decodeValue(v, &s)
 		msgs = append(msgs, &s)
 	}
 	//log.Trace(fmt.Sprintf("GetAllOrderedSentEnvelopMessager=%s", utils.StringInterface(msgs, 3)))
@@ -0,0 +1,38 @@
+package gkvdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+)
+
+// SaveAPIToken :
+func (dao *GkvDB) SaveAPIToken(t *models.APIToken) error {
+	err := dao.saveKeyValueToBucket(models.BucketAPIToken, t.Key, t)
+	return models.GeneratDBError(err)
+}
+
+// GetAPIToken :
+func (dao *GkvDB) GetAPIToken(key string) (t *models.APIToken, err error) {
+	t = &models.APIToken{}
+	err = dao.getKeyValueToBucket(models.BucketAPIToken, key, t)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetAPITokenList :
+func (dao *GkvDB) GetAPITokenList() (ts []*models.APIToken, err error) {
+	tb, err := dao.db.Table(models.BucketAPIToken)
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	buf := tb.Values(-1)
+	if buf == nil || len(buf) == 0 {
+		return
+	}
+	for _, v := range buf {
+		var t models.APIToken
+		dao.decodeValue(v, &t)
+		ts = append(ts, &t)
+	}
+	return
+}
@@ -0,0 +1,65 @@
+package gkvdb
+
+import (
+	"sort"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+)
+
+// AppendAuditLog 只能追加,已经存在的记录不能被覆盖
+func (dao *GkvDB) AppendAuditLog(l *models.AuditLog) error {
+	var old models.AuditLog
+	err := dao.getKeyValueToBucket(models.BucketAuditLog, l.Seq, &old)
+	if err == nil {
+		return rerr.ErrDBDuplicateKey.Printf("audit log %d", l.Seq)
+	}
+	err = dao.saveKeyValueToBucket(models.BucketAuditLog, l.Seq, l)
+	return models.GeneratDBError(err)
+}
+
+func (dao *GkvDB) getAllAuditLog() (ls []*models.AuditLog, err error) {
+	tb, err := dao.db.Table(models.BucketAuditLog)
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	buf := tb.Values(-1)
+	for _, v := range buf {
+		var l models.AuditLog
+		dao.decodeValue(v, &l)
+		ls = append(ls, &l)
+	}
+	sort.Slice(ls, func(i, j int) bool {
+		return ls[i].Seq < ls[j].Seq
+	})
+	return
+}
+
+// GetLastAuditLog :
+func (dao *GkvDB) GetLastAuditLog() (l *models.AuditLog, err error) {
+	ls, err := dao.getAllAuditLog()
+	if err != nil {
+		return
+	}
+	if len(ls) == 0 {
+		err = rerr.ErrNotFound
+		return
+	}
+	l = ls[len(ls)-1]
+	return
+}
+
+// GetAuditLogList 按照Seq排序,包括fromSeq和toSeq
+func (dao *GkvDB) GetAuditLogList(fromSeq, toSeq int64) (ls []*models.AuditLog, err error) {
+	all, err := dao.getAllAuditLog()
+	if err != nil {
+		return
+	}
+	for _, l := range all {
+		if l.Seq >= fromSeq && l.Seq <= toSeq {
+			ls = append(ls, l)
+		}
+	}
+	return
+}
@@ -0,0 +1,38 @@
+package gkvdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+)
+
+// SaveBatchTransfer :
+func (dao *GkvDB) SaveBatchTransfer(b *models.BatchTransfer) error {
+	err := dao.saveKeyValueToBucket(models.BucketBatchTransfer, b.Key, b)
+	return models.GeneratDBError(err)
+}
+
+// GetBatchTransfer :
+func (dao *GkvDB) GetBatchTransfer(key string) (b *models.BatchTransfer, err error) {
+	b = &models.BatchTransfer{}
+	err = dao.getKeyValueToBucket(models.BucketBatchTransfer, key, b)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetBatchTransferList :
+func (dao *GkvDB) GetBatchTransferList() (bs []*models.BatchTransfer, err error) {
+	tb, err := dao.db.Table(models.BucketBatchTransfer)
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	buf := tb.Values(-1)
+	if buf == nil || len(buf) == 0 {
+		return
+	}
+	for _, v := range buf {
+		var b models.BatchTransfer
+		dao.decodeValue(v, &b)
+		bs = append(bs, &b)
+	}
+	return
+}
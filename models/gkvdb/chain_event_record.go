@@ -58,7 +58,7 @@ func (dao *GkvDB) ClearOldChainEventRecord(blockNumber uint64) {
 	}
 	for _, v := range buf {
 		var r models.ChainEventRecord
-		gobDecode(v, &r)
+		dao.decodeValue(v, &r)
 		if r.BlockNumber <= blockNumber {
 			err2 := dao.removeKeyValueFromBucket(models.BucketChainEventRecord, r.ID)
 			if err2 != nil {
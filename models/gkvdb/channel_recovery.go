@@ -0,0 +1,39 @@
+package gkvdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SaveChannelRecovery :
+func (dao *GkvDB) SaveChannelRecovery(r *models.ChannelRecovery) error {
+	err := dao.saveKeyValueToBucket(models.BucketChannelRecovery, r.ChannelIdentifier[:], r)
+	return models.GeneratDBError(err)
+}
+
+// GetChannelRecovery :
+func (dao *GkvDB) GetChannelRecovery(channelIdentifier common.Hash) (r *models.ChannelRecovery, err error) {
+	r = &models.ChannelRecovery{}
+	err = dao.getKeyValueToBucket(models.BucketChannelRecovery, channelIdentifier[:], r)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetChannelRecoveryList :
+func (dao *GkvDB) GetChannelRecoveryList() (rs []*models.ChannelRecovery, err error) {
+	tb, err := dao.db.Table(models.BucketChannelRecovery)
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	buf := tb.Values(-1)
+	if buf == nil || len(buf) == 0 {
+		return
+	}
+	for _, v := range buf {
+		var r models.ChannelRecovery
+		dao.decodeValue(v, &r)
+		rs = append(rs, &r)
+	}
+	return
+}
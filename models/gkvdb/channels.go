@@ -132,7 +132,7 @@ func (dao *GkvDB) GetChannel(token, partner common.Address) (c *channeltype.Seri
 	}
 	for _, v := range buf {
 		var ct channeltype.Serialization
-		gobDecode(v, &ct)
+		dao.decodeValue(v, &ct)
 		cs = append(cs, &ct)
 	}
 	for _, ct := range cs {
@@ -158,7 +158,7 @@ func (dao *GkvDB) GetChannelByAddress(ChannelIdentifier common.Hash) (c *channel
 	var cs []*channeltype.Serialization
 	for _, v := range buf {
 		var ct channeltype.Serialization
-		gobDecode(v, &ct)
+		dao.decodeValue(v, &ct)
 		cs = append(cs, &ct)
 	}
 	for _, ct := range cs {
@@ -185,7 +185,7 @@ func (dao *GkvDB) GetChannelList(token, partner common.Address) (cs []*channelty
 	var cst []*channeltype.Serialization
 	for _, v := range buf {
 		var ct channeltype.Serialization
-		gobDecode(v, &ct)
+		dao.decodeValue(v, &ct)
 		cst = append(cst, &ct)
 	}
 
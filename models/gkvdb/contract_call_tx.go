@@ -128,7 +128,7 @@ func (dao *GkvDB) GetTXInfoList(channelIdentifier common.Hash, openBlockNumber i
 	}
 	for _, v := range buf {
 		var tis models.TXInfoSerialization
-		gobDecode(v, &tis)
+		dao.decodeValue(v, &tis)
 		appendTXInfoIfMatch(&list, &tis, channelIdentifier, openBlockNumber, tokenAddress, txType, status)
 	}
 	return
@@ -32,6 +32,7 @@ type GkvDB struct {
 	channelSettledCallbacks map[*cb.ChannelCb]bool
 	mlock                   sync.Mutex
 	Name                    string
+	encryptor               *models.DBEncryptor //为nil表示没有加密
 }
 
 func newGkvDB() (db *GkvDB) {
@@ -61,12 +62,29 @@ func gobDecode(buf []byte, to interface{}) {
 	}
 }
 
+//encodeValue 只加密值,key必须保持不变
+func (dao *GkvDB) encodeValue(v interface{}) []byte {
+	data := gobEncode(v)
+	if dao.encryptor == nil {
+		return data
+	}
+	return dao.encryptor.Encrypt(data)
+}
+
+func (dao *GkvDB) decodeValue(buf []byte, to interface{}) {
+	buf, err := models.DecryptDBValue(dao.encryptor, buf)
+	if err != nil {
+		panic(err)
+	}
+	gobDecode(buf, to)
+}
+
 func (dao *GkvDB) saveKeyValueToBucket(bucket string, key, value interface{}) error {
 	tb, err := dao.db.Table(bucket)
 	if err != nil {
 		return err
 	}
-	err = tb.Set(gobEncode(key), gobEncode(value))
+	err = tb.Set(gobEncode(key), dao.encodeValue(value))
 	if err != nil {
 		return err
 	}
@@ -82,7 +100,7 @@ func (dao *GkvDB) getKeyValueToBucket(bucket string, key, to interface{}) error
 	if buf == nil || len(buf) == 0 {
 		return ErrorNotFound
 	}
-	gobDecode(buf, to)
+	dao.decodeValue(buf, to)
 	return nil
 }
 
@@ -94,8 +112,16 @@ func (dao *GkvDB) removeKeyValueFromBucket(bucket string, key interface{}) error
 	return tb.Remove(gobEncode(key))
 }
 
-//OpenDb open or create a bolt db at dbPath
+//OpenDb open or create a bolt db at dbPath,fails if the db is encrypted
 func OpenDb(dbPath string) (dao *GkvDB, err error) {
+	return OpenDbWithPassword(dbPath, "", false)
+}
+
+/*
+OpenDbWithPassword open or create a db at dbPath.
+加密的数据库用password解密,没有加密的数据库在encrypt为true的时候用password加密所有的值
+*/
+func OpenDbWithPassword(dbPath, password string, encrypt bool) (dao *GkvDB, err error) {
 	log.Trace(fmt.Sprintf("dbpath=%s", dbPath))
 	dao = newGkvDB()
 	needCreateDb := !common.FileExist(dbPath)
@@ -107,6 +133,11 @@ func OpenDb(dbPath string) (dao *GkvDB, err error) {
 		return
 	}
 	dao.Name = dbPath
+	err = dao.unlock(password, encrypt)
+	if err != nil {
+		dao.db.Close()
+		return
+	}
 	if needCreateDb {
 		err = dao.saveKeyValueToBucket(models.BucketMeta, models.KeyVersion, models.DbVersion)
 		if err != nil {
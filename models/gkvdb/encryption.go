@@ -0,0 +1,306 @@
+package gkvdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gitee.com/johng/gkvdb/gkvdb"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+)
+
+// 加密参数不加密保存在这个表中
+const tableEncryption = "__photon_encryption"
+
+var keyEncryptionMeta = []byte("meta")
+
+var keyEncryptionPending = []byte("pending")
+
+/*
+pendingEncryption 重新加密之前保存,修改加密参数的时候删除.
+gkvdb不能在一个事务中完成重新加密,中断以后下次打开的时候用它继续.
+两个key互相加密保存,所以用旧密码或者新密码都可以继续
+*/
+type pendingEncryption struct {
+	Meta   *models.DBEncryptionMeta `json:"meta,omitempty"`    //新的加密参数,nil表示取消加密
+	NewKey []byte                   `json:"new_key,omitempty"` //旧key加密的新key
+	OldKey []byte                   `json:"old_key,omitempty"` //新key加密的旧key
+}
+
+func (dao *GkvDB) getEncryptionMeta() (meta *models.DBEncryptionMeta, err error) {
+	tb, err := dao.db.Table(tableEncryption)
+	if err != nil {
+		return
+	}
+	data := tb.Get(keyEncryptionMeta)
+	if len(data) == 0 {
+		return
+	}
+	return models.ParseDBEncryptionMeta(data)
+}
+
+func (dao *GkvDB) getPendingEncryption() (p *pendingEncryption, err error) {
+	tb, err := dao.db.Table(tableEncryption)
+	if err != nil {
+		return
+	}
+	data := tb.Get(keyEncryptionPending)
+	if len(data) == 0 {
+		return
+	}
+	p = &pendingEncryption{}
+	err = json.Unmarshal(data, p)
+	if err != nil {
+		return nil, rerr.ErrDBEncryption.AppendError(err)
+	}
+	return
+}
+
+/*
+resumeEncryption 上次重新加密没有完成,用password得到新旧两个key,继续完成
+*/
+func (dao *GkvDB) resumeEncryption(meta *models.DBEncryptionMeta, p *pendingEncryption, password string) (err error) {
+	if password == "" {
+		return rerr.ErrDBEncryption.Printf("database %s is being re-encrypted,password needed", dao.Name)
+	}
+	var from, to *models.DBEncryptor
+	if meta != nil {
+		from, err = meta.Unlock(password)
+		if err == nil && p.Meta != nil {
+			to, err = from.UnwrapKey(p.NewKey)
+		}
+	}
+	if from == nil && p.Meta != nil {
+		to, err = p.Meta.Unlock(password)
+		if err == nil && meta != nil {
+			from, err = to.UnwrapKey(p.OldKey)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	log.Warn(fmt.Sprintf("database %s: resume interrupted re-encryption", dao.Name))
+	dao.encryptor = from
+	return dao.switchEncryption(from, to, p.Meta)
+}
+
+func (dao *GkvDB) unlock(password string, encrypt bool) error {
+	meta, err := dao.getEncryptionMeta()
+	if err != nil {
+		return err
+	}
+	p, err := dao.getPendingEncryption()
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		return dao.resumeEncryption(meta, p, password)
+	}
+	if meta == nil {
+		if encrypt {
+			return dao.SetEncryptionPassword(password)
+		}
+		return nil
+	}
+	if password == "" {
+		return rerr.ErrDBEncryption.Printf("database %s is encrypted,password needed", dao.Name)
+	}
+	e, err := meta.Unlock(password)
+	if err != nil {
+		return err
+	}
+	dao.encryptor = e
+	return nil
+}
+
+//IsEncrypted returns true if values in db are encrypted
+func (dao *GkvDB) IsEncrypted() bool {
+	return dao.encryptor != nil
+}
+
+/*
+tableKeys gkvdb的Items并不可靠(见daotest中的TestGKV),binlog同步到磁盘的过程中可能漏掉一些key,
+所以多次遍历取并集,直到连续params.GkvKeyScanStableRounds次遍历都没有发现新的key
+*/
+func tableKeys(tb *gkvdb.Table) map[string]bool {
+	keys := make(map[string]bool)
+	for stable := 0; stable < params.GkvKeyScanStableRounds; {
+		found := false
+		for k := range tb.Items(-1) {
+			if !keys[k] {
+				keys[k] = true
+				found = true
+			}
+		}
+		if found {
+			stable = 0
+		} else {
+			stable++
+		}
+	}
+	return keys
+}
+
+//encryptionTables 除了保存加密参数的表以外的所有表,每个表对应一个.ix文件
+func (dao *GkvDB) encryptionTables() (tables map[string]*gkvdb.Table, err error) {
+	files, err := filepath.Glob(filepath.Join(dao.Name, "*.ix"))
+	if err != nil {
+		return nil, models.GeneratDBError(err)
+	}
+	tables = make(map[string]*gkvdb.Table)
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".ix")
+		if name == tableEncryption {
+			continue
+		}
+		tables[name], err = dao.db.Table(name)
+		if err != nil {
+			return nil, models.GeneratDBError(err)
+		}
+	}
+	return
+}
+
+//isEncryptedBy 值是否已经是用to加密的(to为nil表示不加密)
+func isEncryptedBy(data []byte, to *models.DBEncryptor) bool {
+	if to == nil {
+		return !models.IsEncryptedDBValue(data)
+	}
+	_, err := to.Decrypt(data)
+	return err == nil
+}
+
+/*
+reencryptTables 把所有还不是用to加密的值重新加密,在一个事务中提交,返回修改了多少个值
+*/
+func (dao *GkvDB) reencryptTables(tables map[string]*gkvdb.Table, from, to *models.DBEncryptor) (changed int, err error) {
+	tx := dao.db.Begin()
+	for name, tb := range tables {
+		for k := range tableKeys(tb) {
+			//Items返回的值可能是旧的,只用来遍历key
+			data := tb.Get([]byte(k))
+			if len(data) == 0 || isEncryptedBy(data, to) {
+				continue
+			}
+			out, _, err := models.ReencryptDBValue(data, from, to)
+			if err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+			err = tx.SetTo([]byte(k), out, name)
+			if err != nil {
+				tx.Rollback()
+				return 0, models.GeneratDBError(err)
+			}
+			changed++
+		}
+	}
+	if changed == 0 {
+		tx.Rollback()
+		return
+	}
+	err = tx.Commit(true)
+	if err != nil {
+		return 0, models.GeneratDBError(err)
+	}
+	return
+}
+
+/*
+SetEncryptionPassword 用新的salt从password派生key,重新加密数据库中所有的值,password为空表示取消加密.
+没有加密的数据库调用这个函数完成迁移,已经加密的数据库调用这个函数更换key.
+只能在没有其他读写的时候调用.
+开始之前先保存新的加密参数,中断以后下次打开数据库的时候继续
+*/
+func (dao *GkvDB) SetEncryptionPassword(password string) error {
+	var meta *models.DBEncryptionMeta
+	var to *models.DBEncryptor
+	var err error
+	if password != "" {
+		meta, to, err = models.NewDBEncryptionMeta(password)
+		if err != nil {
+			return err
+		}
+	}
+	from := dao.encryptor
+	err = dao.savePendingEncryption(from, to, meta)
+	if err != nil {
+		return err
+	}
+	return dao.switchEncryption(from, to, meta)
+}
+
+func (dao *GkvDB) savePendingEncryption(from, to *models.DBEncryptor, meta *models.DBEncryptionMeta) error {
+	p := &pendingEncryption{Meta: meta}
+	if from != nil && to != nil {
+		p.NewKey = from.WrapKey(to)
+		p.OldKey = to.WrapKey(from)
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return rerr.ErrDBEncryption.AppendError(err)
+	}
+	tx := dao.db.Begin()
+	err = tx.SetTo(keyEncryptionPending, data, tableEncryption)
+	if err != nil {
+		tx.Rollback()
+		return models.GeneratDBError(err)
+	}
+	err = tx.Commit(true)
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	return nil
+}
+
+/*
+switchEncryption 把from加密的值改为用to加密,meta是to的加密参数.
+重新加密以后再遍历一次检查每一个值,有遗漏的继续加密,直到一次遍历没有发现需要修改的值,
+这之后才在一个事务中修改加密参数并删除pendingEncryption,检查没有通过的时候加密参数保持不变
+*/
+func (dao *GkvDB) switchEncryption(from, to *models.DBEncryptor, meta *models.DBEncryptionMeta) error {
+	tables, err := dao.encryptionTables()
+	if err != nil {
+		return err
+	}
+	verified := false
+	for i := 0; i < params.GkvReencryptMaxRounds; i++ {
+		changed, err := dao.reencryptTables(tables, from, to)
+		if err != nil {
+			return err
+		}
+		if changed == 0 {
+			verified = true
+			break
+		}
+		if i > 0 {
+			log.Warn(fmt.Sprintf("database %s: %d values were missed when re-encrypting", dao.Name, changed))
+		}
+	}
+	if !verified {
+		return rerr.ErrDBEncryption.Printf("database %s: values keep changing while re-encrypting", dao.Name)
+	}
+	tx := dao.db.Begin()
+	if meta == nil {
+		err = tx.RemoveFrom(keyEncryptionMeta, tableEncryption)
+	} else {
+		err = tx.SetTo(keyEncryptionMeta, meta.Bytes(), tableEncryption)
+	}
+	if err == nil {
+		err = tx.RemoveFrom(keyEncryptionPending, tableEncryption)
+	}
+	if err != nil {
+		tx.Rollback()
+		return models.GeneratDBError(err)
+	}
+	err = tx.Commit(true)
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	dao.encryptor = to
+	return nil
+}
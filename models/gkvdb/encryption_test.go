@@ -0,0 +1,91 @@
+package gkvdb
+
+import (
+	"math/big"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+//interruptEncryption 模拟重新加密的值已经提交,但是加密参数还没有修改的时候崩溃
+func interruptEncryption(t *testing.T, dao *GkvDB, password string) {
+	var meta *models.DBEncryptionMeta
+	var to *models.DBEncryptor
+	var err error
+	if password != "" {
+		meta, to, err = models.NewDBEncryptionMeta(password)
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+	err = dao.savePendingEncryption(dao.encryptor, to, meta)
+	assert.Nil(t, err)
+	tables, err := dao.encryptionTables()
+	assert.Nil(t, err)
+	_, err = dao.reencryptTables(tables, dao.encryptor, to)
+	assert.Nil(t, err)
+	dao.CloseDB()
+}
+
+func TestResumeInterruptedEncryption(t *testing.T) {
+	dbPath := path.Join(os.TempDir(), "testresumeencryption.gkv")
+	os.RemoveAll(dbPath)
+	defer os.RemoveAll(dbPath)
+	dao, err := OpenDb(dbPath)
+	if !assert.Nil(t, err) {
+		return
+	}
+	token := utils.NewRandomAddress()
+	err = dao.SaveSpendingPolicy(&models.SpendingPolicy{TokenAddress: token, MaxPerDay: big.NewInt(10)})
+	assert.Nil(t, err)
+	//关闭以后gkvdb后台的同步还会运行一段时间,等它结束再打开
+	open := func(password string) (*GkvDB, error) {
+		time.Sleep(time.Second)
+		return OpenDbWithPassword(dbPath, password, false)
+	}
+	check := func(password string, encrypted bool) {
+		dao, err = open(password)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, encrypted, dao.IsEncrypted())
+		p, err := dao.GetSpendingPolicy(token)
+		if assert.Nil(t, err) {
+			assert.EqualValues(t, big.NewInt(10), p.MaxPerDay)
+		}
+	}
+
+	//没有加密的数据库迁移中断,需要密码才能继续
+	interruptEncryption(t, dao, "123")
+	_, err = open("")
+	assert.NotNil(t, err)
+	check("123", true)
+	dao.CloseDB()
+	check("123", true)
+
+	//更换key中断,用旧密码继续
+	interruptEncryption(t, dao, "456")
+	check("123", true)
+	dao.CloseDB()
+	_, err = open("123")
+	assert.NotNil(t, err)
+	check("456", true)
+
+	//更换key中断,用新密码继续
+	interruptEncryption(t, dao, "789")
+	check("789", true)
+	dao.CloseDB()
+	check("789", true)
+
+	//取消加密中断
+	interruptEncryption(t, dao, "")
+	check("789", false)
+	dao.CloseDB()
+	check("", false)
+	dao.CloseDB()
+}
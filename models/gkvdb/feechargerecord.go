@@ -44,7 +44,7 @@ func (dao *GkvDB) GetAllFeeChargeRecord() (records []*models.FeeChargeRecord, er
 	}
 	for _, v := range buf {
 		var r models.FeeChargeRecord
-		gobDecode(v, &r)
+		dao.decodeValue(v, &r)
 		records = append(records, &r)
 	}
 	return
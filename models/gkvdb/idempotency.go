@@ -0,0 +1,45 @@
+package gkvdb
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+)
+
+// SaveIdempotencyRecord :
+func (dao *GkvDB) SaveIdempotencyRecord(r *models.IdempotencyRecord) error {
+	err := dao.saveKeyValueToBucket(models.BucketIdempotency, r.Key, r)
+	return models.GeneratDBError(err)
+}
+
+// GetIdempotencyRecord :
+func (dao *GkvDB) GetIdempotencyRecord(key string) (r *models.IdempotencyRecord, err error) {
+	r = &models.IdempotencyRecord{}
+	err = dao.getKeyValueToBucket(models.BucketIdempotency, key, r)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// RemoveIdempotencyRecordBefore delete records created before createTime
+func (dao *GkvDB) RemoveIdempotencyRecordBefore(createTime int64) error {
+	tb, err := dao.db.Table(models.BucketIdempotency)
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	buf := tb.Values(-1)
+	if buf == nil || len(buf) == 0 {
+		return nil
+	}
+	for _, v := range buf {
+		var r models.IdempotencyRecord
+		dao.decodeValue(v, &r)
+		if r.CreateTime < createTime {
+			err = dao.removeKeyValueFromBucket(models.BucketIdempotency, r.Key)
+			if err != nil {
+				log.Error(fmt.Sprintf("RemoveIdempotencyRecordBefore err %s", err))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package gkvdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SaveMediationPolicy :
+func (dao *GkvDB) SaveMediationPolicy(p *models.MediationPolicy) error {
+	p.Key = p.TokenAddress.String()
+	err := dao.saveKeyValueToBucket(models.BucketMediationPolicy, p.Key, p)
+	return models.GeneratDBError(err)
+}
+
+// GetMediationPolicy :
+func (dao *GkvDB) GetMediationPolicy(tokenAddress common.Address) (p *models.MediationPolicy, err error) {
+	p = &models.MediationPolicy{}
+	err = dao.getKeyValueToBucket(models.BucketMediationPolicy, tokenAddress.String(), p)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetMediationPolicyList :
+func (dao *GkvDB) GetMediationPolicyList() (ps []*models.MediationPolicy, err error) {
+	tb, err := dao.db.Table(models.BucketMediationPolicy)
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	buf := tb.Values(-1)
+	if buf == nil || len(buf) == 0 {
+		return
+	}
+	for _, v := range buf {
+		var p models.MediationPolicy
+		dao.decodeValue(v, &p)
+		ps = append(ps, &p)
+	}
+	return
+}
+
+// RemoveMediationPolicy :
+func (dao *GkvDB) RemoveMediationPolicy(tokenAddress common.Address) error {
+	_, err := dao.GetMediationPolicy(tokenAddress)
+	if err != nil {
+		return err
+	}
+	err = dao.removeKeyValueFromBucket(models.BucketMediationPolicy, tokenAddress.String())
+	return models.GeneratDBError(err)
+}
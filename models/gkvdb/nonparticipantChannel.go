@@ -68,7 +68,7 @@ func (dao *GkvDB) GetAllNonParticipantChannelByToken(token common.Address) (edge
 	}
 	for _, v := range buf {
 		var m nonParticipantChannel
-		gobDecode(v, &m)
+		dao.decodeValue(v, &m)
 		edges = append(edges, common.BytesToAddress(m.Participant1Bytes), common.BytesToAddress(m.Participant2Bytes))
 	}
 	return
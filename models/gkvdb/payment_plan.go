@@ -0,0 +1,38 @@
+package gkvdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+)
+
+// SavePaymentPlan :
+func (dao *GkvDB) SavePaymentPlan(p *models.PaymentPlan) error {
+	err := dao.saveKeyValueToBucket(models.BucketPaymentPlan, p.Key, p)
+	return models.GeneratDBError(err)
+}
+
+// GetPaymentPlan :
+func (dao *GkvDB) GetPaymentPlan(key string) (p *models.PaymentPlan, err error) {
+	p = &models.PaymentPlan{}
+	err = dao.getKeyValueToBucket(models.BucketPaymentPlan, key, p)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetPaymentPlanList :
+func (dao *GkvDB) GetPaymentPlanList() (ps []*models.PaymentPlan, err error) {
+	tb, err := dao.db.Table(models.BucketPaymentPlan)
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	buf := tb.Values(-1)
+	if buf == nil || len(buf) == 0 {
+		return
+	}
+	for _, v := range buf {
+		var p models.PaymentPlan
+		dao.decodeValue(v, &p)
+		ps = append(ps, &p)
+	}
+	return
+}
@@ -0,0 +1,21 @@
+package gkvdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SavePaymentReceipt :
+func (dao *GkvDB) SavePaymentReceipt(r *models.PaymentReceipt) error {
+	r.Key = models.NewPaymentReceiptKey(r.TokenAddress, r.LockSecretHash)
+	err := dao.saveKeyValueToBucket(models.BucketPaymentReceipt, r.Key, r)
+	return models.GeneratDBError(err)
+}
+
+// GetPaymentReceipt :
+func (dao *GkvDB) GetPaymentReceipt(tokenAddress common.Address, lockSecretHash common.Hash) (r *models.PaymentReceipt, err error) {
+	r = &models.PaymentReceipt{}
+	err = dao.getKeyValueToBucket(models.BucketPaymentReceipt, models.NewPaymentReceiptKey(tokenAddress, lockSecretHash), r)
+	err = models.GeneratDBError(err)
+	return
+}
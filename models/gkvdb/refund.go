@@ -31,7 +31,7 @@ func (dao *GkvDB) IsLockSecretHashDisposed(lockSecretHash common.Hash) bool {
 	}
 	for _, v := range buf {
 		var sad models.SentAnnounceDisposed
-		gobDecode(v, &sad)
+		dao.decodeValue(v, &sad)
 		sads = append(sads, &sad)
 		if common.BytesToHash(sad.LockSecretHash) == lockSecretHash {
 			return true
@@ -75,7 +75,7 @@ func (dao *GkvDB) IsLockHashCanPunish(lockHash, channelIdentifier common.Hash) b
 	}
 	for _, v := range buf {
 		var rad models.ReceivedAnnounceDisposed
-		gobDecode(v, &rad)
+		dao.decodeValue(v, &rad)
 		if common.BytesToHash(rad.Key) == key {
 			return true
 		}
@@ -112,7 +112,7 @@ func (dao *GkvDB) GetChannelAnnounceDisposed(channelIdentifier common.Hash) (rad
 	}
 	for _, v := range buf {
 		var rad models.ReceivedAnnounceDisposed
-		gobDecode(v, &rad)
+		dao.decodeValue(v, &rad)
 		if common.BytesToHash(rad.ChannelIdentifier) == channelIdentifier {
 			rads = append(rads, &rad)
 		}
@@ -97,6 +97,29 @@ func (dao *GkvDB) UpdateSentTransferDetailStatusMessage(tokenAddress common.Addr
 	return
 }
 
+// UpdateSentTransferDetailPaymentPlan :
+func (dao *GkvDB) UpdateSentTransferDetailPaymentPlan(tokenAddress common.Address, lockSecretHash common.Hash, planKey string, run int) (transfer *models.SentTransferDetail) {
+	transfer = &models.SentTransferDetail{}
+	key := utils.Sha3(tokenAddress[:], lockSecretHash[:]).String()
+	err := dao.getKeyValueToBucket(models.BucketSentTransferDetail, key, transfer)
+	if err == ErrorNotFound {
+		return
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdatePaymentPlan err %s", err))
+		return
+	}
+	transfer.PaymentPlanKey = planKey
+	transfer.PaymentPlanRun = run
+	err = dao.saveKeyValueToBucket(models.BucketSentTransferDetail, transfer.Key, transfer)
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdatePaymentPlan err %s", err))
+		return
+	}
+	log.Trace(fmt.Sprintf("UpdatePaymentPlan key=%s lockSecretHash=%s plan=%s run=%d", key, lockSecretHash.String(), planKey, run))
+	return
+}
+
 // GetSentTransferDetail :
 func (dao *GkvDB) GetSentTransferDetail(tokenAddress common.Address, lockSecretHash common.Hash) (*models.SentTransferDetail, error) {
 	var std models.SentTransferDetail
@@ -122,7 +145,7 @@ func (dao *GkvDB) GetSentTransferDetailList(tokenAddress common.Address, fromTim
 	}
 	for _, v := range buf {
 		var st models.SentTransferDetail
-		gobDecode(v, &st)
+		dao.decodeValue(v, &st)
 		appendSentTransferDetailIfMatch(&transfers, &st, tokenAddress, fromTime, toTime, fromBlock, toBlock)
 	}
 	return
@@ -30,7 +30,7 @@ func (dao *GkvDB) GetAllSettledChannel() (chs []*channeltype.Serialization, err
 	}
 	for _, v := range buf {
 		var channel channeltype.Serialization
-		gobDecode(v, &channel)
+		dao.decodeValue(v, &channel)
 		chs = append(chs, &channel)
 	}
 	return
@@ -0,0 +1,83 @@
+package gkvdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SaveSpendingPolicy :
+func (dao *GkvDB) SaveSpendingPolicy(p *models.SpendingPolicy) error {
+	p.Key = p.TokenAddress.String()
+	err := dao.saveKeyValueToBucket(models.BucketSpendingPolicy, p.Key, p)
+	return models.GeneratDBError(err)
+}
+
+// GetSpendingPolicy :
+func (dao *GkvDB) GetSpendingPolicy(tokenAddress common.Address) (p *models.SpendingPolicy, err error) {
+	p = &models.SpendingPolicy{}
+	err = dao.getKeyValueToBucket(models.BucketSpendingPolicy, tokenAddress.String(), p)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetSpendingPolicyList :
+func (dao *GkvDB) GetSpendingPolicyList() (ps []*models.SpendingPolicy, err error) {
+	tb, err := dao.db.Table(models.BucketSpendingPolicy)
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	buf := tb.Values(-1)
+	if buf == nil || len(buf) == 0 {
+		return
+	}
+	for _, v := range buf {
+		var p models.SpendingPolicy
+		dao.decodeValue(v, &p)
+		ps = append(ps, &p)
+	}
+	return
+}
+
+// RemoveSpendingPolicy :
+func (dao *GkvDB) RemoveSpendingPolicy(tokenAddress common.Address) error {
+	_, err := dao.GetSpendingPolicy(tokenAddress)
+	if err != nil {
+		return err
+	}
+	err = dao.removeKeyValueFromBucket(models.BucketSpendingPolicy, tokenAddress.String())
+	return models.GeneratDBError(err)
+}
+
+// SavePendingApproval :
+func (dao *GkvDB) SavePendingApproval(a *models.PendingApproval) error {
+	err := dao.saveKeyValueToBucket(models.BucketPendingApproval, a.Key, a)
+	return models.GeneratDBError(err)
+}
+
+// GetPendingApproval :
+func (dao *GkvDB) GetPendingApproval(key string) (a *models.PendingApproval, err error) {
+	a = &models.PendingApproval{}
+	err = dao.getKeyValueToBucket(models.BucketPendingApproval, key, a)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetPendingApprovalList :
+func (dao *GkvDB) GetPendingApprovalList() (as []*models.PendingApproval, err error) {
+	tb, err := dao.db.Table(models.BucketPendingApproval)
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	buf := tb.Values(-1)
+	if buf == nil || len(buf) == 0 {
+		return
+	}
+	for _, v := range buf {
+		var a models.PendingApproval
+		dao.decodeValue(v, &a)
+		as = append(as, &a)
+	}
+	return
+}
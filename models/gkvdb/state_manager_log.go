@@ -0,0 +1,93 @@
+package gkvdb
+
+import (
+	"sort"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+)
+
+// AddStateChangeLog :
+func (dao *GkvDB) AddStateChangeLog(l *models.StateChangeLog) error {
+	err := dao.saveKeyValueToBucket(models.BucketStateChangeLog, l.ID, l)
+	return models.GeneratDBError(err)
+}
+
+// GetStateChangeLogs :
+func (dao *GkvDB) GetStateChangeLogs(key string) (ls []*models.StateChangeLog, err error) {
+	tb, err := dao.db.Table(models.BucketStateChangeLog)
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	buf := tb.Values(-1)
+	if buf == nil || len(buf) == 0 {
+		return
+	}
+	for _, v := range buf {
+		var l models.StateChangeLog
+		dao.decodeValue(v, &l)
+		if l.Key == key {
+			ls = append(ls, &l)
+		}
+	}
+	sort.Slice(ls, func(i, j int) bool {
+		return ls[i].Seq < ls[j].Seq
+	})
+	return
+}
+
+// SaveStateManagerSnapshot :
+func (dao *GkvDB) SaveStateManagerSnapshot(s *models.StateManagerSnapshot) error {
+	err := dao.saveKeyValueToBucket(models.BucketStateManagerSnapshot, s.Key, s)
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	return dao.removeStateChangeLogs(s.Key, s.Seq)
+}
+
+// GetStateManagerSnapshotList :
+func (dao *GkvDB) GetStateManagerSnapshotList() (ss []*models.StateManagerSnapshot, err error) {
+	tb, err := dao.db.Table(models.BucketStateManagerSnapshot)
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	buf := tb.Values(-1)
+	if buf == nil || len(buf) == 0 {
+		return
+	}
+	for _, v := range buf {
+		var s models.StateManagerSnapshot
+		dao.decodeValue(v, &s)
+		ss = append(ss, &s)
+	}
+	return
+}
+
+// RemoveStateManagerLog :
+func (dao *GkvDB) RemoveStateManagerLog(key string) error {
+	err := dao.removeStateChangeLogs(key, -1)
+	if err != nil {
+		return err
+	}
+	err = dao.removeKeyValueFromBucket(models.BucketStateManagerSnapshot, key)
+	return models.GeneratDBError(err)
+}
+
+//removeStateChangeLogs 删除key的Seq不大于toSeq的状态变化,toSeq为-1时全部删除
+func (dao *GkvDB) removeStateChangeLogs(key string, toSeq int) error {
+	ls, err := dao.GetStateChangeLogs(key)
+	if err != nil {
+		return err
+	}
+	for _, l := range ls {
+		if toSeq >= 0 && l.Seq > toSeq {
+			break
+		}
+		err = dao.removeKeyValueFromBucket(models.BucketStateChangeLog, l.ID)
+		if err != nil {
+			return models.GeneratDBError(err)
+		}
+	}
+	return nil
+}
@@ -55,6 +55,17 @@ func (dao *GkvDB) GetReceivedTransfer(key string) (*models.ReceivedTransfer, err
 	return &r, err
 }
 
+//UpdateReceivedTransferData 加密附言在交易之后收齐时更新附加信息
+func (dao *GkvDB) UpdateReceivedTransferData(key string, data string) error {
+	r, err := dao.GetReceivedTransfer(key)
+	if err != nil {
+		return err
+	}
+	r.Data = data
+	err = dao.saveKeyValueToBucket(models.BucketReceivedTransfer, key, r)
+	return models.GeneratDBError(err)
+}
+
 //GetReceivedTransferList returns the received transfer between from and to blocks
 func (dao *GkvDB) GetReceivedTransferList(tokenAddress common.Address, fromBlock, toBlock int64) (transfers []*models.ReceivedTransfer, err error) {
 	var tb *gkvdb.Table
@@ -69,7 +80,7 @@ func (dao *GkvDB) GetReceivedTransferList(tokenAddress common.Address, fromBlock
 	}
 	for _, v := range buf {
 		var st models.ReceivedTransfer
-		gobDecode(v, &st)
+		dao.decodeValue(v, &st)
 		appendReceivedTransferIfMatch(&transfers, &st, tokenAddress, fromBlock, toBlock)
 	}
 	return
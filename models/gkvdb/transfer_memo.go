@@ -0,0 +1,21 @@
+package gkvdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SaveTransferMemo :
+func (dao *GkvDB) SaveTransferMemo(m *models.TransferMemo) error {
+	m.Key = models.NewTransferMemoKey(m.MemoID, m.Sender)
+	err := dao.saveKeyValueToBucket(models.BucketTransferMemo, m.Key, m)
+	return models.GeneratDBError(err)
+}
+
+// GetTransferMemo :
+func (dao *GkvDB) GetTransferMemo(memoID common.Hash, sender common.Address) (m *models.TransferMemo, err error) {
+	m = &models.TransferMemo{}
+	err = dao.getKeyValueToBucket(models.BucketTransferMemo, models.NewTransferMemoKey(memoID, sender), m)
+	err = models.GeneratDBError(err)
+	return
+}
@@ -7,12 +7,13 @@ import (
 
 // GkvTX :
 type GkvTX struct {
-	tx *gkvdb.Transaction
+	tx  *gkvdb.Transaction
+	dao *GkvDB
 }
 
 // Set :
 func (gtx *GkvTX) Set(table string, key interface{}, value interface{}) error {
-	return gtx.tx.SetTo(gobEncode(key), gobEncode(value), table)
+	return gtx.tx.SetTo(gobEncode(key), gtx.dao.encodeValue(value), table)
 }
 
 // Save :
@@ -35,6 +36,7 @@ func (gtx *GkvTX) Rollback() error {
 func (dao *GkvDB) StartTx() (tx models.TX) {
 	gtx := dao.db.Begin()
 	return &GkvTX{
-		tx: gtx,
+		tx:  gtx,
+		dao: dao,
 	}
 }
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/gob"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// IdempotencyOperation 使用幂等key的接口类型
+type IdempotencyOperation string
+
+/* #nosec */
+const (
+	IdempotencyOperationTransfer IdempotencyOperation = "transfer"
+	IdempotencyOperationDeposit  IdempotencyOperation = "deposit"
+	IdempotencyOperationWithdraw IdempotencyOperation = "withdraw"
+	IdempotencyOperationClose    IdempotencyOperation = "close"
+	IdempotencyOperationSettle   IdempotencyOperation = "settle"
+)
+
+// IdempotencyRecord :
+// 记录客户端提交的幂等key与第一次请求结果的对应关系,重复提交时直接返回第一次的结果
+// records the result of the first request submitted with a client supplied idempotency key
+type IdempotencyRecord struct {
+	Key               string               `json:"key" storm:"id"`
+	Operation         IdempotencyOperation `json:"operation"`
+	RequestHash       common.Hash          `json:"request_hash"` // 请求参数的hash,同一个key用于不同的参数时拒绝
+	TokenAddress      common.Address       `json:"token_address"`
+	PartnerAddress    common.Address       `json:"partner_address"`
+	LockSecretHash    common.Hash          `json:"lock_secret_hash"`   // transfer的结果
+	ChannelIdentifier common.Hash          `json:"channel_identifier"` // deposit,withdraw,close的结果
+	TXHash            common.Hash          `json:"tx_hash"`            // 对应的链上tx,tx是异步发出的,可能在第一次请求返回时还没有
+	CreateTime        int64                `json:"create_time" storm:"index"`
+	ErrorCode         int                  `json:"error_code,omitempty"` // 请求已经执行,但是失败或者结果未知,比如tx发出以后没有等到结果
+	ErrorMsg          string               `json:"error_message,omitempty"`
+}
+
+func init() {
+	gob.Register(&IdempotencyRecord{})
+}
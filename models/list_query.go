@@ -0,0 +1,171 @@
+package models
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+ListQuery 列表查询的过滤条件,排序和分页,零值的条件代表不限制.
+列表按照记录的时间和key排序,时间分别是SentTransferDetail.SendingTime,ReceivedTransfer.TimeStamp,
+FeeChargeRecord.Timestamp和TXInfo.CallTime,FromTime和ToTime也是这个时间
+*/
+type ListQuery struct {
+	TokenAddress      common.Address
+	FromTime          int64          // >=
+	ToTime            int64          // <
+	FromBlock         int64          // >=,TXInfo是PackBlockNumber
+	ToBlock           int64          // <
+	Status            string         // 多个用逗号分隔,SentTransferDetail是状态码,TXInfo是TXInfoStatus
+	Type              string         // 只用于TXInfo,多个用逗号分隔
+	Target            common.Address // SentTransferDetail.TargetAddress,FeeChargeRecord.TransferTo
+	Initiator         common.Address // ReceivedTransfer.FromAddress,FeeChargeRecord.TransferFrom
+	ChannelIdentifier common.Hash    // FeeChargeRecord是InChannel或者OutChannel
+	MinAmount         *big.Int       // >=,FeeChargeRecord是TransferAmount,TXInfo没有金额
+	MaxAmount         *big.Int       // <=
+	Desc              bool           // 按照时间倒序
+	Cursor            string         // 上一页返回的nextCursor,为空从第一条开始
+	Limit             int            // 每页的记录数,<=0 不分页
+
+	hasCursor  bool
+	cursorTime int64
+	cursorKey  []byte
+}
+
+//ListCursor 返回排在(t,key)这条记录后面的记录的游标
+func ListCursor(t int64, key []byte) string {
+	return strconv.FormatInt(t, 10) + "-" + hex.EncodeToString(key)
+}
+
+//ParseCursor 查询之前解析Cursor
+func (lq *ListQuery) ParseCursor() (err error) {
+	lq.hasCursor = false
+	if lq.Cursor == "" {
+		return
+	}
+	ss := strings.SplitN(lq.Cursor, "-", 2)
+	if len(ss) == 2 {
+		lq.cursorTime, err = strconv.ParseInt(ss[0], 10, 64)
+		if err == nil {
+			lq.cursorKey, err = hex.DecodeString(ss[1])
+		}
+	}
+	if len(ss) != 2 || err != nil {
+		return rerr.ErrArgumentError.Printf("invalid cursor %s", lq.Cursor)
+	}
+	lq.hasCursor = true
+	return
+}
+
+//CursorCondition 排在游标后面的记录,ok为false表示从第一条开始
+func (lq *ListQuery) CursorCondition() (t int64, key []byte, ok bool) {
+	return lq.cursorTime, lq.cursorKey, lq.hasCursor
+}
+
+//afterCursor 按照排序顺序(t,key)是否在游标后面
+func (lq *ListQuery) afterCursor(t int64, key []byte) bool {
+	if !lq.hasCursor {
+		return true
+	}
+	c := bytes.Compare(key, lq.cursorKey)
+	if t != lq.cursorTime {
+		c = 1
+		if t < lq.cursorTime {
+			c = -1
+		}
+	}
+	if lq.Desc {
+		return c < 0
+	}
+	return c > 0
+}
+
+//Page 把按照顺序查询到的最多Limit+1条记录截断到Limit条,keyOf返回第i条记录的时间和key
+func (lq *ListQuery) Page(n int, keyOf func(i int) (t int64, key []byte)) (length int, nextCursor string) {
+	if lq.Limit <= 0 || n <= lq.Limit {
+		return n, ""
+	}
+	t, key := keyOf(lq.Limit - 1)
+	return lq.Limit, ListCursor(t, key)
+}
+
+func (lq *ListQuery) matchCommon(t int64, key []byte, token []byte, blockNumber int64, amount *big.Int) bool {
+	if amount != nil {
+		if lq.MinAmount != nil && amount.Cmp(lq.MinAmount) < 0 {
+			return false
+		}
+		if lq.MaxAmount != nil && amount.Cmp(lq.MaxAmount) > 0 {
+			return false
+		}
+	}
+	if lq.TokenAddress != utils.EmptyAddress && !bytes.Equal(lq.TokenAddress[:], token) {
+		return false
+	}
+	if lq.FromTime > 0 && t < lq.FromTime {
+		return false
+	}
+	if lq.ToTime > 0 && t >= lq.ToTime {
+		return false
+	}
+	if lq.FromBlock > 0 && blockNumber < lq.FromBlock {
+		return false
+	}
+	if lq.ToBlock > 0 && blockNumber >= lq.ToBlock {
+		return false
+	}
+	return lq.afterCursor(t, key)
+}
+
+func matchIn(list string, s string) bool {
+	if list == "" {
+		return true
+	}
+	for _, l := range strings.Split(list, ",") {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAddress(a common.Address, b []byte) bool {
+	return a == utils.EmptyAddress || bytes.Equal(a[:], b)
+}
+
+//MatchSentTransferDetail 查询条件和游标
+func (lq *ListQuery) MatchSentTransferDetail(t *SentTransferDetail) bool {
+	return matchIn(lq.Status, strconv.Itoa(int(t.Status))) &&
+		matchAddress(lq.Target, t.TargetAddress[:]) &&
+		(lq.ChannelIdentifier == utils.EmptyHash || lq.ChannelIdentifier == t.ChannelIdentifier) &&
+		lq.matchCommon(t.SendingTime, []byte(t.Key), t.TokenAddress[:], t.BlockNumber, t.Amount)
+}
+
+//MatchReceivedTransfer 查询条件和游标
+func (lq *ListQuery) MatchReceivedTransfer(t *ReceivedTransfer) bool {
+	return matchAddress(lq.Initiator, t.FromAddress[:]) &&
+		(lq.ChannelIdentifier == utils.EmptyHash || lq.ChannelIdentifier == t.ChannelIdentifier) &&
+		lq.matchCommon(t.TimeStamp, []byte(t.Key), t.TokenAddress[:], t.BlockNumber, t.Amount)
+}
+
+//MatchFeeChargeRecord 查询条件和游标
+func (lq *ListQuery) MatchFeeChargeRecord(r *FeeChargerRecordSerialization) bool {
+	return matchAddress(lq.Target, r.TransferTo) &&
+		matchAddress(lq.Initiator, r.TransferFrom) &&
+		(lq.ChannelIdentifier == utils.EmptyHash || bytes.Equal(lq.ChannelIdentifier[:], r.InChannel) || bytes.Equal(lq.ChannelIdentifier[:], r.OutChannel)) &&
+		lq.matchCommon(r.Timestamp, r.Key, r.TokenAddress, r.BlockNumber, r.TransferAmount)
+}
+
+//MatchTXInfo 查询条件和游标,不使用金额,Target和Initiator条件
+func (lq *ListQuery) MatchTXInfo(tis *TXInfoSerialization) bool {
+	return matchIn(lq.Status, tis.Status) &&
+		matchIn(lq.Type, tis.Type) &&
+		(lq.ChannelIdentifier == utils.EmptyHash || bytes.Equal(lq.ChannelIdentifier[:], tis.ChannelIdentifier)) &&
+		lq.matchCommon(tis.CallTime, tis.TXHash, tis.TokenAddress, tis.PackBlockNumber, nil)
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/gob"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+MediationPolicy 为其他节点中转交易的策略,在开始中转之前检查.
+TokenAddress为空地址的是所有token的默认策略,设置了token自己的策略以后默认策略对这个token不再生效.
+Allowed列表为空表示不限制,在Blocked列表中的节点总是被拒绝,限额为nil或者0表示不限制
+*/
+type MediationPolicy struct {
+	Key                       string           `json:"-" storm:"id"`
+	TokenAddress              common.Address   `json:"token_address"`
+	AllowedPayers             []common.Address `json:"allowed_payers"`
+	BlockedPayers             []common.Address `json:"blocked_payers"`
+	AllowedNextHops           []common.Address `json:"allowed_next_hops"`
+	BlockedNextHops           []common.Address `json:"blocked_next_hops"`
+	MaxAmount                 *big.Int         `json:"max_amount"`                    // 单笔中转的最大金额
+	MaxLocksPerChannel        int              `json:"max_locks_per_channel"`         // 上家和下家通道中同时存在的锁的最大数量
+	MaxLockedAmountPerChannel *big.Int         `json:"max_locked_amount_per_channel"` // 上家和下家通道中锁的总金额,避免通过很多笔小额交易锁住大量资金
+	MinRevealTimeoutMargin    int              `json:"min_reveal_timeout_margin"`     // 上家的锁过期之前,下家的reveal timeout以外至少还要剩余的块数
+}
+
+func inAddressList(addr common.Address, list []common.Address) bool {
+	for _, a := range list {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+//IsPayerAllowed returns true if we can mediate transfers from payer
+func (p *MediationPolicy) IsPayerAllowed(payer common.Address) bool {
+	if inAddressList(payer, p.BlockedPayers) {
+		return false
+	}
+	return len(p.AllowedPayers) == 0 || inAddressList(payer, p.AllowedPayers)
+}
+
+//IsNextHopAllowed returns true if we can forward mediated transfers to next hop
+func (p *MediationPolicy) IsNextHopAllowed(nextHop common.Address) bool {
+	if inAddressList(nextHop, p.BlockedNextHops) {
+		return false
+	}
+	return len(p.AllowedNextHops) == 0 || inAddressList(nextHop, p.AllowedNextHops)
+}
+
+//IsAmountAllowed returns true if amount does not exceed MaxAmount
+func (p *MediationPolicy) IsAmountAllowed(amount *big.Int) bool {
+	return p.MaxAmount == nil || p.MaxAmount.Sign() == 0 || amount.Cmp(p.MaxAmount) <= 0
+}
+
+//IsLocksAllowed returns true if a channel holding locks can be used for mediation
+func (p *MediationPolicy) IsLocksAllowed(locks int) bool {
+	return p.MaxLocksPerChannel <= 0 || locks <= p.MaxLocksPerChannel
+}
+
+//IsLockedAmountAllowed returns true if a channel with amount locked can be used for mediation
+func (p *MediationPolicy) IsLockedAmountAllowed(amount *big.Int) bool {
+	return p.MaxLockedAmountPerChannel == nil || p.MaxLockedAmountPerChannel.Sign() == 0 || amount.Cmp(p.MaxLockedAmountPerChannel) <= 0
+}
+
+func init() {
+	gob.Register(&MediationPolicy{})
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+)
+
+/*
+MigrationTX 升级步骤读写数据库的接口,按照storm的bucket读写解码以后的值.
+sqlite中有独立表的bucket只能修改已经存在的记录,修改不能改变key和索引列
+*/
+type MigrationTX interface {
+	// ForEach 按照key的顺序解码bucket中所有的值,newValue返回用来解码的对象.f中可以修改这个bucket
+	ForEach(bucket string, newValue func() interface{}, f func(key []byte, v interface{}) error) error
+	Get(bucket string, key []byte, to interface{}) error // key不存在的时候返回rerr.ErrNotFound
+	Put(bucket string, key []byte, value interface{}) error
+	Commit() error
+	Rollback() error
+}
+
+//Migrator 数据库实现的升级接口
+type Migrator interface {
+	DBPath() string
+	GetDbVersion() (ver int, err error)
+	Backup(path string) error // 在升级之前把数据库复制到path
+	BeginMigration() (tx MigrationTX, err error)
+}
+
+/*
+Migration 把数据库从Version-1升级到Version的一个步骤,
+返回修改的记录数,dry run的时候所有的修改最后会被回滚
+*/
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(tx MigrationTX) (changed int, err error)
+}
+
+//MigrationStepReport 一个升级步骤的结果
+type MigrationStepReport struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	Changed     int    `json:"changed"`
+}
+
+//MigrationReport 一次升级的结果
+type MigrationReport struct {
+	FromVersion int                    `json:"from_version"`
+	ToVersion   int                    `json:"to_version"`
+	DryRun      bool                   `json:"dry_run"`
+	BackupFile  string                 `json:"backup_file,omitempty"`
+	Steps       []*MigrationStepReport `json:"steps"`
+}
+
+/*
+Migrations 按照版本排序的所有升级步骤,最后一个步骤的版本就是DbVersion.
+修改保存到数据库中的结构,如果旧的数据不能直接gob解码,必须在这里增加一个步骤
+*/
+var Migrations = []*Migration{
+	{
+		Version:     2,
+		Description: "record save time of acks for retention",
+		Migrate:     migrateAckTime,
+	},
+}
+
+func init() {
+	for i, m := range Migrations {
+		if m.Version != i+2 {
+			panic(fmt.Sprintf("migration %s has version %d, expect %d", m.Description, m.Version, i+2))
+		}
+	}
+	if len(Migrations)+1 != DbVersion {
+		panic("DbVersion must be the version of the last migration")
+	}
+}
+
+/*
+RunMigrations 把数据库升级到DbVersion,所有的步骤和新的版本号在一个事务中提交.
+不是dry run的时候先备份数据库,dry run执行所有的步骤以后回滚,只报告会修改哪些记录
+*/
+func RunMigrations(m Migrator, dryRun bool) (report *MigrationReport, err error) {
+	ver, err := m.GetDbVersion()
+	if err != nil {
+		return
+	}
+	report = &MigrationReport{
+		FromVersion: ver,
+		ToVersion:   DbVersion,
+		DryRun:      dryRun,
+		Steps:       []*MigrationStepReport{},
+	}
+	if ver > DbVersion {
+		err = rerr.ErrDBMigration.Printf("database %s version %d is newer than %d,upgrade photon", m.DBPath(), ver, DbVersion)
+		return
+	}
+	if ver == DbVersion {
+		return
+	}
+	if !dryRun {
+		report.BackupFile = fmt.Sprintf("%s.v%d-%s.bak", m.DBPath(), ver, time.Now().Format("20060102-150405"))
+		err = m.Backup(report.BackupFile)
+		if err != nil {
+			err = rerr.ErrDBMigration.Printf("backup %s err %s", m.DBPath(), err)
+			return
+		}
+	}
+	tx, err := m.BeginMigration()
+	if err != nil {
+		return
+	}
+	for _, step := range Migrations {
+		if step.Version <= ver {
+			continue
+		}
+		var changed int
+		changed, err = step.Migrate(tx)
+		if err != nil {
+			tx.Rollback()
+			err = rerr.ErrDBMigration.Printf("migrate to version %d err %s", step.Version, err)
+			return
+		}
+		report.Steps = append(report.Steps, &MigrationStepReport{
+			Version:     step.Version,
+			Description: step.Description,
+			Changed:     changed,
+		})
+		log.Info(fmt.Sprintf("migrate %s to version %d: %s, %d records changed,dry run=%v", m.DBPath(), step.Version, step.Description, changed, dryRun))
+	}
+	if dryRun {
+		err = tx.Rollback()
+		return
+	}
+	err = tx.Put(BucketMeta, []byte(KeyVersion), DbVersion)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+	err = tx.Commit()
+	return
+}
+
+//migrateAckTime 以前保存的ack没有时间,从升级的时候开始计算保留时间
+func migrateAckTime(tx MigrationTX) (changed int, err error) {
+	now := time.Now().Unix()
+	err = tx.ForEach(BucketAck, func() interface{} {
+		return new([]byte)
+	}, func(key []byte, v interface{}) error {
+		var t int64
+		err2 := tx.Get(BucketAckTime, key, &t)
+		if err2 == nil {
+			return nil
+		}
+		if err2 != rerr.ErrNotFound {
+			return err2
+		}
+		changed++
+		return tx.Put(BucketAckTime, key, now)
+	})
+	return
+}
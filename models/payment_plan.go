@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/gob"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PaymentPlanStatus 定时支付计划的状态
+type PaymentPlanStatus string
+
+/* #nosec */
+const (
+	PaymentPlanStatusActive   PaymentPlanStatus = "active"
+	PaymentPlanStatusPaused   PaymentPlanStatus = "paused"
+	PaymentPlanStatusCanceled PaymentPlanStatus = "canceled"
+	PaymentPlanStatusFinished PaymentPlanStatus = "finished"
+)
+
+// MaxPaymentPlanAttempts 每个计划只保留最近的支付记录
+const MaxPaymentPlanAttempts = 100
+
+// PaymentPlanAttempt :
+// 定时支付计划的一次支付,交易发出之后可以通过LockSecretHash查询对应的SentTransferDetail
+type PaymentPlanAttempt struct {
+	Run            int         `json:"run"` // 第几次支付,从1开始
+	Time           int64       `json:"time"`
+	LockSecretHash common.Hash `json:"lock_secret_hash"`
+	Error          string      `json:"error"` // 发起交易失败的原因
+}
+
+// PaymentPlan :
+// 定时支付计划,在NextTime向TargetAddress支付Amount,
+// Interval为0表示只在StartTime支付一次,否则每隔Interval秒支付一次,直到EndTime或者支付了MaxTimes次
+type PaymentPlan struct {
+	Key           string                `json:"key" storm:"id"`
+	TokenAddress  common.Address        `json:"token_address"`
+	TargetAddress common.Address        `json:"target_address"`
+	Amount        *big.Int              `json:"amount"`
+	Data          string                `json:"data"`
+	StartTime     int64                 `json:"start_time"`
+	Interval      int64                 `json:"interval"`  // 单位秒
+	EndTime       int64                 `json:"end_time"`  // 0表示不限制
+	MaxTimes      int                   `json:"max_times"` // 0表示不限制
+	Times         int                   `json:"times"`     // 已经支付的次数
+	NextTime      int64                 `json:"next_time" storm:"index"`
+	Status        PaymentPlanStatus     `json:"status"`
+	CreateTime    int64                 `json:"create_time"`
+	Attempts      []*PaymentPlanAttempt `json:"attempts"`
+	APITokenKey   string                `json:"-"` // 通过有支付上限的API token创建时,预留了所有支付的金额,没有支付的部分退还给它
+}
+
+/*
+ScheduleNext 计算now之后的下一次支付时间,
+节点离线期间错过的支付不会补上,返回false表示计划已经结束
+*/
+func (p *PaymentPlan) ScheduleNext(now int64) bool {
+	if p.Interval <= 0 || (p.MaxTimes > 0 && p.Times >= p.MaxTimes) {
+		p.Status = PaymentPlanStatusFinished
+		return false
+	}
+	next := p.NextTime + p.Interval
+	if next <= now {
+		next += ((now-next)/p.Interval + 1) * p.Interval
+	}
+	if p.EndTime > 0 && next > p.EndTime {
+		p.Status = PaymentPlanStatusFinished
+		return false
+	}
+	p.NextTime = next
+	return true
+}
+
+// AddAttempt 记录一次支付,只保留最近MaxPaymentPlanAttempts次
+func (p *PaymentPlan) AddAttempt(a *PaymentPlanAttempt) {
+	p.Attempts = append(p.Attempts, a)
+	if len(p.Attempts) > MaxPaymentPlanAttempts {
+		p.Attempts = p.Attempts[len(p.Attempts)-MaxPaymentPlanAttempts:]
+	}
+}
+
+func init() {
+	gob.Register(&PaymentPlan{})
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/gob"
+	"math/big"
+
+	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+/*
+PaymentReceipt 支付凭证,交易成功以后发起方可以导出,交给第三方离线验证.
+target签名的SecretRequest证明target认可了这笔交易的金额,secret证明发起方知道对应的密码,
+发起方的签名保证了token,data和时间等其他信息
+*/
+type PaymentReceipt struct {
+	Key              string         `json:"-" storm:"id"`
+	TokenAddress     common.Address `json:"token_address"`
+	InitiatorAddress common.Address `json:"initiator_address"`
+	TargetAddress    common.Address `json:"target_address"`
+	Amount           *big.Int       `json:"amount"`
+	LockSecretHash   common.Hash    `json:"lock_secret_hash"`
+	Secret           common.Hash    `json:"secret"`
+	SecretRequest    hexutil.Bytes  `json:"secret_request"` // target签名的SecretRequest消息
+	Data             string         `json:"data"`
+	Timestamp        int64          `json:"timestamp"` // 交易完成的时间
+	Signature        hexutil.Bytes  `json:"signature"` // 发起方的签名
+}
+
+//NewPaymentReceiptKey key of a receipt
+func NewPaymentReceiptKey(tokenAddress common.Address, lockSecretHash common.Hash) string {
+	return utils.Sha3(tokenAddress[:], lockSecretHash[:]).String()
+}
+
+//signData 发起方签名的内容,变长的字段只使用hash
+func (r *PaymentReceipt) signData() []byte {
+	buf := new(bytes.Buffer)
+	buf.Write(r.TokenAddress[:])
+	buf.Write(r.InitiatorAddress[:])
+	buf.Write(r.TargetAddress[:])
+	buf.Write(utils.BigIntTo32Bytes(r.Amount))
+	buf.Write(r.LockSecretHash[:])
+	buf.Write(r.Secret[:])
+	buf.Write(utils.Sha3(r.SecretRequest).Bytes())
+	buf.Write(utils.Sha3([]byte(r.Data)).Bytes())
+	binary.Write(buf, binary.BigEndian, r.Timestamp)
+	return buf.Bytes()
+}
+
+//Sign 发起方签名
+func (r *PaymentReceipt) Sign(privKey *ecdsa.PrivateKey) (err error) {
+	r.Signature, err = utils.SignData(privKey, r.signData())
+	return
+}
+
+//Verify 不依赖于任何节点状态,只验证凭证中的各个签名是否一致
+func (r *PaymentReceipt) Verify() error {
+	if r.Amount == nil || r.Amount.Cmp(utils.BigInt0) <= 0 {
+		return rerr.ErrInvalidPaymentReceipt.Append("invalid amount")
+	}
+	if utils.ShaSecret(r.Secret[:]) != r.LockSecretHash {
+		return rerr.ErrInvalidPaymentReceipt.Append("secret does not match lock_secret_hash")
+	}
+	sr := new(encoding.SecretRequest)
+	err := sr.UnPack(r.SecretRequest)
+	if err != nil {
+		return rerr.ErrInvalidPaymentReceipt.Printf("invalid secret_request %s", err)
+	}
+	if sr.Sender != r.TargetAddress {
+		return rerr.ErrInvalidPaymentReceipt.Append("secret_request is not signed by target")
+	}
+	if sr.LockSecretHash != r.LockSecretHash || sr.PaymentAmount.Cmp(r.Amount) != 0 {
+		return rerr.ErrInvalidPaymentReceipt.Append("secret_request does not match lock_secret_hash or amount")
+	}
+	signer, err := utils.Ecrecover(utils.Sha3(r.signData()), r.Signature)
+	if err != nil || signer != r.InitiatorAddress {
+		return rerr.ErrInvalidPaymentReceipt.Append("receipt is not signed by initiator")
+	}
+	return nil
+}
+
+func init() {
+	gob.Register(&PaymentReceipt{})
+}
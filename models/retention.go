@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+IncomeAggregate 归档收款和手续费记录以后,按照token和小时保留的收益汇总,
+这样归档以后收益统计仍然是准确的.按小时汇总是为了按照本地时区统计每天的收益
+*/
+type IncomeAggregate struct {
+	Key               string         `json:"-" storm:"id"`
+	TokenAddress      common.Address `json:"token_address"`
+	TokenAddressBytes []byte         `json:"-" storm:"index"`
+	Hour              int64          `json:"hour" storm:"index"` // 这个小时开始的时间戳
+	TransferAmount    *big.Int       `json:"transfer_amount"`    // data不为空的收款
+	TransferCount     int            `json:"transfer_count"`
+	FeeAmount         *big.Int       `json:"fee_amount"`
+	FeeCount          int            `json:"fee_count"`
+}
+
+//NewIncomeAggregate 时间戳timestamp所在小时的收益汇总
+func NewIncomeAggregate(tokenAddress common.Address, timestamp int64) *IncomeAggregate {
+	hour := timestamp - timestamp%3600
+	return &IncomeAggregate{
+		Key:               fmt.Sprintf("%s-%d", tokenAddress.String(), hour),
+		TokenAddress:      tokenAddress,
+		TokenAddressBytes: tokenAddress[:],
+		Hour:              hour,
+		TransferAmount:    big.NewInt(0),
+		FeeAmount:         big.NewInt(0),
+	}
+}
+
+//Add 把a累加到这个汇总上
+func (ia *IncomeAggregate) Add(a *IncomeAggregate) {
+	ia.TransferAmount = new(big.Int).Add(ia.TransferAmount, a.TransferAmount)
+	ia.TransferCount += a.TransferCount
+	ia.FeeAmount = new(big.Int).Add(ia.FeeAmount, a.FeeAmount)
+	ia.FeeCount += a.FeeCount
+}
+
+//AckRecord 保存的ack和保存的时间,升级以前保存的ack从升级的时候开始计算
+type AckRecord struct {
+	EchoHash common.Hash `json:"echo_hash"`
+	Ack      []byte      `json:"ack"`
+	Time     int64       `json:"time"`
+}
+
+func init() {
+	gob.Register(&IncomeAggregate{})
+}
@@ -28,6 +28,10 @@ type SentTransferDetail struct {
 	*/
 	ChannelIdentifier common.Hash `json:"channel_identifier"`
 	OpenBlockNumber   int64       `json:"open_block_number"`
+
+	// 定时支付计划发起的交易,记录计划的Key和第几次支付
+	PaymentPlanKey string `json:"payment_plan_key,omitempty"`
+	PaymentPlanRun int    `json:"payment_plan_run,omitempty"`
 }
 
 func init() {
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"encoding/gob"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+SpendingPolicy 每种token的支付限制,发起交易,withdraw和关闭通道之前检查.
+限额为nil表示不限制,AllowedTargets为空表示可以向任何节点支付
+*/
+type SpendingPolicy struct {
+	Key               string           `json:"-" storm:"id"`
+	TokenAddress      common.Address   `json:"token_address"`
+	MaxPerTransfer    *big.Int         `json:"max_per_transfer"`
+	MaxPerHour        *big.Int         `json:"max_per_hour"` // 最近一小时内发起的交易总额
+	MaxPerDay         *big.Int         `json:"max_per_day"`  // 最近24小时内发起的交易总额
+	AllowedTargets    []common.Address `json:"allowed_targets"`
+	ApprovalThreshold *big.Int         `json:"approval_threshold"` // 交易金额,withdraw金额或者关闭通道时自己的余额达到这个值需要人工审批
+}
+
+//IsTargetAllowed returns true if policy allows paying to target
+func (p *SpendingPolicy) IsTargetAllowed(target common.Address) bool {
+	if len(p.AllowedTargets) == 0 {
+		return true
+	}
+	for _, t := range p.AllowedTargets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+//NeedApproval returns true if amount reaches the approval threshold
+func (p *SpendingPolicy) NeedApproval(amount *big.Int) bool {
+	return p.ApprovalThreshold != nil && amount != nil && amount.Cmp(p.ApprovalThreshold) >= 0
+}
+
+//PendingApprovalOperation 等待审批的操作
+type PendingApprovalOperation string
+
+const (
+	//PendingApprovalOperationTransfer 发起交易
+	PendingApprovalOperationTransfer PendingApprovalOperation = "transfer"
+	//PendingApprovalOperationWithdraw withdraw
+	PendingApprovalOperationWithdraw PendingApprovalOperation = "withdraw"
+	//PendingApprovalOperationClose 关闭通道
+	PendingApprovalOperationClose PendingApprovalOperation = "close"
+)
+
+//PendingApprovalStatus 审批状态
+type PendingApprovalStatus string
+
+const (
+	//PendingApprovalStatusPending 等待审批
+	PendingApprovalStatusPending PendingApprovalStatus = "pending"
+	//PendingApprovalStatusApproved 已经批准并执行,执行结果见Error
+	PendingApprovalStatusApproved PendingApprovalStatus = "approved"
+	//PendingApprovalStatusRejected 已经拒绝
+	PendingApprovalStatusRejected PendingApprovalStatus = "rejected"
+)
+
+/*
+PendingApproval 超过审批阈值的操作,批准以后按照原来的参数执行
+*/
+type PendingApproval struct {
+	Key               string                    `json:"key" storm:"id"`
+	Operation         PendingApprovalOperation  `json:"operation"`
+	TokenAddress      common.Address            `json:"token_address"`
+	Amount            *big.Int                  `json:"amount"`
+	Target            common.Address            `json:"target_address,omitempty"`
+	ChannelIdentifier common.Hash               `json:"channel_identifier,omitempty"`
+	Secret            common.Hash               `json:"-"`
+	IsDirect          bool                      `json:"is_direct,omitempty"`
+	Keysend           bool                      `json:"keysend,omitempty"`
+	Data              string                    `json:"data,omitempty"`
+	RouteInfo         []byte                    `json:"-"` // json格式的路由信息
+	TargetPublicKey   []byte                    `json:"-"`
+	OnionPublicKeys   map[common.Address][]byte `json:"-"`
+	OnionAllowClear   bool                      `json:"-"`
+	Status            PendingApprovalStatus     `json:"status"`
+	LockSecretHash    common.Hash               `json:"lock_secret_hash,omitempty"` // 批准以后发起的交易
+	PaymentPlanKey    string                    `json:"payment_plan_key,omitempty"`
+	PaymentPlanRun    int                       `json:"payment_plan_run,omitempty"`
+	Error             string                    `json:"error,omitempty"`
+	CreateTime        int64                     `json:"create_time" storm:"index"`
+	FinishTime        int64                     `json:"finish_time"`
+}
+
+func init() {
+	gob.Register(&SpendingPolicy{})
+	gob.Register(&PendingApproval{})
+}
@@ -0,0 +1,52 @@
+package sqlitedb
+
+import (
+	"fmt"
+
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//NewSentEnvelopMessager create a sending EnvelopMessager in db
+func (dao *SQLiteDB) NewSentEnvelopMessager(msg encoding.EnvelopMessager, receiver common.Address) {
+	echohash := utils.Sha3(msg.Pack(), receiver[:])
+	tr := &models.SentEnvelopMessager{
+		Message:  msg,
+		Receiver: receiver,
+		Time:     time.Now(),
+		EchoHash: echohash[:],
+	}
+	log.Trace(fmt.Sprintf("NewSentEnvelopMessager EchoHash=%s", utils.BPex(tr.EchoHash)))
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketEnvelopMessager, tr.EchoHash, tr)
+	if err != nil {
+		log.Error(fmt.Sprintf("NewSentEnvelopMessager err=%s", err))
+	}
+}
+
+//DeleteEnvelopMessager  delete a sending message from db
+func (dao *SQLiteDB) DeleteEnvelopMessager(echohash common.Hash) {
+	err := dao.removeKeyValueFromBucket(models.BucketEnvelopMessager, echohash[:])
+	if err != nil {
+		log.Warn(fmt.Sprintf("try to remove envelop message %s,but err= %s", utils.HPex(echohash), err))
+	}
+}
+
+//GetAllOrderedSentEnvelopMessager returns all EnvelopMessager message that have not receive ack and order them by nonce
+func (dao *SQLiteDB) GetAllOrderedSentEnvelopMessager() []*models.SentEnvelopMessager {
+	var msgs []*models.SentEnvelopMessager
+	err := dao.getAllFromBucket(models.BucketEnvelopMessager, func() interface{} {
+		m := new(models.SentEnvelopMessager)
+		msgs = append(msgs, m)
+		return m
+	})
+	if err != nil {
+		panic(fmt.Sprintf("GetAllOrderedSentEnvelopMessager err=%s", err))
+	}
+	models.SortEnvelopMessager(msgs)
+	return msgs
+}
@@ -0,0 +1,47 @@
+package sqlitedb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//GetAck get message related ack message
+func (dao *SQLiteDB) GetAck(echoHash common.Hash) []byte {
+	var data []byte
+	err := dao.getKeyValueToBucket(models.BucketAck, echoHash[:], &data)
+	if err != nil && err != rerr.ErrNotFound {
+		panic(fmt.Sprintf("GetAck err %s", err))
+	}
+	log.Trace(fmt.Sprintf("get ack %s from db,result=%d", utils.HPex(echoHash), len(data)))
+	return data
+}
+
+//SaveAck save a new ack to db
+func (dao *SQLiteDB) SaveAck(echoHash common.Hash, ack []byte, tx models.TX) {
+	log.Trace(fmt.Sprintf("save ack %s to db", utils.HPex(echoHash)))
+	err := tx.Set(models.BucketAck, echoHash[:], ack)
+	if err == nil {
+		//记录保存的时间,按照保留时间归档
+		err = tx.Set(models.BucketAckTime, echoHash[:], time.Now().Unix())
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("db err %s", err))
+	}
+}
+
+//SaveAckNoTx save a ack to db
+func (dao *SQLiteDB) SaveAckNoTx(echoHash common.Hash, ack []byte) {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketAck, echoHash[:], ack)
+	if err == nil {
+		err = dao.saveKeyValueToBucket(dao.db, models.BucketAckTime, echoHash[:], time.Now().Unix())
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("save ack to db err %s", err))
+	}
+}
@@ -0,0 +1,30 @@
+package sqlitedb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+)
+
+// SaveAPIToken :
+func (dao *SQLiteDB) SaveAPIToken(t *models.APIToken) error {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketAPIToken, t.Key, t)
+	return models.GeneratDBError(err)
+}
+
+// GetAPIToken :
+func (dao *SQLiteDB) GetAPIToken(key string) (t *models.APIToken, err error) {
+	t = &models.APIToken{}
+	err = dao.getKeyValueToBucket(models.BucketAPIToken, key, t)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetAPITokenList :
+func (dao *SQLiteDB) GetAPITokenList() (ts []*models.APIToken, err error) {
+	err = dao.getAllFromBucket(models.BucketAPIToken, func() interface{} {
+		t := &models.APIToken{}
+		ts = append(ts, t)
+		return t
+	})
+	err = models.GeneratDBError(err)
+	return
+}
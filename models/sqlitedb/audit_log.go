@@ -0,0 +1,56 @@
+package sqlitedb
+
+import (
+	"database/sql"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+)
+
+// AppendAuditLog 只能追加,已经存在的记录不能被覆盖
+func (dao *SQLiteDB) AppendAuditLog(l *models.AuditLog) error {
+	_, err := dao.db.Exec("INSERT INTO kv (bucket, key, value) VALUES (?, ?, ?)", models.BucketAuditLog, toBytes(l.Seq), dao.encodeValue(l))
+	if err != nil && dao.hasKey(models.BucketAuditLog, l.Seq) {
+		return rerr.ErrDBDuplicateKey.Printf("audit log %d", l.Seq)
+	}
+	return models.GeneratDBError(err)
+}
+
+func (dao *SQLiteDB) hasKey(bucket string, key interface{}) bool {
+	var n int
+	err := dao.db.QueryRow("SELECT count(*) FROM kv WHERE bucket = ? AND key = ?", bucket, toBytes(key)).Scan(&n)
+	return err == nil && n > 0
+}
+
+// GetLastAuditLog :
+func (dao *SQLiteDB) GetLastAuditLog() (l *models.AuditLog, err error) {
+	var buf []byte
+	//key是大端编码的Seq,倒序的第一条就是最后一条
+	err = dao.db.QueryRow("SELECT value FROM kv WHERE bucket = ? ORDER BY key DESC LIMIT 1", models.BucketAuditLog).Scan(&buf)
+	if err == sql.ErrNoRows {
+		err = rerr.ErrNotFound
+		return
+	}
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	l = &models.AuditLog{}
+	err = dao.decodeValue(buf, l)
+	if err != nil {
+		l = nil
+		err = models.GeneratDBError(err)
+	}
+	return
+}
+
+// GetAuditLogList 按照Seq排序,包括fromSeq和toSeq
+func (dao *SQLiteDB) GetAuditLogList(fromSeq, toSeq int64) (ls []*models.AuditLog, err error) {
+	err = dao.queryValues(func() interface{} {
+		l := &models.AuditLog{}
+		ls = append(ls, l)
+		return l
+	}, "SELECT value FROM kv WHERE bucket = ? AND key BETWEEN ? AND ? ORDER BY key", models.BucketAuditLog, toBytes(fromSeq), toBytes(toSeq))
+	err = models.GeneratDBError(err)
+	return
+}
@@ -0,0 +1,30 @@
+package sqlitedb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+)
+
+// SaveBatchTransfer :
+func (dao *SQLiteDB) SaveBatchTransfer(b *models.BatchTransfer) error {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketBatchTransfer, b.Key, b)
+	return models.GeneratDBError(err)
+}
+
+// GetBatchTransfer :
+func (dao *SQLiteDB) GetBatchTransfer(key string) (b *models.BatchTransfer, err error) {
+	b = &models.BatchTransfer{}
+	err = dao.getKeyValueToBucket(models.BucketBatchTransfer, key, b)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetBatchTransferList :
+func (dao *SQLiteDB) GetBatchTransferList() (bs []*models.BatchTransfer, err error) {
+	err = dao.getAllFromBucket(models.BucketBatchTransfer, func() interface{} {
+		b := &models.BatchTransfer{}
+		bs = append(bs, b)
+		return b
+	})
+	err = models.GeneratDBError(err)
+	return
+}
@@ -0,0 +1,81 @@
+package sqlitedb
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// NewDeliveredChainEvent save one
+func (dao *SQLiteDB) NewDeliveredChainEvent(id models.ChainEventID, blockNumber uint64) {
+	e := &models.ChainEventRecord{
+		ID:          id,
+		BlockNumber: blockNumber,
+		Status:      models.ChainEventStatusDelivered,
+	}
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketChainEventRecord, string(e.ID), e)
+	if err != nil {
+		log.Error(fmt.Sprintf("models NewDeliveredChainEvent err=%s", err))
+	}
+	log.Trace(fmt.Sprintf("NewDeliveredChainEvent id=%s blockNumber=%d", e.ID, e.BlockNumber))
+}
+
+// CheckChainEventDelivered check one ChainEvent is delivered or not
+func (dao *SQLiteDB) CheckChainEventDelivered(id models.ChainEventID) (blockNumber uint64, delivered bool) {
+	e := &models.ChainEventRecord{}
+	err := dao.getKeyValueToBucket(models.BucketChainEventRecord, string(id), e)
+	if err == rerr.ErrNotFound {
+		delivered = false
+		return
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("models CheckChainEventDelivered err=%s", err))
+		delivered = false
+		return
+	}
+	if e.Status != models.ChainEventStatusDelivered {
+		delivered = false
+		return
+	}
+	delivered = true
+	blockNumber = e.BlockNumber
+	return
+}
+
+// ClearOldChainEventRecord delete records which blockNumber <= blockNumber in param
+func (dao *SQLiteDB) ClearOldChainEventRecord(blockNumber uint64) {
+	var list []*models.ChainEventRecord
+	err := dao.getAllFromBucket(models.BucketChainEventRecord, func() interface{} {
+		r := new(models.ChainEventRecord)
+		list = append(list, r)
+		return r
+	})
+	if err != nil {
+		log.Error(fmt.Sprintf("models ClearOldChainEventRecord err=%s", err))
+		return
+	}
+	n := 0
+	for _, r := range list {
+		if r.BlockNumber > blockNumber {
+			continue
+		}
+		err2 := dao.removeKeyValueFromBucket(models.BucketChainEventRecord, string(r.ID))
+		if err2 != nil {
+			log.Error(fmt.Sprintf("models ClearOldChainEventRecord remove id=%s blockNumber=%d status=%s err=%s", r.ID, r.BlockNumber, r.Status, err2.Error()))
+		}
+		n++
+	}
+	log.Trace(fmt.Sprintf("ClearOldChainEventRecord remove %d events witch blockNumber < %d", n, blockNumber))
+}
+
+// MakeChainEventID :
+func (dao *SQLiteDB) MakeChainEventID(l *types.Log) models.ChainEventID {
+	var t [25]byte
+	copy(t[:], l.TxHash[:])
+	t[24] = byte(l.Index)
+	return models.ChainEventID(common.Bytes2Hex(t[:]))
+}
@@ -0,0 +1,26 @@
+package sqlitedb
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+)
+
+//GetChainID :
+func (dao *SQLiteDB) GetChainID() int64 {
+	var chainID int64
+	err := dao.getKeyValueToBucket(models.BucketChainID, models.KeyChainID, &chainID)
+	if err != nil {
+		log.Error(fmt.Sprintf("models GetChainId err=%s", err))
+	}
+	return chainID
+}
+
+//SaveChainID :
+func (dao *SQLiteDB) SaveChainID(chainID int64) {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketChainID, models.KeyChainID, chainID)
+	if err != nil {
+		log.Error(fmt.Sprintf("models SaveChainId err=%s", err))
+	}
+}
@@ -0,0 +1,31 @@
+package sqlitedb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SaveChannelRecovery :
+func (dao *SQLiteDB) SaveChannelRecovery(r *models.ChannelRecovery) error {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketChannelRecovery, r.ChannelIdentifier, r)
+	return models.GeneratDBError(err)
+}
+
+// GetChannelRecovery :
+func (dao *SQLiteDB) GetChannelRecovery(channelIdentifier common.Hash) (r *models.ChannelRecovery, err error) {
+	r = &models.ChannelRecovery{}
+	err = dao.getKeyValueToBucket(models.BucketChannelRecovery, channelIdentifier, r)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetChannelRecoveryList :
+func (dao *SQLiteDB) GetChannelRecoveryList() (rs []*models.ChannelRecovery, err error) {
+	err = dao.getAllFromBucket(models.BucketChannelRecovery, func() interface{} {
+		r := &models.ChannelRecovery{}
+		rs = append(rs, r)
+		return r
+	})
+	err = models.GeneratDBError(err)
+	return
+}
@@ -0,0 +1,238 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/models/cb"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func (dao *SQLiteDB) saveChannel(e execer, c *channeltype.Serialization) error {
+	_, err := e.Exec("INSERT OR REPLACE INTO channels (key, token_address, partner_address, state, value) VALUES (?, ?, ?, ?, ?)",
+		c.Key, c.TokenAddressBytes, c.PartnerAddressBytes, int(c.State), dao.encodeValue(c))
+	return err
+}
+
+func (dao *SQLiteDB) queryChannels(query string, args ...interface{}) (cs []*channeltype.Serialization, err error) {
+	err = dao.queryValues(func() interface{} {
+		c := new(channeltype.Serialization)
+		cs = append(cs, c)
+		return c
+	}, query, args...)
+	return
+}
+
+// NewChannel save a just created channel to db
+func (dao *SQLiteDB) NewChannel(c *channeltype.Serialization) error {
+	err := dao.saveChannel(dao.db, c)
+	//notify new channel added
+	dao.handleChannelCallback(dao.newChannelCallbacks, c)
+	if err != nil {
+		log.Error(fmt.Sprintf("NewChannel for models err:%s", err))
+	}
+	return models.GeneratDBError(err)
+}
+
+//UpdateChannelNoTx update channel status without a Tx
+func (dao *SQLiteDB) UpdateChannelNoTx(c *channeltype.Serialization) error {
+	err := dao.saveChannel(dao.db, c)
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdateChannelNoTx err:%s", err))
+	}
+	return models.GeneratDBError(err)
+}
+
+//UpdateChannelAndSaveAck update channel and save ack, must atomic
+func (dao *SQLiteDB) UpdateChannelAndSaveAck(c *channeltype.Serialization, echohash common.Hash, ack []byte) (err error) {
+	tx := dao.StartTx()
+	defer func() {
+		if err != nil {
+			err = tx.Rollback()
+		}
+	}()
+	err = dao.UpdateChannel(c, tx)
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdateChannel err %s", err))
+		err = models.GeneratDBError(err)
+		return
+	}
+	dao.SaveAck(echohash, ack, tx)
+	err = tx.Commit()
+	err = models.GeneratDBError(err)
+	return
+}
+
+func (dao *SQLiteDB) handleChannelCallback(m map[*cb.ChannelCb]bool, c *channeltype.Serialization) {
+	var cbs []*cb.ChannelCb
+	dao.mlock.Lock()
+	for f := range m {
+		remove := (*f)(c)
+		if remove {
+			cbs = append(cbs, f)
+		}
+	}
+	for _, f := range cbs {
+		delete(m, f)
+	}
+	dao.mlock.Unlock()
+}
+
+//UpdateChannelContractBalance update channel balance
+func (dao *SQLiteDB) UpdateChannelContractBalance(c *channeltype.Serialization) error {
+	err := dao.UpdateChannelNoTx(c)
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	//notify listener
+	dao.handleChannelCallback(dao.channelDepositCallbacks, c)
+	return nil
+}
+
+//UpdateChannel update channel status in a Tx
+func (dao *SQLiteDB) UpdateChannel(c *channeltype.Serialization, tx models.TX) error {
+	err := tx.Save(c)
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdateChannel err=%s", err))
+	}
+	return models.GeneratDBError(err)
+}
+
+//UpdateChannelState update channel state ,close settle
+func (dao *SQLiteDB) UpdateChannelState(c *channeltype.Serialization) error {
+	err := dao.UpdateChannelNoTx(c)
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	//notify listener
+	dao.handleChannelCallback(dao.channelStateCallbacks, c)
+	return nil
+}
+
+//RemoveChannel a settled channel from db
+func (dao *SQLiteDB) RemoveChannel(c *channeltype.Serialization) error {
+	if c.State != channeltype.StateSettled {
+		panic("only can remove a settled channel")
+	}
+	dao.handleChannelCallback(dao.channelSettledCallbacks, c)
+	_, err := dao.db.Exec("DELETE FROM channels WHERE key = ?", c.Key)
+	return models.GeneratDBError(err)
+}
+
+//GetChannel return a channel queried by (token,partner),this channel must not settled
+func (dao *SQLiteDB) GetChannel(token, partner common.Address) (c *channeltype.Serialization, err error) {
+	if token == utils.EmptyAddress {
+		panic("token is empty")
+	}
+	if partner == utils.EmptyAddress {
+		panic("partner is empty")
+	}
+	cs, err := dao.queryChannels("SELECT value FROM channels WHERE token_address = ? AND partner_address = ? AND state != ? ORDER BY key LIMIT 1",
+		token[:], partner[:], int(channeltype.StateSettled))
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	if len(cs) == 0 {
+		return nil, rerr.ErrNotFound
+	}
+	c = cs[0]
+	return
+}
+
+//GetChannelByAddress return a channel queried by channel address
+func (dao *SQLiteDB) GetChannelByAddress(ChannelIdentifier common.Hash) (c *channeltype.Serialization, err error) {
+	var buf []byte
+	err = dao.db.QueryRow("SELECT value FROM channels WHERE key = ?", ChannelIdentifier[:]).Scan(&buf)
+	if err == sql.ErrNoRows {
+		err = rerr.ErrNotFound
+		return
+	}
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	c = new(channeltype.Serialization)
+	err = dao.decodeValue(buf, c)
+	if err != nil {
+		c = nil
+		err = models.GeneratDBError(err)
+	}
+	return
+}
+
+//GetChannelList returns all related channels
+//one of token and partner must be empty
+func (dao *SQLiteDB) GetChannelList(token, partner common.Address) (cs []*channeltype.Serialization, err error) {
+	if token == utils.EmptyAddress && partner == utils.EmptyAddress {
+		cs, err = dao.queryChannels("SELECT value FROM channels ORDER BY key")
+	} else if token == utils.EmptyAddress {
+		cs, err = dao.queryChannels("SELECT value FROM channels WHERE partner_address = ? ORDER BY key", partner[:])
+	} else if partner == utils.EmptyAddress {
+		cs, err = dao.queryChannels("SELECT value FROM channels WHERE token_address = ? ORDER BY key", token[:])
+	} else {
+		panic("one of token and partner must be empty")
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+/*
+IsThisLockHasUnlocked return ture when  lockhash has unlocked on channel?
+*/
+func (dao *SQLiteDB) IsThisLockHasUnlocked(channel common.Hash, lockHash common.Hash) bool {
+	var result bool
+	key := utils.Sha3(channel[:], lockHash[:])
+	err := dao.getKeyValueToBucket(models.BucketWithDraw, key.Bytes(), &result)
+	if err != nil {
+		return false
+	}
+	if result != true {
+		panic("withdraw cannot be set to false")
+	}
+	return result
+}
+
+/*
+UnlockThisLock marks that I have withdrawed this secret on channel.
+*/
+func (dao *SQLiteDB) UnlockThisLock(channel common.Hash, lockHash common.Hash) {
+	key := utils.Sha3(channel[:], lockHash[:])
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketWithDraw, key.Bytes(), true)
+	if err != nil {
+		log.Error(fmt.Sprintf("UnlockThisLock write %s to db err %s", hex.EncodeToString(key.Bytes()), err))
+	}
+}
+
+/*
+IsThisLockRemoved return true when  a expired hashlock has been removed from channel status.
+*/
+func (dao *SQLiteDB) IsThisLockRemoved(channel common.Hash, sender common.Address, lockHash common.Hash) bool {
+	var result bool
+	key := utils.Sha3(channel[:], lockHash[:], sender[:])
+	err := dao.getKeyValueToBucket(models.BucketExpiredHashlock, key.Bytes(), &result)
+	if err != nil {
+		return false
+	}
+	if result != true {
+		panic("expiredHashlock cannot be set to false")
+	}
+	return result
+}
+
+/*
+RemoveLock remember this lock has been removed from channel status.
+*/
+func (dao *SQLiteDB) RemoveLock(channel common.Hash, sender common.Address, lockHash common.Hash) {
+	key := utils.Sha3(channel[:], lockHash[:], sender[:])
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketExpiredHashlock, key.Bytes(), true)
+	if err != nil {
+		log.Error(fmt.Sprintf("UnlockThisLock write %s to db err %s", hex.EncodeToString(key.Bytes()), err))
+	}
+}
@@ -0,0 +1,153 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"encoding/json"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func (dao *SQLiteDB) saveTXInfo(tis *models.TXInfoSerialization) error {
+	_, err := dao.db.Exec("INSERT OR REPLACE INTO tx_infos (tx_hash, channel_identifier, open_block_number, token_address, type, status, call_time, value) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		tis.TXHash, tis.ChannelIdentifier, tis.OpenBlockNumber, tis.TokenAddress, tis.Type, tis.Status, tis.CallTime, dao.encodeValue(tis))
+	return err
+}
+
+// NewPendingTXInfo 创建pending状态的TXInfo,即自己发起的tx
+func (dao *SQLiteDB) NewPendingTXInfo(tx *types.Transaction, txType models.TXInfoType, channelIdentifier common.Hash, openBlockNumber int64, txParams models.TXParams) (txInfo *models.TXInfo, err error) {
+	tokenAddress := utils.EmptyAddress
+	if openBlockNumber == 0 && channelIdentifier != utils.EmptyHash {
+		c, err2 := dao.GetChannelByAddress(channelIdentifier)
+		if err2 == nil {
+			openBlockNumber = c.ChannelIdentifier.OpenBlockNumber
+			tokenAddress = c.TokenAddress()
+		}
+	}
+	var txParamsStr string
+	if txParams != nil {
+		if s, ok := txParams.(string); ok {
+			txParamsStr = s
+		} else {
+			var buf []byte
+			buf, err = json.Marshal(txParams)
+			if err != nil {
+				err = models.GeneratDBError(err)
+				return
+			}
+			txParamsStr = string(buf)
+		}
+		if p, ok := txParams.(*models.DepositTXParams); ok && tokenAddress == utils.EmptyAddress {
+			tokenAddress = p.TokenAddress
+		}
+	}
+	txInfo = &models.TXInfo{
+		TXHash:            tx.Hash(),
+		ChannelIdentifier: channelIdentifier,
+		OpenBlockNumber:   openBlockNumber,
+		TokenAddress:      tokenAddress,
+		Type:              txType,
+		IsSelfCall:        true,
+		TXParams:          txParamsStr,
+		Status:            models.TXInfoStatusPending,
+		CallTime:          time.Now().Unix(),
+		GasPrice:          tx.GasPrice().Uint64(),
+	}
+	err = dao.saveTXInfo(txInfo.ToTXInfoSerialization())
+	if err != nil {
+		log.Error(fmt.Sprintf("NewPendingTXInfo txhash=%s, err %s", txInfo.TXHash.String(), err))
+		err = models.GeneratDBError(err)
+		return
+	}
+	log.Trace(fmt.Sprintf("NewPendingTXInfo : \n%s", txInfo))
+	return
+}
+
+// SaveEventToTXInfo 保存事件到TXInfo里面,当收到链上事件的时候调用
+// 和stormdb一样还没有实现
+func (dao *SQLiteDB) SaveEventToTXInfo(event interface{}) (txInfo *models.TXInfo, err error) {
+	return nil, errors.New("TODO")
+}
+
+// UpdateTXInfoStatus :
+func (dao *SQLiteDB) UpdateTXInfoStatus(txHash common.Hash, status models.TXInfoStatus, packBlockNumber int64, gasUsed uint64) (txInfo *models.TXInfo, err error) {
+	var tis models.TXInfoSerialization
+	var buf []byte
+	err = dao.db.QueryRow("SELECT value FROM tx_infos WHERE tx_hash = ?", txHash[:]).Scan(&buf)
+	if err == sql.ErrNoRows {
+		err = rerr.ErrNotFound
+	}
+	if err == nil {
+		err = dao.decodeValue(buf, &tis)
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdateTXInfoStatus err %s", err))
+		err = models.GeneratDBError(err)
+		return
+	}
+	tis.Status = string(status)
+	tis.PackBlockNumber = packBlockNumber
+	tis.PackTime = time.Now().Unix()
+	tis.GasUsed = gasUsed
+	if tis.OpenBlockNumber == 0 && tis.Type == models.TXInfoTypeDeposit {
+		// 通道第一deposit,即通道打开,记录OpenBlockNumber和TokenAddress
+		tis.OpenBlockNumber = packBlockNumber
+		ch, err2 := dao.GetChannelByAddress(common.BytesToHash(tis.ChannelIdentifier))
+		if err2 == nil {
+			tis.TokenAddress = ch.TokenAddressBytes
+		}
+	}
+	err = dao.saveTXInfo(&tis)
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdateTXInfoStatus err %s", err))
+		err = models.GeneratDBError(err)
+		return
+	}
+	log.Trace(fmt.Sprintf("UpdateTXInfoStatus txhash=%s status=%s packBlockNumber=%d", txHash.String(), status, packBlockNumber))
+	txInfo = tis.ToTXInfo()
+	return
+}
+
+// GetTXInfoList :
+// 如果参数不为空,则根据参数查询
+func (dao *SQLiteDB) GetTXInfoList(channelIdentifier common.Hash, openBlockNumber int64, tokenAddress common.Address, txType models.TXInfoType, status models.TXInfoStatus) (list []*models.TXInfo, err error) {
+	var c conditions
+	if channelIdentifier != utils.EmptyHash {
+		c.add("channel_identifier = ?", channelIdentifier[:])
+	}
+	if openBlockNumber != 0 {
+		c.add("open_block_number = ?", openBlockNumber)
+	}
+	if tokenAddress != utils.EmptyAddress {
+		c.add("token_address = ?", tokenAddress[:])
+	}
+	if txType != "" {
+		c.addIn("type", string(txType))
+	}
+	if status != "" {
+		c.addIn("status", string(status))
+	}
+	var l []*models.TXInfoSerialization
+	err = dao.queryValues(func() interface{} {
+		tis := new(models.TXInfoSerialization)
+		l = append(l, tis)
+		return tis
+	}, "SELECT value FROM tx_infos"+c.String()+" ORDER BY tx_hash", c.args...)
+	if err != nil {
+		err = fmt.Errorf("GetTXInfoList err %s", err)
+		err = models.GeneratDBError(err)
+		return
+	}
+	for _, tis := range l {
+		list = append(list, tis.ToTXInfo())
+	}
+	return
+}
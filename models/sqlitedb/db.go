@@ -0,0 +1,430 @@
+package sqlitedb
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/models/cb"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/ethereum/go-ethereum/common"
+	//sqlite driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+/*
+schema 需要范围查询的transfer,TXInfo,手续费记录和通道有独立的表和索引,
+其他数据和storm的bucket一样按照(bucket,key)保存在kv中.
+value都是gob编码(加密数据库再加密)的完整结构,索引列只用于查询,不会加密
+*/
+const schema = `
+CREATE TABLE IF NOT EXISTS kv (
+	bucket TEXT NOT NULL,
+	key BLOB NOT NULL,
+	value BLOB NOT NULL,
+	PRIMARY KEY (bucket, key)
+);
+CREATE TABLE IF NOT EXISTS channels (
+	key BLOB PRIMARY KEY,
+	token_address BLOB NOT NULL,
+	partner_address BLOB NOT NULL,
+	state INTEGER NOT NULL,
+	value BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS channels_token ON channels (token_address);
+CREATE INDEX IF NOT EXISTS channels_partner ON channels (partner_address);
+CREATE TABLE IF NOT EXISTS received_transfers (
+	key TEXT PRIMARY KEY,
+	token_address BLOB NOT NULL,
+	block_number INTEGER NOT NULL,
+	time_stamp INTEGER NOT NULL,
+	value BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS received_transfers_token ON received_transfers (token_address);
+CREATE INDEX IF NOT EXISTS received_transfers_block ON received_transfers (block_number);
+CREATE INDEX IF NOT EXISTS received_transfers_time ON received_transfers (time_stamp);
+CREATE TABLE IF NOT EXISTS sent_transfer_details (
+	key TEXT PRIMARY KEY,
+	token_address BLOB NOT NULL,
+	block_number INTEGER NOT NULL,
+	sending_time INTEGER NOT NULL,
+	finish_time INTEGER NOT NULL,
+	value BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sent_transfer_details_token ON sent_transfer_details (token_address);
+CREATE INDEX IF NOT EXISTS sent_transfer_details_block ON sent_transfer_details (block_number);
+CREATE INDEX IF NOT EXISTS sent_transfer_details_sending ON sent_transfer_details (sending_time);
+CREATE INDEX IF NOT EXISTS sent_transfer_details_finish ON sent_transfer_details (finish_time);
+CREATE TABLE IF NOT EXISTS tx_infos (
+	tx_hash BLOB PRIMARY KEY,
+	channel_identifier BLOB NOT NULL,
+	open_block_number INTEGER NOT NULL,
+	token_address BLOB NOT NULL,
+	type TEXT NOT NULL,
+	status TEXT NOT NULL,
+	call_time INTEGER NOT NULL,
+	value BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS tx_infos_channel ON tx_infos (channel_identifier, open_block_number);
+CREATE INDEX IF NOT EXISTS tx_infos_token ON tx_infos (token_address);
+CREATE INDEX IF NOT EXISTS tx_infos_type ON tx_infos (type);
+CREATE INDEX IF NOT EXISTS tx_infos_status ON tx_infos (status);
+CREATE TABLE IF NOT EXISTS fee_charge_records (
+	key BLOB PRIMARY KEY,
+	lock_secret_hash BLOB NOT NULL,
+	token_address BLOB NOT NULL,
+	timestamp INTEGER NOT NULL,
+	block_number INTEGER NOT NULL,
+	value BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS fee_charge_records_lock ON fee_charge_records (lock_secret_hash);
+CREATE INDEX IF NOT EXISTS fee_charge_records_token_time ON fee_charge_records (token_address, timestamp);
+CREATE INDEX IF NOT EXISTS fee_charge_records_time ON fee_charge_records (timestamp);
+`
+
+//valueTables 所有保存了value列的表,修改加密key的时候需要重新加密
+var valueTables = []string{"kv", "channels", "received_transfers", "sent_transfer_details", "tx_infos", "fee_charge_records"}
+
+//execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+/*
+conditions 拼接查询条件,和storm的q.Matcher一样,所有条件同时满足
+*/
+type conditions struct {
+	where []string
+	args  []interface{}
+}
+
+func (c *conditions) add(where string, arg interface{}) {
+	c.where = append(c.where, where)
+	c.args = append(c.args, arg)
+}
+
+func (c *conditions) String() string {
+	if len(c.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(c.where, " AND ")
+}
+
+//addIn 逗号分隔的多个值使用IN查询
+func (c *conditions) addIn(column, value string) {
+	ss := strings.Split(value, ",")
+	c.where = append(c.where, fmt.Sprintf("%s IN (?%s)", column, strings.Repeat(", ?", len(ss)-1)))
+	for _, s := range ss {
+		c.args = append(c.args, s)
+	}
+}
+
+//SQLiteDB is thread safe
+type SQLiteDB struct {
+	db                      *sql.DB
+	lock                    sync.Mutex
+	newTokenCallbacks       map[*cb.NewTokenCb]bool
+	newChannelCallbacks     map[*cb.ChannelCb]bool
+	channelDepositCallbacks map[*cb.ChannelCb]bool
+	channelStateCallbacks   map[*cb.ChannelCb]bool
+	channelSettledCallbacks map[*cb.ChannelCb]bool
+	mlock                   sync.Mutex
+	Name                    string
+	encryptor               *models.DBEncryptor //为nil表示没有加密
+}
+
+func newSQLiteDB() (db *SQLiteDB) {
+	return &SQLiteDB{
+		newTokenCallbacks:       make(map[*cb.NewTokenCb]bool),
+		newChannelCallbacks:     make(map[*cb.ChannelCb]bool),
+		channelDepositCallbacks: make(map[*cb.ChannelCb]bool),
+		channelStateCallbacks:   make(map[*cb.ChannelCb]bool),
+		channelSettledCallbacks: make(map[*cb.ChannelCb]bool),
+	}
+}
+
+func gobEncode(d interface{}) []byte {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	err := enc.Encode(d)
+	if err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+/*
+toBytes 和storm对id和key的编码保持一致,整数使用大端编码,这样按照key排序和storm遍历的顺序相同
+*/
+func toBytes(key interface{}) []byte {
+	switch k := key.(type) {
+	case []byte:
+		return k
+	case string:
+		return []byte(k)
+	case common.Hash:
+		return k[:]
+	case common.Address:
+		return k[:]
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		var buf bytes.Buffer
+		switch n := k.(type) {
+		case int:
+			k = int64(n)
+		case uint:
+			k = uint64(n)
+		}
+		err := binary.Write(&buf, binary.BigEndian, k)
+		if err != nil {
+			panic(err)
+		}
+		return buf.Bytes()
+	}
+	return gobEncode(key)
+}
+
+//encodeValue 只加密值,key和索引列必须保持不变
+func (dao *SQLiteDB) encodeValue(v interface{}) []byte {
+	data := gobEncode(v)
+	if dao.encryptor == nil {
+		return data
+	}
+	return dao.encryptor.Encrypt(data)
+}
+
+func (dao *SQLiteDB) decodeValue(buf []byte, to interface{}) error {
+	buf, err := models.DecryptDBValue(dao.encryptor, buf)
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewBuffer(buf)).Decode(to)
+}
+
+func (dao *SQLiteDB) saveKeyValueToBucket(e execer, bucket string, key, value interface{}) error {
+	_, err := e.Exec("INSERT OR REPLACE INTO kv (bucket, key, value) VALUES (?, ?, ?)", bucket, toBytes(key), dao.encodeValue(value))
+	return err
+}
+
+func (dao *SQLiteDB) getKeyValueToBucket(bucket string, key, to interface{}) error {
+	var buf []byte
+	err := dao.db.QueryRow("SELECT value FROM kv WHERE bucket = ? AND key = ?", bucket, toBytes(key)).Scan(&buf)
+	if err == sql.ErrNoRows {
+		return rerr.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return dao.decodeValue(buf, to)
+}
+
+//removeKeyValueFromBucket 和storm的DeleteStruct一样,key不存在的时候返回ErrNotFound
+func (dao *SQLiteDB) removeKeyValueFromBucket(bucket string, key interface{}) error {
+	r, err := dao.db.Exec("DELETE FROM kv WHERE bucket = ? AND key = ?", bucket, toBytes(key))
+	if err != nil {
+		return err
+	}
+	n, err := r.RowsAffected()
+	if err == nil && n == 0 {
+		err = rerr.ErrNotFound
+	}
+	return err
+}
+
+/*
+getAllFromBucket 按照key的顺序解码bucket中所有的值,newValue返回用来解码的对象
+*/
+func (dao *SQLiteDB) getAllFromBucket(bucket string, newValue func() interface{}) error {
+	return dao.queryValues(newValue, "SELECT value FROM kv WHERE bucket = ? ORDER BY key", bucket)
+}
+
+//queryValues 解码query返回的value列
+func (dao *SQLiteDB) queryValues(newValue func() interface{}, query string, args ...interface{}) error {
+	rows, err := dao.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var buf []byte
+		err = rows.Scan(&buf)
+		if err != nil {
+			return err
+		}
+		err = dao.decodeValue(buf, newValue())
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+//OpenDb open or create a sqlite db at dbPath,fails if the db is encrypted
+func OpenDb(dbPath string) (dao *SQLiteDB, err error) {
+	return OpenDbWithPassword(dbPath, "", false)
+}
+
+/*
+OpenDbWithPassword open or create a sqlite db at dbPath.
+加密的数据库用password解密,没有加密的数据库在encrypt为true的时候用password加密所有的值
+*/
+func OpenDbWithPassword(dbPath, password string, encrypt bool) (dao *SQLiteDB, err error) {
+	return openDb(dbPath, password, encrypt, true)
+}
+
+//OpenDbForMigration 打开数据库但是不升级,用来离线升级或者dry run
+func OpenDbForMigration(dbPath, password string) (dao *SQLiteDB, err error) {
+	return openDb(dbPath, password, false, false)
+}
+
+//openDb 旧版本的数据库在migrate为true的时候先备份再升级
+func openDb(dbPath, password string, encrypt, migrate bool) (dao *SQLiteDB, err error) {
+	log.Trace(fmt.Sprintf("dbpath=%s", dbPath))
+	dao = newSQLiteDB()
+	needCreateDb := !common.FileExist(dbPath)
+	var ver int
+	//事务一开始就获取写锁,避免两个事务同时升级读锁导致死锁
+	dsn := fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate", dbPath)
+	dao.db, err = sql.Open("sqlite3", dsn)
+	if err == nil {
+		_, err = dao.db.Exec(schema)
+		if err != nil {
+			dao.db.Close()
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("cannot create or open db:%s,makesure you have write permission err:%v", dbPath, err)
+		log.Crit(err.Error())
+		return
+	}
+	dao.Name = dbPath
+	err = dao.unlock(password, encrypt)
+	if err != nil {
+		dao.db.Close()
+		return
+	}
+	if needCreateDb {
+		err = dao.saveKeyValueToBucket(dao.db, models.BucketMeta, models.KeyVersion, models.DbVersion)
+		if err != nil {
+			log.Crit(fmt.Sprintf("unable to create db "))
+			return
+		}
+		err = dao.saveKeyValueToBucket(dao.db, models.BucketToken, models.KeyToken, make(models.AddressMap))
+		if err != nil {
+			log.Crit(fmt.Sprintf("unable to create db "))
+			return
+		}
+		dao.initDb()
+		dao.MarkDbOpenedStatus()
+	} else {
+		err = dao.getKeyValueToBucket(models.BucketMeta, models.KeyVersion, &ver)
+		if err != nil {
+			log.Crit(fmt.Sprintf("wrong db file format "))
+			return
+		}
+		if ver != models.DbVersion && migrate {
+			_, err = dao.Migrate(false)
+			if err != nil {
+				log.Error(fmt.Sprintf("migrate db err %s", err))
+				dao.db.Close()
+				return
+			}
+		}
+		var closeFlag bool
+		err = dao.getKeyValueToBucket(models.BucketMeta, models.KeyCloseFlag, &closeFlag)
+		if err != nil {
+			log.Crit(fmt.Sprintf("db meta data error"))
+		}
+		if closeFlag != true {
+			log.Error("database not closed  last..., try to restore?")
+		}
+	}
+	return
+}
+
+/*
+MarkDbOpenedStatus First step   open the database
+Second step detection for normal closure IsDbCrashedLastTime
+Third step  recovers the data according to the second step
+Fourth step mark the database for processing the data normally. MarkDbOpenedStatus
+*/
+func (dao *SQLiteDB) MarkDbOpenedStatus() {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketMeta, models.KeyCloseFlag, false)
+	if err != nil {
+		log.Error(fmt.Sprintf("db err %s", err))
+	}
+}
+
+//IsDbCrashedLastTime return true when quit but  db not closed
+func (dao *SQLiteDB) IsDbCrashedLastTime() bool {
+	var closeFlag bool
+	err := dao.getKeyValueToBucket(models.BucketMeta, models.KeyCloseFlag, &closeFlag)
+	if err != nil {
+		log.Crit(fmt.Sprintf("db meta data error"))
+	}
+	return closeFlag != true
+}
+
+//CheckWritable write a value to meta and read it back
+func (dao *SQLiteDB) CheckWritable() error {
+	v := time.Now().UnixNano()
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketMeta, models.KeyHealthCheck, v)
+	if err != nil {
+		return err
+	}
+	var v2 int64
+	err = dao.getKeyValueToBucket(models.BucketMeta, models.KeyHealthCheck, &v2)
+	if err != nil {
+		return err
+	}
+	if v2 != v {
+		return fmt.Errorf("read %d after write %d", v2, v)
+	}
+	return nil
+}
+
+//CloseDB close db
+func (dao *SQLiteDB) CloseDB() {
+	dao.lock.Lock()
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketMeta, models.KeyCloseFlag, true)
+	err = dao.db.Close()
+	if err != nil {
+		log.Error(fmt.Sprintf("db err %s", err))
+	}
+	dao.lock.Unlock()
+}
+
+//SaveContractStatus save registry address to db
+func (dao *SQLiteDB) SaveContractStatus(contractStatus models.ContractStatus) {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketMeta, models.KeyRegistry, contractStatus)
+	if err != nil {
+		log.Error(fmt.Sprintf("db err %s", err))
+	}
+}
+
+//GetContractStatus returns registry address in db
+func (dao *SQLiteDB) GetContractStatus() models.ContractStatus {
+	var contractStatus models.ContractStatus
+	err := dao.getKeyValueToBucket(models.BucketMeta, models.KeyRegistry, &contractStatus)
+	if err != nil && err != rerr.ErrNotFound {
+		log.Error(fmt.Sprintf("db err %s", err))
+	}
+	return contractStatus
+}
+
+func init() {
+	gob.Register(&SQLiteDB{}) //cannot save and restore by gob,only avoid noise by gob
+}
+
+func (dao *SQLiteDB) initDb() {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketBlockNumber, models.KeyBlockNumber, 0)
+	if err != nil {
+		log.Error(fmt.Sprintf("db err %s", err))
+	}
+}
@@ -0,0 +1,146 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+)
+
+// 加密参数保存在kv中以__开头的bucket里,这个bucket不加密
+const bucketEncryption = "__photon_encryption"
+
+var keyEncryptionMeta = []byte("meta")
+
+func (dao *SQLiteDB) getEncryptionMeta() (meta *models.DBEncryptionMeta, err error) {
+	var data []byte
+	err = dao.db.QueryRow("SELECT value FROM kv WHERE bucket = ? AND key = ?", bucketEncryption, keyEncryptionMeta).Scan(&data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = nil
+		}
+		return
+	}
+	return models.ParseDBEncryptionMeta(data)
+}
+
+func (dao *SQLiteDB) unlock(password string, encrypt bool) error {
+	meta, err := dao.getEncryptionMeta()
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		if encrypt {
+			return dao.SetEncryptionPassword(password)
+		}
+		return nil
+	}
+	if password == "" {
+		return rerr.ErrDBEncryption.Printf("database %s is encrypted,password needed", dao.Name)
+	}
+	e, err := meta.Unlock(password)
+	if err != nil {
+		return err
+	}
+	dao.encryptor = e
+	return nil
+}
+
+//IsEncrypted returns true if values in db are encrypted
+func (dao *SQLiteDB) IsEncrypted() bool {
+	return dao.encryptor != nil
+}
+
+/*
+SetEncryptionPassword 用新的salt从password派生key,重新加密数据库中所有的值,password为空表示取消加密.
+没有加密的数据库调用这个函数完成迁移,已经加密的数据库调用这个函数更换key.
+所有的修改在一个事务中完成,只能在没有其他读写的时候调用
+*/
+func (dao *SQLiteDB) SetEncryptionPassword(password string) (err error) {
+	var meta *models.DBEncryptionMeta
+	var to *models.DBEncryptor
+	if password != "" {
+		meta, to, err = models.NewDBEncryptionMeta(password)
+		if err != nil {
+			return
+		}
+	}
+	tx, err := dao.db.Begin()
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = models.GeneratDBError(err)
+		}
+	}()
+	for _, table := range valueTables {
+		err = reencryptTable(tx, table, dao.encryptor, to)
+		if err != nil {
+			return
+		}
+	}
+	if meta == nil {
+		_, err = tx.Exec("DELETE FROM kv WHERE bucket = ?", bucketEncryption)
+	} else {
+		_, err = tx.Exec("INSERT OR REPLACE INTO kv (bucket, key, value) VALUES (?, ?, ?)", bucketEncryption, keyEncryptionMeta, meta.Bytes())
+	}
+	if err != nil {
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+	dao.encryptor = to
+	return nil
+}
+
+/*
+reencryptTable 修改表中所有的value,kv中以__开头的bucket保存的不是gob编码的值,不能修改
+*/
+func reencryptTable(tx *sql.Tx, table string, from, to *models.DBEncryptor) error {
+	type change struct {
+		rowid int64
+		value []byte
+	}
+	var changes []change
+	query := fmt.Sprintf("SELECT rowid, value FROM %s", table)
+	if table == "kv" {
+		query += " WHERE bucket NOT LIKE '\\_\\_%' ESCAPE '\\'"
+	}
+	rows, err := tx.Query(query)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var c change
+		err = rows.Scan(&c.rowid, &c.value)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		out, changed, err := models.ReencryptDBValue(c.value, from, to)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if changed {
+			changes = append(changes, change{c.rowid, out})
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	//遍历的时候不修改表
+	for _, c := range changes {
+		_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET value = ? WHERE rowid = ?", table), c.value, c.rowid)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
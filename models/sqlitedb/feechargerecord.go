@@ -0,0 +1,75 @@
+package sqlitedb
+
+import (
+	"fmt"
+
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SaveFeeChargeRecord :
+func (dao *SQLiteDB) SaveFeeChargeRecord(r *models.FeeChargeRecord) (err error) {
+	rs := r.ToSerialized()
+	if rs.Key == nil || common.BytesToHash(rs.Key) == utils.EmptyHash {
+		key := utils.NewRandomHash()
+		rs.Key = key[:]
+	}
+	if rs.Timestamp <= 0 {
+		rs.Timestamp = time.Now().Unix()
+	}
+	_, err = dao.db.Exec("INSERT OR REPLACE INTO fee_charge_records (key, lock_secret_hash, token_address, timestamp, block_number, value) VALUES (?, ?, ?, ?, ?, ?)",
+		rs.Key, rs.LockSecretHash, rs.TokenAddress, rs.Timestamp, rs.BlockNumber, dao.encodeValue(rs))
+	if err != nil {
+		err = fmt.Errorf("SaveFeeChargeRecord err %s", err)
+		err = models.GeneratDBError(err)
+		return
+	}
+	log.Trace(fmt.Sprintf("charge for transfer:%s", r.ToString()))
+	return
+}
+
+func (dao *SQLiteDB) queryFeeChargeRecords(c *conditions) (records []*models.FeeChargeRecord, err error) {
+	var rs []*models.FeeChargerRecordSerialization
+	err = dao.queryValues(func() interface{} {
+		r := new(models.FeeChargerRecordSerialization)
+		rs = append(rs, r)
+		return r
+	}, "SELECT value FROM fee_charge_records"+c.String()+" ORDER BY key", c.args...)
+	for _, r := range rs {
+		records = append(records, r.ToFeeChargeRecord())
+	}
+	return
+}
+
+// GetAllFeeChargeRecord :
+func (dao *SQLiteDB) GetAllFeeChargeRecord(tokenAddress common.Address, fromTime, toTime int64) (records []*models.FeeChargeRecord, err error) {
+	var c conditions
+	if tokenAddress != utils.EmptyAddress {
+		c.add("token_address = ?", tokenAddress[:])
+	}
+	if fromTime > 0 {
+		c.add("timestamp >= ?", fromTime)
+	}
+	if toTime > 0 {
+		c.add("timestamp < ?", toTime)
+	}
+	records, err = dao.queryFeeChargeRecords(&c)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetFeeChargeRecordByLockSecretHash :
+func (dao *SQLiteDB) GetFeeChargeRecordByLockSecretHash(lockSecretHash common.Hash) (records []*models.FeeChargeRecord, err error) {
+	var c conditions
+	c.add("lock_secret_hash = ?", lockSecretHash[:])
+	records, err = dao.queryFeeChargeRecords(&c)
+	if err != nil {
+		err = fmt.Errorf("GetAllFeeChargeRecordByLockSecretHash err %s", err)
+		err = models.GeneratDBError(err)
+	}
+	return
+}
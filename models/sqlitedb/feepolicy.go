@@ -0,0 +1,31 @@
+package sqlitedb
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+)
+
+// SaveFeePolicy :
+func (dao *SQLiteDB) SaveFeePolicy(fp *models.FeePolicy) (err error) {
+	fp.Key = models.KeyFeePolicy
+	err = dao.saveKeyValueToBucket(dao.db, models.BucketFeePolicy, fp.Key, fp)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetFeePolicy :
+func (dao *SQLiteDB) GetFeePolicy() (fp *models.FeePolicy) {
+	fp = &models.FeePolicy{}
+	err := dao.getKeyValueToBucket(models.BucketFeePolicy, models.KeyFeePolicy, fp)
+	if err == rerr.ErrNotFound {
+		return models.NewDefaultFeePolicy()
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("GetFeePolicy err %s, use default fee policy", err))
+		return models.NewDefaultFeePolicy()
+	}
+	return
+}
@@ -0,0 +1,44 @@
+package sqlitedb
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+)
+
+// SaveIdempotencyRecord :
+func (dao *SQLiteDB) SaveIdempotencyRecord(r *models.IdempotencyRecord) error {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketIdempotency, r.Key, r)
+	return models.GeneratDBError(err)
+}
+
+// GetIdempotencyRecord :
+func (dao *SQLiteDB) GetIdempotencyRecord(key string) (r *models.IdempotencyRecord, err error) {
+	r = &models.IdempotencyRecord{}
+	err = dao.getKeyValueToBucket(models.BucketIdempotency, key, r)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// RemoveIdempotencyRecordBefore delete records created before createTime
+func (dao *SQLiteDB) RemoveIdempotencyRecordBefore(createTime int64) error {
+	var rs []*models.IdempotencyRecord
+	err := dao.getAllFromBucket(models.BucketIdempotency, func() interface{} {
+		r := &models.IdempotencyRecord{}
+		rs = append(rs, r)
+		return r
+	})
+	for _, r := range rs {
+		if err != nil {
+			break
+		}
+		if r.CreateTime < createTime {
+			err = dao.removeKeyValueFromBucket(models.BucketIdempotency, r.Key)
+		}
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("RemoveIdempotencyRecordBefore err %s", err))
+	}
+	return models.GeneratDBError(err)
+}
@@ -0,0 +1,194 @@
+package sqlitedb
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/utils"
+)
+
+//listTable 列表的表和排序用的列
+type listTable struct {
+	name       string
+	timeColumn string
+	keyColumn  string
+	textKey    bool // key列是TEXT,游标参数必须是string
+}
+
+/*
+queryPage 按照时间和key排序,查询条件c只是缩小范围,每一条记录还要用match检查,
+最多匹配Limit+1条,多出来的一条用来判断是否还有下一页
+*/
+func (dao *SQLiteDB) queryPage(lq *models.ListQuery, t listTable, c *conditions, newValue func() interface{}, match func(v interface{}) bool) error {
+	err := lq.ParseCursor()
+	if err != nil {
+		return err
+	}
+	order, op := "ASC", ">"
+	if lq.Desc {
+		order, op = "DESC", "<"
+	}
+	if ct, ckey, ok := lq.CursorCondition(); ok {
+		var key interface{} = ckey
+		if t.textKey {
+			key = string(ckey)
+		}
+		c.where = append(c.where, fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", t.timeColumn, op, t.timeColumn, t.keyColumn, op))
+		c.args = append(c.args, ct, ct, key)
+	}
+	if lq.FromTime > 0 {
+		c.add(t.timeColumn+" >= ?", lq.FromTime)
+	}
+	if lq.ToTime > 0 {
+		c.add(t.timeColumn+" < ?", lq.ToTime)
+	}
+	if lq.TokenAddress != utils.EmptyAddress {
+		c.add("token_address = ?", lq.TokenAddress[:])
+	}
+	rows, err := dao.db.Query(fmt.Sprintf("SELECT value FROM %s%s ORDER BY %s %s, %s %s", t.name, c.String(), t.timeColumn, order, t.keyColumn, order), c.args...)
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	defer rows.Close()
+	matched := 0
+	for rows.Next() {
+		var buf []byte
+		err = rows.Scan(&buf)
+		if err != nil {
+			return models.GeneratDBError(err)
+		}
+		v := newValue()
+		err = dao.decodeValue(buf, v)
+		if err != nil {
+			return models.GeneratDBError(err)
+		}
+		if !match(v) {
+			continue
+		}
+		matched++
+		if lq.Limit > 0 && matched > lq.Limit {
+			break
+		}
+	}
+	return models.GeneratDBError(rows.Err())
+}
+
+//addBlockRange 有block_number列的表
+func addBlockRange(lq *models.ListQuery, c *conditions) {
+	if lq.FromBlock > 0 {
+		c.add("block_number >= ?", lq.FromBlock)
+	}
+	if lq.ToBlock > 0 {
+		c.add("block_number < ?", lq.ToBlock)
+	}
+}
+
+// QuerySentTransferDetails :
+func (dao *SQLiteDB) QuerySentTransferDetails(lq *models.ListQuery) (transfers []*models.SentTransferDetail, nextCursor string, err error) {
+	var c conditions
+	addBlockRange(lq, &c)
+	err = dao.queryPage(lq, listTable{"sent_transfer_details", "sending_time", "key", true}, &c, func() interface{} {
+		return new(models.SentTransferDetail)
+	}, func(v interface{}) bool {
+		std := v.(*models.SentTransferDetail)
+		if !lq.MatchSentTransferDetail(std) {
+			return false
+		}
+		transfers = append(transfers, std)
+		return true
+	})
+	if err != nil {
+		return
+	}
+	n, nextCursor := lq.Page(len(transfers), func(i int) (int64, []byte) {
+		return transfers[i].SendingTime, []byte(transfers[i].Key)
+	})
+	transfers = transfers[:n]
+	return
+}
+
+// QueryReceivedTransfers :
+func (dao *SQLiteDB) QueryReceivedTransfers(lq *models.ListQuery) (transfers []*models.ReceivedTransfer, nextCursor string, err error) {
+	var c conditions
+	addBlockRange(lq, &c)
+	err = dao.queryPage(lq, listTable{"received_transfers", "time_stamp", "key", true}, &c, func() interface{} {
+		return new(models.ReceivedTransfer)
+	}, func(v interface{}) bool {
+		r := v.(*models.ReceivedTransfer)
+		if !lq.MatchReceivedTransfer(r) {
+			return false
+		}
+		transfers = append(transfers, r)
+		return true
+	})
+	if err != nil {
+		return
+	}
+	n, nextCursor := lq.Page(len(transfers), func(i int) (int64, []byte) {
+		return transfers[i].TimeStamp, []byte(transfers[i].Key)
+	})
+	transfers = transfers[:n]
+	return
+}
+
+// QueryFeeChargeRecords :
+func (dao *SQLiteDB) QueryFeeChargeRecords(lq *models.ListQuery) (records []*models.FeeChargeRecord, nextCursor string, err error) {
+	var c conditions
+	addBlockRange(lq, &c)
+	var rs []*models.FeeChargerRecordSerialization
+	err = dao.queryPage(lq, listTable{"fee_charge_records", "timestamp", "key", false}, &c, func() interface{} {
+		return new(models.FeeChargerRecordSerialization)
+	}, func(v interface{}) bool {
+		r := v.(*models.FeeChargerRecordSerialization)
+		if !lq.MatchFeeChargeRecord(r) {
+			return false
+		}
+		rs = append(rs, r)
+		return true
+	})
+	if err != nil {
+		return
+	}
+	n, nextCursor := lq.Page(len(rs), func(i int) (int64, []byte) {
+		return rs[i].Timestamp, rs[i].Key
+	})
+	for _, r := range rs[:n] {
+		records = append(records, r.ToFeeChargeRecord())
+	}
+	return
+}
+
+// QueryTXInfos :
+func (dao *SQLiteDB) QueryTXInfos(lq *models.ListQuery) (list []*models.TXInfo, nextCursor string, err error) {
+	var c conditions
+	if lq.ChannelIdentifier != utils.EmptyHash {
+		c.add("channel_identifier = ?", lq.ChannelIdentifier[:])
+	}
+	if lq.Type != "" {
+		c.addIn("type", lq.Type)
+	}
+	if lq.Status != "" {
+		c.addIn("status", lq.Status)
+	}
+	var l []*models.TXInfoSerialization
+	err = dao.queryPage(lq, listTable{"tx_infos", "call_time", "tx_hash", false}, &c, func() interface{} {
+		return new(models.TXInfoSerialization)
+	}, func(v interface{}) bool {
+		tis := v.(*models.TXInfoSerialization)
+		if !lq.MatchTXInfo(tis) {
+			return false
+		}
+		l = append(l, tis)
+		return true
+	})
+	if err != nil {
+		return
+	}
+	n, nextCursor := lq.Page(len(l), func(i int) (int64, []byte) {
+		return l[i].CallTime, l[i].TXHash
+	})
+	for _, tis := range l[:n] {
+		list = append(list, tis.ToTXInfo())
+	}
+	return
+}
@@ -0,0 +1,42 @@
+package sqlitedb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SaveMediationPolicy :
+func (dao *SQLiteDB) SaveMediationPolicy(p *models.MediationPolicy) error {
+	p.Key = p.TokenAddress.String()
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketMediationPolicy, p.Key, p)
+	return models.GeneratDBError(err)
+}
+
+// GetMediationPolicy :
+func (dao *SQLiteDB) GetMediationPolicy(tokenAddress common.Address) (p *models.MediationPolicy, err error) {
+	p = &models.MediationPolicy{}
+	err = dao.getKeyValueToBucket(models.BucketMediationPolicy, tokenAddress.String(), p)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetMediationPolicyList :
+func (dao *SQLiteDB) GetMediationPolicyList() (ps []*models.MediationPolicy, err error) {
+	err = dao.getAllFromBucket(models.BucketMediationPolicy, func() interface{} {
+		p := &models.MediationPolicy{}
+		ps = append(ps, p)
+		return p
+	})
+	err = models.GeneratDBError(err)
+	return
+}
+
+// RemoveMediationPolicy :
+func (dao *SQLiteDB) RemoveMediationPolicy(tokenAddress common.Address) error {
+	p, err := dao.GetMediationPolicy(tokenAddress)
+	if err != nil {
+		return err
+	}
+	err = dao.removeKeyValueFromBucket(models.BucketMediationPolicy, p.Key)
+	return models.GeneratDBError(err)
+}
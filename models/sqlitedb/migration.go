@@ -0,0 +1,160 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+)
+
+//migrationTable 有独立表的storm bucket
+type migrationTable struct {
+	name    string
+	keyName string
+	textKey bool // key列是TEXT,查询参数必须是string
+}
+
+var migrationTables = map[string]migrationTable{
+	"Serialization":                 {"channels", "key", false},
+	"ReceivedTransfer":              {"received_transfers", "key", true},
+	"SentTransferDetail":            {"sent_transfer_details", "key", true},
+	"TXInfoSerialization":           {"tx_infos", "tx_hash", false},
+	"FeeChargerRecordSerialization": {"fee_charge_records", "key", false},
+}
+
+type sqliteMigrationTX struct {
+	tx  *sql.Tx
+	dao *SQLiteDB
+}
+
+func (mtx *sqliteMigrationTX) keyArg(t migrationTable, key []byte) interface{} {
+	if t.textKey {
+		return string(key)
+	}
+	return key
+}
+
+// ForEach :
+func (mtx *sqliteMigrationTX) ForEach(bucket string, newValue func() interface{}, f func(key []byte, v interface{}) error) error {
+	query := "SELECT key, value FROM kv WHERE bucket = ? ORDER BY key"
+	args := []interface{}{bucket}
+	if t, ok := migrationTables[bucket]; ok {
+		query = fmt.Sprintf("SELECT %s, value FROM %s ORDER BY %s", t.keyName, t.name, t.keyName)
+		args = nil
+	}
+	type record struct {
+		key []byte
+		v   interface{}
+	}
+	var records []record
+	rows, err := mtx.tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var key, buf []byte
+		err = rows.Scan(&key, &buf)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		to := newValue()
+		err = mtx.dao.decodeValue(buf, to)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		records = append(records, record{key, to})
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for _, r := range records {
+		err = f(r.key, r.v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get :
+func (mtx *sqliteMigrationTX) Get(bucket string, key []byte, to interface{}) error {
+	var buf []byte
+	var err error
+	if t, ok := migrationTables[bucket]; ok {
+		err = mtx.tx.QueryRow(fmt.Sprintf("SELECT value FROM %s WHERE %s = ?", t.name, t.keyName), mtx.keyArg(t, key)).Scan(&buf)
+	} else {
+		err = mtx.tx.QueryRow("SELECT value FROM kv WHERE bucket = ? AND key = ?", bucket, key).Scan(&buf)
+	}
+	if err == sql.ErrNoRows {
+		return rerr.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return mtx.dao.decodeValue(buf, to)
+}
+
+//Put 独立的表只更新value列,不能增加记录
+func (mtx *sqliteMigrationTX) Put(bucket string, key []byte, value interface{}) error {
+	t, ok := migrationTables[bucket]
+	if !ok {
+		return mtx.dao.saveKeyValueToBucket(mtx.tx, bucket, key, value)
+	}
+	r, err := mtx.tx.Exec(fmt.Sprintf("UPDATE %s SET value = ? WHERE %s = ?", t.name, t.keyName), mtx.dao.encodeValue(value), mtx.keyArg(t, key))
+	if err != nil {
+		return err
+	}
+	n, err := r.RowsAffected()
+	if err == nil && n == 0 {
+		err = rerr.ErrNotFound
+	}
+	return err
+}
+
+// Commit :
+func (mtx *sqliteMigrationTX) Commit() error {
+	return mtx.tx.Commit()
+}
+
+// Rollback :
+func (mtx *sqliteMigrationTX) Rollback() error {
+	return mtx.tx.Rollback()
+}
+
+// DBPath :
+func (dao *SQLiteDB) DBPath() string {
+	return dao.Name
+}
+
+// GetDbVersion :
+func (dao *SQLiteDB) GetDbVersion() (ver int, err error) {
+	err = dao.getKeyValueToBucket(models.BucketMeta, models.KeyVersion, &ver)
+	return
+}
+
+// Backup VACUUM INTO得到一致的备份
+func (dao *SQLiteDB) Backup(path string) error {
+	_, err := dao.db.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+// BeginMigration :
+func (dao *SQLiteDB) BeginMigration() (tx models.MigrationTX, err error) {
+	stx, err := dao.db.Begin()
+	if err != nil {
+		return
+	}
+	return &sqliteMigrationTX{
+		tx:  stx,
+		dao: dao,
+	}, nil
+}
+
+// Migrate 把数据库升级到models.DbVersion,dryRun的时候只报告会修改的记录
+func (dao *SQLiteDB) Migrate(dryRun bool) (report *models.MigrationReport, err error) {
+	return models.RunMigrations(dao, dryRun)
+}
@@ -0,0 +1,79 @@
+package sqlitedb
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//NonParticipantChannel 和stormdb中的结构一样,保存在kv中
+type NonParticipantChannel struct {
+	ChannelIdentifierBytes []byte
+	TokenAddressBytes      []byte
+	Participant1Bytes      []byte
+	Participant2Bytes      []byte
+}
+
+const bucketNonParticipantChannel = "NonParticipantChannel"
+
+//NewNonParticipantChannel 需要保存 channel identifier, 通道的事件都是与此有关系的
+func (dao *SQLiteDB) NewNonParticipantChannel(token common.Address, channel common.Hash, participant1, participant2 common.Address) error {
+	if participant1 == participant2 {
+		return fmt.Errorf("channel error, p1 andf p2 is the same,token=%s,participant=%s", token.String(), participant1.String())
+	}
+	_, _, _, err := dao.GetNonParticipantChannelByID(channel)
+	if err == nil {
+		return fmt.Errorf("channel %s already exists", channel.String())
+	}
+	err = dao.saveKeyValueToBucket(dao.db, bucketNonParticipantChannel, channel[:], &NonParticipantChannel{
+		ChannelIdentifierBytes: channel[:],
+		TokenAddressBytes:      token[:],
+		Participant1Bytes:      participant1[:],
+		Participant2Bytes:      participant2[:],
+	})
+	return models.GeneratDBError(err)
+}
+
+//RemoveNonParticipantChannel a channel is settled
+func (dao *SQLiteDB) RemoveNonParticipantChannel(channel common.Hash) error {
+	err := dao.removeKeyValueFromBucket(bucketNonParticipantChannel, channel[:])
+	return models.GeneratDBError(err)
+}
+
+//GetNonParticipantChannelByID return one channel's information
+func (dao *SQLiteDB) GetNonParticipantChannelByID(channelIdentifierForQuery common.Hash) (
+	tokenAddress common.Address, participant1, participant2 common.Address, err error) {
+	var channel NonParticipantChannel
+	err = dao.getKeyValueToBucket(bucketNonParticipantChannel, channelIdentifierForQuery[:], &channel)
+	if err != nil {
+		err = fmt.Errorf("GetNonParticipantChannelByID err %s", err)
+		return
+	}
+	tokenAddress = common.BytesToAddress(channel.TokenAddressBytes)
+	participant1 = common.BytesToAddress(channel.Participant1Bytes)
+	participant2 = common.BytesToAddress(channel.Participant2Bytes)
+	return
+}
+
+//GetAllNonParticipantChannelByToken returna all channel on this `token`
+func (dao *SQLiteDB) GetAllNonParticipantChannelByToken(token common.Address) (edges []common.Address, err error) {
+	var channels []*NonParticipantChannel
+	err = dao.getAllFromBucket(bucketNonParticipantChannel, func() interface{} {
+		c := new(NonParticipantChannel)
+		channels = append(channels, c)
+		return c
+	})
+	if err != nil {
+		err = fmt.Errorf("GetAllNonParticipantChannelByToken err %s", err)
+		err = models.GeneratDBError(err)
+		return
+	}
+	for _, c := range channels {
+		if bytes.Equal(c.TokenAddressBytes, token[:]) {
+			edges = append(edges, common.BytesToAddress(c.Participant1Bytes), common.BytesToAddress(c.Participant2Bytes))
+		}
+	}
+	return
+}
@@ -0,0 +1,30 @@
+package sqlitedb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+)
+
+// SavePaymentPlan :
+func (dao *SQLiteDB) SavePaymentPlan(p *models.PaymentPlan) error {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketPaymentPlan, p.Key, p)
+	return models.GeneratDBError(err)
+}
+
+// GetPaymentPlan :
+func (dao *SQLiteDB) GetPaymentPlan(key string) (p *models.PaymentPlan, err error) {
+	p = &models.PaymentPlan{}
+	err = dao.getKeyValueToBucket(models.BucketPaymentPlan, key, p)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetPaymentPlanList :
+func (dao *SQLiteDB) GetPaymentPlanList() (ps []*models.PaymentPlan, err error) {
+	err = dao.getAllFromBucket(models.BucketPaymentPlan, func() interface{} {
+		p := &models.PaymentPlan{}
+		ps = append(ps, p)
+		return p
+	})
+	err = models.GeneratDBError(err)
+	return
+}
@@ -0,0 +1,21 @@
+package sqlitedb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SavePaymentReceipt :
+func (dao *SQLiteDB) SavePaymentReceipt(r *models.PaymentReceipt) error {
+	r.Key = models.NewPaymentReceiptKey(r.TokenAddress, r.LockSecretHash)
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketPaymentReceipt, r.Key, r)
+	return models.GeneratDBError(err)
+}
+
+// GetPaymentReceipt :
+func (dao *SQLiteDB) GetPaymentReceipt(tokenAddress common.Address, lockSecretHash common.Hash) (r *models.PaymentReceipt, err error) {
+	r = &models.PaymentReceipt{}
+	err = dao.getKeyValueToBucket(models.BucketPaymentReceipt, models.NewPaymentReceiptKey(tokenAddress, lockSecretHash), r)
+	err = models.GeneratDBError(err)
+	return
+}
@@ -0,0 +1,100 @@
+package sqlitedb
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//MarkLockSecretHashDisposed mark `locksecrethash` disposed on channel `ChannelIdentifier`
+func (dao *SQLiteDB) MarkLockSecretHashDisposed(lockSecretHash common.Hash, ChannelIdentifier common.Hash) error {
+	key := utils.Sha3(lockSecretHash[:], ChannelIdentifier[:])
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketSentAnnounceDisposed, key[:], &models.SentAnnounceDisposed{
+		Key:               key[:],
+		LockSecretHash:    lockSecretHash[:],
+		ChannelIdentifier: ChannelIdentifier,
+	})
+	err = models.GeneratDBError(err)
+	return err
+}
+
+//IsLockSecretHashDisposed this lockSecretHash has Announced Disposed
+func (dao *SQLiteDB) IsLockSecretHashDisposed(lockSecretHash common.Hash) bool {
+	var sads []*models.SentAnnounceDisposed
+	err := dao.getAllFromBucket(models.BucketSentAnnounceDisposed, func() interface{} {
+		sad := new(models.SentAnnounceDisposed)
+		sads = append(sads, sad)
+		return sad
+	})
+	if err != nil {
+		return false
+	}
+	for _, sad := range sads {
+		if bytes.Equal(sad.LockSecretHash, lockSecretHash[:]) {
+			return true
+		}
+	}
+	return false
+}
+
+//IsLockSecretHashChannelIdentifierDisposed `lockSecretHash` and `ChannelIdentifier` is the id of AnnounceDisposed
+func (dao *SQLiteDB) IsLockSecretHashChannelIdentifierDisposed(lockSecretHash common.Hash, ChannelIdentifier common.Hash) bool {
+	key := utils.Sha3(lockSecretHash[:], ChannelIdentifier[:])
+	return dao.hasKey(models.BucketSentAnnounceDisposed, key[:])
+}
+
+//MarkLockHashCanPunish 收到了一个放弃声明,需要保存,在收到 unlock 事件的时候进行 punish
+/*
+ *	MarkLockHashCanPunish : Once receiving an AnnounceDisposed message, we need to store it
+ * 	and submit it to enforce punishment procedure while receiving unlock.
+ */
+func (dao *SQLiteDB) MarkLockHashCanPunish(r *models.ReceivedAnnounceDisposed) error {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketReceivedAnnounceDisposed, r.Key, r)
+	return models.GeneratDBError(err)
+}
+
+//IsLockHashCanPunish can punish this unlock?
+func (dao *SQLiteDB) IsLockHashCanPunish(lockHash, channelIdentifier common.Hash) bool {
+	key := utils.Sha3(lockHash[:], channelIdentifier[:])
+	return dao.hasKey(models.BucketReceivedAnnounceDisposed, key[:])
+}
+
+//GetReceivedAnnounceDisposed return a ReceivedAnnounceDisposed ,if not  exist,return nil
+func (dao *SQLiteDB) GetReceivedAnnounceDisposed(lockHash, channelIdentifier common.Hash) *models.ReceivedAnnounceDisposed {
+	sad := new(models.ReceivedAnnounceDisposed)
+	key := utils.Sha3(lockHash[:], channelIdentifier[:])
+	err := dao.getKeyValueToBucket(models.BucketReceivedAnnounceDisposed, key[:], sad)
+	if err != nil {
+		return nil
+	}
+	return sad
+}
+
+/*
+GetChannelAnnounceDisposed 获取指定 channel中对方声明放弃的锁,
+*/
+/*
+ *	GetChannelAnnounceDisposed : function to receive disposed locks claimed by channel partner in specific channel
+ */
+func (dao *SQLiteDB) GetChannelAnnounceDisposed(channelIdentifier common.Hash) []*models.ReceivedAnnounceDisposed {
+	var all, anns []*models.ReceivedAnnounceDisposed
+	err := dao.getAllFromBucket(models.BucketReceivedAnnounceDisposed, func() interface{} {
+		sad := new(models.ReceivedAnnounceDisposed)
+		all = append(all, sad)
+		return sad
+	})
+	if err != nil {
+		log.Error(fmt.Sprintf("GetChannelAnnounceDisposed for %s ,err %s", channelIdentifier.String(), err))
+		return nil
+	}
+	for _, sad := range all {
+		if bytes.Equal(sad.ChannelIdentifier, channelIdentifier[:]) {
+			anns = append(anns, sad)
+		}
+	}
+	return anns
+}
@@ -0,0 +1,267 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//deleteInTx 在一个事务中用query删除每一个key
+func (dao *SQLiteDB) deleteInTx(tx *sql.Tx, query string, keys []interface{}) error {
+	for _, key := range keys {
+		_, err := tx.Exec(query, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (dao *SQLiteDB) deleteKeys(query string, keys []interface{}) (err error) {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	err = dao.deleteInTx(tx, query, keys)
+	if err != nil {
+		tx.Rollback()
+		return models.GeneratDBError(err)
+	}
+	return models.GeneratDBError(tx.Commit())
+}
+
+// ArchiveIncomeRecords :
+func (dao *SQLiteDB) ArchiveIncomeRecords(receivedTransferKeys []string, feeChargeRecordKeys []common.Hash, aggregates []*models.IncomeAggregate) (err error) {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = models.GeneratDBError(err)
+		}
+	}()
+	for _, a := range aggregates {
+		var buf []byte
+		err = tx.QueryRow("SELECT value FROM kv WHERE bucket = ? AND key = ?", models.BucketIncomeAggregate, toBytes(a.Key)).Scan(&buf)
+		if err == nil {
+			old := new(models.IncomeAggregate)
+			err = dao.decodeValue(buf, old)
+			if err != nil {
+				return
+			}
+			old.Add(a)
+			a = old
+		} else if err != sql.ErrNoRows {
+			return
+		}
+		err = dao.saveKeyValueToBucket(tx, models.BucketIncomeAggregate, a.Key, a)
+		if err != nil {
+			return
+		}
+	}
+	var keys []interface{}
+	for _, key := range receivedTransferKeys {
+		keys = append(keys, key)
+	}
+	err = dao.deleteInTx(tx, "DELETE FROM received_transfers WHERE key = ?", keys)
+	if err != nil {
+		return
+	}
+	keys = nil
+	for _, key := range feeChargeRecordKeys {
+		keys = append(keys, key[:])
+	}
+	err = dao.deleteInTx(tx, "DELETE FROM fee_charge_records WHERE key = ?", keys)
+	if err != nil {
+		return
+	}
+	return tx.Commit()
+}
+
+// GetIncomeAggregateList :
+func (dao *SQLiteDB) GetIncomeAggregateList(tokenAddress common.Address, fromTime, toTime int64) (as []*models.IncomeAggregate, err error) {
+	var all []*models.IncomeAggregate
+	err = dao.getAllFromBucket(models.BucketIncomeAggregate, func() interface{} {
+		a := new(models.IncomeAggregate)
+		all = append(all, a)
+		return a
+	})
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	for _, a := range all {
+		if tokenAddress != utils.EmptyAddress && a.TokenAddress != tokenAddress {
+			continue
+		}
+		if (fromTime > 0 && a.Hour < fromTime) || (toTime > 0 && a.Hour >= toTime) {
+			continue
+		}
+		as = append(as, a)
+	}
+	return
+}
+
+// RemoveSentTransferDetails :
+func (dao *SQLiteDB) RemoveSentTransferDetails(keys []string) error {
+	var ks []interface{}
+	for _, key := range keys {
+		ks = append(ks, key)
+	}
+	return dao.deleteKeys("DELETE FROM sent_transfer_details WHERE key = ?", ks)
+}
+
+// RemoveTXInfos :
+func (dao *SQLiteDB) RemoveTXInfos(txHashes []common.Hash) error {
+	var ks []interface{}
+	for _, h := range txHashes {
+		ks = append(ks, h[:])
+	}
+	return dao.deleteKeys("DELETE FROM tx_infos WHERE tx_hash = ?", ks)
+}
+
+// GetAckList :
+func (dao *SQLiteDB) GetAckList(before int64) (as []*models.AckRecord, err error) {
+	var all []*models.AckRecord
+	rows, err := dao.db.Query("SELECT key, value FROM kv WHERE bucket = ? ORDER BY key", models.BucketAck)
+	if err != nil {
+		return nil, models.GeneratDBError(err)
+	}
+	for rows.Next() {
+		var key, buf []byte
+		err = rows.Scan(&key, &buf)
+		if err == nil {
+			a := &models.AckRecord{EchoHash: common.BytesToHash(key)}
+			err = dao.decodeValue(buf, &a.Ack)
+			all = append(all, a)
+		}
+		if err != nil {
+			rows.Close()
+			return nil, models.GeneratDBError(err)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, models.GeneratDBError(err)
+	}
+	for _, a := range all {
+		err = dao.getKeyValueToBucket(models.BucketAckTime, a.EchoHash[:], &a.Time)
+		if err == rerr.ErrNotFound {
+			//升级的时候已经记录了所有ack的时间
+			err = nil
+			continue
+		}
+		if err != nil {
+			return nil, models.GeneratDBError(err)
+		}
+		if a.Time < before {
+			as = append(as, a)
+		}
+	}
+	return
+}
+
+// RemoveAcks :
+func (dao *SQLiteDB) RemoveAcks(echoHashes []common.Hash) error {
+	var ks []interface{}
+	for _, h := range echoHashes {
+		ks = append(ks, h[:])
+	}
+	return dao.deleteKeys("DELETE FROM kv WHERE bucket IN ('"+models.BucketAck+"', '"+models.BucketAckTime+"') AND key = ?", ks)
+}
+
+// GetTransferMemoList :
+func (dao *SQLiteDB) GetTransferMemoList(before int64) (ms []*models.TransferMemo, err error) {
+	var all []*models.TransferMemo
+	err = dao.getAllFromBucket(models.BucketTransferMemo, func() interface{} {
+		m := new(models.TransferMemo)
+		all = append(all, m)
+		return m
+	})
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	for _, m := range all {
+		if m.CreateTime < before {
+			ms = append(ms, m)
+		}
+	}
+	return
+}
+
+// RemoveTransferMemos :
+func (dao *SQLiteDB) RemoveTransferMemos(keys []string) error {
+	var ks []interface{}
+	for _, key := range keys {
+		ks = append(ks, []byte(key))
+	}
+	return dao.deleteKeys("DELETE FROM kv WHERE bucket = '"+models.BucketTransferMemo+"' AND key = ?", ks)
+}
+
+// GetChainEventRecordList :
+func (dao *SQLiteDB) GetChainEventRecordList(blockNumber uint64) (rs []*models.ChainEventRecord, err error) {
+	var all []*models.ChainEventRecord
+	err = dao.getAllFromBucket(models.BucketChainEventRecord, func() interface{} {
+		r := new(models.ChainEventRecord)
+		all = append(all, r)
+		return r
+	})
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	for _, r := range all {
+		if r.BlockNumber <= blockNumber {
+			rs = append(rs, r)
+		}
+	}
+	return
+}
+
+// Compact 删除的数据只是标记为空闲页,VACUUM重建数据库文件,然后清空WAL文件
+func (dao *SQLiteDB) Compact() error {
+	_, err := dao.db.Exec("VACUUM")
+	if err == nil {
+		_, err = dao.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	}
+	return models.GeneratDBError(err)
+}
+
+/*
+CompactDb 离线压缩dbPath,不需要解密,返回压缩前后文件的大小
+*/
+func CompactDb(dbPath string) (before, after int64, err error) {
+	fi, err := os.Stat(dbPath)
+	if err != nil {
+		return
+	}
+	before = fi.Size()
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_busy_timeout=5000", dbPath))
+	if err != nil {
+		return
+	}
+	_, err = db.Exec("VACUUM")
+	if err == nil {
+		_, err = db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	}
+	db.Close()
+	if err != nil {
+		return
+	}
+	fi, err = os.Stat(dbPath)
+	if err != nil {
+		return
+	}
+	after = fi.Size()
+	log.Info(fmt.Sprintf("compact %s from %d to %d bytes", dbPath, before, after))
+	return
+}
@@ -0,0 +1,177 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/network/rpc/contracts"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func (dao *SQLiteDB) saveSentTransferDetail(std *models.SentTransferDetail) error {
+	_, err := dao.db.Exec("INSERT OR REPLACE INTO sent_transfer_details (key, token_address, block_number, sending_time, finish_time, value) VALUES (?, ?, ?, ?, ?, ?)",
+		std.Key, std.TokenAddress[:], std.BlockNumber, std.SendingTime, std.FinishTime, dao.encodeValue(std))
+	return err
+}
+
+func (dao *SQLiteDB) getSentTransferDetail(key string) (std *models.SentTransferDetail, err error) {
+	var buf []byte
+	std = &models.SentTransferDetail{}
+	err = dao.db.QueryRow("SELECT value FROM sent_transfer_details WHERE key = ?", key).Scan(&buf)
+	if err == sql.ErrNoRows {
+		err = rerr.ErrNotFound
+		return
+	}
+	if err != nil {
+		return
+	}
+	err = dao.decodeValue(buf, std)
+	return
+}
+
+// NewSentTransferDetail :
+func (dao *SQLiteDB) NewSentTransferDetail(tokenAddress, target common.Address, amount *big.Int, data string, isDirect bool, lockSecretHash common.Hash) {
+	std := &models.SentTransferDetail{
+		Key:               utils.Sha3(tokenAddress[:], lockSecretHash[:]).String(),
+		BlockNumber:       dao.GetLatestBlockNumber(),
+		TokenAddress:      tokenAddress,
+		TokenAddressBytes: tokenAddress[:],
+		TargetAddress:     target,
+		Amount:            amount,
+		Data:              data,
+		IsDirect:          isDirect,
+		SendingTime:       time.Now().Unix(),
+		FinishTime:        0,
+		Status:            models.TransferStatusInit,
+		StatusMessage:     "",
+		ChannelIdentifier: utils.EmptyHash,
+		OpenBlockNumber:   0,
+	}
+	err := dao.saveSentTransferDetail(std)
+	if err != nil {
+		log.Error(fmt.Sprintf("NewSendTransferDetail key=%s, err %s", std.Key, err))
+		return
+	}
+	log.Trace(fmt.Sprintf("NewSendTransferDetail key=%s lockSecertHash=%s", std.Key, lockSecretHash.String()))
+}
+
+// UpdateSentTransferDetailStatus :
+func (dao *SQLiteDB) UpdateSentTransferDetailStatus(tokenAddress common.Address, lockSecretHash common.Hash, status models.TransferStatusCode, statusMessage string, otherParams interface{}) (transfer *models.SentTransferDetail) {
+	key := utils.Sha3(tokenAddress[:], lockSecretHash[:]).String()
+	transfer, err := dao.getSentTransferDetail(key)
+	if err == rerr.ErrNotFound {
+		return
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdateStatus err %s", err))
+		return
+	}
+	transfer.Status = status
+	transfer.StatusMessage = fmt.Sprintf("%s%s\n", transfer.StatusMessage, statusMessage)
+	if status == models.TransferStatusSuccess {
+		if otherParams != nil {
+			chID, ok := otherParams.(contracts.ChannelUniqueID)
+			if ok {
+				transfer.ChannelIdentifier = chID.ChannelIdentifier
+				transfer.OpenBlockNumber = chID.OpenBlockNumber
+			}
+		}
+		transfer.FinishTime = time.Now().Unix()
+	}
+	if status == models.TransferStatusCanceled || status == models.TransferStatusFailed {
+		transfer.FinishTime = time.Now().Unix()
+	}
+	err = dao.saveSentTransferDetail(transfer)
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdateStatus err %s", err))
+		return
+	}
+	log.Trace(fmt.Sprintf("UpdateStatus key=%s lockSecretHash=%s %s", key, lockSecretHash.String(), statusMessage))
+	return
+}
+
+// UpdateSentTransferDetailStatusMessage :
+func (dao *SQLiteDB) UpdateSentTransferDetailStatusMessage(tokenAddress common.Address, lockSecretHash common.Hash, statusMessage string) (transfer *models.SentTransferDetail) {
+	key := utils.Sha3(tokenAddress[:], lockSecretHash[:]).String()
+	transfer, err := dao.getSentTransferDetail(key)
+	if err == rerr.ErrNotFound {
+		return
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdateStatusMessage err %s", err))
+		return
+	}
+	transfer.StatusMessage = fmt.Sprintf("%s%s\n", transfer.StatusMessage, statusMessage)
+	err = dao.saveSentTransferDetail(transfer)
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdateStatusMessage err %s", err))
+		return
+	}
+	log.Trace(fmt.Sprintf("UpdateStatusMessage key=%s lockSecretHash=%s %s", key, lockSecretHash.String(), statusMessage))
+	return
+}
+
+// UpdateSentTransferDetailPaymentPlan :
+func (dao *SQLiteDB) UpdateSentTransferDetailPaymentPlan(tokenAddress common.Address, lockSecretHash common.Hash, planKey string, run int) (transfer *models.SentTransferDetail) {
+	key := utils.Sha3(tokenAddress[:], lockSecretHash[:]).String()
+	transfer, err := dao.getSentTransferDetail(key)
+	if err == rerr.ErrNotFound {
+		return
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdatePaymentPlan err %s", err))
+		return
+	}
+	transfer.PaymentPlanKey = planKey
+	transfer.PaymentPlanRun = run
+	err = dao.saveSentTransferDetail(transfer)
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdatePaymentPlan err %s", err))
+		return
+	}
+	log.Trace(fmt.Sprintf("UpdatePaymentPlan key=%s lockSecretHash=%s plan=%s run=%d", key, lockSecretHash.String(), planKey, run))
+	return
+}
+
+// GetSentTransferDetail :
+func (dao *SQLiteDB) GetSentTransferDetail(tokenAddress common.Address, lockSecretHash common.Hash) (*models.SentTransferDetail, error) {
+	key := utils.Sha3(tokenAddress[:], lockSecretHash[:]).String()
+	ts, err := dao.getSentTransferDetail(key)
+	log.Trace(fmt.Sprintf("GetSentTransferDetail key=%s lockSecretHash=%s err=%s", key, lockSecretHash.String(), err))
+	err = models.GeneratDBError(err)
+	return ts, err
+}
+
+// GetSentTransferDetailList :
+// 参数均为查询条件,传空值或负值代表不限制
+func (dao *SQLiteDB) GetSentTransferDetailList(tokenAddress common.Address, fromTime, toTime int64, fromBlock, toBlock int64) (transfers []*models.SentTransferDetail, err error) {
+	var c conditions
+	if tokenAddress != utils.EmptyAddress {
+		c.add("token_address = ?", tokenAddress[:])
+	}
+	if fromTime > 0 {
+		c.add("sending_time >= ?", fromTime)
+	}
+	if toTime > 0 {
+		c.add("finish_time < ?", toTime)
+	}
+	if fromBlock > 0 {
+		c.add("block_number >= ?", fromBlock)
+	}
+	if toBlock > 0 {
+		c.add("block_number < ?", toBlock)
+	}
+	err = dao.queryValues(func() interface{} {
+		std := new(models.SentTransferDetail)
+		transfers = append(transfers, std)
+		return std
+	}, "SELECT value FROM sent_transfer_details"+c.String()+" ORDER BY key", c.args...)
+	return
+}
@@ -0,0 +1,40 @@
+package sqlitedb
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//NewSettledChannel save a settled channel to db
+func (dao *SQLiteDB) NewSettledChannel(c *channeltype.Serialization) error {
+	if c.State != channeltype.StateSettled {
+		panic("only settled channel can saved to settledChannel")
+	}
+	key := fmt.Sprintf("%s-%d", c.ChannelIdentifier.ChannelIdentifier.String(), c.ChannelIdentifier.OpenBlockNumber)
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketSettledChannel, key, c)
+	return models.GeneratDBError(err)
+}
+
+//GetAllSettledChannel returns all settled channel
+func (dao *SQLiteDB) GetAllSettledChannel() (chs []*channeltype.Serialization, err error) {
+	err = dao.getAllFromBucket(models.BucketSettledChannel, func() interface{} {
+		c := new(channeltype.Serialization)
+		chs = append(chs, c)
+		return c
+	})
+	err = models.GeneratDBError(err)
+	return
+}
+
+//GetSettledChannel 返回某个指定的已经 settle 的 channel
+// GetSettledChannel : function to return a specific settled channel.
+func (dao *SQLiteDB) GetSettledChannel(channelIdentifier common.Hash, openBlockNumber int64) (c *channeltype.Serialization, err error) {
+	c = new(channeltype.Serialization)
+	key := fmt.Sprintf("%s-%d", channelIdentifier.String(), openBlockNumber)
+	err = dao.getKeyValueToBucket(models.BucketSettledChannel, key, c)
+	err = models.GeneratDBError(err)
+	return
+}
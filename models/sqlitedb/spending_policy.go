@@ -0,0 +1,67 @@
+package sqlitedb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SaveSpendingPolicy :
+func (dao *SQLiteDB) SaveSpendingPolicy(p *models.SpendingPolicy) error {
+	p.Key = p.TokenAddress.String()
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketSpendingPolicy, p.Key, p)
+	return models.GeneratDBError(err)
+}
+
+// GetSpendingPolicy :
+func (dao *SQLiteDB) GetSpendingPolicy(tokenAddress common.Address) (p *models.SpendingPolicy, err error) {
+	p = &models.SpendingPolicy{}
+	err = dao.getKeyValueToBucket(models.BucketSpendingPolicy, tokenAddress.String(), p)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetSpendingPolicyList :
+func (dao *SQLiteDB) GetSpendingPolicyList() (ps []*models.SpendingPolicy, err error) {
+	err = dao.getAllFromBucket(models.BucketSpendingPolicy, func() interface{} {
+		p := &models.SpendingPolicy{}
+		ps = append(ps, p)
+		return p
+	})
+	err = models.GeneratDBError(err)
+	return
+}
+
+// RemoveSpendingPolicy :
+func (dao *SQLiteDB) RemoveSpendingPolicy(tokenAddress common.Address) error {
+	p, err := dao.GetSpendingPolicy(tokenAddress)
+	if err != nil {
+		return err
+	}
+	err = dao.removeKeyValueFromBucket(models.BucketSpendingPolicy, p.Key)
+	return models.GeneratDBError(err)
+}
+
+// SavePendingApproval :
+func (dao *SQLiteDB) SavePendingApproval(a *models.PendingApproval) error {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketPendingApproval, a.Key, a)
+	return models.GeneratDBError(err)
+}
+
+// GetPendingApproval :
+func (dao *SQLiteDB) GetPendingApproval(key string) (a *models.PendingApproval, err error) {
+	a = &models.PendingApproval{}
+	err = dao.getKeyValueToBucket(models.BucketPendingApproval, key, a)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetPendingApprovalList :
+func (dao *SQLiteDB) GetPendingApprovalList() (as []*models.PendingApproval, err error) {
+	err = dao.getAllFromBucket(models.BucketPendingApproval, func() interface{} {
+		a := &models.PendingApproval{}
+		as = append(as, a)
+		return a
+	})
+	err = models.GeneratDBError(err)
+	return
+}
@@ -0,0 +1,93 @@
+package sqlitedb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+)
+
+/*
+StateChangeLog的ID是key-seq,seq补零到固定长度,
+所以一个状态机的所有状态变化在kv中是[key-,key.)这一段连续的key,按照key排序就是按照Seq排序
+*/
+func stateChangeLogRange(key string) (from, to []byte) {
+	return []byte(key + "-"), []byte(key + ".")
+}
+
+// AddStateChangeLog :
+func (dao *SQLiteDB) AddStateChangeLog(l *models.StateChangeLog) error {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketStateChangeLog, l.ID, l)
+	return models.GeneratDBError(err)
+}
+
+// GetStateChangeLogs :
+func (dao *SQLiteDB) GetStateChangeLogs(key string) (ls []*models.StateChangeLog, err error) {
+	from, to := stateChangeLogRange(key)
+	err = dao.queryValues(func() interface{} {
+		l := &models.StateChangeLog{}
+		ls = append(ls, l)
+		return l
+	}, "SELECT value FROM kv WHERE bucket = ? AND key >= ? AND key < ? ORDER BY key", models.BucketStateChangeLog, from, to)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// SaveStateManagerSnapshot :
+func (dao *SQLiteDB) SaveStateManagerSnapshot(s *models.StateManagerSnapshot) (err error) {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = models.GeneratDBError(err)
+		}
+	}()
+	err = dao.saveKeyValueToBucket(tx, models.BucketStateManagerSnapshot, s.Key, s)
+	if err != nil {
+		return
+	}
+	from, _ := stateChangeLogRange(s.Key)
+	to := models.NewStateChangeLog(s.Key, s.Seq, nil).ID
+	_, err = tx.Exec("DELETE FROM kv WHERE bucket = ? AND key >= ? AND key <= ?", models.BucketStateChangeLog, from, []byte(to))
+	if err != nil {
+		return
+	}
+	err = tx.Commit()
+	return
+}
+
+// GetStateManagerSnapshotList :
+func (dao *SQLiteDB) GetStateManagerSnapshotList() (ss []*models.StateManagerSnapshot, err error) {
+	err = dao.getAllFromBucket(models.BucketStateManagerSnapshot, func() interface{} {
+		s := &models.StateManagerSnapshot{}
+		ss = append(ss, s)
+		return s
+	})
+	err = models.GeneratDBError(err)
+	return
+}
+
+// RemoveStateManagerLog :
+func (dao *SQLiteDB) RemoveStateManagerLog(key string) (err error) {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = models.GeneratDBError(err)
+		}
+	}()
+	from, to := stateChangeLogRange(key)
+	_, err = tx.Exec("DELETE FROM kv WHERE bucket = ? AND key >= ? AND key < ?", models.BucketStateChangeLog, from, to)
+	if err != nil {
+		return
+	}
+	_, err = tx.Exec("DELETE FROM kv WHERE bucket = ? AND key = ?", models.BucketStateManagerSnapshot, toBytes(key))
+	if err != nil {
+		return
+	}
+	err = tx.Commit()
+	return
+}
@@ -0,0 +1,73 @@
+package sqlitedb
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/rerr"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/models/cb"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//GetAllTokens returna all tokens on this registry contract
+func (dao *SQLiteDB) GetAllTokens() (tokens models.AddressMap, err error) {
+	err = dao.getKeyValueToBucket(models.BucketToken, models.KeyToken, &tokens)
+	if err != nil {
+		if err == rerr.ErrNotFound {
+			tokens = make(models.AddressMap)
+		} else {
+			err = rerr.ErrGeneralDBError.AppendError(err)
+		}
+	}
+	return
+}
+
+//AddToken add a new token to db,
+func (dao *SQLiteDB) AddToken(token common.Address, tokenNetworkAddress common.Address) error {
+	var m models.AddressMap
+	err := dao.getKeyValueToBucket(models.BucketToken, models.KeyToken, &m)
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	if m[token] != utils.EmptyAddress {
+		//startup ...
+		log.Info("AddToken ,but already exists,should be ignored when startup...")
+		return nil
+	}
+	m[token] = tokenNetworkAddress
+	err = dao.saveKeyValueToBucket(dao.db, models.BucketToken, models.KeyToken, m)
+	dao.handleTokenCallback(dao.newTokenCallbacks, token)
+	return models.GeneratDBError(err)
+}
+func (dao *SQLiteDB) handleTokenCallback(m map[*cb.NewTokenCb]bool, token common.Address) {
+	var cbs []*cb.NewTokenCb
+	dao.mlock.Lock()
+	for f := range m {
+		remove := (*f)(token)
+		if remove {
+			cbs = append(cbs, f)
+		}
+	}
+	for _, f := range cbs {
+		delete(m, f)
+	}
+	dao.mlock.Unlock()
+}
+
+//UpdateTokenNodes update all nodes that open channel
+func (dao *SQLiteDB) UpdateTokenNodes(token common.Address, nodes []common.Address) error {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketTokenNodes, token[:], nodes)
+	return models.GeneratDBError(err)
+}
+
+//GetTokenNodes return all nodes has channel with me
+func (dao *SQLiteDB) GetTokenNodes(token common.Address) (nodes []common.Address) {
+	err := dao.getKeyValueToBucket(models.BucketTokenNodes, token[:], &nodes)
+	if err != nil {
+		log.Warn(fmt.Sprintf("GetTokenNodes for %s err=%s", token.String(), err))
+	}
+	return
+}
@@ -0,0 +1,105 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"math/big"
+	"time"
+
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func (dao *SQLiteDB) saveReceivedTransfer(r *models.ReceivedTransfer) error {
+	_, err := dao.db.Exec("INSERT OR REPLACE INTO received_transfers (key, token_address, block_number, time_stamp, value) VALUES (?, ?, ?, ?, ?)",
+		r.Key, r.TokenAddress[:], r.BlockNumber, r.TimeStamp, dao.encodeValue(r))
+	return err
+}
+
+//NewReceivedTransfer save a new received transfer to db
+func (dao *SQLiteDB) NewReceivedTransfer(blockNumber int64, channelIdentifier common.Hash, openBlockNumber int64, tokenAddr, fromAddr common.Address, nonce uint64, amount *big.Int, lockSecretHash common.Hash, data string) *models.ReceivedTransfer {
+	if lockSecretHash == utils.EmptyHash {
+		// direct transfer, use fakeLockSecretHash
+		lockSecretHash = utils.NewRandomHash()
+	}
+	key := fmt.Sprintf("%s-%d-%d", channelIdentifier.String(), openBlockNumber, nonce)
+	st := &models.ReceivedTransfer{
+		Key:               key,
+		BlockNumber:       blockNumber,
+		ChannelIdentifier: channelIdentifier,
+		TokenAddress:      tokenAddr,
+		TokenAddressBytes: tokenAddr[:],
+		FromAddress:       fromAddr,
+		Nonce:             nonce,
+		Amount:            amount,
+		Data:              data,
+		OpenBlockNumber:   openBlockNumber,
+		TimeStamp:         time.Now().Unix(),
+	}
+	if ost, err := dao.GetReceivedTransfer(key); err == nil {
+		log.Error(fmt.Sprintf("NewReceivedTransfer, but already exist, old=\n%s,new=\n%s",
+			utils.StringInterface(ost, 2), utils.StringInterface(st, 2)))
+		return nil
+	}
+	err := dao.saveReceivedTransfer(st)
+	if err != nil {
+		log.Error(fmt.Sprintf("save ReceivedTransfer err %s", err))
+	}
+	return st
+}
+
+//GetReceivedTransfer return the received transfer by key
+func (dao *SQLiteDB) GetReceivedTransfer(key string) (*models.ReceivedTransfer, error) {
+	var r models.ReceivedTransfer
+	var buf []byte
+	err := dao.db.QueryRow("SELECT value FROM received_transfers WHERE key = ?", key).Scan(&buf)
+	if err == sql.ErrNoRows {
+		return &r, rerr.ErrNotFound
+	}
+	if err == nil {
+		err = dao.decodeValue(buf, &r)
+	}
+	err = models.GeneratDBError(err)
+	return &r, err
+}
+
+//UpdateReceivedTransferData 加密附言在交易之后收齐时更新附加信息
+func (dao *SQLiteDB) UpdateReceivedTransferData(key string, data string) error {
+	r, err := dao.GetReceivedTransfer(key)
+	if err != nil {
+		return err
+	}
+	r.Data = data
+	err = dao.saveReceivedTransfer(r)
+	return models.GeneratDBError(err)
+}
+
+//GetReceivedTransferList returns the received transfer between from and to blocks
+func (dao *SQLiteDB) GetReceivedTransferList(tokenAddress common.Address, fromBlock, toBlock, fromTime, toTime int64) (transfers []*models.ReceivedTransfer, err error) {
+	var c conditions
+	if tokenAddress != utils.EmptyAddress {
+		c.add("token_address = ?", tokenAddress[:])
+	}
+	if fromBlock > 0 {
+		c.add("block_number >= ?", fromBlock)
+	}
+	if toBlock > 0 {
+		c.add("block_number < ?", toBlock)
+	}
+	if fromTime > 0 {
+		c.add("time_stamp >= ?", fromTime)
+	}
+	if toTime > 0 {
+		c.add("time_stamp < ?", toTime)
+	}
+	err = dao.queryValues(func() interface{} {
+		r := new(models.ReceivedTransfer)
+		transfers = append(transfers, r)
+		return r
+	}, "SELECT value FROM received_transfers"+c.String()+" ORDER BY key", c.args...)
+	return
+}
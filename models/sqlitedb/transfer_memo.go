@@ -0,0 +1,21 @@
+package sqlitedb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SaveTransferMemo :
+func (dao *SQLiteDB) SaveTransferMemo(m *models.TransferMemo) error {
+	m.Key = models.NewTransferMemoKey(m.MemoID, m.Sender)
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketTransferMemo, m.Key, m)
+	return models.GeneratDBError(err)
+}
+
+// GetTransferMemo :
+func (dao *SQLiteDB) GetTransferMemo(memoID common.Hash, sender common.Address) (m *models.TransferMemo, err error) {
+	m = &models.TransferMemo{}
+	err = dao.getKeyValueToBucket(models.BucketTransferMemo, models.NewTransferMemoKey(memoID, sender), m)
+	err = models.GeneratDBError(err)
+	return
+}
@@ -0,0 +1,55 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
+	"github.com/SmartMeshFoundation/Photon/models"
+)
+
+// SQLiteTX :
+type SQLiteTX struct {
+	tx  *sql.Tx
+	dao *SQLiteDB
+}
+
+// Set :
+func (stx *SQLiteTX) Set(table string, key interface{}, value interface{}) error {
+	return stx.dao.saveKeyValueToBucket(stx.tx, table, key, value)
+}
+
+// Save 通道保存在独立的表中,其他结构和storm一样以类型名作为bucket
+func (stx *SQLiteTX) Save(v models.KeyGetter) error {
+	if c, ok := v.(*channeltype.Serialization); ok {
+		return stx.dao.saveChannel(stx.tx, c)
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return stx.dao.saveKeyValueToBucket(stx.tx, t.Name(), v.GetKey(), v)
+}
+
+// Commit :
+func (stx *SQLiteTX) Commit() error {
+	return stx.tx.Commit()
+}
+
+// Rollback :
+func (stx *SQLiteTX) Rollback() error {
+	return stx.tx.Rollback()
+}
+
+//StartTx start a new tx of db
+func (dao *SQLiteDB) StartTx() (tx models.TX) {
+	stx, err := dao.db.Begin()
+	if err != nil {
+		panic(fmt.Sprintf("start transaction error %s", err))
+	}
+	return &SQLiteTX{
+		tx:  stx,
+		dao: dao,
+	}
+}
@@ -0,0 +1,35 @@
+package sqlitedb
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//XMPPMarkAddrSubed mark `addr` subscribed
+func (dao *SQLiteDB) XMPPMarkAddrSubed(addr common.Address) {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketXMPP, addr[:], true)
+	if err != nil {
+		log.Error(fmt.Sprintf("db err %s", err))
+	}
+}
+
+//XMPPIsAddrSubed return true when `addr` already subscirbed
+func (dao *SQLiteDB) XMPPIsAddrSubed(addr common.Address) bool {
+	var r bool
+	err := dao.getKeyValueToBucket(models.BucketXMPP, addr[:], &r)
+	if err != nil {
+		log.Trace(fmt.Sprintf("db err %s", err))
+	}
+	return r
+}
+
+//XMPPUnMarkAddr mark `addr` has been unsubscribed
+func (dao *SQLiteDB) XMPPUnMarkAddr(addr common.Address) {
+	err := dao.saveKeyValueToBucket(dao.db, models.BucketXMPP, addr[:], false)
+	if err != nil {
+		log.Error(fmt.Sprintf("db err %s", err))
+	}
+}
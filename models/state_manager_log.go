@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/gob"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+StateManagerSnapshot 交易状态机的快照,只保存发起方,中间节点和接收方的状态机.
+State是gob编码的状态,为空表示还没有快照,需要从第一个状态变化开始重放.
+Seq是快照已经包含的最后一个状态变化,之后的状态变化保存在StateChangeLog中
+*/
+type StateManagerSnapshot struct {
+	Key            string `storm:"id"` // Transfer2StateManager中的key
+	Name           string // 状态机的名字,决定使用哪个状态转换函数
+	LockSecretHash common.Hash
+	TokenAddress   common.Address
+	Seq            int
+	State          []byte
+	UpdateTime     int64
+}
+
+/*
+StateChangeLog 交易状态机的write-ahead log,状态变化在状态转换之前保存
+*/
+type StateChangeLog struct {
+	ID          string `storm:"id"`
+	Key         string `storm:"index"`
+	Seq         int
+	StateChange []byte // gob编码的状态变化
+}
+
+//NewStateChangeLog ID按照Seq排序
+func NewStateChangeLog(key string, seq int, stateChange []byte) *StateChangeLog {
+	return &StateChangeLog{
+		ID:          fmt.Sprintf("%s-%010d", key, seq),
+		Key:         key,
+		Seq:         seq,
+		StateChange: stateChange,
+	}
+}
+
+func init() {
+	gob.Register(&StateManagerSnapshot{})
+	gob.Register(&StateChangeLog{})
+}
@@ -2,6 +2,7 @@ package stormdb
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/SmartMeshFoundation/Photon/log"
 	"github.com/SmartMeshFoundation/Photon/models"
@@ -25,6 +26,10 @@ func (model *StormDB) GetAck(echoHash common.Hash) []byte {
 func (model *StormDB) SaveAck(echoHash common.Hash, ack []byte, tx models.TX) {
 	log.Trace(fmt.Sprintf("save ack %s to db", utils.HPex(echoHash)))
 	err := tx.Set(models.BucketAck, echoHash[:], ack)
+	if err == nil {
+		//记录保存的时间,按照保留时间归档
+		err = tx.Set(models.BucketAckTime, echoHash[:], time.Now().Unix())
+	}
 	if err != nil {
 		log.Error(fmt.Sprintf("db err %s", err))
 	}
@@ -33,6 +38,9 @@ func (model *StormDB) SaveAck(echoHash common.Hash, ack []byte, tx models.TX) {
 //SaveAckNoTx save a ack to db
 func (model *StormDB) SaveAckNoTx(echoHash common.Hash, ack []byte) {
 	err := model.db.Set(models.BucketAck, echoHash[:], ack)
+	if err == nil {
+		err = model.db.Set(models.BucketAckTime, echoHash[:], time.Now().Unix())
+	}
 	if err != nil {
 		log.Error(fmt.Sprintf("save ack to db err %s", err))
 	}
@@ -0,0 +1,35 @@
+package stormdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/asdine/storm"
+)
+
+// SaveAPIToken :
+func (model *StormDB) SaveAPIToken(t *models.APIToken) error {
+	err := model.db.Save(t)
+	return models.GeneratDBError(err)
+}
+
+// GetAPIToken :
+func (model *StormDB) GetAPIToken(key string) (t *models.APIToken, err error) {
+	t = &models.APIToken{}
+	err = model.db.One("Key", key, t)
+	if err == storm.ErrNotFound {
+		err = rerr.ErrNotFound
+		return
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetAPITokenList :
+func (model *StormDB) GetAPITokenList() (ts []*models.APIToken, err error) {
+	err = model.db.All(&ts)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	err = models.GeneratDBError(err)
+	return
+}
@@ -0,0 +1,48 @@
+package stormdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/asdine/storm"
+)
+
+// AppendAuditLog 只能追加,已经存在的记录不能被覆盖
+func (model *StormDB) AppendAuditLog(l *models.AuditLog) error {
+	var old models.AuditLog
+	err := model.db.One("Seq", l.Seq, &old)
+	if err == nil {
+		return rerr.ErrDBDuplicateKey.Printf("audit log %d", l.Seq)
+	}
+	if err != storm.ErrNotFound {
+		return models.GeneratDBError(err)
+	}
+	err = model.db.Save(l)
+	return models.GeneratDBError(err)
+}
+
+// GetLastAuditLog :
+func (model *StormDB) GetLastAuditLog() (l *models.AuditLog, err error) {
+	var ls []*models.AuditLog
+	//id是大端编码的Seq,倒序遍历的第一条就是最后一条
+	err = model.db.Select().Reverse().Limit(1).Find(&ls)
+	if err == storm.ErrNotFound || (err == nil && len(ls) == 0) {
+		err = rerr.ErrNotFound
+		return
+	}
+	if err != nil {
+		err = models.GeneratDBError(err)
+		return
+	}
+	l = ls[0]
+	return
+}
+
+// GetAuditLogList 按照Seq排序,包括fromSeq和toSeq
+func (model *StormDB) GetAuditLogList(fromSeq, toSeq int64) (ls []*models.AuditLog, err error) {
+	err = model.db.Range("Seq", fromSeq, toSeq, &ls)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	err = models.GeneratDBError(err)
+	return
+}
@@ -0,0 +1,35 @@
+package stormdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/asdine/storm"
+)
+
+// SaveBatchTransfer :
+func (model *StormDB) SaveBatchTransfer(b *models.BatchTransfer) error {
+	err := model.db.Save(b)
+	return models.GeneratDBError(err)
+}
+
+// GetBatchTransfer :
+func (model *StormDB) GetBatchTransfer(key string) (b *models.BatchTransfer, err error) {
+	b = &models.BatchTransfer{}
+	err = model.db.One("Key", key, b)
+	if err == storm.ErrNotFound {
+		err = rerr.ErrNotFound
+		return
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetBatchTransferList :
+func (model *StormDB) GetBatchTransferList() (bs []*models.BatchTransfer, err error) {
+	err = model.db.All(&bs)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	err = models.GeneratDBError(err)
+	return
+}
@@ -0,0 +1,36 @@
+package stormdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/asdine/storm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SaveChannelRecovery :
+func (model *StormDB) SaveChannelRecovery(r *models.ChannelRecovery) error {
+	err := model.db.Save(r)
+	return models.GeneratDBError(err)
+}
+
+// GetChannelRecovery :
+func (model *StormDB) GetChannelRecovery(channelIdentifier common.Hash) (r *models.ChannelRecovery, err error) {
+	r = &models.ChannelRecovery{}
+	err = model.db.One("ChannelIdentifier", channelIdentifier, r)
+	if err == storm.ErrNotFound {
+		err = rerr.ErrNotFound
+		return
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetChannelRecoveryList :
+func (model *StormDB) GetChannelRecoveryList() (rs []*models.ChannelRecovery, err error) {
+	err = model.db.All(&rs)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	err = models.GeneratDBError(err)
+	return
+}
@@ -15,7 +15,6 @@ import (
 	"github.com/SmartMeshFoundation/Photon/models"
 	"github.com/SmartMeshFoundation/Photon/models/cb"
 	"github.com/asdine/storm"
-	gobcodec "github.com/asdine/storm/codec/gob"
 	"github.com/coreos/bbolt"
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -31,6 +30,7 @@ type StormDB struct {
 	channelSettledCallbacks map[*cb.ChannelCb]bool
 	mlock                   sync.Mutex
 	Name                    string
+	codec                   *encryptedCodec
 }
 
 func newStormDB() (db *StormDB) {
@@ -44,19 +44,43 @@ func newStormDB() (db *StormDB) {
 
 }
 
-//OpenDb open or create a bolt db at dbPath
+//OpenDb open or create a bolt db at dbPath,fails if the db is encrypted
 func OpenDb(dbPath string) (model *StormDB, err error) {
+	return OpenDbWithPassword(dbPath, "", false)
+}
+
+/*
+OpenDbWithPassword open or create a bolt db at dbPath.
+加密的数据库用password解密,没有加密的数据库在encrypt为true的时候用password加密所有的值
+*/
+func OpenDbWithPassword(dbPath, password string, encrypt bool) (model *StormDB, err error) {
+	return openDb(dbPath, password, encrypt, true)
+}
+
+//OpenDbForMigration 打开数据库但是不升级,用来离线升级或者dry run
+func OpenDbForMigration(dbPath, password string) (model *StormDB, err error) {
+	return openDb(dbPath, password, false, false)
+}
+
+//openDb 旧版本的数据库在migrate为true的时候先备份再升级
+func openDb(dbPath, password string, encrypt, migrate bool) (model *StormDB, err error) {
 	log.Trace(fmt.Sprintf("dbpath=%s", dbPath))
 	model = newStormDB()
 	needCreateDb := !common.FileExist(dbPath)
 	var ver int
-	model.db, err = storm.Open(dbPath, storm.BoltOptions(os.ModePerm, &bolt.Options{Timeout: 1 * time.Second}), storm.Codec(gobcodec.Codec))
+	model.codec = &encryptedCodec{}
+	model.db, err = storm.Open(dbPath, storm.BoltOptions(os.ModePerm, &bolt.Options{Timeout: 1 * time.Second}), storm.Codec(model.codec))
 	if err != nil {
 		err = fmt.Errorf("cannot create or open db:%s,makesure you have write permission err:%v", dbPath, err)
 		log.Crit(err.Error())
 		return
 	}
 	model.Name = dbPath
+	err = model.unlock(password, encrypt)
+	if err != nil {
+		model.db.Close()
+		return
+	}
 	if needCreateDb {
 		err = model.db.Set(models.BucketMeta, models.KeyVersion, models.DbVersion)
 		if err != nil {
@@ -76,8 +100,13 @@ func OpenDb(dbPath string) (model *StormDB, err error) {
 			log.Crit(fmt.Sprintf("wrong db file format "))
 			return
 		}
-		if ver != models.DbVersion {
-			log.Crit("db version not match")
+		if ver != models.DbVersion && migrate {
+			_, err = model.Migrate(false)
+			if err != nil {
+				log.Error(fmt.Sprintf("migrate db err %s", err))
+				model.db.Close()
+				return
+			}
 		}
 		var closeFlag bool
 		err = model.db.Get(models.BucketMeta, models.KeyCloseFlag, &closeFlag)
@@ -115,6 +144,24 @@ func (model *StormDB) IsDbCrashedLastTime() bool {
 	return closeFlag != true
 }
 
+//CheckWritable write a value to meta and read it back
+func (model *StormDB) CheckWritable() error {
+	v := time.Now().UnixNano()
+	err := model.db.Set(models.BucketMeta, models.KeyHealthCheck, v)
+	if err != nil {
+		return err
+	}
+	var v2 int64
+	err = model.db.Get(models.BucketMeta, models.KeyHealthCheck, &v2)
+	if err != nil {
+		return err
+	}
+	if v2 != v {
+		return fmt.Errorf("read %d after write %d", v2, v)
+	}
+	return nil
+}
+
 //CloseDB close db
 func (model *StormDB) CloseDB() {
 	model.lock.Lock()
@@ -0,0 +1,197 @@
+package stormdb
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	gobcodec "github.com/asdine/storm/codec/gob"
+	"github.com/coreos/bbolt"
+)
+
+// 加密参数保存在storm不会使用的bucket中,以__开头的bucket不加密
+const bucketEncryption = "__photon_encryption"
+
+var keyEncryptionMeta = []byte("meta")
+
+/*
+encryptedCodec 在gob编码以后加密.
+storm用codec编码不是[]byte,string和整数的id,索引和key,这些必须保持不变,
+所以只加密struct,map,slice这些只会作为值保存的类型.
+名字和gob codec一样,否则storm会拒绝打开已有的数据库
+*/
+type encryptedCodec struct {
+	encryptor *models.DBEncryptor
+}
+
+func isValueType(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+//Marshal encode and encrypt v if the db is encrypted
+func (c *encryptedCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := gobcodec.Codec.Marshal(v)
+	if err != nil || c.encryptor == nil || !isValueType(v) {
+		return data, err
+	}
+	return c.encryptor.Encrypt(data), nil
+}
+
+//Unmarshal decrypt and decode,values not encrypted are decoded directly
+func (c *encryptedCodec) Unmarshal(b []byte, v interface{}) error {
+	b, err := models.DecryptDBValue(c.encryptor, b)
+	if err != nil {
+		return err
+	}
+	return gobcodec.Codec.Unmarshal(b, v)
+}
+
+//Name is the same as gob codec
+func (c *encryptedCodec) Name() string {
+	return gobcodec.Codec.Name()
+}
+
+func (model *StormDB) getEncryptionMeta() (meta *models.DBEncryptionMeta, err error) {
+	err = model.db.Bolt.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketEncryption))
+		if b == nil {
+			return nil
+		}
+		data := b.Get(keyEncryptionMeta)
+		if data == nil {
+			return nil
+		}
+		meta, err = models.ParseDBEncryptionMeta(data)
+		return err
+	})
+	return
+}
+
+func (model *StormDB) unlock(password string, encrypt bool) error {
+	meta, err := model.getEncryptionMeta()
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		if encrypt {
+			return model.SetEncryptionPassword(password)
+		}
+		return nil
+	}
+	if password == "" {
+		return rerr.ErrDBEncryption.Printf("database %s is encrypted,password needed", model.Name)
+	}
+	e, err := meta.Unlock(password)
+	if err != nil {
+		return err
+	}
+	model.codec.encryptor = e
+	return nil
+}
+
+//IsEncrypted returns true if values in db are encrypted
+func (model *StormDB) IsEncrypted() bool {
+	return model.codec.encryptor != nil
+}
+
+/*
+SetEncryptionPassword 用新的salt从password派生key,重新加密数据库中所有的值,password为空表示取消加密.
+没有加密的数据库调用这个函数完成迁移,已经加密的数据库调用这个函数更换key.
+所有的修改在一个事务中完成,只能在没有其他读写的时候调用
+*/
+func (model *StormDB) SetEncryptionPassword(password string) error {
+	var meta *models.DBEncryptionMeta
+	var to *models.DBEncryptor
+	var err error
+	if password != "" {
+		meta, to, err = models.NewDBEncryptionMeta(password)
+		if err != nil {
+			return err
+		}
+	}
+	from := model.codec.encryptor
+	err = model.db.Bolt.Update(func(tx *bolt.Tx) error {
+		err2 := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if bytes.HasPrefix(name, []byte("__")) {
+				return nil
+			}
+			return reencryptBucket(b, from, to)
+		})
+		if err2 != nil {
+			return err2
+		}
+		if meta == nil {
+			if tx.Bucket([]byte(bucketEncryption)) == nil {
+				return nil
+			}
+			return tx.DeleteBucket([]byte(bucketEncryption))
+		}
+		b, err2 := tx.CreateBucketIfNotExists([]byte(bucketEncryption))
+		if err2 != nil {
+			return err2
+		}
+		return b.Put(keyEncryptionMeta, meta.Bytes())
+	})
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	model.codec.encryptor = to
+	return nil
+}
+
+/*
+reencryptBucket 修改bucket和子bucket中所有的值,storm的索引和元数据在以__开头的子bucket中,值是id,不能修改
+*/
+func reencryptBucket(b *bolt.Bucket, from, to *models.DBEncryptor) error {
+	type change struct {
+		key   []byte
+		value []byte
+	}
+	var changes []change
+	var children [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			if !bytes.HasPrefix(k, []byte("__")) {
+				children = append(children, append([]byte{}, k...))
+			}
+			return nil
+		}
+		out, changed, err := models.ReencryptDBValue(v, from, to)
+		if err != nil {
+			return err
+		}
+		if changed {
+			changes = append(changes, change{append([]byte{}, k...), out})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	//遍历的时候不能修改bucket
+	for _, c := range changes {
+		err = b.Put(c.key, c.value)
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range children {
+		err = reencryptBucket(b.Bucket(name), from, to)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package stormdb
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+)
+
+// SaveIdempotencyRecord :
+func (model *StormDB) SaveIdempotencyRecord(r *models.IdempotencyRecord) error {
+	err := model.db.Save(r)
+	return models.GeneratDBError(err)
+}
+
+// GetIdempotencyRecord :
+func (model *StormDB) GetIdempotencyRecord(key string) (r *models.IdempotencyRecord, err error) {
+	r = &models.IdempotencyRecord{}
+	err = model.db.One("Key", key, r)
+	if err == storm.ErrNotFound {
+		err = rerr.ErrNotFound
+		return
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// RemoveIdempotencyRecordBefore delete records created before createTime
+func (model *StormDB) RemoveIdempotencyRecordBefore(createTime int64) error {
+	err := model.db.Select(q.Lt("CreateTime", createTime)).Delete(&models.IdempotencyRecord{})
+	if err == storm.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("RemoveIdempotencyRecordBefore err %s", err))
+	}
+	return models.GeneratDBError(err)
+}
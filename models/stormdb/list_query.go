@@ -0,0 +1,109 @@
+package stormdb
+
+import (
+	"reflect"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/asdine/storm"
+)
+
+//listMatcher 用ListQuery的Match方法过滤storm的记录,参数是指向记录的指针
+type listMatcher func(i interface{}) bool
+
+// Match :
+func (m listMatcher) Match(i interface{}) (bool, error) {
+	return m(i), nil
+}
+
+//MatchValue storm的q.And传给子条件的是记录本身,不是指针
+func (m listMatcher) MatchValue(v *reflect.Value) (bool, error) {
+	return m(v.Addr().Interface()), nil
+}
+
+/*
+selectPage 按照timeField和keyField排序,最多查询Limit+1条,多出来的一条用来判断是否还有下一页
+*/
+func (model *StormDB) selectPage(lq *models.ListQuery, match listMatcher, timeField, keyField string, to interface{}) error {
+	err := lq.ParseCursor()
+	if err != nil {
+		return err
+	}
+	query := model.db.Select(match).OrderBy(timeField, keyField)
+	if lq.Desc {
+		query = query.Reverse()
+	}
+	if lq.Limit > 0 {
+		query = query.Limit(lq.Limit + 1)
+	}
+	err = query.Find(to)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return models.GeneratDBError(err)
+}
+
+// QuerySentTransferDetails :
+func (model *StormDB) QuerySentTransferDetails(lq *models.ListQuery) (transfers []*models.SentTransferDetail, nextCursor string, err error) {
+	err = model.selectPage(lq, func(i interface{}) bool {
+		return lq.MatchSentTransferDetail(i.(*models.SentTransferDetail))
+	}, "SendingTime", "Key", &transfers)
+	if err != nil {
+		return
+	}
+	n, nextCursor := lq.Page(len(transfers), func(i int) (int64, []byte) {
+		return transfers[i].SendingTime, []byte(transfers[i].Key)
+	})
+	transfers = transfers[:n]
+	return
+}
+
+// QueryReceivedTransfers :
+func (model *StormDB) QueryReceivedTransfers(lq *models.ListQuery) (transfers []*models.ReceivedTransfer, nextCursor string, err error) {
+	err = model.selectPage(lq, func(i interface{}) bool {
+		return lq.MatchReceivedTransfer(i.(*models.ReceivedTransfer))
+	}, "TimeStamp", "Key", &transfers)
+	if err != nil {
+		return
+	}
+	n, nextCursor := lq.Page(len(transfers), func(i int) (int64, []byte) {
+		return transfers[i].TimeStamp, []byte(transfers[i].Key)
+	})
+	transfers = transfers[:n]
+	return
+}
+
+// QueryFeeChargeRecords :
+func (model *StormDB) QueryFeeChargeRecords(lq *models.ListQuery) (records []*models.FeeChargeRecord, nextCursor string, err error) {
+	var rs []*models.FeeChargerRecordSerialization
+	err = model.selectPage(lq, func(i interface{}) bool {
+		return lq.MatchFeeChargeRecord(i.(*models.FeeChargerRecordSerialization))
+	}, "Timestamp", "Key", &rs)
+	if err != nil {
+		return
+	}
+	n, nextCursor := lq.Page(len(rs), func(i int) (int64, []byte) {
+		return rs[i].Timestamp, rs[i].Key
+	})
+	for _, r := range rs[:n] {
+		records = append(records, r.ToFeeChargeRecord())
+	}
+	return
+}
+
+// QueryTXInfos :
+func (model *StormDB) QueryTXInfos(lq *models.ListQuery) (list []*models.TXInfo, nextCursor string, err error) {
+	var l []*models.TXInfoSerialization
+	err = model.selectPage(lq, func(i interface{}) bool {
+		return lq.MatchTXInfo(i.(*models.TXInfoSerialization))
+	}, "CallTime", "TXHash", &l)
+	if err != nil {
+		return
+	}
+	n, nextCursor := lq.Page(len(l), func(i int) (int64, []byte) {
+		return l[i].CallTime, l[i].TXHash
+	})
+	for _, tis := range l[:n] {
+		list = append(list, tis.ToTXInfo())
+	}
+	return
+}
@@ -0,0 +1,47 @@
+package stormdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/asdine/storm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SaveMediationPolicy :
+func (model *StormDB) SaveMediationPolicy(p *models.MediationPolicy) error {
+	p.Key = p.TokenAddress.String()
+	err := model.db.Save(p)
+	return models.GeneratDBError(err)
+}
+
+// GetMediationPolicy :
+func (model *StormDB) GetMediationPolicy(tokenAddress common.Address) (p *models.MediationPolicy, err error) {
+	p = &models.MediationPolicy{}
+	err = model.db.One("Key", tokenAddress.String(), p)
+	if err == storm.ErrNotFound {
+		err = rerr.ErrNotFound
+		return
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetMediationPolicyList :
+func (model *StormDB) GetMediationPolicyList() (ps []*models.MediationPolicy, err error) {
+	err = model.db.All(&ps)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// RemoveMediationPolicy :
+func (model *StormDB) RemoveMediationPolicy(tokenAddress common.Address) error {
+	p, err := model.GetMediationPolicy(tokenAddress)
+	if err != nil {
+		return err
+	}
+	err = model.db.DeleteStruct(p)
+	return models.GeneratDBError(err)
+}
@@ -0,0 +1,126 @@
+package stormdb
+
+import (
+	"bytes"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/asdine/storm"
+	"github.com/coreos/bbolt"
+)
+
+//stormMigrationTX 直接读写bolt的事务,值和storm一样用codec编码
+type stormMigrationTX struct {
+	tx    *bolt.Tx
+	codec *encryptedCodec
+}
+
+//ForEach storm保存索引的嵌套bucket和__storm开头的key不是记录
+func (mtx *stormMigrationTX) ForEach(bucket string, newValue func() interface{}, f func(key []byte, v interface{}) error) error {
+	b := mtx.tx.Bucket([]byte(bucket))
+	if b == nil {
+		return nil
+	}
+	type record struct {
+		key []byte
+		v   interface{}
+	}
+	var records []record
+	//遍历的时候不能修改bucket
+	err := b.ForEach(func(k, v []byte) error {
+		if v == nil || bytes.HasPrefix(k, []byte("__storm")) {
+			return nil
+		}
+		to := newValue()
+		err := mtx.codec.Unmarshal(v, to)
+		if err != nil {
+			return err
+		}
+		records = append(records, record{append([]byte{}, k...), to})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		err = f(r.key, r.v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get :
+func (mtx *stormMigrationTX) Get(bucket string, key []byte, to interface{}) error {
+	b := mtx.tx.Bucket([]byte(bucket))
+	if b == nil {
+		return rerr.ErrNotFound
+	}
+	v := b.Get(key)
+	if v == nil {
+		return rerr.ErrNotFound
+	}
+	return mtx.codec.Unmarshal(v, to)
+}
+
+// Put :
+func (mtx *stormMigrationTX) Put(bucket string, key []byte, value interface{}) error {
+	b, err := mtx.tx.CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return err
+	}
+	v, err := mtx.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, v)
+}
+
+// Commit :
+func (mtx *stormMigrationTX) Commit() error {
+	return mtx.tx.Commit()
+}
+
+// Rollback :
+func (mtx *stormMigrationTX) Rollback() error {
+	return mtx.tx.Rollback()
+}
+
+// DBPath :
+func (model *StormDB) DBPath() string {
+	return model.Name
+}
+
+// GetDbVersion :
+func (model *StormDB) GetDbVersion() (ver int, err error) {
+	err = model.db.Get(models.BucketMeta, models.KeyVersion, &ver)
+	if err == storm.ErrNotFound {
+		err = rerr.ErrNotFound
+	}
+	return
+}
+
+// Backup 在一个读事务中复制整个数据库
+func (model *StormDB) Backup(path string) error {
+	return model.db.Bolt.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(path, 0600)
+	})
+}
+
+// BeginMigration :
+func (model *StormDB) BeginMigration() (tx models.MigrationTX, err error) {
+	btx, err := model.db.Bolt.Begin(true)
+	if err != nil {
+		return
+	}
+	return &stormMigrationTX{
+		tx:    btx,
+		codec: model.codec,
+	}, nil
+}
+
+// Migrate 把数据库升级到models.DbVersion,dryRun的时候只报告会修改的记录
+func (model *StormDB) Migrate(dryRun bool) (report *models.MigrationReport, err error) {
+	return models.RunMigrations(model, dryRun)
+}
@@ -0,0 +1,35 @@
+package stormdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/asdine/storm"
+)
+
+// SavePaymentPlan :
+func (model *StormDB) SavePaymentPlan(p *models.PaymentPlan) error {
+	err := model.db.Save(p)
+	return models.GeneratDBError(err)
+}
+
+// GetPaymentPlan :
+func (model *StormDB) GetPaymentPlan(key string) (p *models.PaymentPlan, err error) {
+	p = &models.PaymentPlan{}
+	err = model.db.One("Key", key, p)
+	if err == storm.ErrNotFound {
+		err = rerr.ErrNotFound
+		return
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetPaymentPlanList :
+func (model *StormDB) GetPaymentPlanList() (ps []*models.PaymentPlan, err error) {
+	err = model.db.All(&ps)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	err = models.GeneratDBError(err)
+	return
+}
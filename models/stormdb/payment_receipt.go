@@ -0,0 +1,27 @@
+package stormdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/asdine/storm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SavePaymentReceipt :
+func (model *StormDB) SavePaymentReceipt(r *models.PaymentReceipt) error {
+	r.Key = models.NewPaymentReceiptKey(r.TokenAddress, r.LockSecretHash)
+	err := model.db.Save(r)
+	return models.GeneratDBError(err)
+}
+
+// GetPaymentReceipt :
+func (model *StormDB) GetPaymentReceipt(tokenAddress common.Address, lockSecretHash common.Hash) (r *models.PaymentReceipt, err error) {
+	r = &models.PaymentReceipt{}
+	err = model.db.One("Key", models.NewPaymentReceiptKey(tokenAddress, lockSecretHash), r)
+	if err == storm.ErrNotFound {
+		err = rerr.ErrNotFound
+		return
+	}
+	err = models.GeneratDBError(err)
+	return
+}
@@ -0,0 +1,289 @@
+package stormdb
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/coreos/bbolt"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ArchiveIncomeRecords :
+func (model *StormDB) ArchiveIncomeRecords(receivedTransferKeys []string, feeChargeRecordKeys []common.Hash, aggregates []*models.IncomeAggregate) (err error) {
+	tx, err := model.db.Begin(true)
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = models.GeneratDBError(err)
+		}
+	}()
+	for _, a := range aggregates {
+		var old models.IncomeAggregate
+		err = tx.One("Key", a.Key, &old)
+		if err == nil {
+			old.Add(a)
+			a = &old
+		} else if err != storm.ErrNotFound {
+			return
+		}
+		err = tx.Save(a)
+		if err != nil {
+			return
+		}
+	}
+	for _, key := range receivedTransferKeys {
+		err = tx.DeleteStruct(&models.ReceivedTransfer{Key: key})
+		if err != nil && err != storm.ErrNotFound {
+			return
+		}
+	}
+	for _, key := range feeChargeRecordKeys {
+		err = tx.DeleteStruct(&models.FeeChargerRecordSerialization{Key: key[:]})
+		if err != nil && err != storm.ErrNotFound {
+			return
+		}
+	}
+	return tx.Commit()
+}
+
+// GetIncomeAggregateList :
+func (model *StormDB) GetIncomeAggregateList(tokenAddress common.Address, fromTime, toTime int64) (as []*models.IncomeAggregate, err error) {
+	var selectList []q.Matcher
+	if tokenAddress != utils.EmptyAddress {
+		selectList = append(selectList, q.Eq("TokenAddressBytes", tokenAddress[:]))
+	}
+	if fromTime > 0 {
+		selectList = append(selectList, q.Gte("Hour", fromTime))
+	}
+	if toTime > 0 {
+		selectList = append(selectList, q.Lt("Hour", toTime))
+	}
+	if len(selectList) == 0 {
+		err = model.db.All(&as)
+	} else {
+		err = model.db.Select(selectList...).Find(&as)
+	}
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// RemoveSentTransferDetails :
+func (model *StormDB) RemoveSentTransferDetails(keys []string) (err error) {
+	tx, err := model.db.Begin(true)
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	for _, key := range keys {
+		err = tx.DeleteStruct(&models.SentTransferDetail{Key: key})
+		if err != nil && err != storm.ErrNotFound {
+			tx.Rollback()
+			return models.GeneratDBError(err)
+		}
+	}
+	return models.GeneratDBError(tx.Commit())
+}
+
+// RemoveTXInfos :
+func (model *StormDB) RemoveTXInfos(txHashes []common.Hash) (err error) {
+	tx, err := model.db.Begin(true)
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	for _, h := range txHashes {
+		err = tx.DeleteStruct(&models.TXInfoSerialization{TXHash: h[:]})
+		if err != nil && err != storm.ErrNotFound {
+			tx.Rollback()
+			return models.GeneratDBError(err)
+		}
+	}
+	return models.GeneratDBError(tx.Commit())
+}
+
+// GetAckList :
+func (model *StormDB) GetAckList(before int64) (as []*models.AckRecord, err error) {
+	var all []*models.AckRecord
+	err = model.db.Bolt.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(models.BucketAck))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if string(k) == "__storm_metadata" {
+				return nil
+			}
+			a := &models.AckRecord{EchoHash: common.BytesToHash(k)}
+			err2 := model.db.Codec().Unmarshal(v, &a.Ack)
+			if err2 != nil {
+				return err2
+			}
+			all = append(all, a)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, models.GeneratDBError(err)
+	}
+	for _, a := range all {
+		err = model.db.Get(models.BucketAckTime, a.EchoHash[:], &a.Time)
+		if err == storm.ErrNotFound {
+			//升级的时候已经记录了所有ack的时间
+			err = nil
+			continue
+		}
+		if err != nil {
+			return nil, models.GeneratDBError(err)
+		}
+		if a.Time < before {
+			as = append(as, a)
+		}
+	}
+	return
+}
+
+// RemoveAcks :
+func (model *StormDB) RemoveAcks(echoHashes []common.Hash) (err error) {
+	err = model.db.Bolt.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{models.BucketAck, models.BucketAckTime} {
+			b := tx.Bucket([]byte(name))
+			if b == nil {
+				continue
+			}
+			for _, h := range echoHashes {
+				err2 := b.Delete(h[:])
+				if err2 != nil {
+					return err2
+				}
+			}
+		}
+		return nil
+	})
+	return models.GeneratDBError(err)
+}
+
+// GetTransferMemoList :
+func (model *StormDB) GetTransferMemoList(before int64) (ms []*models.TransferMemo, err error) {
+	err = model.db.Select(q.Lt("CreateTime", before)).Find(&ms)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// RemoveTransferMemos :
+func (model *StormDB) RemoveTransferMemos(keys []string) (err error) {
+	tx, err := model.db.Begin(true)
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	for _, key := range keys {
+		err = tx.DeleteStruct(&models.TransferMemo{Key: key})
+		if err != nil && err != storm.ErrNotFound {
+			tx.Rollback()
+			return models.GeneratDBError(err)
+		}
+	}
+	return models.GeneratDBError(tx.Commit())
+}
+
+// GetChainEventRecordList :
+func (model *StormDB) GetChainEventRecordList(blockNumber uint64) (rs []*models.ChainEventRecord, err error) {
+	err = model.db.Range("BlockNumber", 0, blockNumber, &rs)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// Compact boltdb删除数据以后不会缩小文件,只能在photon停止的时候用CompactDb复制一份
+func (model *StormDB) Compact() error {
+	return rerr.ErrGeneralDBError.Printf("%s can only be compacted offline when photon is stopped", model.Name)
+}
+
+/*
+CompactDb 把dbPath中所有的bucket复制到一个新的文件,然后替换原来的文件,
+返回压缩前后文件的大小.photon运行的时候会因为打不开数据库失败
+*/
+func CompactDb(dbPath string) (before, after int64, err error) {
+	fi, err := os.Stat(dbPath)
+	if err != nil {
+		return
+	}
+	before = fi.Size()
+	src, err := bolt.Open(dbPath, 0444, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		err = fmt.Errorf("open %s err %s,photon must be stopped", dbPath, err)
+		return
+	}
+	//上次失败留下的文件
+	tmpPath := dbPath + ".compact"
+	os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, fi.Mode(), &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		src.Close()
+		return
+	}
+	err = src.View(func(stx *bolt.Tx) error {
+		return dst.Update(func(dtx *bolt.Tx) error {
+			return stx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				db, err2 := dtx.CreateBucket(name)
+				if err2 != nil {
+					return err2
+				}
+				return copyBucket(db, b)
+			})
+		})
+	})
+	src.Close()
+	err2 := dst.Close()
+	if err == nil {
+		err = err2
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return
+	}
+	err = os.Rename(tmpPath, dbPath)
+	if err != nil {
+		return
+	}
+	fi, err = os.Stat(dbPath)
+	if err != nil {
+		return
+	}
+	after = fi.Size()
+	log.Info(fmt.Sprintf("compact %s from %d to %d bytes", dbPath, before, after))
+	return
+}
+
+//copyBucket 递归复制,storm的索引保存在嵌套的bucket中
+func copyBucket(to, from *bolt.Bucket) error {
+	err := to.SetSequence(from.Sequence())
+	if err != nil {
+		return err
+	}
+	return from.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return to.Put(k, v)
+		}
+		nb, err := to.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(nb, from.Bucket(k))
+	})
+}
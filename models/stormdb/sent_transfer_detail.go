@@ -99,6 +99,29 @@ func (model *StormDB) UpdateSentTransferDetailStatusMessage(tokenAddress common.
 	return
 }
 
+// UpdateSentTransferDetailPaymentPlan :
+func (model *StormDB) UpdateSentTransferDetailPaymentPlan(tokenAddress common.Address, lockSecretHash common.Hash, planKey string, run int) (transfer *models.SentTransferDetail) {
+	transfer = &models.SentTransferDetail{}
+	key := utils.Sha3(tokenAddress[:], lockSecretHash[:]).String()
+	err := model.db.One("Key", key, transfer)
+	if err == storm.ErrNotFound {
+		return
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdatePaymentPlan err %s", err))
+		return
+	}
+	transfer.PaymentPlanKey = planKey
+	transfer.PaymentPlanRun = run
+	err = model.db.Save(transfer)
+	if err != nil {
+		log.Error(fmt.Sprintf("UpdatePaymentPlan err %s", err))
+		return
+	}
+	log.Trace(fmt.Sprintf("UpdatePaymentPlan key=%s lockSecretHash=%s plan=%s run=%d", key, lockSecretHash.String(), planKey, run))
+	return
+}
+
 // GetSentTransferDetail :
 func (model *StormDB) GetSentTransferDetail(tokenAddress common.Address, lockSecretHash common.Hash) (*models.SentTransferDetail, error) {
 	var ts models.SentTransferDetail
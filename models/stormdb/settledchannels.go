@@ -3,21 +3,12 @@ package stormdb
 import (
 	"fmt"
 
-	"bytes"
-	"encoding/gob"
-
 	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
 	"github.com/SmartMeshFoundation/Photon/models"
 	"github.com/coreos/bbolt"
 	"github.com/ethereum/go-ethereum/common"
 )
 
-func unmarshal(b []byte, v interface{}) error {
-	r := bytes.NewReader(b)
-	dec := gob.NewDecoder(r)
-	return dec.Decode(v)
-}
-
 //NewSettledChannel save a settled channel to db
 func (model *StormDB) NewSettledChannel(c *channeltype.Serialization) error {
 	if c.State != channeltype.StateSettled {
@@ -38,7 +29,7 @@ func (model *StormDB) GetAllSettledChannel() (chs []*channeltype.Serialization,
 			}
 			//log.Trace(fmt.Sprintf("GetAllSettledChannel key=%s, value=%s\n", string(k), hex.EncodeToString(v)))
 			var c channeltype.Serialization
-			err = unmarshal(v, &c)
+			err = model.db.Codec().Unmarshal(v, &c)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,75 @@
+package stormdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/asdine/storm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SaveSpendingPolicy :
+func (model *StormDB) SaveSpendingPolicy(p *models.SpendingPolicy) error {
+	p.Key = p.TokenAddress.String()
+	err := model.db.Save(p)
+	return models.GeneratDBError(err)
+}
+
+// GetSpendingPolicy :
+func (model *StormDB) GetSpendingPolicy(tokenAddress common.Address) (p *models.SpendingPolicy, err error) {
+	p = &models.SpendingPolicy{}
+	err = model.db.One("Key", tokenAddress.String(), p)
+	if err == storm.ErrNotFound {
+		err = rerr.ErrNotFound
+		return
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetSpendingPolicyList :
+func (model *StormDB) GetSpendingPolicyList() (ps []*models.SpendingPolicy, err error) {
+	err = model.db.All(&ps)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// RemoveSpendingPolicy :
+func (model *StormDB) RemoveSpendingPolicy(tokenAddress common.Address) error {
+	p, err := model.GetSpendingPolicy(tokenAddress)
+	if err != nil {
+		return err
+	}
+	err = model.db.DeleteStruct(p)
+	return models.GeneratDBError(err)
+}
+
+// SavePendingApproval :
+func (model *StormDB) SavePendingApproval(a *models.PendingApproval) error {
+	err := model.db.Save(a)
+	return models.GeneratDBError(err)
+}
+
+// GetPendingApproval :
+func (model *StormDB) GetPendingApproval(key string) (a *models.PendingApproval, err error) {
+	a = &models.PendingApproval{}
+	err = model.db.One("Key", key, a)
+	if err == storm.ErrNotFound {
+		err = rerr.ErrNotFound
+		return
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// GetPendingApprovalList :
+func (model *StormDB) GetPendingApprovalList() (as []*models.PendingApproval, err error) {
+	err = model.db.All(&as)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	err = models.GeneratDBError(err)
+	return
+}
@@ -0,0 +1,56 @@
+package stormdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+)
+
+// AddStateChangeLog :
+func (model *StormDB) AddStateChangeLog(l *models.StateChangeLog) error {
+	err := model.db.Save(l)
+	return models.GeneratDBError(err)
+}
+
+// GetStateChangeLogs :
+func (model *StormDB) GetStateChangeLogs(key string) (ls []*models.StateChangeLog, err error) {
+	err = model.db.Select(q.Eq("Key", key)).OrderBy("Seq").Find(&ls)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	err = models.GeneratDBError(err)
+	return
+}
+
+// SaveStateManagerSnapshot :
+func (model *StormDB) SaveStateManagerSnapshot(s *models.StateManagerSnapshot) error {
+	err := model.db.Save(s)
+	if err != nil {
+		return models.GeneratDBError(err)
+	}
+	err = model.db.Select(q.Eq("Key", s.Key), q.Lte("Seq", s.Seq)).Delete(&models.StateChangeLog{})
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return models.GeneratDBError(err)
+}
+
+// GetStateManagerSnapshotList :
+func (model *StormDB) GetStateManagerSnapshotList() (ss []*models.StateManagerSnapshot, err error) {
+	err = model.db.All(&ss)
+	err = models.GeneratDBError(err)
+	return
+}
+
+// RemoveStateManagerLog :
+func (model *StormDB) RemoveStateManagerLog(key string) error {
+	err := model.db.Select(q.Eq("Key", key)).Delete(&models.StateChangeLog{})
+	if err != nil && err != storm.ErrNotFound {
+		return models.GeneratDBError(err)
+	}
+	err = model.db.DeleteStruct(&models.StateManagerSnapshot{Key: key})
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return models.GeneratDBError(err)
+}
@@ -54,6 +54,17 @@ func (model *StormDB) GetReceivedTransfer(key string) (*models.ReceivedTransfer,
 	return &r, err
 }
 
+//UpdateReceivedTransferData 加密附言在交易之后收齐时更新附加信息
+func (model *StormDB) UpdateReceivedTransferData(key string, data string) error {
+	r, err := model.GetReceivedTransfer(key)
+	if err != nil {
+		return err
+	}
+	r.Data = data
+	err = model.db.Save(r)
+	return models.GeneratDBError(err)
+}
+
 //GetReceivedTransferList returns the received transfer between from and to blocks
 func (model *StormDB) GetReceivedTransferList(tokenAddress common.Address, fromBlock, toBlock, fromTime, toTime int64) (transfers []*models.ReceivedTransfer, err error) {
 	var selectList []q.Matcher
@@ -0,0 +1,27 @@
+package stormdb
+
+import (
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/asdine/storm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SaveTransferMemo :
+func (model *StormDB) SaveTransferMemo(m *models.TransferMemo) error {
+	m.Key = models.NewTransferMemoKey(m.MemoID, m.Sender)
+	err := model.db.Save(m)
+	return models.GeneratDBError(err)
+}
+
+// GetTransferMemo :
+func (model *StormDB) GetTransferMemo(memoID common.Hash, sender common.Address) (m *models.TransferMemo, err error) {
+	m = &models.TransferMemo{}
+	err = model.db.One("Key", models.NewTransferMemoKey(memoID, sender), m)
+	if err == storm.ErrNotFound {
+		err = rerr.ErrNotFound
+		return
+	}
+	err = models.GeneratDBError(err)
+	return
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/gob"
+
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+TransferMemo 收到的加密附言,Memo消息和交易到达的先后顺序不确定:
+先收到交易的话,记录ReceivedTransferKey,附言收齐解密以后再更新ReceivedTransfer的Data
+*/
+type TransferMemo struct {
+	Key                 string `storm:"id"`
+	MemoID              common.Hash
+	Sender              common.Address
+	Total               int
+	Chunks              [][]byte
+	Memo                string
+	Finished            bool
+	ReceivedTransferKey string
+	CreateTime          int64
+}
+
+//NewTransferMemoKey 同一个MemoID,只接受发起方发送的附言,其他节点发送的不会被使用
+func NewTransferMemoKey(memoID common.Hash, sender common.Address) string {
+	return utils.Sha3(memoID[:], sender[:]).String()
+}
+
+//Received returns true if all chunks of the memo are received
+func (m *TransferMemo) Received() bool {
+	if m.Total == 0 || len(m.Chunks) != m.Total {
+		return false
+	}
+	for _, c := range m.Chunks {
+		if len(c) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	gob.Register(&TransferMemo{})
+}
@@ -115,6 +115,11 @@ func (t *MatrixMixTransport) NodeStatus(addr common.Address) (deviceType string,
 	return t.matirx.NodeStatus(addr)
 }
 
+//Status connection status with the matrix server, udp can still work when it's not connected
+func (t *MatrixMixTransport) Status() netshare.Status {
+	return t.matirx.Status()
+}
+
 //GetNotify notification of connection status change
 func (t *MatrixMixTransport) GetNotify() (notify <-chan netshare.Status, err error) {
 	//if t.matirx != nil {
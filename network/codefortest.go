@@ -69,12 +69,20 @@ func (t *testChannelStatusGetter) GetChannelStatus(channelIdentifier common.Hash
 	return channeltype.StateOpened, 0
 }
 
+func (t *testChannelStatusGetter) HasOpenChannel(partner common.Address) bool {
+	return true
+}
+
 type testChannelStatusGetterInvalid struct{}
 
 func (t *testChannelStatusGetterInvalid) GetChannelStatus(channelIdentifier common.Hash) (int, int64) {
 	return channeltype.StateInValid, 0
 }
 
+func (t *testChannelStatusGetterInvalid) HasOpenChannel(partner common.Address) bool {
+	return false
+}
+
 type timeBlockNumberGetter struct {
 	t time.Time
 }
@@ -0,0 +1,249 @@
+package network
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//PeerBan 节点的失败统计和封禁状态
+type PeerBan struct {
+	Address     common.Address `json:"address"`
+	Failures    int            `json:"failures"`     // 当前统计窗口内失败的消息数量
+	Bans        int            `json:"bans"`         // 连续被封禁的次数,决定下一次封禁的时长
+	BannedUntil time.Time      `json:"banned_until"` // 在这之前收到的消息全部丢弃
+	LastReason  string         `json:"last_reason"`
+}
+
+//IsBanned returns true if messages from this peer should be dropped now
+func (b *PeerBan) IsBanned(now time.Time) bool {
+	return now.Before(b.BannedUntil)
+}
+
+//超过这个数量以后清理长时间没有消息的节点的限速状态
+const maxInboundBuckets = 10000
+
+type inboundBucketKey struct {
+	sender common.Address
+	cmdid  int
+}
+
+//rateLimitedMessages 只有新的交易和Ping需要限速,其他消息都是对已有交易的回应
+var rateLimitedMessages = map[int]bool{
+	encoding.PingCmdID:             true,
+	encoding.MediatedTransferCmdID: true,
+}
+
+/*
+banExemptMessages 通道对方发来的这些balance proof和密码消息不受封禁限制,
+否则正在进行的交易可能直到锁过期都无法完成
+*/
+var banExemptMessages = map[int]bool{
+	encoding.UnlockCmdID:                           true,
+	encoding.RemoveExpiredLockCmdID:                true,
+	encoding.AnnounceDisposedTransferResponseCmdID: true,
+	encoding.RevealSecretCmdID:                     true,
+	encoding.SecretRequestCmdID:                    true,
+}
+
+/*
+inboundLimiter 限制其他节点发来的消息:
+1. 每个节点的MediatedTransfer和Ping有单独的TokenBucket,超过限速的消息直接丢弃
+2. 违反协议的消息计为失败,InboundFailureWindow内失败次数达到InboundBanFailures就封禁这个节点,超过限速不计为失败
+3. 封禁时长从InboundBanDuration开始,每次加倍,最多InboundMaxBanDuration
+4. 封禁期间和这个节点有打开的通道时,banExemptMessages中的消息仍然接收
+解析或者验证签名失败的消息无法确定发送方,只做统计
+*/
+type inboundLimiter struct {
+	lock            sync.Mutex
+	buckets         map[inboundBucketKey]*TokenBucket
+	peers           map[common.Address]*peerFailures
+	invalidMessages int
+	timeFunc        timeFunc
+	hasOpenChannel  func(partner common.Address) bool
+}
+
+type peerFailures struct {
+	PeerBan
+	windowStart time.Time
+}
+
+func newInboundLimiter(timeFunc timeFunc, hasOpenChannel func(partner common.Address) bool) *inboundLimiter {
+	return &inboundLimiter{
+		buckets:        make(map[inboundBucketKey]*TokenBucket),
+		peers:          make(map[common.Address]*peerFailures),
+		timeFunc:       timeFunc,
+		hasOpenChannel: hasOpenChannel,
+	}
+}
+
+/*
+allow 返回false表示这个消息应该丢弃,发送方被封禁或者超过了限速
+*/
+func (l *inboundLimiter) allow(sender common.Address, cmdid int) (ok bool, reason string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.timeFunc()
+	if pf, exist := l.peers[sender]; exist && pf.IsBanned(now) {
+		if !banExemptMessages[cmdid] || !l.hasOpenChannel(sender) {
+			return false, "banned"
+		}
+	}
+	if !rateLimitedMessages[cmdid] {
+		return true, ""
+	}
+	key := inboundBucketKey{sender, cmdid}
+	tb, exist := l.buckets[key]
+	if !exist {
+		if len(l.buckets) >= maxInboundBuckets {
+			l.pruneLocked(now)
+		}
+		if cmdid == encoding.PingCmdID {
+			tb = NewTokenBucket(params.InboundPingBurst, params.InboundPingRate, l.timeFunc)
+		} else {
+			tb = NewTokenBucket(params.InboundMessageBurst, params.InboundMessageRate, l.timeFunc)
+		}
+		l.buckets[key] = tb
+	}
+	if tb.Consume(1) > 0 {
+		//丢弃的消息不占用token,否则持续发送的节点永远不能恢复
+		tb.Tokens++
+		return false, "rate limited"
+	}
+	return true, ""
+}
+
+//fail 记录一次验证或者处理失败
+func (l *inboundLimiter) fail(sender common.Address, reason string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.failLocked(sender, reason, l.timeFunc())
+}
+
+func (l *inboundLimiter) failLocked(sender common.Address, reason string, now time.Time) {
+	pf, ok := l.peers[sender]
+	if !ok {
+		pf = &peerFailures{PeerBan: PeerBan{Address: sender}, windowStart: now}
+		l.peers[sender] = pf
+	}
+	if pf.IsBanned(now) {
+		return
+	}
+	if !pf.BannedUntil.IsZero() && now.Sub(pf.BannedUntil) > params.InboundMaxBanDuration {
+		pf.Bans = 0
+	}
+	if now.Sub(pf.windowStart) > params.InboundFailureWindow {
+		pf.windowStart = now
+		pf.Failures = 0
+	}
+	pf.Failures++
+	pf.LastReason = reason
+	if pf.Failures < params.InboundBanFailures {
+		return
+	}
+	d := params.InboundBanDuration
+	for i := 0; i < pf.Bans && d < params.InboundMaxBanDuration; i++ {
+		d *= 2
+	}
+	if d > params.InboundMaxBanDuration {
+		d = params.InboundMaxBanDuration
+	}
+	pf.Bans++
+	pf.Failures = 0
+	pf.windowStart = now
+	pf.BannedUntil = now.Add(d)
+	//封禁期间不需要保留限速状态
+	for key := range l.buckets {
+		if key.sender == sender {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+/*
+pruneLocked 删除一个统计窗口内没有被限速的TokenBucket(重新创建的TokenBucket是满的,效果一样),
+以及已经过期很久的失败记录
+*/
+func (l *inboundLimiter) pruneLocked(now time.Time) {
+	for key, tb := range l.buckets {
+		if now.Sub(tb.Timestamp) > params.InboundFailureWindow {
+			delete(l.buckets, key)
+		}
+	}
+	for addr, pf := range l.peers {
+		if pf.IsBanned(now) || now.Sub(pf.windowStart) <= params.InboundFailureWindow {
+			continue
+		}
+		if pf.Bans == 0 || now.Sub(pf.BannedUntil) > params.InboundMaxBanDuration {
+			delete(l.peers, addr)
+		}
+	}
+}
+
+/*
+protocolViolations photon拒绝处理的消息中,只有这些错误说明对方违反了协议或者签名不对,
+过期,重复或者因为本地状态拒绝的消息不是对方的错,不计为失败
+*/
+var protocolViolations = map[int]bool{
+	rerr.ErrInvalidLocksRoot.ErrorCode:              true,
+	rerr.ErrInvalidNonce.ErrorCode:                  true,
+	rerr.ErrInsufficientBalance.ErrorCode:           true,
+	rerr.ErrChannelTransferAmountMismatch.ErrorCode: true,
+	rerr.ErrChannelTransferAmountDecrease.ErrorCode: true,
+	rerr.ErrChannelIdentifierMismatch.ErrorCode:     true,
+	rerr.ErrChannelInvalidSender.ErrorCode:          true,
+	rerr.ErrChannelNotParticipant.ErrorCode:         true,
+	rerr.ErrChannelLockMisMatch.ErrorCode:           true,
+	rerr.ErrChannelLockExpirationTooLarge.ErrorCode: true,
+	rerr.ErrChannelBalanceNotMatch.ErrorCode:        true,
+	rerr.ErrChannelWithdrawAmount.ErrorCode:         true,
+	rerr.ErrChannelBalanceProofNil.ErrorCode:        true,
+	rerr.ErrRemoveNotExpiredLock.ErrorCode:          true,
+	rerr.ErrChannelDuplicateLock.ErrorCode:          true,
+}
+
+//isProtocolViolation 是否应该计为对方的失败
+func isProtocolViolation(err error) bool {
+	e, ok := err.(rerr.StandardError)
+	return ok && protocolViolations[e.ErrorCode]
+}
+
+//invalidMessage 解析或者验证签名失败的消息不知道是谁发的
+func (l *inboundLimiter) invalidMessage() {
+	l.lock.Lock()
+	l.invalidMessages++
+	l.lock.Unlock()
+}
+
+//list 所有有失败记录的节点,正在被封禁的排在前面
+func (l *inboundLimiter) list() (bans []*PeerBan, invalidMessages int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.timeFunc()
+	for _, pf := range l.peers {
+		b := pf.PeerBan
+		bans = append(bans, &b)
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		bi, bj := bans[i].IsBanned(now), bans[j].IsBanned(now)
+		if bi != bj {
+			return bi
+		}
+		return bans[i].BannedUntil.After(bans[j].BannedUntil)
+	})
+	return bans, l.invalidMessages
+}
+
+//lift 解除封禁并清除失败记录,返回false表示没有这个节点的记录
+func (l *inboundLimiter) lift(sender common.Address) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	_, ok := l.peers[sender]
+	delete(l.peers, sender)
+	return ok
+}
@@ -0,0 +1,111 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInboundLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	timeFunc := func() time.Time {
+		return now
+	}
+	peer := utils.NewRandomAddress()
+	other := utils.NewRandomAddress()
+	l := newInboundLimiter(timeFunc, func(partner common.Address) bool {
+		return partner == peer
+	})
+	//ping的限速和其他消息是分开的
+	for i := 0; i < int(params.InboundPingBurst); i++ {
+		ok, _ := l.allow(peer, encoding.PingCmdID)
+		assert.True(t, ok)
+	}
+	ok, reason := l.allow(peer, encoding.PingCmdID)
+	assert.False(t, ok)
+	assert.Equal(t, "rate limited", reason)
+	ok, _ = l.allow(peer, encoding.MediatedTransferCmdID)
+	assert.True(t, ok)
+	ok, _ = l.allow(other, encoding.PingCmdID)
+	assert.True(t, ok)
+	//被丢弃的消息不占用token
+	now = now.Add(time.Duration(float64(time.Second) / params.InboundPingRate))
+	ok, _ = l.allow(peer, encoding.PingCmdID)
+	assert.True(t, ok)
+	//只有MediatedTransfer和Ping限速,超过限速不计为失败
+	for i := 0; i < params.InboundBanFailures; i++ {
+		ok, _ = l.allow(peer, encoding.PingCmdID)
+		assert.False(t, ok)
+		ok, _ = l.allow(peer, encoding.UnlockCmdID)
+		assert.True(t, ok)
+	}
+	bans, _ := l.list()
+	assert.Empty(t, bans)
+
+	//失败太多封禁,每次封禁时长加倍
+	for i := 0; i < params.InboundBanFailures; i++ {
+		l.fail(peer, "invalid")
+	}
+	ok, reason = l.allow(peer, encoding.MediatedTransferCmdID)
+	assert.False(t, ok)
+	assert.Equal(t, "banned", reason)
+	//有打开的通道时,封禁期间仍然接收balance proof和密码消息
+	ok, _ = l.allow(peer, encoding.UnlockCmdID)
+	assert.True(t, ok)
+	ok, _ = l.allow(peer, encoding.RevealSecretCmdID)
+	assert.True(t, ok)
+	bans, _ = l.list()
+	assert.Len(t, bans, 1)
+	assert.EqualValues(t, 1, bans[0].Bans)
+	assert.Equal(t, now.Add(params.InboundBanDuration), bans[0].BannedUntil)
+
+	now = bans[0].BannedUntil
+	ok, _ = l.allow(peer, encoding.MediatedTransferCmdID)
+	assert.True(t, ok)
+	for i := 0; i < params.InboundBanFailures; i++ {
+		l.fail(peer, "invalid")
+	}
+	bans, _ = l.list()
+	assert.EqualValues(t, 2, bans[0].Bans)
+	assert.Equal(t, now.Add(2*params.InboundBanDuration), bans[0].BannedUntil)
+
+	//统计窗口以外的失败不累计
+	for i := 1; i < params.InboundBanFailures; i++ {
+		l.fail(other, "invalid")
+	}
+	now = now.Add(params.InboundFailureWindow + time.Second)
+	l.fail(other, "invalid")
+	ok, _ = l.allow(other, encoding.MediatedTransferCmdID)
+	assert.True(t, ok)
+	//没有打开的通道,封禁期间所有消息都丢弃
+	for i := 1; i < params.InboundBanFailures; i++ {
+		l.fail(other, "invalid")
+	}
+	ok, _ = l.allow(other, encoding.UnlockCmdID)
+	assert.False(t, ok)
+
+	assert.True(t, l.lift(peer))
+	assert.False(t, l.lift(peer))
+	ok, _ = l.allow(peer, encoding.MediatedTransferCmdID)
+	assert.True(t, ok)
+
+	l.invalidMessage()
+	_, invalidMessages := l.list()
+	assert.Equal(t, 1, invalidMessages)
+}
+
+func TestIsProtocolViolation(t *testing.T) {
+	assert.True(t, isProtocolViolation(rerr.ErrInvalidNonce.Printf("nonce=%d", 3)))
+	assert.True(t, isProtocolViolation(rerr.ErrChannelInvalidSender))
+	//过期和重复的消息,以及本地状态不允许的消息不是对方的错
+	assert.False(t, isProtocolViolation(rerr.ErrChannelLockAlreadyExpired))
+	assert.False(t, isProtocolViolation(rerr.ErrChannelRecovering))
+	assert.False(t, isProtocolViolation(errors.New("unknown")))
+}
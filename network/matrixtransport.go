@@ -118,11 +118,11 @@ var (
 // NewMatrixTransport init matrix
 func NewMatrixTransport(logname string, key *ecdsa.PrivateKey, devicetype string, servers map[string]string) *MatrixTransport {
 	mtr := &MatrixTransport{
-		running:       false,
-		stopreceiving: false,
-		NodeAddress:   crypto.PubkeyToAddress(key.PublicKey),
-		key:           key,
-		Peers:         make(map[common.Address]*MatrixPeer),
+		running:               false,
+		stopreceiving:         false,
+		NodeAddress:           crypto.PubkeyToAddress(key.PublicKey),
+		key:                   key,
+		Peers:                 make(map[common.Address]*MatrixPeer),
 		temporaryAddress2Room: make(map[common.Address]string),
 		temporaryPeers:        newMatrixTemporaryPeers(),
 		NodeDeviceType:        devicetype,
@@ -286,6 +286,11 @@ func (m *MatrixTransport) nodeStatusInternal(addr common.Address) (deviceType st
 	return u.deviceType, u.status == peerStatusOnline, true
 }
 
+//Status connection status with the matrix server
+func (m *MatrixTransport) Status() netshare.Status {
+	return m.status
+}
+
 // NodeStatus gets Node states of network, if check self node, `status` is not always be true instead it switches according to server handshake signal.
 func (m *MatrixTransport) NodeStatus(addr common.Address) (deviceType string, isOnline bool) {
 	deviceType, isOnline, _ = m.nodeStatusInternal(addr)
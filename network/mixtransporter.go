@@ -116,6 +116,11 @@ func (t *MixTransport) NodeStatus(addr common.Address) (deviceType string, isOnl
 	return t.xmpp.NodeStatus(addr)
 }
 
+//Status connection status with the xmpp server, udp can still work when it's not connected
+func (t *MixTransport) Status() netshare.Status {
+	return t.xmpp.Status()
+}
+
 //GetNotify notification of connection status change
 func (t *MixTransport) GetNotify() (notify <-chan netshare.Status, err error) {
 	//if t.xmpp.conn != nil {
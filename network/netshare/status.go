@@ -13,3 +13,17 @@ const (
 	//Reconnecting connection error
 	Reconnecting
 )
+
+func (s Status) String() string {
+	switch s {
+	case Disconnected:
+		return "disconnected"
+	case Connected:
+		return "connected"
+	case Closed:
+		return "closed"
+	case Reconnecting:
+		return "reconnecting"
+	}
+	return "unknown"
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
 	"github.com/SmartMeshFoundation/Photon/encoding"
 	"github.com/SmartMeshFoundation/Photon/internal/rpanic"
+	"github.com/SmartMeshFoundation/Photon/internal/tracing"
 	"github.com/SmartMeshFoundation/Photon/log"
 	"github.com/SmartMeshFoundation/Photon/params"
 	"github.com/SmartMeshFoundation/Photon/utils"
@@ -34,8 +35,9 @@ var errExpired = errors.New("message expired")
 MessageToPhoton message and it's echo hash
 */
 type MessageToPhoton struct {
-	Msg      encoding.SignedMessager
-	EchoHash common.Hash
+	Msg             encoding.SignedMessager
+	EchoHash        common.Hash
+	SenderPublicKey []byte // 从签名中恢复的发送方公钥,keysend交易需要
 }
 
 // SentMessageState is the state of message on sending
@@ -48,6 +50,7 @@ type SentMessageState struct {
 	Message  encoding.Messager //message to send
 	EchoHash common.Hash       //message echo hash
 	Data     []byte            //packed message
+	span     *tracing.Span     //从发送到收到ack
 }
 
 // PingSender do send ping task
@@ -67,6 +70,8 @@ ChannelStatusGetter get the status of channel address, so sender can remove msg
 */
 type ChannelStatusGetter interface {
 	GetChannelStatus(channelIdentifier common.Hash) (int, int64)
+	//HasOpenChannel 是否和partner有打开的通道
+	HasOpenChannel(partner common.Address) bool
 }
 
 /*
@@ -144,6 +149,11 @@ type PhotonProtocol struct {
 	receiveChan chan []byte
 	log         log.Logger
 	isReceiving bool
+	//其他节点通过Ping的版本号声明自己支持哪些新功能
+	peerVersions     map[common.Address]int16
+	peerVersionsLock sync.Mutex
+	//限制其他节点发来的消息,封禁恶意节点
+	inboundLimiter *inboundLimiter
 }
 
 // NewPhotonProtocol create PhotonProtocol
@@ -162,7 +172,9 @@ func NewPhotonProtocol(transport Transporter, privKey *ecdsa.PrivateKey, channel
 		quitChan:                  make(chan struct{}),
 		receiveChan:               make(chan []byte, 200),
 		mapLock:                   sync.Mutex{},
+		peerVersions:              make(map[common.Address]int16),
 	}
+	rp.inboundLimiter = newInboundLimiter(time.Now, channelStatusGetter.HasOpenChannel)
 	rp.nodeAddr = crypto.PubkeyToAddress(privKey.PublicKey)
 	transport.RegisterProtocol(rp)
 	rp.log = log.New("name", utils.APex2(rp.nodeAddr))
@@ -232,11 +244,11 @@ func (p *PhotonProtocol) messageCanBeSent(msg encoding.Messager) bool {
 	if channelIdentifier != utils.EmptyHash {
 		status, localOpenBlockNumber := p.ChannelStatusGetter.GetChannelStatus(channelIdentifier)
 		if status == channeltype.StateInValid {
-			p.log.Info(fmt.Sprintf("message cannot be send because of channel status =%d", status))
+			p.log.Info(fmt.Sprintf("message cannot be send because of channel status =%d", status), messageLogCtx(msg)...)
 			return false
 		}
 		if openBlockNumber != localOpenBlockNumber {
-			p.log.Info(fmt.Sprintf("message cannot be send because of channel open block number does't match,now=%d,msg.OpenBlockNumber=%d", localOpenBlockNumber, openBlockNumber))
+			p.log.Info(fmt.Sprintf("message cannot be send because of channel open block number does't match,now=%d,msg.OpenBlockNumber=%d", localOpenBlockNumber, openBlockNumber), messageLogCtx(msg)...)
 			return false
 		}
 	}
@@ -308,11 +320,17 @@ func (p *PhotonProtocol) processSentMessageState(receiver common.Address, channe
 }
 
 func (p *PhotonProtocol) sendMessage(receiver common.Address, msgState *SentMessageState) {
+	logCtx := messageLogCtx(msgState.Message)
 	p.log.Trace(fmt.Sprintf("send to %s,msg=%s, echohash=%s",
 		utils.APex2(msgState.ReceiverAddress), msgState.Message,
-		utils.HPex(msgState.EchoHash)))
-	for {
+		utils.HPex(msgState.EchoHash)), logCtx...)
+	defer msgState.span.End()
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			msgState.span.AddEvent("retry", "attempt", attempt)
+		}
 		if !p.messageCanBeSent(msgState.Message) {
+			msgState.span.SetError(errExpired)
 			msgState.AsyncResult.Result <- errExpired
 			p.mapLock.Lock()
 			delete(p.SentHashesToChannel, msgState.EchoHash)
@@ -322,14 +340,14 @@ func (p *PhotonProtocol) sendMessage(receiver common.Address, msgState *SentMess
 		nextTimeout := timeoutExponentialBackoff(p.retryTimes, p.retryInterval, p.retryInterval*10)
 		err := p.sendRawWitNoAck(receiver, msgState.Data)
 		if err != nil {
-			p.log.Info(fmt.Sprintf("sendRawWitNoAck msg echoHash=%s error %s", utils.HPex(msgState.EchoHash), err.Error()))
+			p.log.Info(fmt.Sprintf("sendRawWitNoAck msg echoHash=%s error %s", utils.HPex(msgState.EchoHash), err.Error()), logCtx...)
 		}
 		timeout := time.After(nextTimeout())
 		var ok bool
 		select {
 		case _, ok = <-msgState.AckChannel:
 			if ok {
-				p.log.Trace(fmt.Sprintf("msg=%s EchoHash=%s, sent success", encoding.MessageType(msgState.Message.Cmd()), utils.HPex(msgState.EchoHash)))
+				p.log.Trace(fmt.Sprintf("msg=%s EchoHash=%s, sent success", encoding.MessageType(msgState.Message.Cmd()), utils.HPex(msgState.EchoHash)), logCtx...)
 				msgState.AsyncResult.Result <- nil
 				p.mapLock.Lock()
 				delete(p.SentHashesToChannel, msgState.EchoHash)
@@ -344,16 +362,18 @@ func (p *PhotonProtocol) sendMessage(receiver common.Address, msgState *SentMess
 			_, isOnline := p.Transport.NodeStatus(receiver)
 			transport, ok1 := p.Transport.(*MatrixMixTransport)
 			if ok1 && !isOnline && transport != nil {
-				log.Warn(fmt.Sprintf("receiver %s is not online,sleep until when he back online", receiver.String()))
+				log.Warn(fmt.Sprintf("receiver %s is not online,sleep until when he back online", receiver.String()), logCtx...)
 				wakeUpChan := make(chan int)
 				// 向transport注册wakeUpChan
 				transport.RegisterWakeUpChan(receiver, wakeUpChan)
 				// 挂起并等待对方上线
+				msgState.span.AddEvent("wait receiver online")
 				<-wakeUpChan
 				// 继续发送并注销wakeUpChan
 				transport.UnRegisterWakeUpChan(receiver)
 			}
 		case <-p.quitChan:
+			msgState.span.SetError(errors.New("protocol stoped"))
 			return
 		}
 	}
@@ -384,10 +404,51 @@ func getMessageChannelIdentifier(msg encoding.Messager) (common.Hash, int64) {
 	case *encoding.WithdrawRequest:
 		channelIdentifier = msg2.ChannelIdentifier
 		openBlockNumber = msg2.OpenBlockNumber
+	case *encoding.RecoveryResponse:
+		//分页的恢复数据按顺序发送,拒绝的回复只有一个分页,通道可能根本不存在
+		if msg2.ErrorCode == 0 {
+			channelIdentifier = msg2.ChannelIdentifier
+			openBlockNumber = msg2.OpenBlockNumber
+		}
 	}
 	return channelIdentifier, openBlockNumber
 }
 
+//messageLogCtx 交易相关消息的日志带上lockSecretHash和channel,其他消息返回nil
+func messageLogCtx(msg encoding.Messager) []interface{} {
+	return utils.TransferLogCtx(getMessageTransfer(msg))
+}
+
+//getMessageTransfer 交易相关消息的lockSecretHash和channel,其他消息的lockSecretHash为空
+func getMessageTransfer(msg encoding.Messager) (lockSecretHash, channelIdentifier common.Hash) {
+	channelIdentifier, _ = getMessageChannelIdentifier(msg)
+	switch msg2 := msg.(type) {
+	case *encoding.MediatedTransfer:
+		lockSecretHash = msg2.LockSecretHash
+	case *encoding.SecretRequest:
+		lockSecretHash = msg2.LockSecretHash
+	case *encoding.RevealSecret:
+		lockSecretHash = msg2.LockSecretHash()
+	case *encoding.UnLock:
+		lockSecretHash = msg2.LockSecretHash()
+	case *encoding.RemoveExpiredHashlockTransfer:
+		lockSecretHash = msg2.LockSecretHash
+	case *encoding.AnnounceDisposed:
+		lockSecretHash = msg2.Lock.LockSecretHash
+		channelIdentifier = msg2.ChannelIdentifier
+	case *encoding.AnnounceDisposedResponse:
+		lockSecretHash = msg2.LockSecretHash
+	}
+	return
+}
+
+//startMessageSpan 交易相关的消息记录trace span,其他消息返回nil
+func startMessageSpan(name string, kind int, peer common.Address, msg encoding.Messager, echohash common.Hash) *tracing.Span {
+	lockSecretHash, channelIdentifier := getMessageTransfer(msg)
+	return tracing.StartSpan(lockSecretHash, name+" "+encoding.MessageType(msg.Cmd()).String(), kind,
+		"peer", peer, "channel", channelIdentifier, "echo_hash", echohash)
+}
+
 /*
 	msg should be signed.
 	msg must be sent success.
@@ -423,6 +484,7 @@ because they sending same SecretRequest and RevealSecret,msg=%s`, msg))
 		Message:         msg,
 		Data:            data,
 		EchoHash:        echohash,
+		span:            startMessageSpan("send", tracing.KindClient, receiver, msg, echohash),
 	}
 	p.SentHashesToChannel[echohash] = msgState
 	p.mapLock.Unlock()
@@ -503,6 +565,7 @@ func (p *PhotonProtocol) receiveInternal(data []byte) {
 	err := messager.UnPack(data)
 	if err != nil {
 		p.log.Warn(fmt.Sprintf("message unpack error : %s", err))
+		p.inboundLimiter.invalidMessage()
 		return
 	}
 	echohash := utils.Sha3(data, p.nodeAddr[:])
@@ -514,10 +577,17 @@ func (p *PhotonProtocol) receiveInternal(data []byte) {
 				p.log.Error(fmt.Sprintf("received a message %s, not ack ,and don't signed", messager))
 				return
 			}
+			//对方没有收到ack在重发,不计入限速
 			p.sendRawAck(sm.GetSender(), ackdata)
 			return
 		}
 	}
+	if sm, ok := messager.(encoding.SignedMessager); ok && messager.Cmd() != encoding.AckCmdID {
+		if allowed, reason := p.inboundLimiter.allow(sm.GetSender(), messager.Cmd()); !allowed {
+			p.log.Debug(fmt.Sprintf("drop message %s from %s, %s", encoding.MessageType(messager.Cmd()), utils.APex2(sm.GetSender()), reason))
+			return
+		}
+	}
 	if messager.Cmd() == encoding.AckCmdID { //some one may be waiting p ack
 		ackMsg := messager.(*encoding.Ack)
 		p.log.Debug(fmt.Sprintf("receive ack ,EchoHash=%s", utils.HPex(ackMsg.Echo)))
@@ -533,24 +603,33 @@ func (p *PhotonProtocol) receiveInternal(data []byte) {
 		p.mapLock.Unlock()
 	} else {
 		signedMessager, ok := messager.(encoding.SignedMessager)
-		p.log.Trace(fmt.Sprintf("received msg=%s from=%s,expect ack EchoHash=%s", messager, utils.APex2(signedMessager.GetSender()), utils.HPex(echohash)))
 		if !ok {
 			p.log.Warn("message should be signed except for ack")
 			return
 		}
+		logCtx := messageLogCtx(messager)
+		p.log.Trace(fmt.Sprintf("received msg=%s from=%s,expect ack EchoHash=%s", messager, utils.APex2(signedMessager.GetSender()), utils.HPex(echohash)), logCtx...)
 		if messager.Cmd() == encoding.PingCmdID { //send ack
+			p.updatePeerVersion(signedMessager.(*encoding.Ping))
 			p.sendAck(signedMessager.GetSender(), p.CreateAck(echohash))
 		} else {
 			//send message to photon ,and wait result
+			span := startMessageSpan("receive", tracing.KindServer, signedMessager.GetSender(), messager, echohash)
 			p.log.Trace(fmt.Sprintf("protocol send message to photon... %s", signedMessager))
-			p.ReceivedMessageChan <- &MessageToPhoton{signedMessager, echohash}
+			pubkey, err2 := encoding.RecoverSenderPublicKey(signedMessager, data)
+			if err2 != nil {
+				p.log.Trace(fmt.Sprintf("recover public key of %s err %s", utils.APex2(signedMessager.GetSender()), err2))
+			}
+			p.ReceivedMessageChan <- &MessageToPhoton{signedMessager, echohash, pubkey}
 			select {
 			case err, ok = <-p.ReceivedMessageResultChan:
 			case <-p.quitChan:
 				ok = false
 				err = errors.New("protocol stoped")
 			}
-			p.log.Trace(fmt.Sprintf("protocol receive message response from photon ok=%v,err=%v", ok, err))
+			p.log.Trace(fmt.Sprintf("protocol receive message response from photon ok=%v,err=%v", ok, err), logCtx...)
+			span.SetError(err)
+			span.End()
 			//only send the Ack if the message was handled without exceptions
 			if err == nil && ok {
 				ack := p.CreateAck(echohash)
@@ -559,13 +638,62 @@ func (p *PhotonProtocol) receiveInternal(data []byte) {
 					p.receivedMessageSaver.SaveAck(echohash, messager, ack.Pack())
 				}
 			} else {
-				p.log.Info(fmt.Sprintf("and photon report error %s, for Received Message %s", err, utils.StringInterface(signedMessager, 3)))
+				p.log.Info(fmt.Sprintf("and photon report error %s, for Received Message %s", err, utils.StringInterface(signedMessager, 3)), logCtx...)
+				if ok && isProtocolViolation(err) {
+					p.inboundLimiter.fail(signedMessager.GetSender(), fmt.Sprintf("%s: %s", encoding.MessageType(messager.Cmd()), err))
+				}
 			}
 		}
 	}
 
 }
 
+/*
+老版本节点发送的Ping版本号为0,节点升级或者降级以后以最新收到的Ping为准
+*/
+func (p *PhotonProtocol) updatePeerVersion(ping *encoding.Ping) {
+	p.peerVersionsLock.Lock()
+	defer p.peerVersionsLock.Unlock()
+	if ping.Version > 0 {
+		p.peerVersions[ping.Sender] = ping.Version
+	} else {
+		delete(p.peerVersions, ping.Sender)
+	}
+}
+
+func (p *PhotonProtocol) peerVersion(addr common.Address) int16 {
+	p.peerVersionsLock.Lock()
+	defer p.peerVersionsLock.Unlock()
+	return p.peerVersions[addr]
+}
+
+// SupportKeysend returns true if the node has told us it can handle MediatedTransfer with encrypted secret
+func (p *PhotonProtocol) SupportKeysend(addr common.Address) bool {
+	return p.peerVersion(addr) >= encoding.PingKeysendVersion
+}
+
+// SupportOnion returns true if the node has told us it can handle onion routed transfers
+func (p *PhotonProtocol) SupportOnion(addr common.Address) bool {
+	return p.peerVersion(addr) >= encoding.PingOnionVersion
+}
+
+// SupportEncryptedMemo returns true if the node has told us it can receive Memo messages
+func (p *PhotonProtocol) SupportEncryptedMemo(addr common.Address) bool {
+	return p.peerVersion(addr) >= encoding.PingEncryptedMemoVersion
+}
+
+/*
+GetPeerBans 因为发送太多消息或者发送无效消息被记录的节点,以及无法确定发送方的无效消息数量
+*/
+func (p *PhotonProtocol) GetPeerBans() (bans []*PeerBan, invalidMessages int) {
+	return p.inboundLimiter.list()
+}
+
+// LiftPeerBan 解除对这个节点的封禁,返回false表示没有这个节点的记录
+func (p *PhotonProtocol) LiftPeerBan(addr common.Address) bool {
+	return p.inboundLimiter.lift(addr)
+}
+
 // StopAndWait stop andf wait for clean.
 func (p *PhotonProtocol) StopAndWait() {
 	p.log.Info("PhotonProtocol stop...")
@@ -16,6 +16,7 @@ import (
 	"github.com/SmartMeshFoundation/Photon/encoding"
 	"github.com/SmartMeshFoundation/Photon/internal/rpanic"
 	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/network/netshare"
 	"github.com/SmartMeshFoundation/Photon/network/xmpptransport"
 	"github.com/SmartMeshFoundation/Photon/params"
 	"github.com/SmartMeshFoundation/Photon/utils"
@@ -55,6 +56,8 @@ type Transporter interface {
 	RegisterProtocol(protcol ProtocolReceiver)
 	//NodeStatus get node's status and is online right now
 	NodeStatus(addr common.Address) (deviceType string, isOnline bool)
+	//Status connection status of this node with the server, udp is connected until stopped
+	Status() netshare.Status
 }
 
 type dummyPolicy struct {
@@ -298,6 +301,14 @@ func (ut *UDPTransport) StopAccepting() {
 	ut.stopReceiving = true
 }
 
+//Status udp has no server, it's connected until stopped
+func (ut *UDPTransport) Status() netshare.Status {
+	if ut.stopped {
+		return netshare.Closed
+	}
+	return netshare.Connected
+}
+
 //NodeStatus always mark the node offline
 func (ut *UDPTransport) NodeStatus(addr common.Address) (deviceType string, isOnline bool) {
 	ut.lock.RLock()
@@ -135,6 +135,17 @@ func (x *XMPPTransport) RegisterProtocol(protcol ProtocolReceiver) {
 	x.protocol = protcol
 }
 
+//Status connection status with the xmpp server
+func (x *XMPPTransport) Status() netshare.Status {
+	if x.stopped {
+		return netshare.Closed
+	}
+	if x.conn == nil {
+		return netshare.Disconnected
+	}
+	return x.conn.Status()
+}
+
 //NodeStatus get node's status and is online right now
 func (x *XMPPTransport) NodeStatus(addr common.Address) (deviceType string, isOnline bool) {
 	if x.conn == nil {
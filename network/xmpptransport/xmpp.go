@@ -359,6 +359,11 @@ func (x *XMPPConnection) Close() {
 	}
 }
 
+//Status of this connection
+func (x *XMPPConnection) Status() netshare.Status {
+	return x.status
+}
+
 //Connected returns true when this connection is ready for sent
 func (x *XMPPConnection) Connected() bool {
 	return x.status == netshare.Connected
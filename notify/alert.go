@@ -0,0 +1,33 @@
+package notify
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//Alert 需要用户处理的异常情况,条件一直满足的时候只通知一次,级别升高的时候再通知
+type Alert struct {
+	Key               string         `json:"key"`
+	Type              int            `json:"type"` // InfoTypeAlertXXX
+	Level             Level          `json:"level"`
+	Message           string         `json:"message"`
+	ChannelIdentifier common.Hash    `json:"channel_identifier"`
+	TokenAddress      common.Address `json:"token_address"`
+	PartnerAddress    common.Address `json:"partner_address"`
+	LockSecretHash    common.Hash    `json:"lock_secret_hash"`
+	DeadlineBlock     int64          `json:"deadline_block,omitempty"` // 必须在这个块之前处理
+	Balance           *big.Int       `json:"balance,omitempty"`
+	BlockNumber       int64          `json:"block_number"` // 第一次发现的块
+	Time              int64          `json:"time"`
+}
+
+/*
+NotifyAlert 新的告警,或者告警的级别升高了
+*/
+func (h *Handler) NotifyAlert(a *Alert) {
+	h.Notify(a.Level, &InfoStruct{
+		Type:    a.Type,
+		Message: a,
+	})
+}
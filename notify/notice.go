@@ -43,6 +43,21 @@ const (
 
 	// InfoTypeContractCallTXInfo 4 自己发起的tx执行完成,通知执行结果,Message类型为models.TXInfo
 	InfoTypeContractCallTXInfo
+
+	// InfoTypeBatchTransferProgress 5 批量转账中有交易结束,通知整体进度以及失败的交易
+	InfoTypeBatchTransferProgress
+
+	// InfoTypePendingApproval 6 有操作超过审批阈值需要审批,或者审批结果已经执行,Message类型为models.PendingApproval
+	InfoTypePendingApproval
+
+	// InfoTypeAlertPartnerClosed 7 对方关闭了通道,Message类型为Alert
+	InfoTypeAlertPartnerClosed
+	// InfoTypeAlertLockNearRevealTimeout 8 知道密码的锁快要过期了,对方还没有unlock,Message类型为Alert
+	InfoTypeAlertLockNearRevealTimeout
+	// InfoTypeAlertSettleWindowClosing 9 关闭的通道快要可以settle了,Message类型为Alert
+	InfoTypeAlertSettleWindowClosing
+	// InfoTypeAlertLowBalance 10 账户余额不够支付关闭通道等交易的gas,Message类型为Alert
+	InfoTypeAlertLowBalance
 )
 
 //InfoStruct for notify to mobile
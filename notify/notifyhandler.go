@@ -161,3 +161,49 @@ func (h *Handler) NotifyContractCallTXInfo(txInfo *models.TXInfo) {
 		Message: txInfo,
 	})
 }
+
+type batchTransferProgress struct {
+	Key          string                    `json:"key"`
+	Total        int                       `json:"total"`
+	SuccessCount int                       `json:"success_count"`
+	FailedCount  int                       `json:"failed_count"`
+	Finished     bool                      `json:"finished"`
+	FailedItem   *models.BatchTransferItem `json:"failed_item,omitempty"`
+}
+
+/*
+NotifyBatchTransferProgress 批量转账中有交易结束时通知上层整体进度,
+如果这笔交易失败了,同时通知失败的交易
+*/
+func (h *Handler) NotifyBatchTransferProgress(b *models.BatchTransfer, item *models.BatchTransferItem) {
+	p := &batchTransferProgress{
+		Key:          b.Key,
+		Total:        len(b.Items),
+		SuccessCount: b.SuccessCount,
+		FailedCount:  b.FailedCount,
+		Finished:     b.IsFinished(),
+	}
+	level := Level(LevelInfo)
+	if item != nil && item.Status != models.TransferStatusSuccess {
+		level = LevelWarn
+		p.FailedItem = item
+	}
+	h.Notify(level, &InfoStruct{
+		Type:    InfoTypeBatchTransferProgress,
+		Message: p,
+	})
+}
+
+/*
+NotifyPendingApproval 有新的操作等待审批,或者审批过的操作已经执行
+*/
+func (h *Handler) NotifyPendingApproval(a *models.PendingApproval) {
+	level := Level(LevelWarn)
+	if a.Status != models.PendingApprovalStatusPending {
+		level = LevelInfo
+	}
+	h.Notify(level, &InfoStruct{
+		Type:    InfoTypePendingApproval,
+		Message: a,
+	})
+}
@@ -0,0 +1,123 @@
+package photon
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/pfsproxy"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/transfer/route"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+attachOnion 为每一条路由创建onion,路径上的每一个节点都必须满足下面的条件之一:
+1. 用户在onionPublicKeys中指定了它的公钥
+2. 通过Ping声明过支持onion交易,并且从它的消息签名中恢复过公钥
+不满足条件的路由被丢弃,只有allowClearPath为true的时候才保留,按照普通的keysend交易明文发送路径
+*/
+func (rs *Service) attachOnion(routes []*route.State, target common.Address, onionPublicKeys map[common.Address][]byte, allowClearPath bool) []*route.State {
+	var result []*route.State
+	for _, r := range routes {
+		onion, err := rs.newOnion(r.Path, target, onionPublicKeys)
+		if err != nil {
+			if allowClearPath {
+				log.Info(fmt.Sprintf("route %s can not use onion,send path in clear,err=%s", utils.StringInterface1(r.Path), err))
+				result = append(result, r)
+			} else {
+				log.Info(fmt.Sprintf("ignore route %s,it can not use onion,err=%s", utils.StringInterface1(r.Path), err))
+			}
+			continue
+		}
+		r.Onion = onion
+		result = append(result, r)
+	}
+	return result
+}
+
+func (rs *Service) newOnion(path []common.Address, target common.Address, onionPublicKeys map[common.Address][]byte) (onion []byte, err error) {
+	if len(path) == 0 || path[len(path)-1] != target {
+		return nil, fmt.Errorf("route has no full path to target")
+	}
+	var pubkeys [][]byte
+	var fees []*big.Int
+	for _, hop := range path {
+		pubkey := onionPublicKeys[hop]
+		if len(pubkey) == 0 {
+			if !rs.Protocol.SupportOnion(hop) {
+				return nil, fmt.Errorf("%s does not support onion", utils.APex2(hop))
+			}
+			pubkey = rs.nodePublicKeys[hop]
+		}
+		if len(pubkey) == 0 {
+			return nil, fmt.Errorf("public key of %s is unknown", utils.APex2(hop))
+		}
+		pubkeys = append(pubkeys, pubkey)
+		// 不收费的网络中每一跳的手续费都是0,否则只知道总的手续费,由中间节点按照自己的收费策略收取
+		var fee *big.Int
+		if rs.PfsProxy == nil {
+			fee = utils.BigInt0
+		}
+		fees = append(fees, fee)
+	}
+	return encoding.NewOnion(path, pubkeys, fees)
+}
+
+/*
+OnionTransfer 发起onion路由的keysend交易,中间节点只知道自己的上一跳和下一跳,不知道发起方,target以及完整的路径.
+onion需要完整的路径,所以必须通过routeInfo指定路由或者与target有直接通道.
+onionPublicKeys是路径上节点的公钥,没有指定的节点必须通过Ping声明过支持onion并且收到过它的消息,
+否则该路由不能使用,超过params.MaxOnionHops的路由也一样,没有可用的路由时返回ErrOnionNotSupported.
+allowClearPath为true时这些路由退化为普通的keysend交易,明文发送路径.
+timeout为0表示一直等待交易结果
+*/
+func (r *API) OnionTransfer(tokenAddress common.Address, amount *big.Int, target common.Address, onionPublicKeys map[common.Address][]byte, allowClearPath bool, timeout time.Duration, data string, routeInfo []pfsproxy.FindPathResponse) (result *utils.AsyncResult, err error) {
+	result, err = r.onionTransferInternal(tokenAddress, amount, target, onionPublicKeys, allowClearPath, data, routeInfo)
+	if err != nil {
+		return
+	}
+	if timeout > 0 {
+		select {
+		case <-time.After(timeout):
+			return result, rerr.ErrTransferTimeout
+		case err = <-result.Result:
+		}
+	} else {
+		err = <-result.Result
+	}
+	return
+}
+
+// OnionTransferAsync :
+func (r *API) OnionTransferAsync(tokenAddress common.Address, amount *big.Int, target common.Address, onionPublicKeys map[common.Address][]byte, allowClearPath bool, data string, routeInfo []pfsproxy.FindPathResponse) (result *utils.AsyncResult, err error) {
+	result, err = r.onionTransferInternal(tokenAddress, amount, target, onionPublicKeys, allowClearPath, data, routeInfo)
+	if err != nil {
+		return
+	}
+	select {
+	case <-time.After(300 * time.Millisecond):
+	case err = <-result.Result:
+	}
+	return
+}
+
+func (r *API) onionTransferInternal(tokenAddress common.Address, amount *big.Int, target common.Address, onionPublicKeys map[common.Address][]byte, allowClearPath bool, data string, routeInfo []pfsproxy.FindPathResponse) (result *utils.AsyncResult, err error) {
+	keys := make(map[common.Address][]byte)
+	for addr, pubkey := range onionPublicKeys {
+		_, err = encoding.UnmarshalPublicKey(pubkey)
+		if err != nil {
+			err = rerr.ErrArgumentError.AppendError(err)
+			return
+		}
+		if utils.PubkeyToAddress(pubkey) != addr {
+			err = rerr.ErrArgumentError.Printf("public key does not belong to %s", addr.String())
+			return
+		}
+		keys[addr] = pubkey
+	}
+	return r.keysendInternal(tokenAddress, amount, target, keys[target], data, routeInfo, keys, allowClearPath)
+}
@@ -0,0 +1,38 @@
+package photon
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/transfer/route"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachOnion(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	target := crypto.PubkeyToAddress(key.PublicKey)
+	keys := map[common.Address][]byte{target: crypto.FromECDSAPub(&key.PublicKey)}
+	rs := &Service{}
+	newRoutes := func() []*route.State {
+		return []*route.State{
+			{Path: []common.Address{target}},
+			//没有到target的完整路径,不能创建onion
+			{Path: []common.Address{utils.NewRandomAddress()}},
+		}
+	}
+	routes := rs.attachOnion(newRoutes(), target, keys, false)
+	if assert.Len(t, routes, 1) {
+		assert.NotEmpty(t, routes[0].Onion)
+	}
+	//不能创建onion的路由被丢弃
+	routes = rs.attachOnion(newRoutes()[1:], target, keys, false)
+	assert.Empty(t, routes)
+	//用户允许的时候明文发送路径
+	routes = rs.attachOnion(newRoutes(), target, keys, true)
+	if assert.Len(t, routes, 2) {
+		assert.NotEmpty(t, routes[0].Onion)
+		assert.Empty(t, routes[1].Onion)
+	}
+}
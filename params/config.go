@@ -2,6 +2,7 @@ package params
 
 import (
 	"crypto/ecdsa"
+	"math/big"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -71,6 +72,31 @@ type Config struct {
 	PfsHost                   string // pathfinder server host
 	HTTPUsername              string
 	HTTPPassword              string
+	IdempotencyRetention      time.Duration // how long a client supplied idempotency key is remembered
+	APITLSCert                string        // 指定了证书和私钥的时候REST接口使用https
+	APITLSKey                 string
+	APIClientCA               string        // 指定以后客户端必须提供这个CA签发的证书
+	DBPassword                string        // keystore的密码,用来派生数据库加密的key
+	EncryptDB                 bool          // 没有加密的数据库在启动的时候加密
+	ReconcileInterval         time.Duration // 和合约对账的间隔,0表示不定时对账
+	ReconcileRepair           bool          // 是否修复对账发现的漏掉的存款
+	Retention                 RetentionConfig
+	TraceFile                 string   // 交易的trace span导出到这个文件
+	TraceCollector            string   // 交易的trace span发送到OTLP/HTTP collector,比如http://127.0.0.1:4318/v1/traces
+	AlertMinBalance           *big.Int // 账户余额低于这个值的时候告警,0表示不检查
+}
+
+//RetentionConfig 历史记录保留多久,超过的记录导出到归档文件以后删除,0表示永久保留
+type RetentionConfig struct {
+	Interval           time.Duration // 归档的间隔
+	ReceivedTransfer   time.Duration
+	SentTransferDetail time.Duration // 只归档已经完成的交易
+	TXInfo             time.Duration // 只归档已经打包的tx
+	FeeChargeRecord    time.Duration
+	Ack                time.Duration
+	TransferMemo       time.Duration // 按照收到附言的时间,没有收齐的附言也会归档
+	ChainEventBlocks   int64         // 保留最近多少块的链上事件记录
+	Compact            bool          // 归档以后压缩数据库,boltdb只能离线压缩
 }
 
 //DefaultConfig default config
@@ -84,11 +110,17 @@ var DefaultConfig = Config{
 		ThrottleCapacity:     defaultProtocolRhrottleCapacity,
 		ThrottleFillRate:     defaultProtocolThrottleFillRate,
 	},
-	UseRPC:            true,
-	UseConsole:        false,
-	MsgTimeout:        100 * time.Second,
-	EnableHealthCheck: false,
-	XMPPServer:        DefaultXMPPServer,
+	UseRPC:               true,
+	UseConsole:           false,
+	MsgTimeout:           100 * time.Second,
+	EnableHealthCheck:    false,
+	XMPPServer:           DefaultXMPPServer,
+	IdempotencyRetention: DefaultIdempotencyRetention,
+	ReconcileInterval:    DefaultReconcileInterval,
+	Retention: RetentionConfig{
+		Interval: DefaultRetentionInterval,
+	},
+	AlertMinBalance: big.NewInt(DefaultAlertMinBalance),
 }
 
 //ConditionQuit is for test
@@ -47,6 +47,66 @@ const DefaultTxTimeout = 5 * time.Minute //15seconds for one block,it may take s
 //MaxRequestTimeout args
 const MaxRequestTimeout = 20 * time.Minute //longest time for a request ,for example ,settle all channles?
 
+//DefaultIdempotencyRetention how long the result of a request with idempotency key is kept
+const DefaultIdempotencyRetention = 24 * time.Hour
+
+//DefaultBatchTransferConcurrency transfers of a batch running at the same time
+const DefaultBatchTransferConcurrency = 5
+
+//MaxBatchTransferConcurrency max transfers of a batch running at the same time
+const MaxBatchTransferConcurrency = 50
+
+//MaxBatchTransferItems max transfers in one batch
+const MaxBatchTransferItems = 1000
+
+//MinPaymentPlanInterval min interval of a recurring payment plan
+const MinPaymentPlanInterval = 60 * time.Second
+
+//PaymentPlanCheckInterval how often to check whether a payment plan is due
+const PaymentPlanCheckInterval = 10 * time.Second
+
+//DefaultReconcileInterval how often channels are reconciled with the contract
+const DefaultReconcileInterval = 10 * time.Minute
+
+//DefaultRetentionInterval how often history records older than their retention are archived
+const DefaultRetentionInterval = 24 * time.Hour
+
+//DefaultAlertMinBalance alert when balance is less than the gas of closing and settling a channel at default gas price
+const DefaultAlertMinBalance = 2 * DefaultGasLimit * DefaultGasPrice
+
+//AlertBalanceCheckBlocks how many blocks between two balance checks of the alert
+const AlertBalanceCheckBlocks = 20
+
+//HealthMaxBlockLag the node is not ready when the block it has processed is behind the chain more than this
+const HealthMaxBlockLag = 10
+
+//HealthCheckTimeout timeout of querying the chain and pfs in a readiness check
+const HealthCheckTimeout = 5 * time.Second
+
+//HealthReportCacheTime health and readiness reports are reused for this long, so unauthenticated calls can not load the node
+var HealthReportCacheTime = 5 * time.Second
+
+//RecoveryLocksPerMessage max locks in one RecoveryResponse, limited by UDPMaxMessageSize
+const RecoveryLocksPerMessage = 6
+
+//GkvKeyScanStableRounds gkvdb遍历key的时候,连续这么多次没有发现新的key才认为已经找到了所有的key
+const GkvKeyScanStableRounds = 3
+
+//GkvReencryptMaxRounds gkvdb重新加密并检查的最多次数
+const GkvReencryptMaxRounds = 5
+
+//RecoveryRetryInterval how often recovery requests are resent to partners who haven't answered
+const RecoveryRetryInterval = 30 * time.Second
+
+//StateManagerSnapshotInterval how many state changes are written to the write-ahead log of a state manager before a snapshot
+const StateManagerSnapshotInterval = 20
+
+//StateManagerHistoryLimit how many state changes of a state manager are kept in memory for debugging
+const StateManagerHistoryLimit = 100
+
+//MaxOnionHops max hops(including target) of an onion routed transfer, limited by UDPMaxMessageSize
+const MaxOnionHops = 3
+
 var gasLimitHex string
 
 //ChannelSettleTimeoutMin min settle timeout
@@ -197,6 +257,45 @@ var ForkConfirmNumber int64 = 17
 // MaxTransferDataLen : 交易附件信息最大长度
 var MaxTransferDataLen = 256
 
+// MaxMemoLen : 加密以后通过Memo消息发送的交易附言最大长度
+var MaxMemoLen = 4096
+
+// MemoChunkSize : 每一个Memo消息携带的加密附言的最大长度
+const MemoChunkSize = 1000
+
+// PendingMemoTimeout : 还没有对应交易的附言在内存中保留的时间,要覆盖默认结算窗口内锁的有效期
+var PendingMemoTimeout = time.Hour
+
+// MaxPendingMemosPerSender : 每个节点最多有多少个还没有对应交易的附言
+var MaxPendingMemosPerSender = 16
+
+// MaxPendingMemos : 内存中所有节点的附言总数上限
+var MaxPendingMemos = 1024
+
+// InboundMessageBurst : 每个节点允许连续收到的MediatedTransfer数量
+var InboundMessageBurst = 200.0
+
+// InboundMessageRate : 每个节点每秒允许收到的MediatedTransfer数量
+var InboundMessageRate = 50.0
+
+// InboundPingBurst : Ping不需要photon处理,限制更严格
+var InboundPingBurst = 10.0
+
+// InboundPingRate : 每个节点每秒允许收到的Ping数量
+var InboundPingRate = 1.0
+
+// InboundBanFailures : InboundFailureWindow内违反协议的消息达到这个数量,封禁发送方
+var InboundBanFailures = 50
+
+// InboundFailureWindow : 统计失败消息的时间窗口
+var InboundFailureWindow = time.Minute
+
+// InboundBanDuration : 第一次封禁的时长,以后每次封禁时长加倍
+var InboundBanDuration = time.Minute
+
+// InboundMaxBanDuration : 最长封禁时长,解封以后这么长时间没有再被封禁,封禁时长重新计算
+var InboundMaxBanDuration = 24 * time.Hour
+
 // SMTTokenName SMTToken名,固定
 const SMTTokenName = "SMTToken"
 
@@ -0,0 +1,235 @@
+package photon
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/notify"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+paymentPlanLoop 定时检查是否有到期的支付计划,只有节点运行的时候才会支付,
+离线期间错过的支付不会补上
+*/
+func (rs *Service) paymentPlanLoop() {
+	ticker := time.NewTicker(params.PaymentPlanCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rs.runDuePaymentPlans()
+		case <-rs.quitChan:
+			return
+		}
+	}
+}
+
+func (rs *Service) runDuePaymentPlans() {
+	if rs.StopCreateNewTransfers {
+		return
+	}
+	rs.paymentPlanLock.Lock()
+	defer rs.paymentPlanLock.Unlock()
+	ps, err := rs.dao.GetPaymentPlanList()
+	if err != nil {
+		log.Error(fmt.Sprintf("GetPaymentPlanList err %s", err))
+		return
+	}
+	now := time.Now().Unix()
+	for _, p := range ps {
+		if p.Status != models.PaymentPlanStatusActive || p.NextTime > now {
+			continue
+		}
+		rs.executePaymentPlan(p, now)
+	}
+}
+
+func (rs *Service) executePaymentPlan(p *models.PaymentPlan, now int64) {
+	attempt := &models.PaymentPlanAttempt{
+		Run:  p.Times + 1,
+		Time: now,
+	}
+	// 和TransferAsync一样短暂等待交易结果,交易记录中保存计划的Key和第几次支付
+	result := rs.transferReqClient(&transferReq{
+		TokenAddress:   p.TokenAddress,
+		Amount:         p.Amount,
+		Target:         p.TargetAddress,
+		Data:           p.Data,
+		PaymentPlanKey: p.Key,
+		PaymentPlanRun: attempt.Run,
+	})
+	attempt.LockSecretHash = result.LockSecretHash
+	if p.APITokenKey != "" {
+		if result.LockSecretHash == utils.EmptyHash {
+			rs.refundAPIToken(p.APITokenKey, p.TokenAddress, p.Amount)
+		} else {
+			rs.chargeAPIToken(p.APITokenKey, &models.APITokenCharge{
+				TokenAddress:   p.TokenAddress,
+				LockSecretHash: result.LockSecretHash,
+				Amount:         p.Amount,
+			})
+		}
+	}
+	var err error
+	select {
+	case <-time.After(300 * time.Millisecond):
+	case err = <-result.Result:
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+		rs.NotifyHandler.NotifyString(notify.LevelWarn, fmt.Sprintf("payment plan %s transfer err %s", p.Key, err))
+	}
+	log.Info(fmt.Sprintf("payment plan %s pay %s to %s,lockSecretHash=%s,err=%v", p.Key, p.Amount, utils.APex2(p.TargetAddress), attempt.LockSecretHash.String(), err))
+	p.Times++
+	p.AddAttempt(attempt)
+	if !p.ScheduleNext(now) {
+		rs.refundPaymentPlan(p)
+	}
+	err = rs.dao.SavePaymentPlan(p)
+	if err != nil {
+		log.Error(fmt.Sprintf("SavePaymentPlan key=%s err %s", p.Key, err))
+	}
+}
+
+//paymentPlanRuns 计划最多支付的次数,0表示不限制
+func paymentPlanRuns(p *models.PaymentPlan) int {
+	if p.Interval == 0 {
+		return 1
+	}
+	return p.MaxTimes
+}
+
+//refundPaymentPlan 计划结束或者取消以后,把没有支付的次数预留的金额退还给API token
+func (rs *Service) refundPaymentPlan(p *models.PaymentPlan) {
+	left := paymentPlanRuns(p) - p.Times
+	if p.APITokenKey == "" || left <= 0 {
+		return
+	}
+	rs.refundAPIToken(p.APITokenKey, p.TokenAddress, new(big.Int).Mul(p.Amount, big.NewInt(int64(left))))
+}
+
+/*
+CreatePaymentPlan 创建定时支付计划.
+startTime为0表示立即开始,interval为0表示只在startTime支付一次,
+endTime和maxTimes为0表示不限制.
+apiTokenKey不为空时,按照计划的最大支付次数预留这个API token的支付金额,不能创建不限次数的计划
+*/
+func (r *API) CreatePaymentPlan(tokenAddress, target common.Address, amount *big.Int, data string, startTime, interval, endTime int64, maxTimes int, apiTokenKey string) (p *models.PaymentPlan, err error) {
+	if amount == nil || amount.Cmp(utils.BigInt0) <= 0 {
+		err = rerr.ErrInvalidAmount
+		return
+	}
+	if len(data) > params.MaxTransferDataLen {
+		err = rerr.ErrArgumentError.Printf("invalid data, length must < %d", params.MaxTransferDataLen)
+		return
+	}
+	if interval < 0 || (interval > 0 && interval < int64(params.MinPaymentPlanInterval/time.Second)) {
+		err = rerr.ErrArgumentError.Printf("interval must be 0 or >= %s", params.MinPaymentPlanInterval)
+		return
+	}
+	if maxTimes < 0 {
+		err = rerr.ErrArgumentError.Append("max_times must not be negative")
+		return
+	}
+	now := time.Now().Unix()
+	if startTime <= 0 {
+		startTime = now
+	}
+	if endTime > 0 && endTime < startTime {
+		err = rerr.ErrArgumentError.Append("end_time must after start_time")
+		return
+	}
+	p = &models.PaymentPlan{
+		Key:           utils.NewRandomHash().String(),
+		TokenAddress:  tokenAddress,
+		TargetAddress: target,
+		Amount:        amount,
+		Data:          data,
+		StartTime:     startTime,
+		Interval:      interval,
+		EndTime:       endTime,
+		MaxTimes:      maxTimes,
+		NextTime:      startTime,
+		Status:        models.PaymentPlanStatusActive,
+		CreateTime:    now,
+		APITokenKey:   apiTokenKey,
+	}
+	if apiTokenKey != "" {
+		runs := paymentPlanRuns(p)
+		if runs == 0 {
+			err = rerr.ErrSpendingCapExceeded.Append("api token with spending cap can not create payment plan without max_times")
+			return
+		}
+		err = r.Photon.spendAPIToken(apiTokenKey, map[common.Address]*big.Int{
+			tokenAddress: new(big.Int).Mul(amount, big.NewInt(int64(runs))),
+		})
+		if err != nil {
+			return
+		}
+	}
+	err = r.Photon.dao.SavePaymentPlan(p)
+	if err != nil {
+		r.Photon.refundPaymentPlan(p)
+	}
+	return
+}
+
+// GetPaymentPlan :
+func (r *API) GetPaymentPlan(key string) (p *models.PaymentPlan, err error) {
+	return r.Photon.dao.GetPaymentPlan(key)
+}
+
+// GetPaymentPlanList :
+func (r *API) GetPaymentPlanList() (ps []*models.PaymentPlan, err error) {
+	return r.Photon.dao.GetPaymentPlanList()
+}
+
+// PausePaymentPlan 暂停支付计划,暂停期间到期的支付会被跳过
+func (r *API) PausePaymentPlan(key string) (p *models.PaymentPlan, err error) {
+	return r.updatePaymentPlanStatus(key, models.PaymentPlanStatusActive, models.PaymentPlanStatusPaused)
+}
+
+// ResumePaymentPlan 恢复暂停的支付计划
+func (r *API) ResumePaymentPlan(key string) (p *models.PaymentPlan, err error) {
+	return r.updatePaymentPlanStatus(key, models.PaymentPlanStatusPaused, models.PaymentPlanStatusActive)
+}
+
+// CancelPaymentPlan 取消支付计划,已经发出的交易不受影响
+func (r *API) CancelPaymentPlan(key string) (p *models.PaymentPlan, err error) {
+	return r.updatePaymentPlanStatus(key, "", models.PaymentPlanStatusCanceled)
+}
+
+/*
+from为空表示除了已经结束的计划,任何状态都可以转换为to
+*/
+func (r *API) updatePaymentPlanStatus(key string, from, to models.PaymentPlanStatus) (p *models.PaymentPlan, err error) {
+	r.Photon.paymentPlanLock.Lock()
+	defer r.Photon.paymentPlanLock.Unlock()
+	p, err = r.Photon.dao.GetPaymentPlan(key)
+	if err != nil {
+		return
+	}
+	if (from != "" && p.Status != from) || p.Status == models.PaymentPlanStatusCanceled || p.Status == models.PaymentPlanStatusFinished {
+		err = rerr.ErrPaymentPlanState.Printf("can not change payment plan from %s to %s", p.Status, to)
+		return
+	}
+	if to == models.PaymentPlanStatusActive && p.Interval > 0 && p.NextTime <= time.Now().Unix() {
+		// 跳过暂停期间错过的支付,只支付一次的计划恢复之后立即支付
+		if !p.ScheduleNext(time.Now().Unix()) {
+			to = models.PaymentPlanStatusFinished
+		}
+	}
+	p.Status = to
+	if to == models.PaymentPlanStatusCanceled || to == models.PaymentPlanStatusFinished {
+		r.Photon.refundPaymentPlan(p)
+	}
+	err = r.Photon.dao.SavePaymentPlan(p)
+	return
+}
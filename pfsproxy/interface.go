@@ -2,6 +2,7 @@ package pfsproxy
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/SmartMeshFoundation/Photon/models"
 	"github.com/ethereum/go-ethereum/common"
@@ -54,4 +55,9 @@ type PfsProxy interface {
 		get fee rate by channel
 	*/
 	GetChannelFee(channelIdentifier common.Hash) (feeConstant *big.Int, feePercent int64, err error)
+
+	/*
+		check whether pfs is reachable
+	*/
+	Ping(timeout time.Duration) error
 }
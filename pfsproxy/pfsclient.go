@@ -491,6 +491,29 @@ func (pfg *pfsClient) GetChannelFee(channelIdentifier common.Hash) (feeConstant
 	return resp.FeeConstant, resp.FeePercent, nil
 }
 
+/*
+Ping 查询本节点的费率,pfs返回了http响应就认为可以访问,5xx表示pfs出错了
+*/
+func (pfg *pfsClient) Ping(timeout time.Duration) (err error) {
+	if pfg.host == "" || pfg.privateKey == nil {
+		err = ErrNotInit
+		return
+	}
+	req := &req{
+		FullURL: pfg.host + "/pfs/1/account_rate/" + crypto.PubkeyToAddress(pfg.privateKey.PublicKey).String(),
+		Method:  http.MethodGet,
+		Timeout: timeout,
+	}
+	statusCode, _, err := req.Invoke()
+	if err != nil {
+		return
+	}
+	if statusCode >= http.StatusInternalServerError {
+		err = fmt.Errorf("PfgAPI Ping %s err : http status=%d", req.FullURL, statusCode)
+	}
+	return
+}
+
 func marshal(v interface{}) string {
 	p, err := json.Marshal(v)
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 
 	"time"
 
+	"sync"
 	"sync/atomic"
 
 	"math/big"
@@ -22,6 +23,7 @@ import (
 	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
 	"github.com/SmartMeshFoundation/Photon/encoding"
 	"github.com/SmartMeshFoundation/Photon/internal/rpanic"
+	"github.com/SmartMeshFoundation/Photon/internal/tracing"
 	"github.com/SmartMeshFoundation/Photon/log"
 	"github.com/SmartMeshFoundation/Photon/models"
 	"github.com/SmartMeshFoundation/Photon/network"
@@ -138,7 +140,30 @@ type Service struct {
 	EthConnectionStatus                   chan netshare.Status
 	ChanHistoryContractEventsDealComplete chan struct{}
 	BuildInfo                             *BuildInfo
-	ChanSubmitBalanceProofToPFS           chan *channel.Channel // 供submitBalanceProofToPfsLoop线程使用
+	ChanSubmitBalanceProofToPFS           chan *channel.Channel                   // 供submitBalanceProofToPfsLoop线程使用
+	idempotencyKeeper                     *idempotencyKeeper                      // 幂等key,可以在loop之外访问
+	memoBuffer                            *memoBuffer                             // 还没有对应交易的加密附言
+	paymentPlanLock                       sync.Mutex                              // 保护定时支付计划的读取和更新
+	apiTokenLock                          sync.Mutex                              // 保护API token已支付金额的更新
+	hasAPIToken                           bool                                    // 启动时加载,API token只能在节点停止的时候创建
+	spendingPolicyLock                    sync.Mutex                              // 保证支付限额的检查和交易的发起是原子的,同时保护审批状态的更新
+	auditLogLock                          sync.Mutex                              // 保证审计日志的Seq和hash链是连续的
+	reconcileLock                         sync.Mutex                              // 同时只有一个对账,保护reconcileReport
+	reconcileReport                       *ReconcileReport                        // 最近一次对账的结果
+	retentionLock                         sync.Mutex                              // 同时只有一个归档,保护retentionReport
+	retentionReport                       *RetentionReport                        // 最近一次归档的结果
+	recoveringChannels                    map[common.Hash]*models.ChannelRecovery // 正在从对方恢复的通道,只在loop中访问
+	recoveryResponses                     map[common.Hash]*recoveryPages          // 正在收集的恢复数据分页,只在loop中访问
+	stateManagerHistories                 map[common.Hash]*stateManagerHistory    // 交易状态机最近的状态变化和事件,供调试使用,只在loop中访问
+	nodePublicKeys                        map[common.Address][]byte               // 从收到的消息签名中恢复的公钥,只在loop中访问,keysend交易使用
+	alertLock                             sync.Mutex                              // 保护alerts
+	alerts                                map[string]*notify.Alert                // 当前的告警
+	alertBalanceChecking                  int32                                   // 是否正在查询余额,原子访问
+	startupComplete                       int32                                   // Start是否已经完成,原子访问
+	historyEventsComplete                 int32                                   // 启动时积压的链上事件是否已经处理完毕,原子访问
+	livenessCache                         healthReportCache                       // /healthz最近的结果
+	readinessCache                        healthReportCache                       // /readyz最近的结果
+	isNewDB                               bool                                    // 启动时数据库是空的
 }
 
 //NewPhotonService create photon service
@@ -173,6 +198,10 @@ func NewPhotonService(chain *rpc.BlockChainService, privateKey *ecdsa.PrivateKey
 		ChanHistoryContractEventsDealComplete: make(chan struct{}),
 		BuildInfo:                             new(BuildInfo),
 		ChanSubmitBalanceProofToPFS:           make(chan *channel.Channel, 100),
+		idempotencyKeeper:                     newIdempotencyKeeper(),
+		memoBuffer:                            newMemoBuffer(),
+		nodePublicKeys:                        make(map[common.Address][]byte),
+		alerts:                                make(map[string]*notify.Alert),
 	}
 	rs.BlockNumber.Store(int64(0))
 	rs.MessageHandler = newPhotonMessageHandler(rs)
@@ -221,13 +250,22 @@ func NewPhotonService(chain *rpc.BlockChainService, privateKey *ecdsa.PrivateKey
 
 // Start the node.
 func (rs *Service) Start() (err error) {
-
+	err = tracing.Start(rs.Config.TraceFile, rs.Config.TraceCollector, rs.NodeAddress)
+	if err != nil {
+		return
+	}
 	/*
 		事先从DB里面获取最后的blocknumber,以免重启后因为超时而拒绝掉之前的MediatedTransfer消息
 		Get the last block number from the DB beforehand to avoid rejecting the previous MeditatedTransfer message after restart because of timeout
 	*/
 	n := rs.dao.GetLatestBlockNumber()
 	rs.BlockNumber.Store(n)
+	rs.isNewDB = n == 0
+	ts, err := rs.dao.GetAPITokenList()
+	if err != nil {
+		return
+	}
+	rs.hasAPIToken = len(ts) > 0
 	err = rs.registerRegistry()
 	if err != nil {
 		return
@@ -278,7 +316,6 @@ func (rs *Service) Start() (err error) {
 		<-rs.ChanHistoryContractEventsDealComplete
 		log.Info(fmt.Sprintf("Photon Startup complete and history events process complete."))
 	}
-
 	/*
 		将protocol接受消息移到历史事件处理之后,
 		保证不在历史事件处理完毕之前进入事件主循环.
@@ -292,8 +329,14 @@ func (rs *Service) Start() (err error) {
 		启动定时提交balance_proof到pfs的线程
 	*/
 	go rs.submitBalanceProofToPfsLoop()
+	go rs.resumeBatchTransfers()
+	rs.resumeAPITokenCharges(ts)
+	go rs.paymentPlanLoop()
+	go rs.reconcileLoop()
+	go rs.retentionLoop()
 	//
 	rs.isStarting = false
+	atomic.StoreInt32(&rs.startupComplete, 1)
 	rs.startNeighboursHealthCheck()
 	// 只有在混合模式下启动时,才订阅其他节点的在线状态
 	// Only when starting under MixUDPXMPP, we can subscribe online status of other nodes.
@@ -313,6 +356,7 @@ func (rs *Service) Stop() {
 	rs.Chain.Client.Close()
 	rs.NotifyHandler.Stop()
 	time.Sleep(100 * time.Millisecond) // let other goroutines quit
+	tracing.Stop()
 	rs.dao.CloseDB()
 	//anther instance cann run now
 	err := rs.FileLocker.Unlock()
@@ -343,6 +387,9 @@ func (rs *Service) loop() {
 		//message from other nodes
 		case m, ok = <-rs.Protocol.ReceivedMessageChan:
 			if ok {
+				if m.SenderPublicKey != nil {
+					rs.nodePublicKeys[m.Msg.GetSender()] = m.SenderPublicKey
+				}
 				err = rs.MessageHandler.onMessage(m.Msg, m.EchoHash)
 				if err != nil {
 					log.Error(fmt.Sprintf("MessageHandler.onMessage %v", err))
@@ -363,6 +410,12 @@ func (rs *Service) loop() {
 					_, isHistoryComplete := st.(*mediatedtransfer.ContractHistoryEventCompleteStateChange)
 					if isHistoryComplete {
 						if rs.ChanHistoryContractEventsDealComplete != nil {
+							//数据库丢失以后,历史事件重建了所有通道,在接收消息之前向对方请求通道的最新状态
+							err = rs.startChannelRecovery(rs.isNewDB)
+							if err != nil {
+								log.Error(fmt.Sprintf("start channel recovery err %s", err))
+							}
+							atomic.StoreInt32(&rs.historyEventsComplete, 1)
 							close(rs.ChanHistoryContractEventsDealComplete)
 							rs.ChanHistoryContractEventsDealComplete = nil
 						} else {
@@ -476,6 +529,7 @@ func (rs *Service) handleBlockNumber(st *transfer.BlockStateChange) {
 			}
 		}
 	}
+	rs.checkAlerts(st.BlockNumber)
 	rs.dao.SaveLatestBlockNumber(st.BlockNumber)
 	return
 }
@@ -494,6 +548,17 @@ func (rs *Service) GetChannelStatus(channelIdentifier common.Hash) (int, int64)
 	return int(c.State), c.ChannelIdentifier.OpenBlockNumber
 }
 
+// HasOpenChannel returns true if we have an open channel with partner on any token
+func (rs *Service) HasOpenChannel(partner common.Address) bool {
+	for _, g := range rs.Token2ChannelGraph {
+		ch := g.GetPartenerAddress2Channel(partner)
+		if ch != nil && ch.State == channeltype.StateOpened {
+			return true
+		}
+	}
+	return false
+}
+
 func (rs *Service) findChannelByIdentifier(channelIdentifier common.Hash) (*channel.Channel, error) {
 	for _, g := range rs.Token2ChannelGraph {
 		ch := g.ChannelIdentifier2Channel[channelIdentifier]
@@ -626,15 +691,20 @@ func (rs *Service) registerChannelForHashlock(netchannel *channel.Channel, lockS
 	}
 }
 func (rs *Service) channelSerilization2Channel(c *channeltype.Serialization, tokenNetwork *rpc.TokenNetworkProxy) (ch *channel.Channel, err error) {
+	ExternState := channel.NewChannelExternalState(rs.registerChannelForHashlock, tokenNetwork,
+		c.ChannelIdentifier, rs.PrivateKey,
+		rs.Chain.Client, rs.dao, c.ClosedBlock,
+		c.OurAddress, c.PartnerAddress())
+	return serialization2Channel(c, ExternState)
+}
+
+//serialization2Channel 离线重放交易状态机的时候没有链和数据库,只需要通道的状态
+func serialization2Channel(c *channeltype.Serialization, ExternState *channel.ExternalState) (ch *channel.Channel, err error) {
 	OurState := channel.NewChannelEndState(c.OurAddress, c.OurContractBalance,
 		c.OurBalanceProof, mtree.NewMerkleTree(c.OurLeaves))
 	PartnerState := channel.NewChannelEndState(c.PartnerAddress(),
 		c.PartnerContractBalance,
 		c.PartnerBalanceProof, mtree.NewMerkleTree(c.PartnerLeaves))
-	ExternState := channel.NewChannelExternalState(rs.registerChannelForHashlock, tokenNetwork,
-		c.ChannelIdentifier, rs.PrivateKey,
-		rs.Chain.Client, rs.dao, c.ClosedBlock,
-		c.OurAddress, c.PartnerAddress())
 	ch, err = channel.NewChannel(OurState, PartnerState, ExternState, c.TokenAddress(), c.ChannelIdentifier, c.RevealTimeout, c.SettleTimeout)
 	if err != nil {
 		return
@@ -648,6 +718,7 @@ func (rs *Service) channelSerilization2Channel(c *channeltype.Serialization, tok
 	ch.OurState.ContractBalance = c.OurContractBalance
 	ch.PartnerState.ContractBalance = c.PartnerContractBalance
 	ch.ExternState.ClosedBlock = c.ClosedBlock
+	ch.ExternState.ClosingAddress = c.ClosingAddress
 	ch.ExternState.SettledBlock = c.SettledBlock
 	return
 }
@@ -771,13 +842,19 @@ func (rs *Service) directTransferAsync(tokenAddress, target common.Address, amou
 		result.Result <- err
 		return
 	}
-	tr.Data = []byte(data)
+	memos, clearData, err := rs.newEncryptedMemos(target, encoding.DirectTransferMemoID(directChannel.ChannelIdentifier.ChannelIdentifier, directChannel.ChannelIdentifier.OpenBlockNumber, tr.Nonce), data)
+	if err != nil {
+		result.Result <- err
+		return
+	}
+	tr.Data = []byte(clearData)
 	err = tr.Sign(rs.PrivateKey, tr)
 	err = directChannel.RegisterTransfer(rs.GetBlockNumber(), tr)
 	if err != nil {
 		result.Result <- err
 		return
 	}
+	rs.sendMemos(target, memos)
 	//This should be set once the direct transfer is acknowledged
 	transferSuccess := &transfer.EventTransferSentSuccess{
 		LockSecretHash:    utils.EmptyHash,
@@ -845,7 +922,8 @@ Calls:
  *			2.1 taker should contain lockSecretHash, but no secret.
  *			2.2 maker should contain lockSecretHash and secret.
  */
-func (rs *Service) startMediatedTransferInternal(tokenAddress, target common.Address, amount *big.Int, lockSecretHash common.Hash, expiration int64, secret common.Hash, data string, routeInfo []pfsproxy.FindPathResponse) (result *utils.AsyncResult, stateManager *transfer.StateManager) {
+func (rs *Service) startMediatedTransferInternal(tokenAddress, target common.Address, amount *big.Int, lockSecretHash common.Hash, expiration int64, secret common.Hash, data string, routeInfo []pfsproxy.FindPathResponse, encryptedSecret []byte, onionPublicKeys map[common.Address][]byte, onionAllowClearPath bool) (result *utils.AsyncResult, stateManager *transfer.StateManager) {
+	logCtx := utils.TransferLogCtx(lockSecretHash, utils.EmptyHash)
 	var availableRoutes []*route.State
 	//var err error
 	//targetAmount := new(big.Int).Sub(amount, fee)
@@ -855,14 +933,16 @@ func (rs *Service) startMediatedTransferInternal(tokenAddress, target common.Add
 		result.Result <- rerr.ErrTokenNotFound
 		return
 	}
+	tracing.StartTransfer(lockSecretHash, "initiate transfer", "token", tokenAddress, "target", target, "amount", amount)
+	routeSpan := tracing.StartSpan(lockSecretHash, "route lookup", tracing.KindInternal, "user_routes", len(routeInfo))
 	// 2019-03消息升级过后,如果参数没有RouteInfo,仅支持与target直接拥有通道的情况下发送交易或是在不收费的网络下使用本地路由
 	if routeInfo == nil || len(routeInfo) == 0 {
 		// 当前为不支持收费的网络下时,使用本地路由
 		if rs.PfsProxy == nil {
-			log.Trace("get available routes without fee from local channel graph")
+			log.Trace("get available routes without fee from local channel graph", logCtx...)
 			availableRoutes = g.GetBestRoutes(rs.Protocol, rs.NodeAddress, target, amount, amount, graph.EmptyExlude, rs)
 		} else {
-			log.Trace("get available routes to partner from local channel graph")
+			log.Trace("get available routes to partner from local channel graph", logCtx...)
 			ch := rs.getChannel(tokenAddress, target)
 			if ch != nil {
 				r := route.NewState(ch, []common.Address{ch.PartnerState.Address})
@@ -872,7 +952,7 @@ func (rs *Service) startMediatedTransferInternal(tokenAddress, target common.Add
 		}
 	} else {
 		// 用户指定了路由的话,采用用户指定的路由,否则从pfs或者本地查询路由
-		log.Trace("get available routes from user req")
+		log.Trace("get available routes from user req", logCtx...)
 		for _, path := range routeInfo {
 			if path.Result == nil || len(path.Result) == 0 {
 				continue
@@ -888,15 +968,37 @@ func (rs *Service) startMediatedTransferInternal(tokenAddress, target common.Add
 			availableRoutes = append(availableRoutes, r)
 		}
 	}
-	log.Trace(fmt.Sprintf("availableRoutes=%s", utils.StringInterface(availableRoutes, 3)))
+	log.Trace(fmt.Sprintf("availableRoutes=%s", utils.StringInterface(availableRoutes, 3)), logCtx...)
+	routeSpan.SetAttributes("routes", len(availableRoutes))
+	routeSpan.End()
 	if len(availableRoutes) <= 0 {
+		tracing.EndTransfer(lockSecretHash, rerr.ErrNoAvailabeRoute)
 		result.Result <- rerr.ErrNoAvailabeRoute
 		return
 	}
 	if rs.Config.IsMeshNetwork {
+		tracing.EndTransfer(lockSecretHash, rerr.ErrNotAllowMediatedTransfer)
 		result.Result <- rerr.ErrNotAllowMediatedTransfer
 		return
 	}
+	if len(encryptedSecret) > 0 {
+		availableRoutes = rs.filterKeysendRoutes(availableRoutes, true)
+		if len(availableRoutes) == 0 {
+			err := rerr.ErrKeysendNotSupported.Printf("no route to %s supports keysend", target.String())
+			tracing.EndTransfer(lockSecretHash, err)
+			result.Result <- err
+			return
+		}
+	}
+	if onionPublicKeys != nil {
+		availableRoutes = rs.attachOnion(availableRoutes, target, onionPublicKeys, onionAllowClearPath)
+		if len(availableRoutes) == 0 {
+			err := rerr.ErrOnionNotSupported.Printf("no route to %s can carry an onion", target.String())
+			tracing.EndTransfer(lockSecretHash, err)
+			result.Result <- err
+			return
+		}
+	}
 	/*
 		when user specified fee, for test or other purpose.
 	*/
@@ -908,16 +1010,17 @@ func (rs *Service) startMediatedTransferInternal(tokenAddress, target common.Add
 	//}
 	routesState := route.NewRoutesState(availableRoutes)
 	transferState := &mediatedtransfer.LockedTransferState{
-		TargetAmount:   new(big.Int).Set(amount),
-		Amount:         new(big.Int).Set(amount),
-		Token:          tokenAddress,
-		Initiator:      rs.NodeAddress,
-		Target:         target,
-		Expiration:     expiration,
-		LockSecretHash: lockSecretHash,
-		Secret:         secret,
-		Fee:            utils.BigInt0,
-		Data:           data,
+		TargetAmount:    new(big.Int).Set(amount),
+		Amount:          new(big.Int).Set(amount),
+		Token:           tokenAddress,
+		Initiator:       rs.NodeAddress,
+		Target:          target,
+		Expiration:      expiration,
+		LockSecretHash:  lockSecretHash,
+		Secret:          secret,
+		Fee:             utils.BigInt0,
+		Data:            data,
+		EncryptedSecret: encryptedSecret,
 	}
 	/*
 		发起方每次切换路径不再切换密码,不切换依然可以保证安全
@@ -933,13 +1036,15 @@ func (rs *Service) startMediatedTransferInternal(tokenAddress, target common.Add
 		Db:             rs.dao,
 	}
 	//log.Trace(fmt.Sprintf("start mediated transfer availableRoutes=%s", utils.StringInterface(availableRoutes, 2)))
-	stateManager = transfer.NewStateManager(initiator.StateTransition, nil, initiator.NameInitiatorTransition, lockSecretHash, transferState.Token)
 	smkey := utils.Sha3(lockSecretHash[:], tokenAddress[:])
 	manager := rs.Transfer2StateManager[smkey]
 	if manager != nil {
+		//重复的交易不影响进行中交易的root span
+		tracing.EndTransfer(lockSecretHash, nil)
 		result.Result <- rerr.ErrDuplicateTransfer
 		return
 	}
+	stateManager = transfer.NewStateManager(initiator.StateTransition, nil, initiator.NameInitiatorTransition, lockSecretHash, transferState.Token)
 	rs.Transfer2StateManager[smkey] = stateManager
 	rs.Transfer2Result[smkey] = result
 	//rs.dao.AddStateManager(stateManager)
@@ -968,7 +1073,7 @@ func (rs *Service) startMediatedTransfer(tokenAddress, target common.Address, am
 			return true
 		}
 		rs.SecretRequestPredictorMap[lockSecretHash] = secretRequestHook
-		log.Trace(fmt.Sprintf("Register SecretRequestPredictor for secret=[%s] lockSecretHash=[%s]\n", secret.String(), lockSecretHash.String()))
+		log.Trace(fmt.Sprintf("Register SecretRequestPredictor for secret=[%s] lockSecretHash=[%s]\n", secret.String(), lockSecretHash.String()), utils.TransferLogCtx(lockSecretHash, utils.EmptyHash)...)
 	} else {
 		/*
 			普通交易，随机生成密码
@@ -977,18 +1082,30 @@ func (rs *Service) startMediatedTransfer(tokenAddress, target common.Address, am
 		secret = utils.NewRandomHash()
 		lockSecretHash = utils.ShaSecret(secret[:])
 	}
+	// 附言加密以后单独发送给target,不再通过RevealSecret明文发送
+	memos, clearData, err := rs.newEncryptedMemos(target, lockSecretHash, data)
+	if err != nil {
+		delete(rs.SecretRequestPredictorMap, lockSecretHash)
+		result = utils.NewAsyncResult()
+		result.Result <- err
+		return
+	}
 	/*
 		发起方在这里记录发起的交易状态,后续UpdateTransferStatus会更新DB中的值
 	*/
 	rs.dao.NewSentTransferDetail(tokenAddress, target, amount, data, false, lockSecretHash)
 	//rs.dao.NewTransferStatus(tokenAddress, lockSecretHash)
-	result, _ = rs.startMediatedTransferInternal(tokenAddress, target, amount, lockSecretHash, 0, secret, data, routeInfo)
+	result, stateManager := rs.startMediatedTransferInternal(tokenAddress, target, amount, lockSecretHash, 0, secret, clearData, routeInfo, nil, nil, false)
+	if stateManager != nil {
+		rs.sendMemos(target, memos)
+	}
 	result.LockSecretHash = lockSecretHash
 	return
 }
 
-//receive a MediatedTransfer, i'm a hop node
-func (rs *Service) mediateMediatedTransfer(msg *encoding.MediatedTransfer, ch *channel.Channel) {
+//receive a MediatedTransfer, i'm a hop node. onionHop is not nil only for onion routed transfers
+func (rs *Service) mediateMediatedTransfer(msg *encoding.MediatedTransfer, ch *channel.Channel, onionHop *encoding.OnionHop) {
+	logCtx := utils.TransferLogCtx(msg.LockSecretHash, ch.ChannelIdentifier.ChannelIdentifier)
 	tokenAddress := ch.TokenAddress
 	smkey := utils.Sha3(msg.LockSecretHash[:], tokenAddress[:])
 	stateManager := rs.Transfer2StateManager[smkey]
@@ -1003,7 +1120,7 @@ func (rs *Service) mediateMediatedTransfer(msg *encoding.MediatedTransfer, ch *c
 	 *	Locks can be duplicated, like in token swap.
 	 */
 	if rs.dao.IsLockSecretHashChannelIdentifierDisposed(msg.LockSecretHash, ch.ChannelIdentifier.ChannelIdentifier) {
-		log.Error(fmt.Sprintf("receive a lock secret hash,and it's my annouce disposed. %s", msg.LockSecretHash.String()))
+		log.Error(fmt.Sprintf("receive a lock secret hash,and it's my annouce disposed. %s", msg.LockSecretHash.String()), logCtx...)
 		//忽略,什么都不做
 		// do nothing.
 		return
@@ -1014,14 +1131,17 @@ func (rs *Service) mediateMediatedTransfer(msg *encoding.MediatedTransfer, ch *c
 	fromChannel := ch
 	fromRoute := graph.Channel2RouteState(fromChannel, msg.Sender, amount, rs, msg.Path)
 	fromTransfer := mediatedtransfer.LockedTransferFromMessage(msg, ch.TokenAddress)
+	if onionHop != nil {
+		fromTransfer.Onion = onionHop.Inner
+	}
 	if stateManager != nil {
 		if stateManager.Name != mediator.NameMediatorTransition {
-			log.Error(fmt.Sprintf("receive mediator transfer,but i'm not a mediator,msg=%s,stateManager=%s", msg, utils.StringInterface(stateManager, 3)))
+			log.Error(fmt.Sprintf("receive mediator transfer,but i'm not a mediator,msg=%s,stateManager=%s", msg, utils.StringInterface(stateManager, 3)), logCtx...)
 			return
 		}
 		// 2019-03 消息升级后,仅在不收费的情况下支持重复交易
 		if rs.PfsProxy != nil {
-			log.Error(fmt.Sprintf("receive repeate mediator transfer,but i'm not a disable-fee node ,msg=%s,stateManager=%s", msg, utils.StringInterface(stateManager, 3)))
+			log.Error(fmt.Sprintf("receive repeate mediator transfer,but i'm not a disable-fee node ,msg=%s,stateManager=%s", msg, utils.StringInterface(stateManager, 3)), logCtx...)
 			return
 		}
 		stateChange := &mediatedtransfer.MediatorReReceiveStateChange{
@@ -1033,9 +1153,27 @@ func (rs *Service) mediateMediatedTransfer(msg *encoding.MediatedTransfer, ch *c
 		rs.StateMachineEventHandler.dispatch(stateManager, stateChange)
 	} else {
 		// 2019-03 消息升级后,路由以mtr中带有的path为准,有且只有一条,如果在不支持手续费的网络中,则根据本地路由继续交易
-		if len(msg.Path) == 0 {
+		if onionHop != nil {
+			// onion交易,下一跳和手续费以onion中的为准,并且不能低于自己的收费
+			nextChan := rs.getChannel(ch.TokenAddress, onionHop.NextHop)
+			if nextChan == nil {
+				log.Error(fmt.Sprintf("receive onion mediated transfer,but no channel with next hop %s", utils.APex2(onionHop.NextHop)), logCtx...)
+				return
+			}
+			availableRoute := route.NewState(nextChan, nil)
+			targetAmount := new(big.Int).Sub(msg.PaymentAmount, msg.Fee)
+			availableRoute.Fee = rs.FeePolicy.GetNodeChargeFee(nextChan.PartnerState.Address, nextChan.TokenAddress, targetAmount)
+			if onionHop.Fee != nil {
+				if onionHop.Fee.Cmp(availableRoute.Fee) < 0 {
+					log.Error(fmt.Sprintf("receive onion mediated transfer,but fee %s is less than my fee %s", onionHop.Fee, availableRoute.Fee), logCtx...)
+					return
+				}
+				availableRoute.Fee = onionHop.Fee
+			}
+			avaiableRoutes = append(avaiableRoutes, availableRoute)
+		} else if len(msg.Path) == 0 {
 			if rs.PfsProxy != nil {
-				log.Error("receive MediatedTransfer without route info,ignore")
+				log.Error("receive MediatedTransfer without route info,ignore", logCtx...)
 				return
 			}
 			exclude := graph.MakeExclude(msg.Sender, msg.Initiator)
@@ -1051,7 +1189,7 @@ func (rs *Service) mediateMediatedTransfer(msg *encoding.MediatedTransfer, ch *c
 				}
 			}
 			if myIndexInPath == -1 {
-				log.Error("can not found myself in msg.Path")
+				log.Error("can not found myself in msg.Path", logCtx...)
 				return
 			}
 			nextChan := rs.getChannel(ch.TokenAddress, msg.Path[myIndexInPath+1])
@@ -1076,6 +1214,10 @@ func (rs *Service) mediateMediatedTransfer(msg *encoding.MediatedTransfer, ch *c
 		//	//log.Trace(fmt.Sprintf("g=%s", utils.StringInterface(g, 7)))
 		//	avaiableRoutes = g.GetBestRoutes(rs.Protocol, rs.NodeAddress, targetAddr, amount, targetAmount, exclude, rs)
 		//}
+		avaiableRoutes = rs.filterMediationRoutes(msg, fromChannel, avaiableRoutes)
+		if len(msg.EncryptedSecret) > 0 {
+			avaiableRoutes = rs.filterKeysendRoutes(avaiableRoutes, false)
+		}
 		routesState := route.NewRoutesState(avaiableRoutes)
 		blockNumber := rs.GetBlockNumber()
 		initMediator := &mediatedtransfer.ActionInitMediatorStateChange{
@@ -1087,6 +1229,8 @@ func (rs *Service) mediateMediatedTransfer(msg *encoding.MediatedTransfer, ch *c
 			Message:     msg,
 			Db:          rs.dao,
 		}
+		tracing.StartTransfer(msg.LockSecretHash, "mediate transfer", "token", tokenAddress, "from", msg.Sender,
+			"amount", msg.PaymentAmount, "fee", msg.Fee, "routes", len(avaiableRoutes), "onion", onionHop != nil)
 		stateManager = transfer.NewStateManager(mediator.StateTransition, nil, mediator.NameMediatorTransition, fromTransfer.LockSecretHash, fromTransfer.Token)
 		//rs.dao.AddStateManager(stateManager)
 		rs.Transfer2StateManager[smkey] = stateManager //for path A-B-C-F-B-D-E ,node B will have two StateManagers for one identifier
@@ -1096,6 +1240,7 @@ func (rs *Service) mediateMediatedTransfer(msg *encoding.MediatedTransfer, ch *c
 
 //receive a MediatedTransfer, i'm the target
 func (rs *Service) targetMediatedTransfer(msg *encoding.MediatedTransfer, ch *channel.Channel) {
+	logCtx := utils.TransferLogCtx(msg.LockSecretHash, ch.ChannelIdentifier.ChannelIdentifier)
 	smkey := utils.Sha3(msg.LockSecretHash[:], ch.TokenAddress[:])
 	stateManager := rs.Transfer2StateManager[smkey]
 	/*
@@ -1110,27 +1255,44 @@ func (rs *Service) targetMediatedTransfer(msg *encoding.MediatedTransfer, ch *ch
 	 */
 	if rs.dao.IsLockSecretHashChannelIdentifierDisposed(msg.LockSecretHash, ch.ChannelIdentifier.ChannelIdentifier) {
 		//todo 需要通知photon用户
-		log.Error(fmt.Sprintf("receive a lock secret hash,and it's my annouce disposed. %s", msg.LockSecretHash.String()))
+		log.Error(fmt.Sprintf("receive a lock secret hash,and it's my annouce disposed. %s", msg.LockSecretHash.String()), logCtx...)
 		return
 	}
 	if stateManager != nil {
 		if stateManager.Name != target.NameTargetTransition {
-			log.Error(fmt.Sprintf("receive mediator transfer,but i'm not a target,msg=%s,stateManager=%s", msg, utils.StringInterface(stateManager, 3)))
+			log.Error(fmt.Sprintf("receive mediator transfer,but i'm not a target,msg=%s,stateManager=%s", msg, utils.StringInterface(stateManager, 3)), logCtx...)
 			return
 		}
 		log.Error(fmt.Sprintf("receive mediator transfer msg=%s,duplicate? attack?,i'm a target,and has received mediator message. statemanager=%s",
-			msg, utils.StringInterface(stateManager, 3)))
+			msg, utils.StringInterface(stateManager, 3)), logCtx...)
 		return
 	}
 	g := rs.getToken2ChannelGraph(ch.TokenAddress)
 	fromChannel := g.GetPartenerAddress2Channel(msg.Sender)
 	if fromChannel == nil {
 		log.Error(fmt.Sprintf("GetPartenerAddress2Channel returns nil ,but %s should have channel with %s on token %s",
-			utils.APex2(g.OurAddress), utils.APex2(msg.Sender), utils.APex2(g.TokenAddress)))
+			utils.APex2(g.OurAddress), utils.APex2(msg.Sender), utils.APex2(g.TokenAddress)), logCtx...)
 		return
 	}
 	fromRoute := graph.Channel2RouteState(fromChannel, msg.Sender, msg.PaymentAmount, rs, msg.Path)
 	fromTransfer := mediatedtransfer.LockedTransferFromMessage(msg, ch.TokenAddress)
+	if len(msg.EncryptedSecret) > 0 {
+		/*
+			keysend交易,解密出密码以后直接领取,解密失败则按照普通交易向发起方请求密码
+		*/
+		secret, data, err := encoding.DecryptSecret(rs.PrivateKey, msg.EncryptedSecret)
+		if err == nil && utils.ShaSecret(secret[:]) == msg.LockSecretHash {
+			fromTransfer.Secret = secret
+			fromTransfer.Data = data
+		} else {
+			log.Warn(fmt.Sprintf("receive keysend transfer %s,but secret is invalid,err=%v", msg, err), logCtx...)
+		}
+	}
+	if fromTransfer.Secret == utils.EmptyHash && msg.Initiator == utils.EmptyAddress {
+		// onion交易不知道发起方,没办法请求密码,只能等锁过期
+		log.Error(fmt.Sprintf("receive onion transfer %s,but can not get the secret", msg), logCtx...)
+		return
+	}
 	initTarget := &mediatedtransfer.ActionInitTargetStateChange{
 		OurAddress:  rs.NodeAddress,
 		FromRoute:   fromRoute,
@@ -1139,6 +1301,8 @@ func (rs *Service) targetMediatedTransfer(msg *encoding.MediatedTransfer, ch *ch
 		Message:     msg,
 		Db:          rs.dao,
 	}
+	tracing.StartTransfer(msg.LockSecretHash, "receive transfer", "token", ch.TokenAddress, "from", msg.Sender,
+		"amount", msg.PaymentAmount, "keysend", fromTransfer.Secret != utils.EmptyHash)
 	stateManager = transfer.NewStateManager(target.StateTransiton, nil, target.NameTargetTransition, fromTransfer.LockSecretHash, fromTransfer.Token)
 	//rs.dao.AddStateManager(stateManager)
 	rs.Transfer2StateManager[smkey] = stateManager
@@ -1460,7 +1624,7 @@ func (rs *Service) tokenSwapMaker(tokenswap *TokenSwap) (result *utils.AsyncResu
 	}
 	rs.SentMediatedTransferListenerMap[&sentMtrHook] = true
 	rs.ReceivedMediatedTrasnferListenerMap[&receiveMtrHook] = true
-	result, _ = rs.startMediatedTransferInternal(tokenswap.FromToken, tokenswap.ToNodeAddress, tokenswap.FromAmount, tokenswap.LockSecretHash, 0, tokenswap.Secret, "", tokenswap.RouteInfo)
+	result, _ = rs.startMediatedTransferInternal(tokenswap.FromToken, tokenswap.ToNodeAddress, tokenswap.FromAmount, tokenswap.LockSecretHash, 0, tokenswap.Secret, "", tokenswap.RouteInfo, nil, nil, false)
 	return
 }
 
@@ -1514,7 +1678,7 @@ func (rs *Service) messageTokenSwapTaker(msg *encoding.MediatedTransfer, tokensw
 		taker and maker may have direct channels on these two tokens.
 	*/
 	takerExpiration := msg.Expiration - int64(rs.Config.RevealTimeout)
-	result, stateManager := rs.startMediatedTransferInternal(tokenswap.ToToken, tokenswap.FromNodeAddress, tokenswap.ToAmount, tokenswap.LockSecretHash, takerExpiration, utils.EmptyHash, "", tokenswap.RouteInfo)
+	result, stateManager := rs.startMediatedTransferInternal(tokenswap.ToToken, tokenswap.FromNodeAddress, tokenswap.ToAmount, tokenswap.LockSecretHash, takerExpiration, utils.EmptyHash, "", tokenswap.RouteInfo, nil, nil, false)
 	if stateManager == nil {
 		log.Error(fmt.Sprintf("taker tokenwap error %s", <-result.Result))
 		return false
@@ -1701,14 +1865,24 @@ func (rs *Service) handleEthRPCConnectionOK() {
 //all user's request
 func (rs *Service) handleReq(req *apiReq) {
 	var result *utils.AsyncResult
+	if len(rs.recoveringChannels) > 0 && recoveryBlockedReqs[req.Name] {
+		result = utils.NewAsyncResultWithError(rerr.ErrChannelRecovering.Printf("%d channels are recovering", len(rs.recoveringChannels)))
+		req.result <- result
+		return
+	}
 	switch req.Name {
 	case transferReqName: //mediated transfer only
 		r := req.Req.(*transferReq)
 		if r.IsDirectTransfer {
 			result = rs.directTransferAsync(r.TokenAddress, r.Target, r.Amount, r.Data)
+		} else if r.Keysend {
+			result = rs.startKeysendTransfer(r.TokenAddress, r.Target, r.Amount, r.Data, r.TargetPublicKey, r.RouteInfo, r.OnionPublicKeys, r.OnionAllowClear)
 		} else {
 			result = rs.startMediatedTransfer(r.TokenAddress, r.Target, r.Amount, r.Secret, r.Data, r.RouteInfo)
 		}
+		if r.PaymentPlanKey != "" && result.LockSecretHash != utils.EmptyHash {
+			rs.dao.UpdateSentTransferDetailPaymentPlan(r.TokenAddress, result.LockSecretHash, r.PaymentPlanKey, r.PaymentPlanRun)
+		}
 	case newChannelReqName:
 		r := req.Req.(*newChannelReq)
 		if r.amount != nil && r.amount.Cmp(utils.BigInt0) > 0 {
@@ -1764,6 +1938,17 @@ func (rs *Service) handleReq(req *apiReq) {
 	case forceUnlockReqName:
 		r := req.Req.(*forceUnlockReq)
 		result = rs.forceUnlock(r)
+	case repairDepositReqName:
+		r := req.Req.(*repairDepositReq)
+		result = rs.repairDeposit(r)
+	case channelRecoveryReqName:
+		result = rs.sendChannelRecoveryRequests()
+	case abandonChannelRecoveryReqName:
+		r := req.Req.(*abandonChannelRecoveryReq)
+		result = rs.abandonChannelRecovery(r)
+	case debugStateManagerReqName:
+		r := req.Req.(*debugStateManagerReq)
+		result = rs.debugStateManager(r)
 	default:
 		panic("unkown req")
 	}
@@ -2142,6 +2327,7 @@ func (rs *Service) registerSecretToStateManagerFromUser(req *registerSecretReq)
 	// register secret in state manager
 	state.FromTransfer.Secret = secret
 	state.Secret = secret
+	rs.saveStateManagerSnapshot(manager)
 	result.Result <- nil
 	return
 }
@@ -230,6 +230,27 @@ func (r *API) StartHealthCheckFor(nodeAddress common.Address) (deviceType string
 	return r.GetNodeNetworkState(nodeAddress)
 }
 
+//PeerBans 因为发送太多消息或者无效消息被限制的节点
+type PeerBans struct {
+	Peers           []*network.PeerBan `json:"peers"`
+	InvalidMessages int                `json:"invalid_messages"` // 无法确定发送方的无效消息数量
+}
+
+//GetPeerBans returns peers which are rate limited or banned
+func (r *API) GetPeerBans() *PeerBans {
+	bans, invalidMessages := r.Photon.Protocol.GetPeerBans()
+	return &PeerBans{bans, invalidMessages}
+}
+
+//LiftPeerBan lift the ban of a peer and forget its failures
+func (r *API) LiftPeerBan(addr common.Address) error {
+	if !r.Photon.Protocol.LiftPeerBan(addr) {
+		return rerr.ErrNotFound.Printf("peer %s is not banned", addr.String())
+	}
+	log.Info(fmt.Sprintf("lift ban of %s", utils.APex2(addr)))
+	return nil
+}
+
 //GetTokenList returns all available tokens
 func (r *API) GetTokenList() (tokens []common.Address) {
 	tokensmap, err := r.Photon.dao.GetAllTokens()
@@ -1192,6 +1213,7 @@ type IncomeDetail struct {
 	Data      string   `json:"data"`
 	Type      string   `json:"type"` // 0=转账收益 1-手续费收益
 	TimeStamp int64    `json:"time_stamp"`
+	Archived  int      `json:"archived,omitempty"` // 大于0表示这是已经归档的记录按小时的汇总,TimeStamp是这个小时开始的时间
 }
 
 type incomeDetailSorter []*IncomeDetail
@@ -1243,6 +1265,29 @@ func (r *API) GetIncomeDetails(tokenAddress common.Address, fromTime, toTime int
 			TimeStamp: f.Timestamp,
 		})
 	}
+	aggregates, err := r.Photon.dao.GetIncomeAggregateList(tokenAddress, fromTime, toTime)
+	if err != nil {
+		err = rerr.ErrGeneralDBError.Append(err.Error())
+		return
+	}
+	for _, a := range aggregates {
+		if a.TransferCount > 0 {
+			list = append(list, &IncomeDetail{
+				Amount:    a.TransferAmount,
+				Type:      incomeTypeTransfer,
+				TimeStamp: a.Hour,
+				Archived:  a.TransferCount,
+			})
+		}
+		if a.FeeCount > 0 {
+			list = append(list, &IncomeDetail{
+				Amount:    a.FeeAmount,
+				Type:      incomeTypeFee,
+				TimeStamp: a.Hour,
+				Archived:  a.FeeCount,
+			})
+		}
+	}
 	// 排序,并裁剪至limit
 	sort.Stable(incomeDetailSorter(list))
 	if limit > 0 && len(list) > limit {
@@ -0,0 +1,69 @@
+package photon
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/transfer"
+	"github.com/SmartMeshFoundation/Photon/transfer/mediatedtransfer"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+savePaymentReceipt 发起方把密码告诉target的时候,保存target签名的SecretRequest,
+交易成功以后可以据此导出支付凭证.
+keysend交易target不会发送SecretRequest,所以没有支付凭证
+*/
+func (rs *Service) savePaymentReceipt(event *mediatedtransfer.EventSendRevealSecret, stateManager *transfer.StateManager) {
+	sr, ok := stateManager.LastReceivedMessage.(*encoding.SecretRequest)
+	if !ok || sr.Sender != event.Receiver || sr.LockSecretHash != event.LockSecretHash {
+		return
+	}
+	r := &models.PaymentReceipt{
+		TokenAddress:     event.Token,
+		InitiatorAddress: rs.NodeAddress,
+		TargetAddress:    sr.Sender,
+		Amount:           sr.PaymentAmount,
+		LockSecretHash:   event.LockSecretHash,
+		Secret:           event.Secret,
+		SecretRequest:    sr.Pack(),
+		Data:             event.Data,
+	}
+	err := rs.dao.SavePaymentReceipt(r)
+	if err != nil {
+		log.Error(fmt.Sprintf("SavePaymentReceipt lockSecretHash=%s err %s", r.LockSecretHash.String(), err))
+	}
+}
+
+/*
+GetPaymentReceipt 导出已经成功的交易的支付凭证,凭证由本节点签名,可以交给第三方验证
+*/
+func (r *API) GetPaymentReceipt(tokenAddress common.Address, lockSecretHash common.Hash) (receipt *models.PaymentReceipt, err error) {
+	std, err := r.Photon.dao.GetSentTransferDetail(tokenAddress, lockSecretHash)
+	if err != nil {
+		return
+	}
+	if std.Status != models.TransferStatusSuccess {
+		err = rerr.ErrPaymentReceiptNotReady.Printf("transfer status is %d", std.Status)
+		return
+	}
+	receipt, err = r.Photon.dao.GetPaymentReceipt(tokenAddress, lockSecretHash)
+	if err == rerr.ErrNotFound {
+		err = rerr.ErrPaymentReceiptNotReady.Append("no SecretRequest of target,keysend or direct transfer has no receipt")
+		return
+	}
+	if err != nil {
+		return
+	}
+	receipt.Timestamp = std.FinishTime
+	err = receipt.Sign(r.Photon.PrivateKey)
+	return
+}
+
+// VerifyPaymentReceipt 离线验证支付凭证,不需要查询本地数据
+func (r *API) VerifyPaymentReceipt(receipt *models.PaymentReceipt) error {
+	return receipt.Verify()
+}
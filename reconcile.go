@@ -0,0 +1,304 @@
+package photon
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/notify"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// 合约中通道的状态
+const (
+	chainChannelStateSettled = 0 // 不存在或者已经settle
+	chainChannelStateOpened  = 1
+	chainChannelStateClosed  = 2
+)
+
+/*
+reconcileChainStates 本地通道状态允许的链上状态,
+正在进行的链上操作可能已经完成,事件还没有处理
+*/
+var reconcileChainStates = map[channeltype.State][]uint8{
+	channeltype.StateOpened:                      {chainChannelStateOpened},
+	channeltype.StateClosed:                      {chainChannelStateClosed},
+	channeltype.StateClosing:                     {chainChannelStateOpened, chainChannelStateClosed},
+	channeltype.StateSettling:                    {chainChannelStateClosed, chainChannelStateSettled},
+	channeltype.StateWithdraw:                    {chainChannelStateOpened},
+	channeltype.StateCooprativeSettle:            {chainChannelStateOpened, chainChannelStateSettled},
+	channeltype.StatePrepareForCooperativeSettle: {chainChannelStateOpened},
+	channeltype.StatePrepareForWithdraw:          {chainChannelStateOpened},
+	channeltype.StateError:                       {chainChannelStateOpened, chainChannelStateClosed},
+	channeltype.StatePartnerCooperativeSettling:  {chainChannelStateOpened, chainChannelStateSettled},
+	channeltype.StatePartnerWithdrawing:          {chainChannelStateOpened},
+}
+
+// 对账比较的字段
+const (
+	ReconcileFieldState           = "state"
+	ReconcileFieldOpenBlockNumber = "open_block_number"
+	ReconcileFieldSettleTimeout   = "settle_timeout"
+	ReconcileFieldOurDeposit      = "our_deposit"
+	ReconcileFieldPartnerDeposit  = "partner_deposit"
+	ReconcileFieldOurNonce        = "our_nonce"
+	ReconcileFieldPartnerNonce    = "partner_nonce"
+)
+
+//ReconcileDivergence 本地通道和链上不一致的一个字段
+type ReconcileDivergence struct {
+	ChannelIdentifier common.Hash    `json:"channel_identifier"`
+	OpenBlockNumber   int64          `json:"open_block_number"`
+	TokenAddress      common.Address `json:"token_address"`
+	PartnerAddress    common.Address `json:"partner_address"`
+	Field             string         `json:"field"`
+	Local             string         `json:"local"`
+	OnChain           string         `json:"on_chain"`
+	FirstSeen         int64          `json:"first_seen"`
+	Confirmed         bool           `json:"confirmed"` // 连续两次对账都不一致,不是因为链上事件还没有处理
+	Repaired          bool           `json:"repaired"`
+}
+
+func (d *ReconcileDivergence) key() string {
+	return d.ChannelIdentifier.String() + d.Field + d.Local + d.OnChain
+}
+
+//ReconcileReport 一次对账的结果
+type ReconcileReport struct {
+	StartTime   int64                  `json:"start_time"`
+	EndTime     int64                  `json:"end_time"`
+	BlockNumber int64                  `json:"block_number"` // 对账开始时本地处理到的块
+	Channels    int                    `json:"channels"`
+	Errors      []string               `json:"errors,omitempty"` // 查询失败的通道
+	Divergences []*ReconcileDivergence `json:"divergences"`
+}
+
+/*
+reconcileLoop 定时和合约中的通道信息对账,
+查询合约只能用最新的块,本地的事件处理会有延迟,所以只有连续两次都不一致才确认
+*/
+func (rs *Service) reconcileLoop() {
+	if rs.Config.ReconcileInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(rs.Config.ReconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_, err := rs.reconcile()
+			if err != nil {
+				log.Warn(fmt.Sprintf("reconcile err %s", err))
+			}
+		case <-rs.quitChan:
+			return
+		}
+	}
+}
+
+/*
+reconcile 检查所有没有settle的通道,和上一次的结果比较确认不一致,
+打开ReconcileRepair时修复确认的漏掉的存款
+*/
+func (rs *Service) reconcile() (report *ReconcileReport, err error) {
+	if !rs.Chain.Client.IsConnected() {
+		err = rerr.ErrSpectrumNotConnected
+		return
+	}
+	rs.reconcileLock.Lock()
+	defer rs.reconcileLock.Unlock()
+	cs, err := rs.dao.GetChannelList(utils.EmptyAddress, utils.EmptyAddress)
+	if err != nil {
+		return
+	}
+	report = &ReconcileReport{
+		StartTime:   time.Now().Unix(),
+		BlockNumber: rs.GetBlockNumber(),
+		Divergences: []*ReconcileDivergence{},
+	}
+	prev := make(map[string]*ReconcileDivergence)
+	if rs.reconcileReport != nil {
+		for _, d := range rs.reconcileReport.Divergences {
+			prev[d.key()] = d
+		}
+	}
+	for _, c := range cs {
+		if c.State == channeltype.StateSettled || c.State == channeltype.StateInValid {
+			continue
+		}
+		report.Channels++
+		ds, err2 := rs.reconcileChannel(c)
+		if err2 != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", c.ChannelIdentifier.ChannelIdentifier.String(), err2))
+			continue
+		}
+		for _, d := range ds {
+			d.FirstSeen = report.StartTime
+			if p, ok := prev[d.key()]; ok {
+				d.FirstSeen = p.FirstSeen
+				d.Confirmed = true
+				d.Repaired = p.Repaired
+			}
+			if d.Confirmed && !d.Repaired {
+				rs.handleReconcileDivergence(c, d)
+			}
+			report.Divergences = append(report.Divergences, d)
+		}
+	}
+	report.EndTime = time.Now().Unix()
+	rs.reconcileReport = report
+	return
+}
+
+func (rs *Service) reconcileChannel(c *channeltype.Serialization) (ds []*ReconcileDivergence, err error) {
+	token := c.TokenAddress()
+	partner := c.PartnerAddress()
+	tokenNetwork, err := rs.Chain.TokenNetwork(token)
+	if err != nil {
+		return
+	}
+	_, _, openBlockNumber, state, settleTimeout, err := tokenNetwork.GetChannelInfo(c.OurAddress, partner)
+	if err != nil {
+		return
+	}
+	add := func(field, local, onChain string) {
+		ds = append(ds, &ReconcileDivergence{
+			ChannelIdentifier: c.ChannelIdentifier.ChannelIdentifier,
+			OpenBlockNumber:   c.ChannelIdentifier.OpenBlockNumber,
+			TokenAddress:      token,
+			PartnerAddress:    partner,
+			Field:             field,
+			Local:             local,
+			OnChain:           onChain,
+		})
+	}
+	allowed := false
+	for _, s := range reconcileChainStates[c.State] {
+		if s == state {
+			allowed = true
+		}
+	}
+	if !allowed {
+		add(ReconcileFieldState, c.State.String(), strconv.Itoa(int(state)))
+	}
+	if state == chainChannelStateSettled {
+		//链上已经没有这个通道了,其他字段都没有意义
+		return
+	}
+	if int64(openBlockNumber) != c.ChannelIdentifier.OpenBlockNumber {
+		//withdraw或者重新打开以后是另一个通道,存款也不能比较
+		add(ReconcileFieldOpenBlockNumber, strconv.FormatInt(c.ChannelIdentifier.OpenBlockNumber, 10), strconv.FormatUint(openBlockNumber, 10))
+		return
+	}
+	if int(settleTimeout) != c.SettleTimeout {
+		add(ReconcileFieldSettleTimeout, strconv.Itoa(c.SettleTimeout), strconv.FormatUint(settleTimeout, 10))
+	}
+	check := func(participant, other common.Address, depositField, nonceField string, localDeposit *big.Int, localNonce uint64) error {
+		deposit, _, nonce, err2 := tokenNetwork.GetChannelParticipantInfo(participant, other)
+		if err2 != nil {
+			return err2
+		}
+		if localDeposit == nil {
+			localDeposit = utils.BigInt0
+		}
+		if deposit.Cmp(localDeposit) != 0 {
+			add(depositField, localDeposit.String(), deposit.String())
+		}
+		//链上的balance proof是对方提交的,不会比本地的新
+		if nonce > localNonce {
+			add(nonceField, strconv.FormatUint(localNonce, 10), strconv.FormatUint(nonce, 10))
+		}
+		return nil
+	}
+	err = check(c.OurAddress, partner, ReconcileFieldOurDeposit, ReconcileFieldOurNonce, c.OurContractBalance, c.OurBalanceProof.Nonce)
+	if err != nil {
+		return
+	}
+	err = check(partner, c.OurAddress, ReconcileFieldPartnerDeposit, ReconcileFieldPartnerNonce, c.PartnerContractBalance, c.PartnerBalanceProof.Nonce)
+	return
+}
+
+/*
+handleReconcileDivergence 确认的不一致只通知一次,
+只有通道打开时链上存款比本地多是可以安全修复的,其他的需要人工处理
+*/
+func (rs *Service) handleReconcileDivergence(c *channeltype.Serialization, d *ReconcileDivergence) {
+	log.Warn(fmt.Sprintf("channel %s diverges from chain, %s local=%s onchain=%s", d.ChannelIdentifier.String(), d.Field, d.Local, d.OnChain))
+	if !rs.Config.ReconcileRepair || c.State != channeltype.StateOpened ||
+		(d.Field != ReconcileFieldOurDeposit && d.Field != ReconcileFieldPartnerDeposit) {
+		rs.NotifyHandler.NotifyString(notify.LevelWarn, fmt.Sprintf("通道%s和链上不一致,%s 本地=%s 链上=%s", utils.HPex(d.ChannelIdentifier), d.Field, d.Local, d.OnChain))
+		return
+	}
+	local, _ := new(big.Int).SetString(d.Local, 10)
+	onChain, _ := new(big.Int).SetString(d.OnChain, 10)
+	if local == nil || onChain == nil || onChain.Cmp(local) <= 0 {
+		rs.NotifyHandler.NotifyString(notify.LevelWarn, fmt.Sprintf("通道%s和链上不一致,%s 本地=%s 链上=%s", utils.HPex(d.ChannelIdentifier), d.Field, d.Local, d.OnChain))
+		return
+	}
+	participant := c.OurAddress
+	if d.Field == ReconcileFieldPartnerDeposit {
+		participant = d.PartnerAddress
+	}
+	result := rs.repairDepositClient(&mediatedtransfer.ContractBalanceStateChange{
+		ChannelIdentifier:  d.ChannelIdentifier,
+		ParticipantAddress: participant,
+		Balance:            onChain,
+		BlockNumber:        rs.GetBlockNumber(),
+	}, d.OpenBlockNumber)
+	err := <-result.Result
+	if err != nil {
+		log.Error(fmt.Sprintf("repair deposit of channel %s err %s", d.ChannelIdentifier.String(), err))
+		return
+	}
+	d.Repaired = true
+	log.Info(fmt.Sprintf("repaired missed deposit of channel %s, %s %s->%s", d.ChannelIdentifier.String(), d.Field, d.Local, d.OnChain))
+	rs.NotifyHandler.NotifyString(notify.LevelInfo, fmt.Sprintf("通道%s补上了漏掉的存款,%s %s->%s", utils.HPex(d.ChannelIdentifier), d.Field, d.Local, d.OnChain))
+}
+
+/*
+repairDeposit 在主循环中按照链上事件的处理方式更新存款,
+通道已经变化或者事件已经处理过的时候不修改
+*/
+func (rs *Service) repairDeposit(req *repairDepositReq) (result *utils.AsyncResult) {
+	result = utils.NewAsyncResult()
+	st := req.StateChange
+	ch := rs.getChannelWithAddr(st.ChannelIdentifier)
+	if ch == nil {
+		result.Result <- rerr.ErrChannelNotFound.Printf("can not find channel %s", st.ChannelIdentifier.String())
+		return
+	}
+	if ch.State != channeltype.StateOpened || ch.ChannelIdentifier.OpenBlockNumber != req.OpenBlockNumber {
+		result.Result <- rerr.ErrChannelState.Printf("channel %s has changed", st.ChannelIdentifier.String())
+		return
+	}
+	endState, err := ch.GetStateFor(st.ParticipantAddress)
+	if err != nil {
+		result.Result <- err
+		return
+	}
+	if endState.ContractBalance.Cmp(st.Balance) >= 0 {
+		result.Result <- nil
+		return
+	}
+	result.Result <- rs.StateMachineEventHandler.handleBalance(st)
+	return
+}
+
+// GetReconcileReport 最近一次对账的结果,还没有对账时返回nil
+func (r *API) GetReconcileReport() *ReconcileReport {
+	rs := r.Photon
+	rs.reconcileLock.Lock()
+	defer rs.reconcileLock.Unlock()
+	return rs.reconcileReport
+}
+
+// Reconcile 立即对账一次
+func (r *API) Reconcile() (report *ReconcileReport, err error) {
+	return r.Photon.reconcile()
+}
@@ -0,0 +1,478 @@
+package photon
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/channel"
+	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
+	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/network/rpc/contracts"
+	"github.com/SmartMeshFoundation/Photon/notify"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/transfer"
+	"github.com/SmartMeshFoundation/Photon/transfer/mtree"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+数据库丢失以后,链上事件只能重建通道的存款和状态,双方的balance proof和锁都没有了,
+这时候关闭通道会使用过期的balance proof.
+启动时数据库是空的,历史事件处理完毕以后,为每一个没有settle的通道向对方请求双方签名过的最新balance proof和锁,
+校验签名,nonce和locksroot以后重建通道.只要还有通道没有恢复,就不发起也不接受任何交易和通道操作.
+*/
+
+// 恢复期间不能执行的用户请求
+var recoveryBlockedReqs = map[string]bool{
+	transferReqName:                    true,
+	closeChannelReqName:                true,
+	settleChannelReqName:               true,
+	cooperativeSettleChannelReqName:    true,
+	prepareForCooperativeSettleReqName: true,
+	withdrawReqName:                    true,
+	prepareWithdrawReqName:             true,
+	tokenSwapMakerReqName:              true,
+	tokenSwapTakerReqName:              true,
+	forceUnlockReqName:                 true,
+}
+
+/*
+startChannelRecovery 在主循环中历史事件处理完毕的时候调用,这时候链上已有的通道都已经加载.
+启动时公链已经连接的话,这发生在开始接收消息之前.
+isNewDB表示启动时数据库是空的,需要为链上已有的通道创建恢复记录,
+否则只继续上次没有完成的恢复
+*/
+func (rs *Service) startChannelRecovery(isNewDB bool) (err error) {
+	if isNewDB {
+		err = rs.createChannelRecoveries()
+		if err != nil {
+			return
+		}
+	}
+	result := rs.sendChannelRecoveryRequests()
+	err = <-result.Result
+	if err != nil {
+		return
+	}
+	if result.Tag.(int) > 0 {
+		go rs.channelRecoveryLoop()
+	}
+	return
+}
+
+func (rs *Service) createChannelRecoveries() error {
+	cs, err := rs.dao.GetChannelList(utils.EmptyAddress, utils.EmptyAddress)
+	if err != nil {
+		return err
+	}
+	for _, c := range cs {
+		if c.State == channeltype.StateSettled || c.State == channeltype.StateInValid {
+			continue
+		}
+		r := &models.ChannelRecovery{
+			ChannelIdentifier: c.ChannelIdentifier.ChannelIdentifier,
+			OpenBlockNumber:   c.ChannelIdentifier.OpenBlockNumber,
+			TokenAddress:      c.TokenAddress(),
+			PartnerAddress:    c.PartnerAddress(),
+			Status:            models.ChannelRecoveryStatusPending,
+			StartTime:         time.Now().Unix(),
+		}
+		err = rs.queryRecoveryChainNonces(r, c.OurAddress)
+		if err != nil {
+			//收到对方的回复时再查询
+			log.Warn(fmt.Sprintf("query nonce of channel %s on chain err %s", r.ChannelIdentifier.String(), err))
+		}
+		err = rs.dao.SaveChannelRecovery(r)
+		if err != nil {
+			return err
+		}
+		log.Info(fmt.Sprintf("database is empty, recover channel %s from partner %s", r.ChannelIdentifier.String(), utils.APex2(r.PartnerAddress)))
+	}
+	return nil
+}
+
+/*
+queryRecoveryChainNonces 通道关闭以后链上记录了对方提交的balance proof,恢复的不能比它旧.
+查询失败的时候ChainNonceKnown为false,不能用nonce为0代替
+*/
+func (rs *Service) queryRecoveryChainNonces(r *models.ChannelRecovery, ourAddress common.Address) error {
+	tokenNetwork, err := rs.Chain.TokenNetwork(r.TokenAddress)
+	if err != nil {
+		return err
+	}
+	_, _, ourNonce, err := tokenNetwork.GetChannelParticipantInfo(ourAddress, r.PartnerAddress)
+	if err != nil {
+		return err
+	}
+	_, _, partnerNonce, err := tokenNetwork.GetChannelParticipantInfo(r.PartnerAddress, ourAddress)
+	if err != nil {
+		return err
+	}
+	r.ChainOurNonce = ourNonce
+	r.ChainPartnerNonce = partnerNonce
+	r.ChainNonceKnown = true
+	return nil
+}
+
+/*
+channelRecoveryLoop 定时给还没有回复的对方重新发送请求,全部恢复以后退出
+*/
+func (rs *Service) channelRecoveryLoop() {
+	ticker := time.NewTicker(params.RecoveryRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			result := rs.channelRecoveryClient()
+			err := <-result.Result
+			if err != nil {
+				log.Error(fmt.Sprintf("send recovery request err %s", err))
+				continue
+			}
+			if result.Tag.(int) == 0 {
+				return
+			}
+		case <-rs.quitChan:
+			return
+		}
+	}
+}
+
+/*
+sendChannelRecoveryRequests 在主循环中运行,第一次调用时从数据库加载没有完成的恢复,
+向对方发送请求,Tag是还没有完成恢复的通道数
+*/
+func (rs *Service) sendChannelRecoveryRequests() (result *utils.AsyncResult) {
+	result = utils.NewAsyncResult()
+	if rs.recoveringChannels == nil {
+		rs.recoveringChannels = make(map[common.Hash]*models.ChannelRecovery)
+		rs.recoveryResponses = make(map[common.Hash]*recoveryPages)
+		rcs, err := rs.dao.GetChannelRecoveryList()
+		if err != nil {
+			result.Result <- err
+			return
+		}
+		for _, r := range rcs {
+			if r.Status == models.ChannelRecoveryStatusPending {
+				rs.recoveringChannels[r.ChannelIdentifier] = r
+			}
+		}
+	}
+	for _, r := range rs.recoveringChannels {
+		req := encoding.NewRecoveryRequest(r.ChannelIdentifier, r.OpenBlockNumber)
+		err := req.Sign(rs.PrivateKey, req)
+		if err != nil {
+			result.Result <- err
+			return
+		}
+		err = rs.sendAsync(r.PartnerAddress, req)
+		if err != nil {
+			log.Error(fmt.Sprintf("send %s to %s err %s", req, utils.APex2(r.PartnerAddress), err))
+		}
+	}
+	result.Tag = len(rs.recoveringChannels)
+	result.Result <- nil
+	return
+}
+
+/*
+checkRecoveringMessage 恢复期间不接受新的交易,正在恢复的通道不接受任何改变通道状态的消息,
+返回错误时不会回复ack,对方会重发
+*/
+func (rs *Service) checkRecoveringMessage(msg encoding.SignedMessager) error {
+	if len(rs.recoveringChannels) == 0 {
+		return nil
+	}
+	var channelIdentifier common.Hash
+	switch m := msg.(type) {
+	case *encoding.MediatedTransfer, *encoding.DirectTransfer:
+		return rerr.ErrChannelRecovering.Printf("refuse %s", msg.Name())
+	case encoding.EnvelopMessager:
+		channelIdentifier = m.GetEnvelopMessage().ChannelIdentifier
+	case *encoding.SettleRequest:
+		channelIdentifier = m.ChannelIdentifier
+	case *encoding.WithdrawRequest:
+		channelIdentifier = m.ChannelIdentifier
+	default:
+		return nil
+	}
+	if rs.recoveringChannels[channelIdentifier] != nil {
+		return rerr.ErrChannelRecovering.Printf("refuse %s on channel %s", msg.Name(), utils.HPex(channelIdentifier))
+	}
+	return nil
+}
+
+func newRecoveryBalanceProof(bp *transfer.BalanceProofState) *encoding.RecoveryBalanceProof {
+	p := &encoding.RecoveryBalanceProof{
+		Nonce:          bp.Nonce,
+		TransferAmount: bp.TransferAmount,
+		Locksroot:      bp.LocksRoot,
+		MessageHash:    bp.MessageHash,
+		Signature:      bp.Signature,
+	}
+	if p.TransferAmount == nil {
+		p.TransferAmount = new(big.Int)
+	}
+	return p
+}
+
+/*
+handleRecoveryRequest 对方丢失了数据,把双方签名过的最新balance proof和锁发给对方.
+这些数据对方本来就有,发送给对方没有任何风险
+*/
+func (rs *Service) handleRecoveryRequest(req *encoding.RecoveryRequest) error {
+	ch := rs.getChannelWithAddr(req.ChannelIdentifier)
+	if ch == nil || ch.PartnerState.Address != req.Sender {
+		return rerr.ErrChannelNotFound.Printf("receive %s,but channel not found", req)
+	}
+	var err error
+	switch {
+	case ch.ChannelIdentifier.OpenBlockNumber != req.OpenBlockNumber:
+		err = rerr.ErrChannelNotFound.Printf("open block number is %d", ch.ChannelIdentifier.OpenBlockNumber)
+	case rs.recoveringChannels[req.ChannelIdentifier] != nil:
+		err = rerr.ErrChannelRecovering.Append("partner is recovering too")
+	}
+	var ms []*encoding.RecoveryResponse
+	if err != nil {
+		errorCode, errorMsg := rerr.ErrUnknown.ErrorCode, err.Error()
+		if e2, ok := err.(rerr.StandardError); ok {
+			errorCode, errorMsg = e2.ErrorCode, e2.ErrorMsg
+		}
+		ms = append(ms, encoding.NewErrorRecoveryResponse(req, errorCode, errorMsg))
+	} else {
+		ms = encoding.NewRecoveryResponses(req.ChannelIdentifier, req.OpenBlockNumber,
+			newRecoveryBalanceProof(ch.PartnerState.BalanceProofState), newRecoveryBalanceProof(ch.OurState.BalanceProofState),
+			ch.PartnerState.Tree.Leaves, ch.OurState.Tree.Leaves)
+	}
+	for _, m := range ms {
+		err = m.Sign(rs.PrivateKey, m)
+		if err != nil {
+			return err
+		}
+		err = rs.sendAsync(req.Sender, m)
+		if err != nil {
+			return err
+		}
+	}
+	log.Info(fmt.Sprintf("send recovery data of channel %s to %s", utils.HPex(req.ChannelIdentifier), utils.APex2(req.Sender)))
+	return nil
+}
+
+/*
+handleRecoveryResponse 收齐所有分页以后校验并恢复通道,
+校验失败的时候记录原因,等待下次请求
+*/
+func (rs *Service) handleRecoveryResponse(m *encoding.RecoveryResponse) error {
+	r := rs.recoveringChannels[m.ChannelIdentifier]
+	if r == nil || r.PartnerAddress != m.Sender || r.OpenBlockNumber != m.OpenBlockNumber {
+		log.Warn(fmt.Sprintf("receive unexpected %s", m))
+		return nil
+	}
+	if m.ErrorCode != 0 {
+		return rs.channelRecoveryFailed(r, fmt.Errorf("partner refused: %d %s", m.ErrorCode, m.ErrorMsg))
+	}
+	pages := rs.recoveryResponses[m.ChannelIdentifier]
+	if pages == nil || !pages.match(m) {
+		//第一个到达的分页不一定是Index为0的,对方在两次回复之间更新了通道时只保留新的
+		pages = newRecoveryPages(m)
+		rs.recoveryResponses[m.ChannelIdentifier] = pages
+	}
+	ourLocks, partnerLocks, ok := pages.add(m)
+	if !ok {
+		return nil
+	}
+	delete(rs.recoveryResponses, m.ChannelIdentifier)
+	err := rs.recoverChannel(r, m.RequesterProof, m.ResponderProof, ourLocks, partnerLocks)
+	if err != nil {
+		return rs.channelRecoveryFailed(r, err)
+	}
+	r.Status = models.ChannelRecoveryStatusRecovered
+	r.FinishTime = time.Now().Unix()
+	r.OurNonce = m.RequesterProof.Nonce
+	r.PartnerNonce = m.ResponderProof.Nonce
+	r.Locks = len(ourLocks) + len(partnerLocks)
+	r.Error = ""
+	delete(rs.recoveringChannels, r.ChannelIdentifier)
+	log.Info(fmt.Sprintf("channel %s recovered from partner,our nonce=%d,partner nonce=%d,locks=%d",
+		r.ChannelIdentifier.String(), r.OurNonce, r.PartnerNonce, r.Locks))
+	rs.NotifyHandler.NotifyString(notify.LevelInfo, fmt.Sprintf("通道%s已经从对方恢复", utils.HPex(r.ChannelIdentifier)))
+	return rs.dao.SaveChannelRecovery(r)
+}
+
+/*
+recoveryPages 收集同一个通道的恢复数据分页,
+Total和双方的balance proof来自第一个到达的分页,后续分页必须和它一致
+*/
+type recoveryPages struct {
+	Total          uint16
+	RequesterProof *encoding.RecoveryBalanceProof
+	ResponderProof *encoding.RecoveryBalanceProof
+	Pages          []*encoding.RecoveryResponse
+}
+
+func newRecoveryPages(m *encoding.RecoveryResponse) *recoveryPages {
+	return &recoveryPages{
+		Total:          m.Total,
+		RequesterProof: m.RequesterProof,
+		ResponderProof: m.ResponderProof,
+		Pages:          make([]*encoding.RecoveryResponse, m.Total),
+	}
+}
+
+func (p *recoveryPages) match(m *encoding.RecoveryResponse) bool {
+	return p.Total == m.Total && p.RequesterProof.Equal(m.RequesterProof) && p.ResponderProof.Equal(m.ResponderProof)
+}
+
+//add 保存一个分页,收齐以后按Index顺序返回双方的锁
+func (p *recoveryPages) add(m *encoding.RecoveryResponse) (requesterLocks, responderLocks []*mtree.Lock, complete bool) {
+	if int(m.Index) >= len(p.Pages) {
+		return
+	}
+	p.Pages[m.Index] = m
+	for _, page := range p.Pages {
+		if page == nil {
+			return nil, nil, false
+		}
+		requesterLocks = append(requesterLocks, page.RequesterLocks...)
+		responderLocks = append(responderLocks, page.ResponderLocks...)
+	}
+	complete = true
+	return
+}
+
+func (rs *Service) channelRecoveryFailed(r *models.ChannelRecovery, err error) error {
+	log.Warn(fmt.Sprintf("recover channel %s err %s", r.ChannelIdentifier.String(), err))
+	r.Error = err.Error()
+	return rs.dao.SaveChannelRecovery(r)
+}
+
+/*
+recoveryBalanceProofState 校验balance proof的签名和nonce,locks必须和locksroot一致
+*/
+func recoveryBalanceProofState(p *encoding.RecoveryBalanceProof, channelID contracts.ChannelUniqueID, signer common.Address, minNonce uint64, locks []*mtree.Lock) (*transfer.BalanceProofState, error) {
+	if p.Nonce < minNonce {
+		return nil, fmt.Errorf("balance proof of %s is older than the one on chain,nonce=%d,chain nonce=%d", utils.APex2(signer), p.Nonce, minNonce)
+	}
+	if !utils.IsValidUint256(p.TransferAmount) {
+		return nil, fmt.Errorf("invalid transfer amount %s", p.TransferAmount)
+	}
+	if mtree.NewMerkleTree(locks).MerkleRoot() != p.Locksroot {
+		return nil, fmt.Errorf("locks of %s don't match locksroot", utils.APex2(signer))
+	}
+	if p.Nonce == 0 {
+		if p.TransferAmount.Sign() != 0 || p.Locksroot != utils.EmptyHash || len(locks) > 0 {
+			return nil, fmt.Errorf("balance proof of %s has no nonce", utils.APex2(signer))
+		}
+		return transfer.NewEmptyBalanceProofState(), nil
+	}
+	s, err := p.Signer(channelID.ChannelIdentifier, channelID.OpenBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if s != signer {
+		return nil, fmt.Errorf("balance proof should be signed by %s,but signed by %s", utils.APex2(signer), utils.APex2(s))
+	}
+	return transfer.NewBalanceProofState(p.Nonce, p.TransferAmount, p.Locksroot, channelID, p.MessageHash, p.Signature), nil
+}
+
+/*
+recoverChannel 用对方提供的数据重建通道,通道在恢复期间没有任何变化,
+重建以后双方的余额都不能为负数.
+不知道链上nonce的时候无法判断对方提供的balance proof是不是旧的,拒绝恢复,等待下次回复
+*/
+func (rs *Service) recoverChannel(r *models.ChannelRecovery, ourProof, partnerProof *encoding.RecoveryBalanceProof, ourLocks, partnerLocks []*mtree.Lock) error {
+	ch := rs.getChannelWithAddr(r.ChannelIdentifier)
+	if ch == nil {
+		return rerr.ErrChannelNotFound.Printf("channel %s", r.ChannelIdentifier.String())
+	}
+	if ch.ChannelIdentifier.OpenBlockNumber != r.OpenBlockNumber {
+		return rerr.ErrChannelNotFound.Printf("channel %s has been reopened", r.ChannelIdentifier.String())
+	}
+	if !r.ChainNonceKnown {
+		err := rs.queryRecoveryChainNonces(r, rs.NodeAddress)
+		if err != nil {
+			return fmt.Errorf("nonce on chain is unknown,query err %s", err)
+		}
+	}
+	ourBalanceProof, err := recoveryBalanceProofState(ourProof, ch.ChannelIdentifier, rs.NodeAddress, r.ChainOurNonce, ourLocks)
+	if err != nil {
+		return err
+	}
+	partnerBalanceProof, err := recoveryBalanceProofState(partnerProof, ch.ChannelIdentifier, r.PartnerAddress, r.ChainPartnerNonce, partnerLocks)
+	if err != nil {
+		return err
+	}
+	if ch.OurState.BalanceProofState.Nonce > ourBalanceProof.Nonce || ch.PartnerState.BalanceProofState.Nonce > partnerBalanceProof.Nonce {
+		return fmt.Errorf("local balance proof is newer than the recovered one")
+	}
+	//链上事件已经记录的transfer amount,比如对方关闭通道时提交的
+	ourBalanceProof.ContractTransferAmount = ch.OurState.BalanceProofState.ContractTransferAmount
+	partnerBalanceProof.ContractTransferAmount = ch.PartnerState.BalanceProofState.ContractTransferAmount
+	cs := channel.NewChannelSerialization(ch)
+	cs.OurBalanceProof = ourBalanceProof
+	cs.PartnerBalanceProof = partnerBalanceProof
+	cs.OurLeaves = ourLocks
+	cs.PartnerLeaves = partnerLocks
+	cs.OurKnownSecrets = nil
+	cs.PartnerKnownSecrets = nil
+	tokenNetwork, err := rs.Chain.TokenNetwork(ch.TokenAddress)
+	if err != nil {
+		return err
+	}
+	ch2, err := rs.channelSerilization2Channel(cs, tokenNetwork)
+	if err != nil {
+		return err
+	}
+	if ch2.OurState.Distributable(ch2.PartnerState).Sign() < 0 || ch2.PartnerState.Distributable(ch2.OurState).Sign() < 0 {
+		return fmt.Errorf("recovered balance proofs exceed the deposits")
+	}
+	ch.OurState = ch2.OurState
+	ch.PartnerState = ch2.PartnerState
+	err = rs.UpdateChannelNoTx(channel.NewChannelSerialization(ch))
+	if err != nil {
+		return err
+	}
+	rs.restoreLocksOfChannels([]*channel.Channel{ch})
+	return nil
+}
+
+/*
+abandonChannelRecovery 对方一直不能回复的时候,用户可以放弃恢复,
+通道继续使用链上事件重建的状态,关闭通道时可能使用过期的balance proof
+*/
+func (rs *Service) abandonChannelRecovery(req *abandonChannelRecoveryReq) (result *utils.AsyncResult) {
+	result = utils.NewAsyncResult()
+	r := rs.recoveringChannels[req.ChannelIdentifier]
+	if r == nil {
+		result.Result <- rerr.ErrNotFound.Printf("channel %s is not recovering", req.ChannelIdentifier.String())
+		return
+	}
+	r.Status = models.ChannelRecoveryStatusAbandoned
+	r.FinishTime = time.Now().Unix()
+	err := rs.dao.SaveChannelRecovery(r)
+	if err != nil {
+		result.Result <- err
+		return
+	}
+	delete(rs.recoveringChannels, r.ChannelIdentifier)
+	delete(rs.recoveryResponses, r.ChannelIdentifier)
+	log.Warn(fmt.Sprintf("recovery of channel %s abandoned", r.ChannelIdentifier.String()))
+	result.Result <- nil
+	return
+}
+
+// GetChannelRecoveryList 所有通道的恢复记录
+func (r *API) GetChannelRecoveryList() (rs []*models.ChannelRecovery, err error) {
+	return r.Photon.dao.GetChannelRecoveryList()
+}
+
+// AbandonChannelRecovery 放弃从对方恢复这个通道
+func (r *API) AbandonChannelRecovery(channelIdentifier common.Hash) error {
+	result := r.Photon.abandonChannelRecoveryClient(channelIdentifier)
+	return <-result.Result
+}
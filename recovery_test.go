@@ -0,0 +1,69 @@
+package photon
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/encoding"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/transfer/mtree"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleRecoveryResponseOutOfOrder(t *testing.T) {
+	db, err := newTestStormDb()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.CloseDB()
+	partner := utils.NewRandomAddress()
+	r := &models.ChannelRecovery{
+		ChannelIdentifier: utils.NewRandomHash(),
+		OpenBlockNumber:   3,
+		PartnerAddress:    partner,
+		Status:            models.ChannelRecoveryStatusPending,
+	}
+	rs := &Service{
+		dao:                db,
+		recoveringChannels: map[common.Hash]*models.ChannelRecovery{r.ChannelIdentifier: r},
+		recoveryResponses:  make(map[common.Hash]*recoveryPages),
+	}
+	var locks []*mtree.Lock
+	for i := 0; i < 2*params.RecoveryLocksPerMessage+1; i++ {
+		locks = append(locks, &mtree.Lock{
+			Expiration:     int64(100 + i),
+			Amount:         big.NewInt(int64(i + 1)),
+			LockSecretHash: utils.NewRandomHash(),
+		})
+	}
+	proof := &encoding.RecoveryBalanceProof{TransferAmount: new(big.Int)}
+	ms := encoding.NewRecoveryResponses(r.ChannelIdentifier, r.OpenBlockNumber, proof, proof, locks, nil)
+	if !assert.Len(t, ms, 3) {
+		return
+	}
+	for _, m := range ms {
+		m.Sender = partner
+	}
+	//最后一个分页先到达
+	for i := len(ms) - 1; i > 0; i-- {
+		err = rs.handleRecoveryResponse(ms[i])
+		assert.Nil(t, err)
+		assert.Empty(t, r.Error)
+	}
+	pages := rs.recoveryResponses[r.ChannelIdentifier]
+	if assert.NotNil(t, pages) {
+		assert.EqualValues(t, len(ms), pages.Total)
+		requesterLocks, _, complete := pages.add(ms[0])
+		assert.True(t, complete)
+		assert.Equal(t, locks, requesterLocks)
+	}
+	//收齐以后开始恢复,测试中没有这个通道,所以恢复失败
+	err = rs.handleRecoveryResponse(ms[0])
+	assert.Nil(t, err)
+	assert.NotEmpty(t, r.Error)
+	assert.Nil(t, rs.recoveryResponses[r.ChannelIdentifier])
+}
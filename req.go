@@ -3,7 +3,9 @@ package photon
 import (
 	"math/big"
 
+	"github.com/SmartMeshFoundation/Photon/models"
 	"github.com/SmartMeshFoundation/Photon/pfsproxy"
+	"github.com/SmartMeshFoundation/Photon/transfer/mediatedtransfer"
 	"github.com/SmartMeshFoundation/Photon/utils"
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -49,6 +51,10 @@ const registerSecretReqName = "RegisterSecret"
 const getUnfinishedReceviedTransferReqName = "GetUnfinishedReceivedTransfer"
 const forceUnlockReqName = "ForceUnlock"
 const registerSecretOnChainReqName = "registerSecretOnChain"
+const repairDepositReqName = "repairDeposit"
+const channelRecoveryReqName = "channelRecovery"
+const abandonChannelRecoveryReqName = "abandonChannelRecovery"
+const debugStateManagerReqName = "debugStateManager"
 
 /*
 transfer api
@@ -61,6 +67,12 @@ type transferReq struct {
 	IsDirectTransfer bool
 	Data             string
 	RouteInfo        []pfsproxy.FindPathResponse
+	Keysend          bool                      // 发起方生成密码并加密给target,target收到以后直接领取
+	TargetPublicKey  []byte                    // keysend交易target的公钥,为空时使用从target消息签名中恢复的公钥
+	OnionPublicKeys  map[common.Address][]byte // 不为nil表示keysend交易使用onion路由,保存用户指定的路径上节点的公钥
+	OnionAllowClear  bool                      // onion交易中不能创建onion的路由明文发送路径
+	PaymentPlanKey   string                    // 定时支付计划发起的交易
+	PaymentPlanRun   int
 }
 
 /*
@@ -139,21 +151,46 @@ Transfer `amount` between this node and `target`.
              expire.
 */
 func (rs *Service) transferAsyncClient(tokenAddress common.Address, amount *big.Int, target common.Address, secret common.Hash, isDirectTransfer bool, data string, routeInfo []pfsproxy.FindPathResponse) *utils.AsyncResult {
-	req := &apiReq{
+	return rs.transferReqClient(&transferReq{
+		TokenAddress:     tokenAddress,
+		Amount:           amount,
+		Target:           target,
+		Secret:           secret,
+		IsDirectTransfer: isDirectTransfer,
+		Data:             data,
+		RouteInfo:        routeInfo,
+	})
+	//return rs.startMediatedTransfer(tokenAddress, target, amount, identifier)
+}
+func (rs *Service) keysendAsyncClient(tokenAddress common.Address, amount *big.Int, target common.Address, targetPublicKey []byte, data string, routeInfo []pfsproxy.FindPathResponse, onionPublicKeys map[common.Address][]byte, onionAllowClear bool) *utils.AsyncResult {
+	return rs.transferReqClient(&transferReq{
+		TokenAddress:    tokenAddress,
+		Amount:          amount,
+		Target:          target,
+		Data:            data,
+		RouteInfo:       routeInfo,
+		Keysend:         true,
+		TargetPublicKey: targetPublicKey,
+		OnionPublicKeys: onionPublicKeys,
+		OnionAllowClear: onionAllowClear,
+	})
+}
+
+/*
+transferReqClient 发起交易之前检查支付策略,加锁保证并发的交易不会同时通过每小时和每天的限额检查
+*/
+func (rs *Service) transferReqClient(tr *transferReq) *utils.AsyncResult {
+	rs.spendingPolicyLock.Lock()
+	defer rs.spendingPolicyLock.Unlock()
+	err := rs.checkSpendingPolicy(newTransferApproval(tr))
+	if err != nil {
+		return utils.NewAsyncResultWithError(err)
+	}
+	return rs.sendReqClient(&apiReq{
 		ReqID: utils.RandomString(10),
 		Name:  transferReqName,
-		Req: &transferReq{
-			TokenAddress:     tokenAddress,
-			Amount:           amount,
-			Target:           target,
-			Secret:           secret,
-			IsDirectTransfer: isDirectTransfer,
-			Data:             data,
-			RouteInfo:        routeInfo,
-		},
-	}
-	return rs.sendReqClient(req)
-	//return rs.startMediatedTransfer(tokenAddress, target, amount, identifier)
+		Req:   tr,
+	})
 }
 func (rs *Service) sendReqClient(req *apiReq) *utils.AsyncResult {
 	req.result = make(chan *utils.AsyncResult, 1)
@@ -176,6 +213,10 @@ func (rs *Service) depositAndOpenChannelClient(token, partner common.Address, se
 	return rs.sendReqClient(req)
 }
 func (rs *Service) closeChannelClient(channelIdentifier common.Hash) *utils.AsyncResult {
+	err := rs.checkChannelSpendingPolicy(models.PendingApprovalOperationClose, channelIdentifier, nil)
+	if err != nil {
+		return utils.NewAsyncResultWithError(err)
+	}
 	req := &apiReq{
 		ReqID: utils.RandomString(10),
 		Name:  closeChannelReqName,
@@ -226,6 +267,10 @@ func (rs *Service) cancelMarkChannelForCooperativeSettleClient(channelIdentifier
 	return rs.sendReqClient(req)
 }
 func (rs *Service) withdrawClient(channelIdentifier common.Hash, amount *big.Int) *utils.AsyncResult {
+	err := rs.checkChannelSpendingPolicy(models.PendingApprovalOperationWithdraw, channelIdentifier, amount)
+	if err != nil {
+		return utils.NewAsyncResultWithError(err)
+	}
 	req := &apiReq{
 		ReqID: utils.RandomString(10),
 		Name:  withdrawReqName,
@@ -362,3 +407,72 @@ func (rs *Service) registerSecretOnChainClient(secret common.Hash) *utils.AsyncR
 	}
 	return rs.sendReqClient(req)
 }
+
+/*
+repair deposit missed by event processing,found by reconciliation
+*/
+type repairDepositReq struct {
+	StateChange     *mediatedtransfer.ContractBalanceStateChange
+	OpenBlockNumber int64
+}
+
+func (rs *Service) repairDepositClient(st *mediatedtransfer.ContractBalanceStateChange, openBlockNumber int64) *utils.AsyncResult {
+	req := &apiReq{
+		ReqID: utils.RandomString(10),
+		Name:  repairDepositReqName,
+		Req: &repairDepositReq{
+			StateChange:     st,
+			OpenBlockNumber: openBlockNumber,
+		},
+	}
+	return rs.sendReqClient(req)
+}
+
+/*
+send recovery requests for channels which are still recovering
+*/
+func (rs *Service) channelRecoveryClient() *utils.AsyncResult {
+	req := &apiReq{
+		ReqID: utils.RandomString(10),
+		Name:  channelRecoveryReqName,
+	}
+	return rs.sendReqClient(req)
+}
+
+/*
+give up recovering a channel from partner
+*/
+type abandonChannelRecoveryReq struct {
+	ChannelIdentifier common.Hash
+}
+
+func (rs *Service) abandonChannelRecoveryClient(channelIdentifier common.Hash) *utils.AsyncResult {
+	req := &apiReq{
+		ReqID: utils.RandomString(10),
+		Name:  abandonChannelRecoveryReqName,
+		Req: &abandonChannelRecoveryReq{
+			ChannelIdentifier: channelIdentifier,
+		},
+	}
+	return rs.sendReqClient(req)
+}
+
+/*
+inspect state managers of transfers,Key is empty for all state managers
+*/
+type debugStateManagerReq struct {
+	Key    common.Hash
+	Record bool
+}
+
+func (rs *Service) debugStateManagerClient(key common.Hash, record bool) *utils.AsyncResult {
+	req := &apiReq{
+		ReqID: utils.RandomString(10),
+		Name:  debugStateManagerReqName,
+		Req: &debugStateManagerReq{
+			Key:    key,
+			Record: record,
+		},
+	}
+	return rs.sendReqClient(req)
+}
@@ -173,6 +173,36 @@ var (
 	ErrUpdateButHaveTransfer = newError(1021, "ErrUpdateButHaveTransfer")
 	//ErrNotChargeFee 进行与收费相关的操作,但是没有启用收费
 	ErrNotChargeFee = newError(1022, "ErrNotChargeFee")
+	//ErrIdempotencyKeyInProgress 使用同一个幂等key的请求正在处理中
+	ErrIdempotencyKeyInProgress = newError(1023, "ErrIdempotencyKeyInProgress")
+	//ErrIdempotencyKeyReused 同一个幂等key被用于不同的操作
+	ErrIdempotencyKeyReused = newError(1024, "ErrIdempotencyKeyReused")
+	//ErrPaymentPlanState 定时支付计划当前状态不允许此操作,比如恢复已经取消的计划
+	ErrPaymentPlanState = newError(1025, "ErrPaymentPlanState")
+	//ErrKeysendPublicKeyUnknown keysend交易不知道target的公钥,需要用户指定或者先收到过target的消息
+	ErrKeysendPublicKeyUnknown = newError(1026, "ErrKeysendPublicKeyUnknown")
+	//ErrPaymentReceiptNotReady 交易还没有成功,或者没有收到target签名的SecretRequest,无法生成支付凭证
+	ErrPaymentReceiptNotReady = newError(1027, "ErrPaymentReceiptNotReady")
+	//ErrInvalidPaymentReceipt 支付凭证验证失败
+	ErrInvalidPaymentReceipt = newError(1028, "ErrInvalidPaymentReceipt")
+	//ErrUnauthorized 没有提供API token或者API token的权限不够
+	ErrUnauthorized = newError(1029, "ErrUnauthorized")
+	//ErrSpendingCapExceeded 交易金额超过了API token的支付上限
+	ErrSpendingCapExceeded = newError(1030, "ErrSpendingCapExceeded")
+	//ErrSpendingPolicyViolated 违反了支付策略,比如超过单笔或者每天的限额,target不在允许的列表中
+	ErrSpendingPolicyViolated = newError(1031, "ErrSpendingPolicyViolated")
+	//ErrPendingApproval 超过审批阈值,操作已经加入待审批队列,批准以后才会执行
+	ErrPendingApproval = newError(1032, "ErrPendingApproval")
+	//ErrDBEncryption 数据库已经加密但是没有提供密码,或者密码错误
+	ErrDBEncryption = newError(1033, "ErrDBEncryption")
+	//ErrChannelRecovering 数据库丢失以后还在从通道对方恢复数据,不能交易
+	ErrChannelRecovering = newError(1034, "ErrChannelRecovering")
+	//ErrDBMigration 数据库版本比程序新,或者升级数据库失败
+	ErrDBMigration = newError(1035, "ErrDBMigration")
+	//ErrKeysendNotSupported 路径上的节点没有通过Ping声明过支持keysend交易
+	ErrKeysendNotSupported = newError(1036, "ErrKeysendNotSupported")
+	//ErrOnionNotSupported 没有一条路由可以创建onion,并且用户没有允许明文发送路径
+	ErrOnionNotSupported = newError(1037, "ErrOnionNotSupported")
 	/*
 		以太坊报公链节点报的错误
 
@@ -0,0 +1,209 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// 查询审计日志本身不记录
+const auditLogRoute = "/api/1/audit_logs"
+
+// 这些接口会直接退出进程,只能在调用之前记录
+var auditBeforeRoutes = []string{
+	"/api/1/stop",
+	"/api/1/debug/shutdown",
+}
+
+/*
+auditMiddleware 把改变状态的接口调用和debug接口的调用写入审计日志,在authMiddleware之后执行,没有通过认证的请求不记录.
+GET和只读的POST接口不记录,需要admin权限的GET接口(debug,stop等)也会记录
+*/
+type auditMiddleware struct{}
+
+func isAuditedRoute(method, path string) bool {
+	switch {
+	case strings.HasPrefix(path, auditLogRoute):
+		return false
+	case hasPrefix(path, adminRoutes):
+		return true
+	case method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions:
+		return false
+	case method == http.MethodPost && hasPrefix(path, readPostRoutes):
+		return false
+	}
+	return true
+}
+
+//auditResponseWriter 记录handler返回的结果
+type auditResponseWriter struct {
+	rest.ResponseWriter
+	resp *dto.APIResponse
+}
+
+//WriteJson 保存返回的APIResponse
+func (w *auditResponseWriter) WriteJson(v interface{}) error {
+	if resp, ok := v.(*dto.APIResponse); ok {
+		w.resp = resp
+	}
+	return w.ResponseWriter.WriteJson(v)
+}
+
+//Write 少数接口直接写http.ResponseWriter
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	return w.ResponseWriter.(http.ResponseWriter).Write(b)
+}
+
+//auditCaller 调用方的身份
+func auditCaller(r *rest.Request) string {
+	if t, ok := r.Env[envAPIToken].(*models.APIToken); ok {
+		return "token:" + t.Name
+	}
+	if username, _, ok := r.BasicAuth(); ok && HTTPUsername != "" && username == HTTPUsername {
+		return "user:" + username
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return "cert:" + r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	return "anonymous"
+}
+
+//auditParams 路径参数,query和body,secret在保存之前会被隐藏
+func auditParams(r *rest.Request, body []byte) string {
+	params := make(map[string]interface{})
+	if len(r.PathParams) > 0 {
+		params["path"] = r.PathParams
+	}
+	if len(r.URL.RawQuery) > 0 {
+		params["query"] = r.URL.Query()
+	}
+	if len(body) > 0 {
+		var v interface{}
+		if json.Unmarshal(body, &v) == nil {
+			params["body"] = v
+		} else {
+			params["body"] = string(body)
+		}
+	}
+	buf, err := json.Marshal(params)
+	if err != nil {
+		return ""
+	}
+	return string(buf)
+}
+
+//auditOperation method和path,路径中的secret不记录
+func auditOperation(r *rest.Request) string {
+	path := r.URL.Path
+	for k, v := range r.PathParams {
+		if strings.Contains(strings.ToLower(k), "secret") && !strings.Contains(strings.ToLower(k), "hash") && v != "" {
+			path = strings.Replace(path, v, "***", -1)
+		}
+	}
+	return r.Method + " " + path
+}
+
+func appendAuditLog(l *models.AuditLog, resp *dto.APIResponse) {
+	var data json.RawMessage
+	if resp != nil {
+		l.ErrorCode = resp.ErrorCode
+		l.ErrorMessage = resp.ErrorMsg
+		data = resp.Data
+	}
+	err := API.AppendAuditLog(l, data)
+	if err != nil {
+		log.Error(fmt.Sprintf("append audit log of %s err %s", l.Operation, err))
+	}
+}
+
+//MiddlewareFunc makes auditMiddleware implement the rest.Middleware interface
+func (m *auditMiddleware) MiddlewareFunc(handler rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		if !isAuditedRoute(r.Method, r.URL.Path) {
+			handler(w, r)
+			return
+		}
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(r.Body)
+			if err != nil {
+				log.Warn(fmt.Sprintf("read body of %s err %s", r.URL.Path, err))
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		newAuditLog := func(t time.Time) *models.AuditLog {
+			return &models.AuditLog{
+				Time:       t.Unix(),
+				Source:     models.AuditSourceREST,
+				Caller:     auditCaller(r),
+				RemoteAddr: r.RemoteAddr,
+				Operation:  auditOperation(r),
+				Params:     auditParams(r, body),
+			}
+		}
+		if hasPrefix(r.URL.Path, auditBeforeRoutes) {
+			appendAuditLog(newAuditLog(time.Now()), nil)
+			handler(w, r)
+			return
+		}
+		startTime := time.Now()
+		aw := &auditResponseWriter{ResponseWriter: w}
+		handler(aw, r)
+		//路径参数是在router中解析的,handler返回以后才有
+		appendAuditLog(newAuditLog(startTime), aw.resp)
+	}
+}
+
+/*
+GetAuditLogList 按照seq查询审计日志,from和to都包括在内,不指定from从第一条开始,不指定to到最后一条
+*/
+func GetAuditLogList(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetAuditLogList ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	var from, to int64
+	var err error
+	if s := r.URL.Query().Get("from"); s != "" {
+		from, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+			return
+		}
+	}
+	if s := r.URL.Query().Get("to"); s != "" {
+		to, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+			return
+		}
+	}
+	ls, err := API.GetAuditLogList(from, to)
+	resp = dto.NewAPIResponse(err, ls)
+}
+
+/*
+VerifyAuditLog 检查审计日志的hash链
+*/
+func VerifyAuditLog(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> VerifyAuditLog ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	result, err := API.VerifyAuditLog()
+	resp = dto.NewAPIResponse(err, result)
+}
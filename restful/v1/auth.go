@@ -0,0 +1,196 @@
+package v1
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const envAPIToken = "API_TOKEN"
+
+/*
+authMiddleware 检查调用方的权限:
+1. Authorization: Bearer <token>,按照token的scope检查是否可以调用这个接口
+2. Authorization: Basic,http-username和http-password拥有所有权限
+既没有API token也没有指定http-username的时候不需要认证,和以前保持一致
+*/
+type authMiddleware struct{}
+
+//MiddlewareFunc makes authMiddleware implement the rest.Middleware interface
+func (m *authMiddleware) MiddlewareFunc(handler rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		if r.Method == http.MethodGet && isPublicRoute(r.URL.Path) {
+			handler(w, r)
+			return
+		}
+		err := authenticate(r)
+		if err != nil {
+			log.Info(fmt.Sprintf("Restful Api Call ----> %s %s unauthorized ,err=%s", r.Method, r.URL.Path, err))
+			if HTTPUsername != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="please input username and password"`)
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			writejson(w, dto.NewExceptionAPIResponse(err))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func authenticate(r *rest.Request) error {
+	auth := r.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		t, err := API.GetAPIToken(strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")))
+		if err != nil {
+			return err
+		}
+		required := routeScope(r.Method, r.URL.Path)
+		if !t.Scope.Allows(required) {
+			return rerr.ErrUnauthorized.Printf("api token %s with scope %s can not call %s %s", t.Name, t.Scope, r.Method, r.URL.Path)
+		}
+		r.Env[envAPIToken] = t
+		return nil
+	}
+	if HTTPUsername != "" && HTTPPassword != "" {
+		username, password, ok := r.BasicAuth()
+		if ok && username == HTTPUsername && password == HTTPPassword {
+			return nil
+		}
+		return rerr.ErrUnauthorized.Append("invalid username or password")
+	}
+	if API.HasAPIToken() {
+		return rerr.ErrUnauthorized.Append("api token needed")
+	}
+	return nil
+}
+
+// 不需要认证的接口,进程管理工具不能提供token,返回的结果中没有敏感信息
+var publicRoutes = []string{
+	"/healthz",
+	"/readyz",
+}
+
+func isPublicRoute(path string) bool {
+	for _, p := range publicRoutes {
+		if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// 只读的POST接口
+var readPostRoutes = []string{
+	"/api/1/receipts/verify",
+	"/api/1/tx/query",
+	"/api/1/income/",
+}
+
+// 返回交易密码的GET接口,收据中的密码可以证明已经支付,只读的token不能查询
+var payGetRoutes = []string{
+	"/api/1/receipts/",
+}
+
+// 发起交易的接口,金额计入API token的支付上限
+var payRoutes = []string{
+	"/api/1/transfers",
+	"/api/1/batch_transfers",
+	"/api/1/payment_plans",
+	"/api/1/transfercancel/",
+	"/api/1/registersecret",
+}
+
+// 不在这里的接口都需要admin,debug和停止节点的接口即使是GET也需要admin
+var adminRoutes = []string{
+	"/api/1/debug/",
+	"/api/1/stop",
+	"/api/1/switch/",
+	"/api/1/updatenodes",
+	"/api/1/prepare-update",
+	"/api/1/audit_logs",
+	"/api/1/reconcile",
+	"/api/1/retention",
+	"/api/1/recovery",
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+//routeScope 调用这个接口需要的最低权限
+func routeScope(method, path string) models.APITokenScope {
+	switch {
+	case hasPrefix(path, adminRoutes):
+		return models.APITokenScopeAdmin
+	case method == http.MethodGet && hasPrefix(path, payGetRoutes):
+		return models.APITokenScopePay
+	case method == http.MethodGet:
+		return models.APITokenScopeRead
+	case method == http.MethodPost && hasPrefix(path, readPostRoutes):
+		return models.APITokenScopeRead
+	case (method == http.MethodPost || method == http.MethodPut) && hasPrefix(path, payRoutes):
+		return models.APITokenScopePay
+	}
+	return models.APITokenScopeAdmin
+}
+
+/*
+spendAPIToken 通过有支付上限的API token发起交易时,预留支付的金额,超过上限返回错误.
+返回的key不为空的时候,调用方需要在交易没有发起时退还,发起以后记录交易.
+使用http-username或者不需要认证的时候不限制
+*/
+func spendAPIToken(r *rest.Request, tokenAddress common.Address, amount *big.Int) (key string, err error) {
+	key = spendingCapKey(r)
+	if key == "" || amount == nil || amount.Sign() <= 0 {
+		return "", nil
+	}
+	err = API.SpendAPIToken(key, map[common.Address]*big.Int{tokenAddress: amount})
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+//spendingCapKey 调用方的API token有支付上限时返回它的key
+func spendingCapKey(r *rest.Request) string {
+	t, ok := r.Env[envAPIToken].(*models.APIToken)
+	if !ok || t.SpendingCap == nil {
+		return ""
+	}
+	return t.Key
+}
+
+//newTLSConfig 指定了clientCA的时候,客户端必须提供这个CA签发的证书
+func newTLSConfig(clientCA string) (*tls.Config, error) {
+	c := &tls.Config{MinVersion: tls.VersionTLS12}
+	if clientCA == "" {
+		return c, nil
+	}
+	pem, err := ioutil.ReadFile(clientCA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in %s", clientCA)
+	}
+	c.ClientCAs = pool
+	c.ClientAuth = tls.RequireAndVerifyClientCert
+	return c, nil
+}
@@ -0,0 +1,95 @@
+package v1
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+//BatchTransferItemData one transfer of a batch
+type BatchTransferItemData struct {
+	Token  string   `json:"token_address"`
+	Target string   `json:"target_address"`
+	Amount *big.Int `json:"amount"`
+	Data   string   `json:"data"`
+}
+
+//BatchTransferData post for batch transfers
+type BatchTransferData struct {
+	Items       []*BatchTransferItemData `json:"items"`
+	Concurrency int                      `json:"concurrency,omitempty"` // 同时进行的交易数,不指定使用默认值
+}
+
+/*
+BatchTransfers is the api of /batch_transfers
+交易在后台执行,立即返回批量转账的key,通过GetBatchTransfer查询进度
+*/
+func BatchTransfers(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> BatchTransfers ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	if API.Photon.StopCreateNewTransfers {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrStopCreateNewTransfer)
+		return
+	}
+	req := &BatchTransferData{}
+	err := r.DecodeJsonPayload(req)
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	var items []*models.BatchTransferItem
+	for i, d := range req.Items {
+		item := &models.BatchTransferItem{
+			Amount: d.Amount,
+			Data:   d.Data,
+		}
+		item.TokenAddress, err = utils.HexToAddress(d.Token)
+		if err != nil {
+			resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.Errorf("item %d invalid token %s", i, d.Token))
+			return
+		}
+		item.TargetAddress, err = utils.HexToAddress(d.Target)
+		if err != nil {
+			resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.Errorf("item %d invalid target %s", i, d.Target))
+			return
+		}
+		items = append(items, item)
+	}
+	b, err := API.BatchTransfer(items, req.Concurrency, spendingCapKey(r))
+	resp = dto.NewAPIResponse(err, b)
+}
+
+/*
+GetBatchTransfer returns progress and status of every transfer of a batch
+*/
+func GetBatchTransfer(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetBatchTransfer ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	b, err := API.GetBatchTransfer(r.PathParam("key"))
+	resp = dto.NewAPIResponse(err, b)
+}
+
+/*
+GetBatchTransferList returns all batch transfers
+*/
+func GetBatchTransferList(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetBatchTransferList ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	bs, err := API.GetBatchTransferList()
+	resp = dto.NewAPIResponse(err, bs)
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
 	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
 	"github.com/SmartMeshFoundation/Photon/utils"
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/ethereum/go-ethereum/common"
@@ -165,11 +166,23 @@ func Deposit(w rest.ResponseWriter, r *rest.Request) {
 		return
 	}
 
-	c, err := API.DepositAndOpenChannel(tokenAddr, partnerAddr, req.SettleTimeout, API.Photon.Config.RevealTimeout, req.Balance, req.NewChannel)
+	var c *channeltype.Serialization
+	record, duplicate, err := API.Idempotent(getIdempotencyKey(r), models.IdempotencyOperationDeposit, []interface{}{tokenAddr, partnerAddr, req.Balance, req.SettleTimeout, req.NewChannel}, func() (*models.IdempotencyRecord, error) {
+		var err2 error
+		c, err2 = API.DepositAndOpenChannel(tokenAddr, partnerAddr, req.SettleTimeout, API.Photon.Config.RevealTimeout, req.Balance, req.NewChannel)
+		return newChannelIdempotencyResult(c, err2)
+	})
 	if err != nil {
 		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
 		return
 	}
+	if duplicate {
+		c, err = getIdempotentChannel(record)
+		if err != nil {
+			resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+			return
+		}
+	}
 	var d *ChannelData
 	if c != nil {
 		d = &ChannelData{
@@ -241,23 +254,35 @@ func CloseSettleChannel(w rest.ResponseWriter, r *rest.Request) {
 		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError)
 		return
 	}
-	if req.StateInt == channeltype.StateClosed {
-		if req.Force {
-			c, err = API.Close(c.TokenAddress(), c.PartnerAddress())
+	op := models.IdempotencyOperationClose
+	if req.StateInt == channeltype.StateSettled {
+		op = models.IdempotencyOperationSettle
+	}
+	record, duplicate, err := API.Idempotent(getIdempotencyKey(r), op, []interface{}{channelIdentifier, req.Force}, func() (*models.IdempotencyRecord, error) {
+		var err2 error
+		if req.StateInt == channeltype.StateClosed {
+			if req.Force {
+				c, err2 = API.Close(c.TokenAddress(), c.PartnerAddress())
+			} else {
+				//cooperative settle channel
+				c, err2 = API.CooperativeSettle(c.TokenAddress(), c.PartnerAddress())
+			}
 		} else {
-			//cooperative settle channel
-			c, err = API.CooperativeSettle(c.TokenAddress(), c.PartnerAddress())
+			c, err2 = API.Settle(c.TokenAddress(), c.PartnerAddress())
 		}
-	} else if req.StateInt == channeltype.StateSettled {
-		c, err = API.Settle(c.TokenAddress(), c.PartnerAddress())
-	} else {
-		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError)
-		return
-	}
+		return newChannelIdempotencyResult(c, err2)
+	})
 	if err != nil {
 		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
 		return
 	}
+	if duplicate {
+		c, err = getIdempotentChannel(record)
+		if err != nil {
+			resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+			return
+		}
+	}
 	d := &ChannelData{
 		ChannelIdentifier:   c.ChannelIdentifier.ChannelIdentifier.String(),
 		OpenBlockNumber:     c.ChannelIdentifier.OpenBlockNumber,
@@ -311,11 +336,22 @@ func withdraw(w rest.ResponseWriter, r *rest.Request) {
 			resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.Append("op must be null if amount is not zero"))
 			return
 		}
-		c, err = API.Withdraw(c.TokenAddress(), c.PartnerAddress(), req.Amount)
+		record, duplicate, err := API.Idempotent(getIdempotencyKey(r), models.IdempotencyOperationWithdraw, []interface{}{channelIdentifier, req.Amount}, func() (*models.IdempotencyRecord, error) {
+			var err2 error
+			c, err2 = API.Withdraw(c.TokenAddress(), c.PartnerAddress(), req.Amount)
+			return newChannelIdempotencyResult(c, err2)
+		})
 		if err != nil {
 			resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
 			return
 		}
+		if duplicate {
+			c, err = getIdempotentChannel(record)
+			if err != nil {
+				resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+				return
+			}
+		}
 	} else {
 		if req.Op == OpPrepareWithdraw {
 			c, err = API.PrepareForWithdraw(c.TokenAddress(), c.PartnerAddress())
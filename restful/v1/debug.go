@@ -165,3 +165,69 @@ func RegisterSecretOnChain(w rest.ResponseWriter, r *rest.Request) {
 	err := API.RegisterSecretOnChain(secret)
 	resp = dto.NewAPIResponse(err, "ok")
 }
+
+/*
+GetStateManagerList 所有进行中交易的状态机和它们的当前状态
+*/
+func GetStateManagerList(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetStateManagerList ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	infos, err := API.GetStateManagerList()
+	resp = dto.NewAPIResponse(err, infos)
+}
+
+/*
+GetStateManager 一个状态机的当前状态,以及最近的状态变化和产生的事件
+*/
+func GetStateManager(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetStateManager ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	key := common.HexToHash(r.PathParam("key"))
+	if key == utils.EmptyHash {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.Append("invalid key"))
+		return
+	}
+	info, err := API.GetStateManager(key)
+	resp = dto.NewAPIResponse(err, info)
+}
+
+/*
+GetStateManagerRecord 导出状态机最近的状态变化,保存data到文件以后用photon replay离线重放
+*/
+func GetStateManagerRecord(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetStateManagerRecord ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	key := common.HexToHash(r.PathParam("key"))
+	if key == utils.EmptyHash {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.Append("invalid key"))
+		return
+	}
+	record, err := API.GetStateManagerRecord(key)
+	resp = dto.NewAPIResponse(err, record)
+}
+
+/*
+GetTransferLogs 按照lockSecretHash查询一个交易的日志,日志中可能有密码,所以需要admin
+*/
+func GetTransferLogs(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetTransferLogs ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	lockSecretHash := common.HexToHash(r.PathParam("locksecrethash"))
+	if lockSecretHash == utils.EmptyHash {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.Append("invalid locksecrethash"))
+		return
+	}
+	resp = dto.NewAPIResponse(nil, API.GetTransferLogs(lockSecretHash))
+}
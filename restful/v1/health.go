@@ -0,0 +1,32 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+
+	photon "github.com/SmartMeshFoundation/Photon"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+/*
+Healthz 存活检查,失败的时候返回503,进程管理工具应该重启节点
+*/
+func Healthz(w rest.ResponseWriter, r *rest.Request) {
+	writeHealthReport(w, "Healthz", API.GetLiveness())
+}
+
+/*
+Readyz 就绪检查,失败的时候返回503,节点暂时不能处理交易
+*/
+func Readyz(w rest.ResponseWriter, r *rest.Request) {
+	writeHealthReport(w, "Readyz", API.GetReadiness())
+}
+
+func writeHealthReport(w rest.ResponseWriter, name string, report *photon.HealthReport) {
+	log.Trace(fmt.Sprintf("Restful Api Call ----> %s ,status=%s", name, report.Status))
+	if report.Status != photon.HealthStatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writejson(w, report)
+}
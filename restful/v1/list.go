@@ -0,0 +1,139 @@
+package v1
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/SmartMeshFoundation/Photon"
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+/*
+getListQueryParams 从query参数中解析列表的查询条件,参数名和photon.ListQueryParams的json名字相同
+*/
+func getListQueryParams(r *rest.Request) (p *photon.ListQueryParams, err error) {
+	m := r.URL.Query()
+	p = &photon.ListQueryParams{
+		TokenAddress:      m.Get("token_address"),
+		Status:            m.Get("status"),
+		Type:              m.Get("type"),
+		Target:            m.Get("target_address"),
+		Initiator:         m.Get("initiator_address"),
+		ChannelIdentifier: m.Get("channel_identifier"),
+		Order:             m.Get("order"),
+		Cursor:            m.Get("cursor"),
+	}
+	ints := map[string]*int64{
+		"from_time":  &p.FromTime,
+		"to_time":    &p.ToTime,
+		"from_block": &p.FromBlock,
+		"to_block":   &p.ToBlock,
+	}
+	for name, v := range ints {
+		if s := m.Get(name); s != "" {
+			*v, err = strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				err = rerr.ErrArgumentError.Printf("invalid %s %s", name, s)
+				return
+			}
+		}
+	}
+	amounts := map[string]**big.Int{
+		"min_amount": &p.MinAmount,
+		"max_amount": &p.MaxAmount,
+	}
+	for name, v := range amounts {
+		if s := m.Get(name); s != "" {
+			a, ok := new(big.Int).SetString(s, 0)
+			if !ok {
+				err = rerr.ErrArgumentError.Printf("invalid %s %s", name, s)
+				return
+			}
+			*v = a
+		}
+	}
+	if s := m.Get("limit"); s != "" {
+		p.Limit, err = strconv.Atoi(s)
+		if err != nil {
+			err = rerr.ErrArgumentError.Printf("invalid limit %s", s)
+			return
+		}
+	}
+	return
+}
+
+/*
+ListSentTransfers 分页查询发出的交易
+*/
+func ListSentTransfers(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> ListSentTransfers ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	p, err := getListQueryParams(r)
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(err)
+		return
+	}
+	page, err := API.QuerySentTransfers(p)
+	resp = dto.NewAPIResponse(err, page)
+}
+
+/*
+ListReceivedTransfers 分页查询收到的交易
+*/
+func ListReceivedTransfers(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> ListReceivedTransfers ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	p, err := getListQueryParams(r)
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(err)
+		return
+	}
+	page, err := API.QueryReceivedTransfers(p)
+	resp = dto.NewAPIResponse(err, page)
+}
+
+/*
+ListFeeChargeRecords 分页查询收取的手续费
+*/
+func ListFeeChargeRecords(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> ListFeeChargeRecords ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	p, err := getListQueryParams(r)
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(err)
+		return
+	}
+	page, err := API.QueryFeeChargeRecords(p)
+	resp = dto.NewAPIResponse(err, page)
+}
+
+/*
+ListTXInfos 分页查询合约调用的tx
+*/
+func ListTXInfos(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> ListTXInfos ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	p, err := getListQueryParams(r)
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(err)
+		return
+	}
+	page, err := API.QueryTXInfos(p)
+	resp = dto.NewAPIResponse(err, page)
+}
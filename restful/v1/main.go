@@ -47,16 +47,14 @@ func Start() {
 		api.Use(rest.DefaultProdStack...)
 	}
 	api.Use(rest.DefaultDevStack...)
-	if HTTPUsername != "" && HTTPPassword != "" {
-		api.Use(&rest.AuthBasicMiddleware{
-			Realm: "please input username and password",
-			Authenticator: func(userId string, password string) bool {
-				return userId == HTTPUsername && password == HTTPPassword
-			},
-		})
-	}
+	api.Use(&authMiddleware{})
+	api.Use(&auditMiddleware{})
 	router, err := rest.MakeRouter(
-
+		/*
+			health check for process supervisors, no authentication
+		*/
+		rest.Get("/healthz", Healthz),
+		rest.Get("/readyz", Readyz),
 		/*
 			prepare update
 		*/
@@ -66,9 +64,31 @@ func Start() {
 		*/
 		rest.Get("/api/1/querysenttransfer", GetSentTransferDetails),
 		rest.Get("/api/1/queryreceivedtransfer", GetReceivedTransfers),
+		rest.Get("/api/1/list/sent_transfers", ListSentTransfers),
+		rest.Get("/api/1/list/received_transfers", ListReceivedTransfers),
+		rest.Get("/api/1/list/fees", ListFeeChargeRecords),
+		rest.Get("/api/1/list/txs", ListTXInfos),
 		rest.Post("/api/1/transfers/:token/:target", Transfers),
+		rest.Post("/api/1/batch_transfers", BatchTransfers),
+		rest.Get("/api/1/batch_transfers", GetBatchTransferList),
+		rest.Get("/api/1/batch_transfers/:key", GetBatchTransfer),
+		rest.Post("/api/1/payment_plans", CreatePaymentPlan),
+		rest.Get("/api/1/payment_plans", GetPaymentPlanList),
+		rest.Get("/api/1/payment_plans/:key", GetPaymentPlan),
+		rest.Put("/api/1/payment_plans/:key", UpdatePaymentPlan),
+		rest.Get("/api/1/spending_policies", GetSpendingPolicyList),
+		rest.Put("/api/1/spending_policies/:token", SetSpendingPolicy),
+		rest.Delete("/api/1/spending_policies/:token", RemoveSpendingPolicy),
+		rest.Get("/api/1/pending_approvals", GetPendingApprovalList),
+		rest.Get("/api/1/pending_approvals/:key", GetPendingApproval),
+		rest.Put("/api/1/pending_approvals/:key", UpdatePendingApproval),
+		rest.Get("/api/1/mediation_policies", GetMediationPolicyList),
+		rest.Put("/api/1/mediation_policies/:token", SetMediationPolicy),
+		rest.Delete("/api/1/mediation_policies/:token", RemoveMediationPolicy),
 		rest.Get("/api/1/transferstatus/:token/:locksecrethash", GetSentTransferDetail),
 		rest.Post("/api/1/transfercancel/:token/:locksecrethash", CancelTransfer),
+		rest.Get("/api/1/receipts/:token/:locksecrethash", GetPaymentReceipt),
+		rest.Post("/api/1/receipts/verify", VerifyPaymentReceipt),
 		/*
 			transfer with specified secret
 		*/
@@ -133,6 +153,17 @@ func Start() {
 		rest.Get("/api/1/stop", Stop),
 		rest.Get("/api/1/switch/:mesh", SwitchNetwork),
 		rest.Post("/api/1/updatenodes", UpdateMeshNetworkNodes),
+		rest.Get("/api/1/peer_bans", GetPeerBans),
+		rest.Delete("/api/1/peer_bans/:addr", LiftPeerBan),
+		rest.Get("/api/1/audit_logs", GetAuditLogList),
+		rest.Get("/api/1/audit_logs/verify", VerifyAuditLog),
+		rest.Get("/api/1/reconcile", GetReconcileReport),
+		rest.Post("/api/1/reconcile", Reconcile),
+		rest.Get("/api/1/retention", GetRetentionReport),
+		rest.Post("/api/1/retention", Archive),
+		rest.Get("/api/1/recovery", GetChannelRecoveryList),
+		rest.Post("/api/1/recovery/:channel/abandon", AbandonChannelRecovery),
+		rest.Get("/api/1/alerts", GetAlerts),
 
 		/*
 			1. withdraw
@@ -167,6 +198,10 @@ func Start() {
 		rest.Get("/api/1/debug/force-unlock/:channel/:secret", ForceUnlock),
 		rest.Get("/api/1/debug/register-secret-onchain/:secret", RegisterSecretOnChain),
 		rest.Get("/api/1/debug/pfs/:channel", BalanceUpdateForPFS),
+		rest.Get("/api/1/debug/state-managers", GetStateManagerList),
+		rest.Get("/api/1/debug/state-managers/:key", GetStateManager),
+		rest.Get("/api/1/debug/state-managers/:key/record", GetStateManagerRecord),
+		rest.Get("/api/1/debug/transfer-logs/:locksecrethash", GetTransferLogs),
 		rest.Post("/api/1/debug/notify_network_down", NotifyNetworkDown), // notify photon network down
 		rest.Get("/api/1/debug/shutdown", func(writer rest.ResponseWriter, request *rest.Request) {
 			API.Photon.Stop()
@@ -179,7 +214,24 @@ func Start() {
 	api.SetApp(router)
 	listen := fmt.Sprintf("%s:%d", Config.APIHost, Config.APIPort)
 	server := &http.Server{Addr: listen, Handler: api.MakeHandler()}
-	go server.ListenAndServe()
+	if Config.APITLSCert != "" && Config.APITLSKey != "" {
+		server.TLSConfig, err = newTLSConfig(Config.APIClientCA)
+		if err != nil {
+			log.Crit(fmt.Sprintf("load api client ca err %s", err))
+		}
+	}
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS(Config.APITLSCert, Config.APITLSKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		// 端口被占用或者证书不对的时候直接退出,不能让节点在没有API的情况下运行
+		if err != nil && err != http.ErrServerClosed {
+			log.Crit(fmt.Sprintf("api listen on %s err %s", listen, err))
+		}
+	}()
 	<-QuitChain
 	err = server.Shutdown(context.Background())
 	if err != nil {
@@ -0,0 +1,68 @@
+package v1
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+/*
+GetMediationPolicyList returns mediation policies of all tokens,the one of token 0x0000000000000000000000000000000000000000 is the default policy
+*/
+func GetMediationPolicyList(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetMediationPolicyList ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	ps, err := API.GetMediationPolicyList()
+	resp = dto.NewAPIResponse(err, ps)
+}
+
+/*
+SetMediationPolicy is the api of PUT /mediation_policies/:token,replace the mediation policy of the token
+*/
+func SetMediationPolicy(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> SetMediationPolicy ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	tokenAddr, err := utils.HexToAddress(r.PathParam("token"))
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	p := &models.MediationPolicy{}
+	err = r.DecodeJsonPayload(p)
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	p.TokenAddress = tokenAddr
+	err = API.SetMediationPolicy(p)
+	resp = dto.NewAPIResponse(err, p)
+}
+
+/*
+RemoveMediationPolicy is the api of DELETE /mediation_policies/:token
+*/
+func RemoveMediationPolicy(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> RemoveMediationPolicy ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	tokenAddr, err := utils.HexToAddress(r.PathParam("token"))
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	err = API.RemoveMediationPolicy(tokenAddr)
+	resp = dto.NewAPIResponse(err, nil)
+}
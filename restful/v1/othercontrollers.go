@@ -227,3 +227,29 @@ func GetBuildInfo(w rest.ResponseWriter, r *rest.Request) {
 	}()
 	resp = dto.NewSuccessAPIResponse(API.GetBuildInfo())
 }
+
+// GetPeerBans : 因为发送太多消息或者无效消息被限制的节点
+func GetPeerBans(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetPeerBans ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	resp = dto.NewSuccessAPIResponse(API.GetPeerBans())
+}
+
+// LiftPeerBan : 解除对节点的封禁
+func LiftPeerBan(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> LiftPeerBan ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	addr, err := utils.HexToAddress(r.PathParam("addr"))
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	err = API.LiftPeerBan(addr)
+	resp = dto.NewAPIResponse(err, nil)
+}
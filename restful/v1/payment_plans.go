@@ -0,0 +1,116 @@
+package v1
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+//PaymentPlanData post for payment plans
+type PaymentPlanData struct {
+	Token     string   `json:"token_address"`
+	Target    string   `json:"target_address"`
+	Amount    *big.Int `json:"amount"`
+	Data      string   `json:"data"`
+	StartTime int64    `json:"start_time"` // 第一次支付的时间戳,不指定表示立即开始
+	Interval  int64    `json:"interval"`   // 支付间隔,单位秒,不指定表示只支付一次
+	EndTime   int64    `json:"end_time"`
+	MaxTimes  int      `json:"max_times"`
+}
+
+/*
+CreatePaymentPlan is the api of POST /payment_plans
+*/
+func CreatePaymentPlan(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> CreatePaymentPlan ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	req := &PaymentPlanData{}
+	err := r.DecodeJsonPayload(req)
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	tokenAddr, err := utils.HexToAddress(req.Token)
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	targetAddr, err := utils.HexToAddress(req.Target)
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	p, err := API.CreatePaymentPlan(tokenAddr, targetAddr, req.Amount, req.Data, req.StartTime, req.Interval, req.EndTime, req.MaxTimes, spendingCapKey(r))
+	resp = dto.NewAPIResponse(err, p)
+}
+
+/*
+GetPaymentPlanList returns all payment plans
+*/
+func GetPaymentPlanList(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetPaymentPlanList ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	ps, err := API.GetPaymentPlanList()
+	resp = dto.NewAPIResponse(err, ps)
+}
+
+/*
+GetPaymentPlan returns a payment plan and its recent payments
+*/
+func GetPaymentPlan(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetPaymentPlan ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	p, err := API.GetPaymentPlan(r.PathParam("key"))
+	resp = dto.NewAPIResponse(err, p)
+}
+
+/*
+UpdatePaymentPlan pause,resume or cancel a payment plan
+*/
+func UpdatePaymentPlan(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> UpdatePaymentPlan ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	type Req struct {
+		Op string `json:"op"`
+	}
+	const OpPause = "pause"
+	const OpResume = "resume"
+	const OpCancel = "cancel"
+	req := &Req{}
+	err := r.DecodeJsonPayload(req)
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	key := r.PathParam("key")
+	var p *models.PaymentPlan
+	switch req.Op {
+	case OpPause:
+		p, err = API.PausePaymentPlan(key)
+	case OpResume:
+		p, err = API.ResumePaymentPlan(key)
+	case OpCancel:
+		p, err = API.CancelPaymentPlan(key)
+	default:
+		err = rerr.ErrArgumentError.Errorf("unkown operation %s", req.Op)
+	}
+	resp = dto.NewAPIResponse(err, p)
+}
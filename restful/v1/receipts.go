@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+GetPaymentReceipt export the signed receipt of a successful sent transfer
+*/
+func GetPaymentReceipt(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetPaymentReceipt ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	lockSecretHash := common.HexToHash(r.PathParam("locksecrethash"))
+	tokenAddr, err := utils.HexToAddress(r.PathParam("token"))
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	receipt, err := API.GetPaymentReceipt(tokenAddr, lockSecretHash)
+	resp = dto.NewAPIResponse(err, receipt)
+}
+
+/*
+VerifyPaymentReceipt check signatures of a receipt,it does not depend on state of this node
+*/
+func VerifyPaymentReceipt(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> VerifyPaymentReceipt ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	receipt := &models.PaymentReceipt{}
+	err := r.DecodeJsonPayload(receipt)
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	err = API.VerifyPaymentReceipt(receipt)
+	resp = dto.NewAPIResponse(err, receipt)
+}
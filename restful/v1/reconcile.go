@@ -0,0 +1,34 @@
+package v1
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+/*
+GetReconcileReport 最近一次和合约对账的结果,还没有对账时data为null
+*/
+func GetReconcileReport(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetReconcileReport ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	resp = dto.NewSuccessAPIResponse(API.GetReconcileReport())
+}
+
+/*
+Reconcile 立即和合约对账一次,返回对账的结果
+*/
+func Reconcile(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> Reconcile ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	report, err := API.Reconcile()
+	resp = dto.NewAPIResponse(err, report)
+}
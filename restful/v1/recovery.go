@@ -0,0 +1,43 @@
+package v1
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+GetChannelRecoveryList 数据库丢失以后从对方恢复通道的记录
+*/
+func GetChannelRecoveryList(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetChannelRecoveryList ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	rs, err := API.GetChannelRecoveryList()
+	resp = dto.NewAPIResponse(err, rs)
+}
+
+/*
+AbandonChannelRecovery 放弃从对方恢复通道,通道使用链上事件重建的状态
+*/
+func AbandonChannelRecovery(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> AbandonChannelRecovery ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	channelIdentifier := common.HexToHash(r.PathParam("channel"))
+	if channelIdentifier == utils.EmptyHash {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.Append("invalid channel identifier"))
+		return
+	}
+	err := API.AbandonChannelRecovery(channelIdentifier)
+	resp = dto.NewAPIResponse(err, nil)
+}
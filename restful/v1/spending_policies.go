@@ -0,0 +1,127 @@
+package v1
+
+import (
+	"fmt"
+
+	"github.com/SmartMeshFoundation/Photon/dto"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+/*
+GetSpendingPolicyList returns spending policies of all tokens
+*/
+func GetSpendingPolicyList(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetSpendingPolicyList ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	ps, err := API.GetSpendingPolicyList()
+	resp = dto.NewAPIResponse(err, ps)
+}
+
+/*
+SetSpendingPolicy is the api of PUT /spending_policies/:token,replace the policy of the token
+*/
+func SetSpendingPolicy(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> SetSpendingPolicy ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	tokenAddr, err := utils.HexToAddress(r.PathParam("token"))
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	p := &models.SpendingPolicy{}
+	err = r.DecodeJsonPayload(p)
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	p.TokenAddress = tokenAddr
+	err = API.SetSpendingPolicy(p)
+	resp = dto.NewAPIResponse(err, p)
+}
+
+/*
+RemoveSpendingPolicy is the api of DELETE /spending_policies/:token
+*/
+func RemoveSpendingPolicy(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> RemoveSpendingPolicy ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	tokenAddr, err := utils.HexToAddress(r.PathParam("token"))
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	err = API.RemoveSpendingPolicy(tokenAddr)
+	resp = dto.NewAPIResponse(err, nil)
+}
+
+/*
+GetPendingApprovalList returns all operations waiting for approval or already approved/rejected
+*/
+func GetPendingApprovalList(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetPendingApprovalList ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	as, err := API.GetPendingApprovalList()
+	resp = dto.NewAPIResponse(err, as)
+}
+
+/*
+GetPendingApproval returns a pending approval
+*/
+func GetPendingApproval(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> GetPendingApproval ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	a, err := API.GetPendingApproval(r.PathParam("key"))
+	resp = dto.NewAPIResponse(err, a)
+}
+
+/*
+UpdatePendingApproval approve or reject a pending approval
+*/
+func UpdatePendingApproval(w rest.ResponseWriter, r *rest.Request) {
+	var resp *dto.APIResponse
+	defer func() {
+		log.Trace(fmt.Sprintf("Restful Api Call ----> UpdatePendingApproval ,err=%s", resp.ToFormatString()))
+		writejson(w, resp)
+	}()
+	type Req struct {
+		Op string `json:"op"`
+	}
+	const OpApprove = "approve"
+	const OpReject = "reject"
+	req := &Req{}
+	err := r.DecodeJsonPayload(req)
+	if err != nil {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err))
+		return
+	}
+	key := r.PathParam("key")
+	var a *models.PendingApproval
+	switch req.Op {
+	case OpApprove:
+		a, err = API.ApprovePendingApproval(key)
+	case OpReject:
+		a, err = API.RejectPendingApproval(key)
+	default:
+		err = rerr.ErrArgumentError.Errorf("unkown operation %s", req.Op)
+	}
+	resp = dto.NewAPIResponse(err, a)
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/SmartMeshFoundation/Photon/dto"
 	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
 	"github.com/SmartMeshFoundation/Photon/params"
 	"github.com/SmartMeshFoundation/Photon/pfsproxy"
 	"github.com/SmartMeshFoundation/Photon/utils"
@@ -18,16 +19,21 @@ import (
 
 //TransferData post for transfers
 type TransferData struct {
-	Initiator      string                      `json:"initiator_address"`
-	Target         string                      `json:"target_address"`
-	Token          string                      `json:"token_address"`
-	Amount         *big.Int                    `json:"amount"`
-	Secret         string                      `json:"secret,omitempty"` // 当用户想使用自己指定的密码,而非随机密码时使用	// client can assign specific secret
-	LockSecretHash string                      `json:"lockSecretHash"`
-	IsDirect       bool                        `json:"is_direct,omitempty"`
-	Sync           bool                        `json:"sync,omitempty"` //是否同步
-	Data           string                      `json:"data"`           // 交易附加信息,长度不超过256
-	RouteInfo      []pfsproxy.FindPathResponse `json:"route_info"`     // 指定的路由信息
+	Initiator       string                      `json:"initiator_address"`
+	Target          string                      `json:"target_address"`
+	Token           string                      `json:"token_address"`
+	Amount          *big.Int                    `json:"amount"`
+	Secret          string                      `json:"secret,omitempty"` // 当用户想使用自己指定的密码,而非随机密码时使用	// client can assign specific secret
+	LockSecretHash  string                      `json:"lockSecretHash"`
+	IsDirect        bool                        `json:"is_direct,omitempty"`
+	Sync            bool                        `json:"sync,omitempty"`                   //是否同步
+	Data            string                      `json:"data"`                             // 交易附加信息,长度不超过256
+	RouteInfo       []pfsproxy.FindPathResponse `json:"route_info"`                       // 指定的路由信息
+	Keysend         bool                        `json:"keysend,omitempty"`                // 密码加密给target,target不需要交互直接领取
+	TargetPublicKey string                      `json:"target_public_key,omitempty"`      // keysend时target的公钥,不指定则使用从target消息中恢复的公钥
+	Onion           bool                        `json:"onion,omitempty"`                  // 使用onion路由的keysend交易,中间节点不知道发起方,target和完整路径
+	OnionPublicKeys map[string]string           `json:"onion_public_keys,omitempty"`      // onion路由中节点地址到公钥,不指定的节点使用从它的消息中恢复的公钥
+	OnionAllowClear bool                        `json:"onion_allow_clear_path,omitempty"` // 不能创建onion的路由退化为普通keysend交易,明文发送路径
 }
 
 /*
@@ -102,16 +108,73 @@ func Transfers(w rest.ResponseWriter, r *rest.Request) {
 		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.Append("invalid secret"))
 		return
 	}
-	if len(req.Data) > params.MaxTransferDataLen {
-		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.Append("Invalid data, length must < 256"))
+	// 普通交易的附言加密以后单独发送给target,keysend交易的附言和密码一起加密在MediatedTransfer中
+	maxDataLen := params.MaxMemoLen
+	if req.Keysend || req.Onion {
+		maxDataLen = params.MaxTransferDataLen
+	}
+	if len(req.Data) > maxDataLen {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.Printf("Invalid data, length must < %d", maxDataLen))
+		return
+	}
+	if (req.Keysend || req.Onion) && (len(req.Secret) != 0 || req.IsDirect) {
+		resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.Append("keysend transfer can not specify secret or be direct"))
 		return
 	}
-	var result *utils.AsyncResult
-	if req.Sync {
-		result, err = API.Transfer(tokenAddr, req.Amount, targetAddr, common.HexToHash(req.Secret), params.MaxRequestTimeout, req.IsDirect, req.Data, req.RouteInfo)
-	} else {
-		result, err = API.TransferAsync(tokenAddr, req.Amount, targetAddr, common.HexToHash(req.Secret), req.IsDirect, req.Data, req.RouteInfo)
+	onionPublicKeys := make(map[common.Address][]byte)
+	if req.Onion {
+		for addr, pubkey := range req.OnionPublicKeys {
+			hopAddr, err2 := utils.HexToAddress(addr)
+			if err2 != nil {
+				resp = dto.NewExceptionAPIResponse(rerr.ErrArgumentError.AppendError(err2))
+				return
+			}
+			onionPublicKeys[hopAddr] = common.FromHex(pubkey)
+		}
+		if len(req.TargetPublicKey) > 0 {
+			onionPublicKeys[targetAddr] = common.FromHex(req.TargetPublicKey)
+		}
 	}
+	// 只比较决定支付内容的参数,sync和路由信息不同仍然是同一笔交易
+	requestParams := []interface{}{tokenAddr, targetAddr, req.Amount, common.HexToHash(req.Secret), req.IsDirect, req.Data, req.Keysend, req.Onion}
+	record, _, err := API.Idempotent(getIdempotencyKey(r), models.IdempotencyOperationTransfer, requestParams, func() (*models.IdempotencyRecord, error) {
+		var result *utils.AsyncResult
+		apiTokenKey, err2 := spendAPIToken(r, tokenAddr, req.Amount)
+		if err2 != nil {
+			return nil, err2
+		}
+		if req.Onion {
+			if req.Sync {
+				result, err2 = API.OnionTransfer(tokenAddr, req.Amount, targetAddr, onionPublicKeys, req.OnionAllowClear, params.MaxRequestTimeout, req.Data, req.RouteInfo)
+			} else {
+				result, err2 = API.OnionTransferAsync(tokenAddr, req.Amount, targetAddr, onionPublicKeys, req.OnionAllowClear, req.Data, req.RouteInfo)
+			}
+		} else if req.Keysend {
+			if req.Sync {
+				result, err2 = API.Keysend(tokenAddr, req.Amount, targetAddr, common.FromHex(req.TargetPublicKey), params.MaxRequestTimeout, req.Data, req.RouteInfo)
+			} else {
+				result, err2 = API.KeysendAsync(tokenAddr, req.Amount, targetAddr, common.FromHex(req.TargetPublicKey), req.Data, req.RouteInfo)
+			}
+		} else if req.Sync {
+			result, err2 = API.Transfer(tokenAddr, req.Amount, targetAddr, common.HexToHash(req.Secret), params.MaxRequestTimeout, req.IsDirect, req.Data, req.RouteInfo)
+		} else {
+			result, err2 = API.TransferAsync(tokenAddr, req.Amount, targetAddr, common.HexToHash(req.Secret), req.IsDirect, req.Data, req.RouteInfo)
+		}
+		// 没有生成lockSecretHash说明交易根本没有发起
+		if result == nil || result.LockSecretHash == utils.EmptyHash {
+			if apiTokenKey != "" {
+				API.RefundAPIToken(apiTokenKey, tokenAddr, req.Amount)
+			}
+			return nil, err2
+		}
+		if apiTokenKey != "" {
+			API.ChargeAPIToken(apiTokenKey, tokenAddr, result.LockSecretHash, req.Amount)
+		}
+		return &models.IdempotencyRecord{
+			TokenAddress:   tokenAddr,
+			LockSecretHash: result.LockSecretHash,
+		}, err2
+	})
 	if err != nil {
 		resp = dto.NewExceptionAPIResponse(err)
 		return
@@ -119,7 +182,7 @@ func Transfers(w rest.ResponseWriter, r *rest.Request) {
 	req.Initiator = API.Photon.NodeAddress.String()
 	req.Target = target
 	req.Token = token
-	req.LockSecretHash = result.LockSecretHash.String()
+	req.LockSecretHash = record.LockSecretHash.String()
 	resp = dto.NewSuccessAPIResponse(req)
 }
 
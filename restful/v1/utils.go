@@ -3,9 +3,12 @@ package v1
 import (
 	"fmt"
 
+	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
 	"github.com/SmartMeshFoundation/Photon/dto"
 
 	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
 	"github.com/SmartMeshFoundation/Photon/utils"
 	"github.com/ant0ine/go-json-rest/rest"
 )
@@ -29,3 +32,71 @@ func writejson(w rest.ResponseWriter, result interface{}) {
 		log.Warn(fmt.Sprintf("writejson err %s", err))
 	}
 }
+
+/*
+getIdempotencyKey 客户端通过Idempotency-Key header指定幂等key,
+使用同一个key重复提交的transfer,deposit,withdraw,close请求只会被执行一次,返回第一次的结果
+*/
+func getIdempotencyKey(r *rest.Request) string {
+	return r.Header.Get("Idempotency-Key")
+}
+
+func newChannelIdempotencyRecord(c *channeltype.Serialization) *models.IdempotencyRecord {
+	return &models.IdempotencyRecord{
+		TokenAddress:      c.TokenAddress(),
+		PartnerAddress:    c.PartnerAddress(),
+		ChannelIdentifier: c.ChannelIdentifier.ChannelIdentifier,
+	}
+}
+
+/*
+newChannelIdempotencyResult 通道操作失败的时候,参数和通道状态的错误说明什么都没有做,可以使用同一个key重试.
+其他错误发生时tx可能已经发出,和交易一样保存结果,重试只返回第一次的错误,不会重复执行
+*/
+func newChannelIdempotencyResult(c *channeltype.Serialization, err error) (*models.IdempotencyRecord, error) {
+	if err == nil {
+		return newChannelIdempotencyRecord(c), nil
+	}
+	if c == nil {
+		return nil, err
+	}
+	e, ok := err.(rerr.StandardError)
+	if !ok {
+		e = rerr.ErrUnrecognized.AppendError(err)
+	}
+	switch {
+	case ok && e.ErrorCode < rerr.ErrInsufficientBalanceForGas.ErrorCode:
+		return nil, err
+	case e.ErrorCode == rerr.ErrInsufficientBalanceForGas.ErrorCode,
+		e.ErrorCode == rerr.ErrSpectrumNotConnected.ErrorCode,
+		e.ErrorCode == rerr.ErrSpectrumSyncError.ErrorCode:
+		//tx没有发出
+		return nil, err
+	}
+	record := newChannelIdempotencyRecord(c)
+	record.ErrorCode = e.ErrorCode
+	record.ErrorMsg = e.ErrorMsg
+	return record, err
+}
+
+/*
+getIdempotentChannel 重复请求返回通道的当前状态,
+新创建的通道在tx被打包之前是查不到的,这时候和DepositAndOpenChannel一样返回一个空的通道.
+第一次请求失败的话返回当时的错误
+*/
+func getIdempotentChannel(record *models.IdempotencyRecord) (*channeltype.Serialization, error) {
+	if record.ErrorCode != 0 {
+		return nil, rerr.StandardError{ErrorCode: record.ErrorCode, ErrorMsg: record.ErrorMsg}
+	}
+	c, err := API.GetChannel(record.ChannelIdentifier)
+	if err == nil {
+		return c, nil
+	}
+	c = channeltype.NewEmptySerialization()
+	c.ChannelIdentifier.ChannelIdentifier = record.ChannelIdentifier
+	c.TokenAddressBytes = record.TokenAddress[:]
+	c.OurAddress = API.Photon.NodeAddress
+	c.PartnerAddressBytes = record.PartnerAddress[:]
+	c.State = channeltype.StateInValid
+	return c, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/SmartMeshFoundation/Photon/channel"
+	"github.com/SmartMeshFoundation/Photon/internal/tracing"
 	"github.com/SmartMeshFoundation/Photon/log"
 	"github.com/SmartMeshFoundation/Photon/transfer"
 	"github.com/SmartMeshFoundation/Photon/transfer/mediatedtransfer"
@@ -26,7 +27,10 @@ import (
  *		2. to create related StateManager as to those locks withholden by a particpant.
  */
 func (rs *Service) restore() {
-	//1. 处理未完成的锁
+	//0. 从快照和write-ahead log恢复交易状态机
+	// 0. replay state managers from snapshots and write-ahead log
+	rs.restoreStateManagers()
+	//1. 处理未完成的锁,已经恢复了状态机的交易不再创建crashnode
 	// 1. handle incomplete locks
 	rs.restoreLocks()
 	//打印回复后的通道信息
@@ -59,50 +63,63 @@ type lockInfo struct {
 }
 
 func (rs *Service) restoreLocks() {
-	token2ActionInitCrashRestartStateChange := make(map[common.Hash]*mediatedtransfer.ActionInitCrashRestartStateChange)
-	var locks []*lockInfo
-	//收集所有的锁,
-	// collect all locks.
+	var chs []*channel.Channel
 	//log.Trace(fmt.Sprintf("Token2TokenNetwork=%s", utils.StringInterface(rs.Token2TokenNetwork, 7)))
 	//log.Trace(fmt.Sprintf("Token2ChannelGraph=%s", utils.StringInterface(rs.Token2ChannelGraph, 7)))
 	for token := range rs.Token2TokenNetwork {
 		g := rs.Token2ChannelGraph[token]
 		//log.Trace(fmt.Sprintf("process token=%s", token.String()))
 		for _, ch := range g.ChannelIdentifier2Channel {
-			for _, l := range ch.OurState.Lock2PendingLocks {
-				locks = append(locks, &lockInfo{
-					l:      l.Lock,
-					isSent: true,
-					token:  token,
-					ch:     ch,
-				})
-			}
-			for _, l := range ch.OurState.Lock2UnclaimedLocks {
-				//todo 密码已经链上注册的锁,需要跳过
-				locks = append(locks, &lockInfo{
-					l:      l.Lock,
-					isSent: true,
-					token:  token,
-					ch:     ch,
-				})
-			}
-			for _, l := range ch.PartnerState.Lock2PendingLocks {
-				locks = append(locks, &lockInfo{
-					l:      l.Lock,
-					isSent: false,
-					token:  token,
-					ch:     ch,
-				})
-			}
-			for _, l := range ch.PartnerState.Lock2UnclaimedLocks {
-				//todo 密码已经链上注册的锁,需要跳过
-				locks = append(locks, &lockInfo{
-					l:      l.Lock,
-					isSent: false,
-					token:  token,
-					ch:     ch,
-				})
-			}
+			chs = append(chs, ch)
+		}
+	}
+	rs.restoreLocksOfChannels(chs)
+}
+
+/*
+restoreLocksOfChannels 为这些通道中持有的锁建立crashnode StateManager,
+从通道对方恢复数据以后也需要调用
+*/
+func (rs *Service) restoreLocksOfChannels(chs []*channel.Channel) {
+	token2ActionInitCrashRestartStateChange := make(map[common.Hash]*mediatedtransfer.ActionInitCrashRestartStateChange)
+	var locks []*lockInfo
+	//收集所有的锁,
+	// collect all locks.
+	for _, ch := range chs {
+		token := ch.TokenAddress
+		for _, l := range ch.OurState.Lock2PendingLocks {
+			locks = append(locks, &lockInfo{
+				l:      l.Lock,
+				isSent: true,
+				token:  token,
+				ch:     ch,
+			})
+		}
+		for _, l := range ch.OurState.Lock2UnclaimedLocks {
+			//todo 密码已经链上注册的锁,需要跳过
+			locks = append(locks, &lockInfo{
+				l:      l.Lock,
+				isSent: true,
+				token:  token,
+				ch:     ch,
+			})
+		}
+		for _, l := range ch.PartnerState.Lock2PendingLocks {
+			locks = append(locks, &lockInfo{
+				l:      l.Lock,
+				isSent: false,
+				token:  token,
+				ch:     ch,
+			})
+		}
+		for _, l := range ch.PartnerState.Lock2UnclaimedLocks {
+			//todo 密码已经链上注册的锁,需要跳过
+			locks = append(locks, &lockInfo{
+				l:      l.Lock,
+				isSent: false,
+				token:  token,
+				ch:     ch,
+			})
 		}
 	}
 	//log.Trace(fmt.Sprintf("after restart current locks %s", utils.StringInterface(locks, 4)))
@@ -137,6 +154,10 @@ func (rs *Service) restoreLocks() {
 	//根据ActionInitCrashRestartStateChange,创建对应的 stateManager
 	// Create corresponding stateManager, according to ActionInitCrashRestartStateChange.
 	for k, st := range token2ActionInitCrashRestartStateChange {
+		if rs.Transfer2StateManager[k] != nil {
+			continue
+		}
+		tracing.StartTransfer(st.LockSecretHash, "restore crashed transfer", "token", st.Token)
 		stateManager := transfer.NewStateManager(crashnode.StateTransition, nil, crashnode.NameCrashNodeTransition, st.LockSecretHash, st.Token)
 		rs.Transfer2StateManager[k] = stateManager
 		rs.StateMachineEventHandler.dispatch(stateManager, st)
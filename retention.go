@@ -0,0 +1,389 @@
+package photon
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//RetentionTableReport 一次归档中一个表的结果
+type RetentionTableReport struct {
+	Table    string `json:"table"`
+	Archived int    `json:"archived"`
+	File     string `json:"file,omitempty"` // 归档文件,每行一条json记录,gzip压缩
+	Error    string `json:"error,omitempty"`
+}
+
+//RetentionReport 一次归档的结果
+type RetentionReport struct {
+	StartTime int64                   `json:"start_time"`
+	EndTime   int64                   `json:"end_time"`
+	Tables    []*RetentionTableReport `json:"tables"`
+	Compacted bool                    `json:"compacted"`
+	Error     string                  `json:"error,omitempty"` // 压缩失败的原因
+}
+
+/*
+retentionLoop 定时归档超过保留时间的历史记录
+*/
+func (rs *Service) retentionLoop() {
+	if rs.Config.Retention.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(rs.Config.Retention.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rs.archive()
+		case <-rs.quitChan:
+			return
+		}
+	}
+}
+
+func (rs *Service) archiveDir() string {
+	return filepath.Join(filepath.Dir(rs.Config.DataBasePath), "archive")
+}
+
+/*
+archive 先把超过保留时间的记录写到归档文件,然后再从数据库删除,
+写文件失败的表这次不删除.收款和手续费记录删除的时候累加到按小时的收益汇总中
+*/
+func (rs *Service) archive() (report *RetentionReport) {
+	rs.retentionLock.Lock()
+	defer rs.retentionLock.Unlock()
+	cfg := rs.Config.Retention
+	now := time.Now()
+	report = &RetentionReport{
+		StartTime: now.Unix(),
+		Tables:    []*RetentionTableReport{},
+	}
+	add := func(table string, n int, file string, err error) {
+		t := &RetentionTableReport{
+			Table:    table,
+			Archived: n,
+			File:     file,
+		}
+		if err != nil {
+			t.Error = err.Error()
+			log.Error(fmt.Sprintf("archive %s err %s", table, err))
+		} else if n > 0 {
+			log.Info(fmt.Sprintf("archive %d records of %s to %s", n, table, file))
+		}
+		report.Tables = append(report.Tables, t)
+	}
+	if cfg.ReceivedTransfer > 0 || cfg.FeeChargeRecord > 0 {
+		rs.archiveIncomeRecords(now, add)
+	}
+	if cfg.SentTransferDetail > 0 {
+		n, file, err := rs.archiveSentTransferDetails(now)
+		add(models.BucketSentTransferDetail, n, file, err)
+	}
+	if cfg.TXInfo > 0 {
+		n, file, err := rs.archiveTXInfos(now)
+		add(models.BucketTXInfo, n, file, err)
+	}
+	if cfg.Ack > 0 {
+		n, file, err := rs.archiveAcks(now)
+		add(models.BucketAck, n, file, err)
+	}
+	if cfg.TransferMemo > 0 {
+		n, file, err := rs.archiveTransferMemos(now)
+		add(models.BucketTransferMemo, n, file, err)
+	}
+	if cfg.ChainEventBlocks > 0 {
+		n, file, err := rs.archiveChainEventRecords(now)
+		add(models.BucketChainEventRecord, n, file, err)
+	}
+	if cfg.Compact {
+		err := rs.dao.Compact()
+		if err != nil {
+			report.Error = err.Error()
+			log.Warn(fmt.Sprintf("compact db err %s", err))
+		} else {
+			report.Compacted = true
+		}
+	}
+	report.EndTime = time.Now().Unix()
+	rs.retentionReport = report
+	return
+}
+
+/*
+writeArchive 每条记录一行json,gzip压缩,文件sync以后才能删除数据库中的记录
+*/
+func (rs *Service) writeArchive(table string, now time.Time, rows []interface{}) (file string, err error) {
+	dir := rs.archiveDir()
+	err = os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return
+	}
+	file = filepath.Join(dir, fmt.Sprintf("%s-%s.json.gz", table, now.Format("20060102-150405.000000000")))
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(file)
+		}
+	}()
+	zw := gzip.NewWriter(f)
+	enc := json.NewEncoder(zw)
+	for _, r := range rows {
+		err = enc.Encode(r)
+		if err != nil {
+			f.Close()
+			return
+		}
+	}
+	err = zw.Close()
+	if err == nil {
+		err = f.Sync()
+	}
+	err2 := f.Close()
+	if err == nil {
+		err = err2
+	}
+	return
+}
+
+func (rs *Service) archiveIncomeRecords(now time.Time, add func(table string, n int, file string, err error)) {
+	cfg := rs.Config.Retention
+	var err error
+	var rts []*models.ReceivedTransfer
+	var fees []*models.FeeChargeRecord
+	if cfg.ReceivedTransfer > 0 {
+		rts, err = rs.dao.GetReceivedTransferList(utils.EmptyAddress, -1, -1, -1, now.Add(-cfg.ReceivedTransfer).Unix())
+		if err != nil {
+			add(models.BucketReceivedTransfer, 0, "", err)
+			return
+		}
+	}
+	if cfg.FeeChargeRecord > 0 {
+		fees, err = rs.dao.GetAllFeeChargeRecord(utils.EmptyAddress, -1, now.Add(-cfg.FeeChargeRecord).Unix())
+		if err != nil {
+			add(models.BucketFeeChargeRecord, 0, "", err)
+			return
+		}
+	}
+	if len(rts) == 0 && len(fees) == 0 {
+		return
+	}
+	aggregates := make(map[string]*models.IncomeAggregate)
+	aggregate := func(token common.Address, timestamp int64) *models.IncomeAggregate {
+		a := models.NewIncomeAggregate(token, timestamp)
+		if old, ok := aggregates[a.Key]; ok {
+			return old
+		}
+		aggregates[a.Key] = a
+		return a
+	}
+	var rtKeys []string
+	var rtRows []interface{}
+	for _, rt := range rts {
+		rtKeys = append(rtKeys, rt.Key)
+		rtRows = append(rtRows, rt)
+		//和GetIncomeDetails一样,只有data不为空的收款才是收益
+		if rt.Data != "" {
+			a := aggregate(rt.TokenAddress, rt.TimeStamp)
+			a.TransferAmount.Add(a.TransferAmount, rt.Amount)
+			a.TransferCount++
+		}
+	}
+	var feeKeys []common.Hash
+	var feeRows []interface{}
+	for _, f := range fees {
+		feeKeys = append(feeKeys, f.Key)
+		feeRows = append(feeRows, f)
+		a := aggregate(f.TokenAddress, f.Timestamp)
+		a.FeeAmount.Add(a.FeeAmount, f.Fee)
+		a.FeeCount++
+	}
+	var rtFile, feeFile string
+	if len(rtRows) > 0 {
+		rtFile, err = rs.writeArchive(models.BucketReceivedTransfer, now, rtRows)
+		if err != nil {
+			add(models.BucketReceivedTransfer, 0, "", err)
+			return
+		}
+	}
+	if len(feeRows) > 0 {
+		feeFile, err = rs.writeArchive(models.BucketFeeChargeRecord, now, feeRows)
+		if err != nil {
+			add(models.BucketFeeChargeRecord, 0, "", err)
+			return
+		}
+	}
+	var as []*models.IncomeAggregate
+	for _, a := range aggregates {
+		as = append(as, a)
+	}
+	err = rs.dao.ArchiveIncomeRecords(rtKeys, feeKeys, as)
+	if err != nil {
+		//没有删除的记录下次会再归档一次,归档文件中会有重复的记录
+		rtKeys, feeKeys = nil, nil
+	}
+	if cfg.ReceivedTransfer > 0 {
+		add(models.BucketReceivedTransfer, len(rtKeys), rtFile, err)
+	}
+	if cfg.FeeChargeRecord > 0 {
+		add(models.BucketFeeChargeRecord, len(feeKeys), feeFile, err)
+	}
+}
+
+func (rs *Service) archiveSentTransferDetails(now time.Time) (n int, file string, err error) {
+	transfers, err := rs.dao.GetSentTransferDetailList(utils.EmptyAddress, -1, now.Add(-rs.Config.Retention.SentTransferDetail).Unix(), -1, -1)
+	if err != nil {
+		return
+	}
+	var keys []string
+	var rows []interface{}
+	for _, t := range transfers {
+		//还没有完成的交易FinishTime为0
+		if t.FinishTime <= 0 {
+			continue
+		}
+		keys = append(keys, t.Key)
+		rows = append(rows, t)
+	}
+	if len(keys) == 0 {
+		return
+	}
+	file, err = rs.writeArchive(models.BucketSentTransferDetail, now, rows)
+	if err != nil {
+		return
+	}
+	err = rs.dao.RemoveSentTransferDetails(keys)
+	if err == nil {
+		n = len(keys)
+	}
+	return
+}
+
+func (rs *Service) archiveTXInfos(now time.Time) (n int, file string, err error) {
+	list, err := rs.dao.GetTXInfoList(utils.EmptyHash, 0, utils.EmptyAddress, "", "")
+	if err != nil {
+		return
+	}
+	before := now.Add(-rs.Config.Retention.TXInfo).Unix()
+	var hashes []common.Hash
+	var rows []interface{}
+	for _, t := range list {
+		//pending的tx崩溃恢复的时候还需要
+		if t.Status == models.TXInfoStatusPending || t.PackTime <= 0 || t.PackTime >= before {
+			continue
+		}
+		hashes = append(hashes, t.TXHash)
+		rows = append(rows, t)
+	}
+	if len(hashes) == 0 {
+		return
+	}
+	file, err = rs.writeArchive(models.BucketTXInfo, now, rows)
+	if err != nil {
+		return
+	}
+	err = rs.dao.RemoveTXInfos(hashes)
+	if err == nil {
+		n = len(hashes)
+	}
+	return
+}
+
+func (rs *Service) archiveAcks(now time.Time) (n int, file string, err error) {
+	acks, err := rs.dao.GetAckList(now.Add(-rs.Config.Retention.Ack).Unix())
+	if err != nil || len(acks) == 0 {
+		return
+	}
+	var hashes []common.Hash
+	var rows []interface{}
+	for _, a := range acks {
+		hashes = append(hashes, a.EchoHash)
+		rows = append(rows, a)
+	}
+	file, err = rs.writeArchive(models.BucketAck, now, rows)
+	if err != nil {
+		return
+	}
+	err = rs.dao.RemoveAcks(hashes)
+	if err == nil {
+		n = len(hashes)
+	}
+	return
+}
+
+func (rs *Service) archiveTransferMemos(now time.Time) (n int, file string, err error) {
+	memos, err := rs.dao.GetTransferMemoList(now.Add(-rs.Config.Retention.TransferMemo).Unix())
+	if err != nil || len(memos) == 0 {
+		return
+	}
+	var keys []string
+	var rows []interface{}
+	for _, m := range memos {
+		keys = append(keys, m.Key)
+		rows = append(rows, m)
+	}
+	file, err = rs.writeArchive(models.BucketTransferMemo, now, rows)
+	if err != nil {
+		return
+	}
+	err = rs.dao.RemoveTransferMemos(keys)
+	if err == nil {
+		n = len(keys)
+	}
+	return
+}
+
+/*
+archiveChainEventRecords 链上事件记录用来去重,
+至少保留重新查询事件的范围2*ForkConfirmNumber
+*/
+func (rs *Service) archiveChainEventRecords(now time.Time) (n int, file string, err error) {
+	keep := rs.Config.Retention.ChainEventBlocks
+	if keep < 2*params.ForkConfirmNumber {
+		keep = 2 * params.ForkConfirmNumber
+	}
+	blockNumber := rs.GetBlockNumber() - keep
+	if blockNumber <= 0 {
+		return
+	}
+	records, err := rs.dao.GetChainEventRecordList(uint64(blockNumber))
+	if err != nil || len(records) == 0 {
+		return
+	}
+	var rows []interface{}
+	for _, r := range records {
+		rows = append(rows, r)
+	}
+	file, err = rs.writeArchive(models.BucketChainEventRecord, now, rows)
+	if err != nil {
+		return
+	}
+	rs.dao.ClearOldChainEventRecord(uint64(blockNumber))
+	n = len(records)
+	return
+}
+
+// GetRetentionReport 最近一次归档的结果,还没有归档时返回nil
+func (r *API) GetRetentionReport() *RetentionReport {
+	rs := r.Photon
+	rs.retentionLock.Lock()
+	defer rs.retentionLock.Unlock()
+	return rs.retentionReport
+}
+
+// Archive 立即按照保留时间归档一次
+func (r *API) Archive() *RetentionReport {
+	return r.Photon.archive()
+}
@@ -0,0 +1,287 @@
+package photon
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/pfsproxy"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func newTransferApproval(tr *transferReq) *models.PendingApproval {
+	a := &models.PendingApproval{
+		Operation:       models.PendingApprovalOperationTransfer,
+		TokenAddress:    tr.TokenAddress,
+		Amount:          tr.Amount,
+		Target:          tr.Target,
+		Secret:          tr.Secret,
+		IsDirect:        tr.IsDirectTransfer,
+		Keysend:         tr.Keysend,
+		Data:            tr.Data,
+		TargetPublicKey: tr.TargetPublicKey,
+		OnionPublicKeys: tr.OnionPublicKeys,
+		OnionAllowClear: tr.OnionAllowClear,
+		PaymentPlanKey:  tr.PaymentPlanKey,
+		PaymentPlanRun:  tr.PaymentPlanRun,
+	}
+	if len(tr.RouteInfo) > 0 {
+		a.RouteInfo, _ = json.Marshal(tr.RouteInfo)
+	}
+	return a
+}
+
+func approvalToTransferReq(a *models.PendingApproval) *transferReq {
+	tr := &transferReq{
+		TokenAddress:     a.TokenAddress,
+		Amount:           a.Amount,
+		Target:           a.Target,
+		Secret:           a.Secret,
+		IsDirectTransfer: a.IsDirect,
+		Data:             a.Data,
+		Keysend:          a.Keysend,
+		TargetPublicKey:  a.TargetPublicKey,
+		OnionPublicKeys:  a.OnionPublicKeys,
+		OnionAllowClear:  a.OnionAllowClear,
+		PaymentPlanKey:   a.PaymentPlanKey,
+		PaymentPlanRun:   a.PaymentPlanRun,
+	}
+	if len(a.RouteInfo) > 0 {
+		var routeInfo []pfsproxy.FindPathResponse
+		err := json.Unmarshal(a.RouteInfo, &routeInfo)
+		if err != nil {
+			log.Error(fmt.Sprintf("pending approval %s unmarshal route info err %s", a.Key, err))
+		}
+		tr.RouteInfo = routeInfo
+	}
+	return tr
+}
+
+/*
+checkSpendingPolicy 按照token的支付策略检查操作:
+1. 交易的target必须在允许的列表中,金额不能超过单笔,每小时和每天的限额,否则直接拒绝
+2. withdraw的金额回到自己的账户,只检查单笔限额;关闭通道只受审批阈值限制
+3. 金额达到审批阈值的操作保存到待审批队列,返回ErrPendingApproval,批准以后才会执行
+没有设置支付策略的token不受限制
+*/
+func (rs *Service) checkSpendingPolicy(a *models.PendingApproval) error {
+	p, err := rs.dao.GetSpendingPolicy(a.TokenAddress)
+	if err == rerr.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	switch a.Operation {
+	case models.PendingApprovalOperationTransfer:
+		err = rs.checkTransferLimit(p, a.Target, a.Amount)
+	case models.PendingApprovalOperationWithdraw:
+		err = checkMaxPerTransfer(p, a.Amount)
+	}
+	if err != nil {
+		return err
+	}
+	if !p.NeedApproval(a.Amount) {
+		return nil
+	}
+	a.Key = utils.NewRandomHash().String()
+	a.Status = models.PendingApprovalStatusPending
+	a.CreateTime = time.Now().Unix()
+	err = rs.dao.SavePendingApproval(a)
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("%s %s of token %s needs approval,key=%s", a.Operation, a.Amount, utils.APex2(a.TokenAddress), a.Key))
+	rs.NotifyHandler.NotifyPendingApproval(a)
+	return rerr.ErrPendingApproval.Printf("key=%s", a.Key)
+}
+
+func (rs *Service) checkTransferLimit(p *models.SpendingPolicy, target common.Address, amount *big.Int) error {
+	if !p.IsTargetAllowed(target) {
+		return rerr.ErrSpendingPolicyViolated.Printf("target %s is not allowed", target.String())
+	}
+	err := checkMaxPerTransfer(p, amount)
+	if err != nil {
+		return err
+	}
+	if p.MaxPerHour == nil && p.MaxPerDay == nil {
+		return nil
+	}
+	hour, day, err := rs.recentSpent(p.TokenAddress)
+	if err != nil {
+		return err
+	}
+	if p.MaxPerHour != nil && new(big.Int).Add(hour, amount).Cmp(p.MaxPerHour) > 0 {
+		return rerr.ErrSpendingPolicyViolated.Printf("spent %s in last hour,max per hour is %s", hour, p.MaxPerHour)
+	}
+	if p.MaxPerDay != nil && new(big.Int).Add(day, amount).Cmp(p.MaxPerDay) > 0 {
+		return rerr.ErrSpendingPolicyViolated.Printf("spent %s in last day,max per day is %s", day, p.MaxPerDay)
+	}
+	return nil
+}
+
+func checkMaxPerTransfer(p *models.SpendingPolicy, amount *big.Int) error {
+	if p.MaxPerTransfer != nil && amount.Cmp(p.MaxPerTransfer) > 0 {
+		return rerr.ErrSpendingPolicyViolated.Printf("amount %s exceeds max per transfer %s", amount, p.MaxPerTransfer)
+	}
+	return nil
+}
+
+/*
+recentSpent 最近一小时和24小时内发起的交易总额,失败和取消的交易不计算在内
+*/
+func (rs *Service) recentSpent(tokenAddress common.Address) (hour, day *big.Int, err error) {
+	now := time.Now().Unix()
+	hour = big.NewInt(0)
+	day = big.NewInt(0)
+	transfers, err := rs.dao.GetSentTransferDetailList(tokenAddress, now-int64(24*time.Hour/time.Second), -1, -1, -1)
+	if err != nil {
+		return
+	}
+	for _, t := range transfers {
+		if t.Status == models.TransferStatusFailed || t.Status == models.TransferStatusCanceled || t.Amount == nil {
+			continue
+		}
+		day.Add(day, t.Amount)
+		if t.SendingTime >= now-int64(time.Hour/time.Second) {
+			hour.Add(hour, t.Amount)
+		}
+	}
+	return
+}
+
+/*
+checkChannelSpendingPolicy withdraw金额或者关闭通道时自己的余额达到审批阈值需要审批
+*/
+func (rs *Service) checkChannelSpendingPolicy(op models.PendingApprovalOperation, channelIdentifier common.Hash, amount *big.Int) error {
+	c, err := rs.dao.GetChannelByAddress(channelIdentifier)
+	if err != nil {
+		return err
+	}
+	if amount == nil {
+		amount = c.OurBalance()
+	}
+	return rs.checkSpendingPolicy(&models.PendingApproval{
+		Operation:         op,
+		TokenAddress:      c.TokenAddress(),
+		Amount:            amount,
+		Target:            c.PartnerAddress(),
+		ChannelIdentifier: channelIdentifier,
+	})
+}
+
+// SetSpendingPolicy :
+func (r *API) SetSpendingPolicy(p *models.SpendingPolicy) error {
+	for _, v := range []*big.Int{p.MaxPerTransfer, p.MaxPerHour, p.MaxPerDay, p.ApprovalThreshold} {
+		if v != nil && v.Sign() < 0 {
+			return rerr.ErrArgumentError.Append("limit must not be negative")
+		}
+	}
+	return r.Photon.dao.SaveSpendingPolicy(p)
+}
+
+// GetSpendingPolicyList :
+func (r *API) GetSpendingPolicyList() (ps []*models.SpendingPolicy, err error) {
+	return r.Photon.dao.GetSpendingPolicyList()
+}
+
+// RemoveSpendingPolicy :
+func (r *API) RemoveSpendingPolicy(tokenAddress common.Address) error {
+	return r.Photon.dao.RemoveSpendingPolicy(tokenAddress)
+}
+
+// GetPendingApprovalList :
+func (r *API) GetPendingApprovalList() (as []*models.PendingApproval, err error) {
+	return r.Photon.dao.GetPendingApprovalList()
+}
+
+// GetPendingApproval :
+func (r *API) GetPendingApproval(key string) (a *models.PendingApproval, err error) {
+	return r.Photon.dao.GetPendingApproval(key)
+}
+
+/*
+ApprovePendingApproval 批准以后按照原来的参数执行,不再检查支付策略.
+交易发起以后就返回,withdraw和关闭通道等待tx的结果
+*/
+func (r *API) ApprovePendingApproval(key string) (a *models.PendingApproval, err error) {
+	a, err = r.finishPendingApproval(key, models.PendingApprovalStatusApproved)
+	if err != nil {
+		return
+	}
+	rs := r.Photon
+	var result *utils.AsyncResult
+	var opErr error
+	switch a.Operation {
+	case models.PendingApprovalOperationTransfer:
+		result = rs.sendReqClient(&apiReq{
+			ReqID: utils.RandomString(10),
+			Name:  transferReqName,
+			Req:   approvalToTransferReq(a),
+		})
+		select {
+		case <-time.After(300 * time.Millisecond):
+		case opErr = <-result.Result:
+		}
+		a.LockSecretHash = result.LockSecretHash
+	case models.PendingApprovalOperationWithdraw:
+		result = rs.sendReqClient(&apiReq{
+			ReqID: utils.RandomString(10),
+			Name:  withdrawReqName,
+			Req: &withdrawReq{
+				addr:   a.ChannelIdentifier,
+				amount: a.Amount,
+			},
+		})
+		opErr = <-result.Result
+	case models.PendingApprovalOperationClose:
+		result = rs.sendReqClient(&apiReq{
+			ReqID: utils.RandomString(10),
+			Name:  closeChannelReqName,
+			Req: &closeSettleChannelReq{
+				addr: a.ChannelIdentifier,
+			},
+		})
+		opErr = <-result.Result
+	}
+	if opErr != nil {
+		a.Error = opErr.Error()
+	}
+	log.Info(fmt.Sprintf("pending approval %s approved,%s %s err=%v", a.Key, a.Operation, a.Amount, opErr))
+	err = rs.dao.SavePendingApproval(a)
+	rs.NotifyHandler.NotifyPendingApproval(a)
+	return
+}
+
+// RejectPendingApproval :
+func (r *API) RejectPendingApproval(key string) (a *models.PendingApproval, err error) {
+	a, err = r.finishPendingApproval(key, models.PendingApprovalStatusRejected)
+	if err != nil {
+		return
+	}
+	r.Photon.NotifyHandler.NotifyPendingApproval(a)
+	return
+}
+
+//finishPendingApproval 每个待审批的操作只能批准或者拒绝一次
+func (r *API) finishPendingApproval(key string, status models.PendingApprovalStatus) (a *models.PendingApproval, err error) {
+	r.Photon.spendingPolicyLock.Lock()
+	defer r.Photon.spendingPolicyLock.Unlock()
+	a, err = r.Photon.dao.GetPendingApproval(key)
+	if err != nil {
+		return
+	}
+	if a.Status != models.PendingApprovalStatusPending {
+		err = rerr.ErrArgumentError.Printf("pending approval %s is already %s", key, a.Status)
+		return
+	}
+	a.Status = status
+	a.FinishTime = time.Now().Unix()
+	err = r.Photon.dao.SavePendingApproval(a)
+	return
+}
@@ -0,0 +1,54 @@
+package photon
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSpendingPolicyChannelOperations(t *testing.T) {
+	db, err := newTestStormDb()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.CloseDB()
+	rs := &Service{
+		dao: db,
+	}
+	token := utils.NewRandomAddress()
+	err = db.SaveSpendingPolicy(&models.SpendingPolicy{
+		TokenAddress:   token,
+		MaxPerTransfer: big.NewInt(100),
+		AllowedTargets: []common.Address{utils.NewRandomAddress()},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	check := func(op models.PendingApprovalOperation, amount int64) error {
+		return rs.checkSpendingPolicy(&models.PendingApproval{
+			Operation:    op,
+			TokenAddress: token,
+			Amount:       big.NewInt(amount),
+			Target:       utils.NewRandomAddress(),
+		})
+	}
+	//withdraw只检查单笔限额,不检查target
+	assert.Nil(t, check(models.PendingApprovalOperationWithdraw, 100))
+	err = check(models.PendingApprovalOperationWithdraw, 101)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, rerr.ErrSpendingPolicyViolated.ErrorCode, err.(rerr.StandardError).ErrorCode)
+	}
+	//关闭通道只受审批阈值限制
+	assert.Nil(t, check(models.PendingApprovalOperationClose, 1000))
+	//交易还要检查target
+	err = check(models.PendingApprovalOperationTransfer, 100)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, rerr.ErrSpendingPolicyViolated.ErrorCode, err.(rerr.StandardError).ErrorCode)
+	}
+}
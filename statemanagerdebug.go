@@ -0,0 +1,368 @@
+package photon
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/channel"
+	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/transfer"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+/*
+交易卡住的时候用来查看状态机,以及离线重放记录下来的状态变化.
+每个状态机在内存中保留最近params.StateManagerHistoryLimit个状态变化和产生的事件,
+超过以后从当前状态重新开始记录,所以记录总是可以从它的起始状态开始重放
+*/
+
+type stateManagerStep struct {
+	seq         int
+	time        int64
+	stateChange transfer.StateChange
+	data        []byte // gob编码的状态变化,编码失败时为空
+	events      []transfer.Event
+}
+
+type stateManagerHistory struct {
+	state []byte // 第一个状态变化之前的状态
+	steps []*stateManagerStep
+}
+
+func newStateManagerHistory(state []byte) *stateManagerHistory {
+	return &stateManagerHistory{state: state}
+}
+
+func (h *stateManagerHistory) add(seq int, st transfer.StateChange, data []byte, events []transfer.Event) {
+	h.steps = append(h.steps, &stateManagerStep{
+		seq:         seq,
+		time:        time.Now().Unix(),
+		stateChange: st,
+		data:        data,
+		events:      events,
+	})
+}
+
+/*
+getStateManagerHistory 在状态转换之前调用,记录满了以后从当前状态重新开始
+*/
+func (rs *Service) getStateManagerHistory(sm *transfer.StateManager) *stateManagerHistory {
+	key := stateManagerKey(sm)
+	h := rs.stateManagerHistories[key]
+	if h == nil || len(h.steps) >= params.StateManagerHistoryLimit {
+		state, _ := encodeState(sm.CurrentState)
+		h = newStateManagerHistory(state)
+		rs.setStateManagerHistory(key, h)
+	}
+	return h
+}
+
+func (rs *Service) setStateManagerHistory(key common.Hash, h *stateManagerHistory) {
+	if rs.stateManagerHistories == nil {
+		rs.stateManagerHistories = make(map[common.Hash]*stateManagerHistory)
+	}
+	rs.stateManagerHistories[key] = h
+}
+
+//StateManagerEventInfo 状态转换产生的事件
+type StateManagerEventInfo struct {
+	Type  string          `json:"type"`
+	Event json.RawMessage `json:"event"`
+}
+
+//StateManagerStepInfo 一次状态转换,State是离线重放时转换之后的状态
+type StateManagerStepInfo struct {
+	Seq             int                      `json:"seq"`
+	Time            int64                    `json:"time,omitempty"`
+	StateChangeType string                   `json:"state_change_type"`
+	StateChange     json.RawMessage          `json:"state_change"`
+	Events          []*StateManagerEventInfo `json:"events"`
+	State           json.RawMessage          `json:"state,omitempty"`
+}
+
+//StateManagerInfo 交易状态机的当前状态,查询单个状态机时包含内存中记录的状态变化和事件
+type StateManagerInfo struct {
+	Key            common.Hash             `json:"key"`
+	Name           string                  `json:"name"`
+	LockSecretHash common.Hash             `json:"lock_secret_hash"`
+	TokenAddress   common.Address          `json:"token_address"`
+	StateChangeSeq int                     `json:"state_change_seq"`
+	SnapshotSeq    int                     `json:"snapshot_seq"`
+	State          json.RawMessage         `json:"state"`
+	Steps          []*StateManagerStepInfo `json:"steps,omitempty"`
+}
+
+/*
+StateManagerRecord 一段可以离线重放的状态变化.
+State是第一个状态变化之前的状态,Channels是记录时这个token上的通道,
+重放时路由使用这些通道,它们可能和状态变化发生时不一样
+*/
+type StateManagerRecord struct {
+	Key            common.Hash    `json:"key"`
+	Name           string         `json:"name"`
+	LockSecretHash common.Hash    `json:"lock_secret_hash"`
+	TokenAddress   common.Address `json:"token_address"`
+	FirstSeq       int            `json:"first_seq"`
+	State          []byte         `json:"state"`
+	StateChanges   [][]byte       `json:"state_changes"`
+	Channels       [][]byte       `json:"channels"`
+}
+
+/*
+toJSON 状态中的Db是数据库本身,不能输出
+*/
+func toJSON(v interface{}) json.RawMessage {
+	if v == nil {
+		return json.RawMessage("null")
+	}
+	data, err := json.Marshal(withoutDb(v))
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("marshal %T err %s", v, err))
+	}
+	return data
+}
+
+func typeName(v interface{}) string {
+	return fmt.Sprintf("%T", v)
+}
+
+func newStateManagerStepInfo(seq int, st transfer.StateChange, events []transfer.Event) *StateManagerStepInfo {
+	info := &StateManagerStepInfo{
+		Seq:             seq,
+		StateChangeType: typeName(st),
+		StateChange:     toJSON(st),
+		Events:          []*StateManagerEventInfo{},
+	}
+	for _, e := range events {
+		info.Events = append(info.Events, &StateManagerEventInfo{
+			Type:  typeName(e),
+			Event: toJSON(e),
+		})
+	}
+	return info
+}
+
+func newStateManagerInfo(key common.Hash, sm *transfer.StateManager) *StateManagerInfo {
+	return &StateManagerInfo{
+		Key:            key,
+		Name:           sm.Name,
+		LockSecretHash: sm.Identifier,
+		TokenAddress:   sm.TokenAddress,
+		StateChangeSeq: sm.StateChangeSeq,
+		SnapshotSeq:    sm.SnapshotSeq,
+		State:          toJSON(sm.CurrentState),
+	}
+}
+
+/*
+debugStateManager 在loop中把状态机转换成json,避免和状态转换同时访问
+*/
+func (rs *Service) debugStateManager(req *debugStateManagerReq) (result *utils.AsyncResult) {
+	result = utils.NewAsyncResult()
+	if req.Key == utils.EmptyHash {
+		var infos []*StateManagerInfo
+		for key, sm := range rs.Transfer2StateManager {
+			infos = append(infos, newStateManagerInfo(key, sm))
+		}
+		sort.Slice(infos, func(i, j int) bool {
+			return infos[i].Key.String() < infos[j].Key.String()
+		})
+		result.Tag = infos
+		result.Result <- nil
+		return
+	}
+	sm := rs.Transfer2StateManager[req.Key]
+	if sm == nil {
+		result.Result <- rerr.ErrTransferNotFound.Printf("state manager %s", req.Key.String())
+		return
+	}
+	h := rs.stateManagerHistories[req.Key]
+	if req.Record {
+		if h == nil {
+			result.Result <- rerr.ErrNotFound.Printf("state manager %s has no recorded state changes", req.Key.String())
+			return
+		}
+		r, err := newStateManagerRecord(rs.dao, req.Key, sm.Name, sm.Identifier, sm.TokenAddress, h)
+		result.Tag = r
+		result.Result <- err
+		return
+	}
+	info := newStateManagerInfo(req.Key, sm)
+	if h != nil {
+		for _, s := range h.steps {
+			step := newStateManagerStepInfo(s.seq, s.stateChange, s.events)
+			step.Time = s.time
+			info.Steps = append(info.Steps, step)
+		}
+	}
+	result.Tag = info
+	result.Result <- nil
+	return
+}
+
+func gobEncode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func newStateManagerRecord(dao models.Dao, key common.Hash, name string, lockSecretHash common.Hash, tokenAddress common.Address, h *stateManagerHistory) (r *StateManagerRecord, err error) {
+	r = &StateManagerRecord{
+		Key:            key,
+		Name:           name,
+		LockSecretHash: lockSecretHash,
+		TokenAddress:   tokenAddress,
+		State:          h.state,
+	}
+	for i, s := range h.steps {
+		if s.data == nil {
+			err = fmt.Errorf("state change %d can not be encoded", s.seq)
+			return
+		}
+		if i == 0 {
+			r.FirstSeq = s.seq
+		}
+		r.StateChanges = append(r.StateChanges, s.data)
+	}
+	css, err := dao.GetChannelList(tokenAddress, utils.EmptyAddress)
+	if err != nil {
+		return
+	}
+	for _, cs := range css {
+		var data []byte
+		data, err = gobEncode(cs)
+		if err != nil {
+			return
+		}
+		r.Channels = append(r.Channels, data)
+	}
+	return
+}
+
+/*
+NewStateManagerRecordFromDB 从停止的photon的数据库中读取状态机的快照和write-ahead log,
+key是Transfer2StateManager中的key
+*/
+func NewStateManagerRecordFromDB(dao models.Dao, key common.Hash) (r *StateManagerRecord, err error) {
+	ss, err := dao.GetStateManagerSnapshotList()
+	if err != nil {
+		return
+	}
+	for _, s := range ss {
+		if s.Key != key.String() {
+			continue
+		}
+		h := newStateManagerHistory(s.State)
+		var ls []*models.StateChangeLog
+		ls, err = dao.GetStateChangeLogs(s.Key)
+		if err != nil {
+			return
+		}
+		for _, l := range ls {
+			if l.Seq > s.Seq {
+				h.add(l.Seq, nil, l.StateChange, nil)
+			}
+		}
+		return newStateManagerRecord(dao, key, s.Name, s.LockSecretHash, s.TokenAddress, h)
+	}
+	return nil, rerr.ErrNotFound.Printf("state manager %s", key.String())
+}
+
+/*
+ReplayStateManagerRecord 离线重放,返回每一步的状态变化,产生的事件和之后的状态.
+没有数据库和链,事件只是输出,不会执行
+*/
+func ReplayStateManagerRecord(r *StateManagerRecord) (steps []*StateManagerStepInfo, err error) {
+	f := stateManagerTransitions[r.Name]
+	if f == nil {
+		return nil, fmt.Errorf("unknown state manager %s", r.Name)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return
+	}
+	channels := make(map[common.Hash]*channel.Channel)
+	for _, data := range r.Channels {
+		cs := &channeltype.Serialization{}
+		err = gob.NewDecoder(bytes.NewReader(data)).Decode(cs)
+		if err != nil {
+			return
+		}
+		var ch *channel.Channel
+		ch, err = serialization2Channel(cs, channel.NewChannelExternalState(nil, nil, cs.ChannelIdentifier, key, nil, nil, cs.ClosedBlock, cs.OurAddress, cs.PartnerAddress()))
+		if err != nil {
+			return
+		}
+		channels[ch.ChannelIdentifier.ChannelIdentifier] = ch
+	}
+	linker := &stateLinker{
+		db: channeltype.NewMockChannelDb(),
+		findChannel: func(channelIdentifier common.Hash) *channel.Channel {
+			return channels[channelIdentifier]
+		},
+	}
+	state, err := linker.decodeState(r.State)
+	if err != nil {
+		return
+	}
+	sm := transfer.NewStateManager(f, state, r.Name, r.LockSecretHash, r.TokenAddress)
+	for i, data := range r.StateChanges {
+		var st transfer.StateChange
+		st, err = linker.decodeStateChange(data)
+		if err != nil {
+			return
+		}
+		events := sm.Dispatch(st)
+		step := newStateManagerStepInfo(r.FirstSeq+i, st, events)
+		step.State = toJSON(sm.CurrentState)
+		steps = append(steps, step)
+	}
+	return
+}
+
+//GetStateManagerList 所有进行中交易的状态机
+func (r *API) GetStateManagerList() (infos []*StateManagerInfo, err error) {
+	result := r.Photon.debugStateManagerClient(utils.EmptyHash, false)
+	err = <-result.Result
+	if err != nil {
+		return
+	}
+	infos, _ = result.Tag.([]*StateManagerInfo)
+	return
+}
+
+//GetStateManager 一个状态机的当前状态和最近的状态变化
+func (r *API) GetStateManager(key common.Hash) (info *StateManagerInfo, err error) {
+	result := r.Photon.debugStateManagerClient(key, false)
+	err = <-result.Result
+	if err != nil {
+		return
+	}
+	info = result.Tag.(*StateManagerInfo)
+	return
+}
+
+//GetStateManagerRecord 导出状态机最近的状态变化,用photon replay离线重放
+func (r *API) GetStateManagerRecord(key common.Hash) (record *StateManagerRecord, err error) {
+	result := r.Photon.debugStateManagerClient(key, true)
+	err = <-result.Result
+	if err != nil {
+		return
+	}
+	record = result.Tag.(*StateManagerRecord)
+	return
+}
+
+//GetTransferLogs 一个交易经过本节点的所有日志,不受verbosity的限制
+func (r *API) GetTransferLogs(lockSecretHash common.Hash) []*utils.TransferLogRecord {
+	return utils.TransferLogs.Get(lockSecretHash)
+}
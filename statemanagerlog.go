@@ -0,0 +1,352 @@
+package photon
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/channel"
+	"github.com/SmartMeshFoundation/Photon/channel/channeltype"
+	"github.com/SmartMeshFoundation/Photon/internal/tracing"
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/SmartMeshFoundation/Photon/models"
+	"github.com/SmartMeshFoundation/Photon/params"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/transfer"
+	"github.com/SmartMeshFoundation/Photon/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/Photon/transfer/mediatedtransfer/initiator"
+	"github.com/SmartMeshFoundation/Photon/transfer/mediatedtransfer/mediator"
+	"github.com/SmartMeshFoundation/Photon/transfer/mediatedtransfer/target"
+	"github.com/SmartMeshFoundation/Photon/transfer/route"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+交易状态机的write-ahead log和快照.
+每个状态变化在状态转换之前写入StateChangeLog,每params.StateManagerSnapshotInterval个状态变化保存一次快照,
+重启以后从快照开始重放之后的状态变化,恢复发起方,中间节点和接收方的状态机.
+重放时不再处理产生的事件,崩溃之前发送的消息和通道的变化都已经保存,消息会由reSendEnvelopMessage重发.
+选择路由的状态转换依赖通道当时的余额,重放时通道已经变化了,所以这些状态转换之后立即保存快照,不需要重放.
+交易结束,状态机被删除的时候删除它的快照和状态变化.
+*/
+
+// 需要持久化的状态机,crashnode每次重启都会根据通道中的锁重新创建
+var stateManagerTransitions = map[string]transfer.FuncStateTransition{
+	initiator.NameInitiatorTransition: initiator.StateTransition,
+	mediator.NameMediatorTransition:   mediator.StateTransition,
+	target.NameTargetTransition:       target.StateTransiton,
+}
+
+// gob只能通过接口编码注册过的类型,所以需要包装一下
+type stateGob struct {
+	State transfer.State
+}
+
+type stateChangeGob struct {
+	StateChange transfer.StateChange
+}
+
+func stateManagerKey(sm *transfer.StateManager) common.Hash {
+	return utils.Sha3(sm.Identifier[:], sm.TokenAddress[:])
+}
+
+/*
+withoutDb Db是数据库本身,不能编码,恢复的时候重新设置
+*/
+func withoutDb(v interface{}) interface{} {
+	switch v2 := v.(type) {
+	case *mediatedtransfer.InitiatorState:
+		c := *v2
+		c.Db = nil
+		return &c
+	case *mediatedtransfer.MediatorState:
+		c := *v2
+		c.Db = nil
+		return &c
+	case *mediatedtransfer.TargetState:
+		c := *v2
+		c.Db = nil
+		return &c
+	case *mediatedtransfer.ActionInitInitiatorStateChange:
+		c := *v2
+		c.Db = nil
+		return &c
+	case *mediatedtransfer.ActionInitMediatorStateChange:
+		c := *v2
+		c.Db = nil
+		return &c
+	case *mediatedtransfer.ActionInitTargetStateChange:
+		c := *v2
+		c.Db = nil
+		return &c
+	}
+	return v
+}
+
+func routesOf(rs *route.RoutesState) (routes []*route.State) {
+	if rs == nil {
+		return
+	}
+	routes = append(routes, rs.AvailableRoutes...)
+	routes = append(routes, rs.IgnoredRoutes...)
+	routes = append(routes, rs.RefundedRoutes...)
+	for _, r := range rs.CanceledRoutes {
+		routes = append(routes, r.Route)
+	}
+	return
+}
+
+/*
+stateLinker 解码以后的状态和状态变化需要重新设置Db和路由对应的通道,
+离线重放的时候使用记录中的通道
+*/
+type stateLinker struct {
+	db          channeltype.Db
+	findChannel func(channelIdentifier common.Hash) *channel.Channel
+}
+
+func (rs *Service) newStateLinker() *stateLinker {
+	return &stateLinker{
+		db:          rs.dao,
+		findChannel: rs.getChannelWithAddr,
+	}
+}
+
+func (l *stateLinker) relink(v interface{}) error {
+	var routes []*route.State
+	switch v2 := v.(type) {
+	case *mediatedtransfer.InitiatorState:
+		v2.Db = l.db
+		routes = append(routesOf(v2.Routes), v2.Route)
+	case *mediatedtransfer.MediatorState:
+		v2.Db = l.db
+		routes = routesOf(v2.Routes)
+		for _, p := range v2.TransfersPair {
+			routes = append(routes, p.PayerRoute, p.PayeeRoute)
+		}
+	case *mediatedtransfer.TargetState:
+		v2.Db = l.db
+		routes = append(routes, v2.FromRoute)
+	case *mediatedtransfer.ActionInitInitiatorStateChange:
+		v2.Db = l.db
+		routes = routesOf(v2.Routes)
+	case *mediatedtransfer.ActionInitMediatorStateChange:
+		v2.Db = l.db
+		routes = append(routesOf(v2.Routes), v2.FromRoute)
+	case *mediatedtransfer.ActionInitTargetStateChange:
+		v2.Db = l.db
+		routes = append(routes, v2.FromRoute)
+	case *mediatedtransfer.MediatorReReceiveStateChange:
+		routes = append(routes, v2.FromRoute)
+	}
+	for _, r := range routes {
+		if r == nil {
+			continue
+		}
+		ch := l.findChannel(r.ChannelIdentifier)
+		if ch == nil {
+			return rerr.ErrChannelNotFound.Printf("channel %s of route", utils.HPex(r.ChannelIdentifier))
+		}
+		r.SetChannel(ch)
+	}
+	return nil
+}
+
+func encodeStateChange(st transfer.StateChange) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&stateChangeGob{withoutDb(st)})
+	return buf.Bytes(), err
+}
+
+func (l *stateLinker) decodeStateChange(data []byte) (transfer.StateChange, error) {
+	var w stateChangeGob
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&w)
+	if err != nil {
+		return nil, err
+	}
+	return w.StateChange, l.relink(w.StateChange)
+}
+
+func encodeState(state transfer.State) ([]byte, error) {
+	if state == nil {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&stateGob{withoutDb(state)})
+	return buf.Bytes(), err
+}
+
+func (l *stateLinker) decodeState(data []byte) (transfer.State, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var w stateGob
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&w)
+	if err != nil {
+		return nil, err
+	}
+	return w.State, l.relink(w.State)
+}
+
+/*
+logStateChange 在状态转换之前把状态变化写入write-ahead log,
+第一个状态变化之前先保存快照,这样重启时才能找到这个状态机
+*/
+func (rs *Service) logStateChange(sm *transfer.StateManager, st transfer.StateChange) (data []byte, err error) {
+	if sm.StateChangeSeq == 0 {
+		err = rs.saveStateManagerSnapshot(sm)
+		if err != nil {
+			return
+		}
+	}
+	sm.StateChangeSeq++
+	data, err = encodeStateChange(st)
+	if err != nil {
+		return
+	}
+	err = rs.dao.AddStateChangeLog(models.NewStateChangeLog(stateManagerKey(sm).String(), sm.StateChangeSeq, data))
+	return
+}
+
+/*
+needSnapshot 选择路由的状态转换不能确定性的重放
+*/
+func needSnapshot(sm *transfer.StateManager, st transfer.StateChange) bool {
+	switch st.(type) {
+	case *mediatedtransfer.ActionInitInitiatorStateChange,
+		*mediatedtransfer.ActionInitMediatorStateChange,
+		*mediatedtransfer.ActionInitTargetStateChange,
+		*mediatedtransfer.MediatorReReceiveStateChange,
+		*mediatedtransfer.ReceiveAnnounceDisposedStateChange,
+		*mediatedtransfer.ActionCancelRouteStateChange:
+		return true
+	}
+	return sm.StateChangeSeq-sm.SnapshotSeq >= params.StateManagerSnapshotInterval
+}
+
+/*
+saveStateManagerSnapshot 保存快照,快照已经包含的状态变化会被删除.
+不通过状态变化直接修改了状态机的状态以后也要调用
+*/
+func (rs *Service) saveStateManagerSnapshot(sm *transfer.StateManager) (err error) {
+	if stateManagerTransitions[sm.Name] == nil {
+		return
+	}
+	data, err := encodeState(sm.CurrentState)
+	if err == nil {
+		err = rs.dao.SaveStateManagerSnapshot(&models.StateManagerSnapshot{
+			Key:            stateManagerKey(sm).String(),
+			Name:           sm.Name,
+			LockSecretHash: sm.Identifier,
+			TokenAddress:   sm.TokenAddress,
+			Seq:            sm.StateChangeSeq,
+			State:          data,
+			UpdateTime:     time.Now().Unix(),
+		})
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("save snapshot of state manager %s err %s", utils.HPex(sm.Identifier), err))
+		return
+	}
+	sm.SnapshotSeq = sm.StateChangeSeq
+	return
+}
+
+/*
+dispatchWithLog 状态转换前后维护write-ahead log和快照,
+写入状态变化失败的时候立即保存快照,保证重启以后的状态是完整的
+*/
+func (rs *Service) dispatchWithLog(sm *transfer.StateManager, st transfer.StateChange) (events []transfer.Event) {
+	if stateManagerTransitions[sm.Name] == nil {
+		return sm.Dispatch(st)
+	}
+	data, err := rs.logStateChange(sm, st)
+	if err != nil {
+		log.Error(fmt.Sprintf("write state change of state manager %s err %s", utils.HPex(sm.Identifier), err))
+	}
+	h := rs.getStateManagerHistory(sm)
+	events = sm.Dispatch(st)
+	h.add(sm.StateChangeSeq, st, data, events)
+	if err != nil || needSnapshot(sm, st) {
+		rs.saveStateManagerSnapshot(sm)
+	}
+	return
+}
+
+/*
+removeStateManagerLog 交易结束,删除状态机的快照和状态变化
+*/
+func (rs *Service) removeStateManagerLog(key common.Hash) {
+	delete(rs.stateManagerHistories, key)
+	err := rs.dao.RemoveStateManagerLog(key.String())
+	if err != nil {
+		log.Error(fmt.Sprintf("remove log of state manager %s err %s", key.String(), err))
+	}
+}
+
+/*
+replayStateManager 从快照开始重放之后的状态变化,事件在崩溃之前已经处理过了,这里丢弃,
+重放的过程作为状态机的历史,供调试使用
+*/
+func (rs *Service) replayStateManager(s *models.StateManagerSnapshot) (sm *transfer.StateManager, h *stateManagerHistory, err error) {
+	f := stateManagerTransitions[s.Name]
+	if f == nil {
+		err = fmt.Errorf("unknown state manager %s", s.Name)
+		return
+	}
+	linker := rs.newStateLinker()
+	sm = transfer.NewStateManager(f, nil, s.Name, s.LockSecretHash, s.TokenAddress)
+	sm.CurrentState, err = linker.decodeState(s.State)
+	if err != nil {
+		return
+	}
+	sm.StateChangeSeq = s.Seq
+	sm.SnapshotSeq = s.Seq
+	h = newStateManagerHistory(s.State)
+	ls, err := rs.dao.GetStateChangeLogs(s.Key)
+	if err != nil {
+		return
+	}
+	for _, l := range ls {
+		//保存快照以后,删除状态变化之前崩溃了
+		if l.Seq <= s.Seq {
+			continue
+		}
+		var st transfer.StateChange
+		st, err = linker.decodeStateChange(l.StateChange)
+		if err != nil {
+			return
+		}
+		events := sm.Dispatch(st)
+		sm.StateChangeSeq = l.Seq
+		h.add(l.Seq, st, l.StateChange, events)
+	}
+	return
+}
+
+/*
+restoreStateManagers 重启以后恢复没有结束的交易状态机,
+恢复失败的交易和restoreLocks一样由crashnode处理
+*/
+func (rs *Service) restoreStateManagers() {
+	ss, err := rs.dao.GetStateManagerSnapshotList()
+	if err != nil {
+		log.Error(fmt.Sprintf("GetStateManagerSnapshotList err %s", err))
+		return
+	}
+	for _, s := range ss {
+		key := common.HexToHash(s.Key)
+		sm, h, err := rs.replayStateManager(s)
+		if err != nil || sm.CurrentState == nil {
+			log.Warn(fmt.Sprintf("can not restore state manager %s %s,err=%v", s.Name, utils.HPex(s.LockSecretHash), err))
+			rs.removeStateManagerLog(key)
+			continue
+		}
+		tracing.StartTransfer(sm.Identifier, "restore transfer", "state_manager", sm.Name, "seq", sm.StateChangeSeq)
+		rs.Transfer2StateManager[key] = sm
+		rs.setStateManagerHistory(key, h)
+		log.Info(fmt.Sprintf("restore state manager %s %s,seq=%d", s.Name, utils.HPex(s.LockSecretHash), sm.StateChangeSeq))
+	}
+}
@@ -0,0 +1,253 @@
+package photon
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/channel"
+	"github.com/SmartMeshFoundation/Photon/network/graph"
+	"github.com/SmartMeshFoundation/Photon/rerr"
+	"github.com/SmartMeshFoundation/Photon/transfer"
+	"github.com/SmartMeshFoundation/Photon/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/Photon/transfer/mediatedtransfer/initiator"
+	"github.com/SmartMeshFoundation/Photon/transfer/mediatedtransfer/mediator"
+	"github.com/SmartMeshFoundation/Photon/transfer/mediatedtransfer/target"
+	"github.com/SmartMeshFoundation/Photon/transfer/route"
+	"github.com/SmartMeshFoundation/Photon/utils"
+	"github.com/SmartMeshFoundation/Photon/utils/utest"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateManagerLogReplay(t *testing.T) {
+	db, err := newTestStormDb()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.CloseDB()
+	rs := &Service{
+		dao:                   db,
+		Transfer2StateManager: make(map[common.Hash]*transfer.StateManager),
+	}
+	token := utils.NewRandomAddress()
+	lockSecretHash := utils.NewRandomHash()
+	state := &mediatedtransfer.TargetState{
+		OurAddress: utils.NewRandomAddress(),
+		FromTransfer: &mediatedtransfer.LockedTransferState{
+			TargetAmount:   big.NewInt(10),
+			Amount:         big.NewInt(10),
+			Token:          token,
+			Expiration:     100,
+			LockSecretHash: lockSecretHash,
+		},
+		BlockNumber: 10,
+		State:       mediatedtransfer.StateWaitingRegisterSecret,
+		Db:          db,
+	}
+	sm := transfer.NewStateManager(target.StateTransiton, state, target.NameTargetTransition, lockSecretHash, token)
+	rs.saveStateManagerSnapshot(sm)
+	//编码不能修改状态机中的Db
+	assert.Equal(t, db, state.Db)
+	for i := 1; i <= 3; i++ {
+		rs.dispatchWithLog(sm, &transfer.BlockStateChange{BlockNumber: int64(10 + i)})
+	}
+	assert.Equal(t, 3, sm.StateChangeSeq)
+	assert.Equal(t, 0, sm.SnapshotSeq)
+
+	rs.restoreStateManagers()
+	key := utils.Sha3(lockSecretHash[:], token[:])
+	sm2 := rs.Transfer2StateManager[key]
+	if !assert.NotNil(t, sm2) {
+		return
+	}
+	assert.Equal(t, 3, sm2.StateChangeSeq)
+	state2 := sm2.CurrentState.(*mediatedtransfer.TargetState)
+	assert.EqualValues(t, 13, state2.BlockNumber)
+	assert.Equal(t, db, state2.Db)
+	assert.EqualValues(t, state.FromTransfer, state2.FromTransfer)
+
+	rs.removeStateManagerLog(key)
+	rs.Transfer2StateManager = make(map[common.Hash]*transfer.StateManager)
+	rs.restoreStateManagers()
+	assert.Len(t, rs.Transfer2StateManager, 0)
+}
+
+/*
+newTestRouteService 路由对应的通道注册到ChannelGraph中,重放的时候才能重新关联
+*/
+func newTestRouteService(t *testing.T, routes ...*route.State) *Service {
+	db, err := newTestStormDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &graph.ChannelGraph{ChannelIdentifier2Channel: make(map[common.Hash]*channel.Channel)}
+	for _, r := range routes {
+		g.ChannelIdentifier2Channel[r.ChannelIdentifier] = r.Channel()
+	}
+	return &Service{
+		dao:                   db,
+		Transfer2StateManager: make(map[common.Hash]*transfer.StateManager),
+		Token2ChannelGraph:    map[common.Address]*graph.ChannelGraph{utils.NewRandomAddress(): g},
+	}
+}
+
+/*
+replayTestStateManager 保存快照以后再记录几个状态变化,然后从快照和write-ahead log恢复
+*/
+func replayTestStateManager(t *testing.T, rs *Service, sm *transfer.StateManager) *transfer.StateManager {
+	rs.saveStateManagerSnapshot(sm)
+	for i := 1; i <= 3; i++ {
+		rs.dispatchWithLog(sm, &transfer.BlockStateChange{BlockNumber: int64(10 + i)})
+	}
+	assert.Equal(t, 3, sm.StateChangeSeq)
+	assert.Equal(t, 0, sm.SnapshotSeq)
+	rs.restoreStateManagers()
+	return rs.Transfer2StateManager[stateManagerKey(sm)]
+}
+
+func TestInitiatorStateManagerLogReplay(t *testing.T) {
+	r1 := utest.MakeRoute(utest.HOP1, big.NewInt(100), utest.UnitSettleTimeout, utest.UnitRevealTimeout, 0, utils.NewRandomHash())
+	r2 := utest.MakeRoute(utest.HOP2, big.NewInt(100), utest.UnitSettleTimeout, utest.UnitRevealTimeout, 0, utils.NewRandomHash())
+	rs := newTestRouteService(t, r1, r2)
+	defer rs.dao.CloseDB()
+	token := utils.NewRandomAddress()
+	secret := utils.NewRandomHash()
+	lockSecretHash := utils.ShaSecret(secret[:])
+	state := &mediatedtransfer.InitiatorState{
+		OurAddress:     utest.ADDR,
+		Transfer:       utest.MakeTransfer(big.NewInt(10), utest.ADDR, utest.HOP3, 1000, secret, lockSecretHash, token),
+		Routes:         route.NewRoutesState([]*route.State{r2}),
+		Route:          r1,
+		BlockNumber:    10,
+		LockSecretHash: lockSecretHash,
+		Secret:         secret,
+		Db:             rs.dao,
+	}
+	sm := transfer.NewStateManager(initiator.StateTransition, state, initiator.NameInitiatorTransition, lockSecretHash, token)
+	sm2 := replayTestStateManager(t, rs, sm)
+	if !assert.NotNil(t, sm2) {
+		return
+	}
+	assert.Equal(t, 3, sm2.StateChangeSeq)
+	state2 := sm2.CurrentState.(*mediatedtransfer.InitiatorState)
+	assert.EqualValues(t, 13, state2.BlockNumber)
+	assert.Equal(t, rs.dao, state2.Db)
+	assert.EqualValues(t, state.Transfer, state2.Transfer)
+	if assert.NotNil(t, state2.Route) {
+		assert.Equal(t, r1.ChannelIdentifier, state2.Route.ChannelIdentifier)
+		assert.True(t, state2.Route.Channel() == r1.Channel())
+	}
+	if assert.Len(t, state2.Routes.AvailableRoutes, 1) {
+		assert.True(t, state2.Routes.AvailableRoutes[0].Channel() == r2.Channel())
+	}
+}
+
+func TestMediatorStateManagerLogReplay(t *testing.T) {
+	payerRoute := utest.MakeRoute(utest.HOP1, big.NewInt(100), utest.UnitSettleTimeout, utest.UnitRevealTimeout, 0, utils.NewRandomHash())
+	payeeRoute := utest.MakeRoute(utest.HOP2, big.NewInt(100), utest.UnitSettleTimeout, utest.UnitRevealTimeout, 0, utils.NewRandomHash())
+	nextRoute := utest.MakeRoute(utest.HOP3, big.NewInt(100), utest.UnitSettleTimeout, utest.UnitRevealTimeout, 0, utils.NewRandomHash())
+	rs := newTestRouteService(t, payerRoute, payeeRoute, nextRoute)
+	defer rs.dao.CloseDB()
+	token := utils.NewRandomAddress()
+	lockSecretHash := utils.NewRandomHash()
+	payerTransfer := utest.MakeTransfer(big.NewInt(10), utest.HOP1, utest.HOP4, 1000, utils.EmptyHash, lockSecretHash, token)
+	payeeTransfer := utest.MakeTransfer(big.NewInt(10), utest.HOP1, utest.HOP4, 990, utils.EmptyHash, lockSecretHash, token)
+	state := &mediatedtransfer.MediatorState{
+		OurAddress:  utest.ADDR,
+		Routes:      route.NewRoutesState([]*route.State{nextRoute}),
+		BlockNumber: 10,
+		Hashlock:    lockSecretHash,
+		TransfersPair: []*mediatedtransfer.MediationPairState{
+			mediatedtransfer.NewMediationPairState(payerRoute, payeeRoute, payerTransfer, payeeTransfer),
+		},
+		LockSecretHash: lockSecretHash,
+		Token:          token,
+		Db:             rs.dao,
+	}
+	sm := transfer.NewStateManager(mediator.StateTransition, state, mediator.NameMediatorTransition, lockSecretHash, token)
+	sm2 := replayTestStateManager(t, rs, sm)
+	if !assert.NotNil(t, sm2) {
+		return
+	}
+	assert.Equal(t, 3, sm2.StateChangeSeq)
+	state2 := sm2.CurrentState.(*mediatedtransfer.MediatorState)
+	assert.EqualValues(t, 13, state2.BlockNumber)
+	assert.Equal(t, rs.dao, state2.Db)
+	if assert.Len(t, state2.TransfersPair, 1) {
+		pair := state2.TransfersPair[0]
+		assert.EqualValues(t, payerTransfer, pair.PayerTransfer)
+		assert.EqualValues(t, payeeTransfer, pair.PayeeTransfer)
+		assert.Equal(t, mediatedtransfer.StatePayerPending, pair.PayerState)
+		assert.Equal(t, mediatedtransfer.StatePayeePending, pair.PayeeState)
+		assert.True(t, pair.PayerRoute.Channel() == payerRoute.Channel())
+		assert.True(t, pair.PayeeRoute.Channel() == payeeRoute.Channel())
+	}
+	if assert.Len(t, state2.Routes.AvailableRoutes, 1) {
+		assert.True(t, state2.Routes.AvailableRoutes[0].Channel() == nextRoute.Channel())
+	}
+}
+
+func TestStateManagerRecordReplay(t *testing.T) {
+	db, err := newTestStormDb()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.CloseDB()
+	rs := &Service{
+		dao:                   db,
+		Transfer2StateManager: make(map[common.Hash]*transfer.StateManager),
+	}
+	token := utils.NewRandomAddress()
+	lockSecretHash := utils.NewRandomHash()
+	state := &mediatedtransfer.TargetState{
+		FromTransfer: &mediatedtransfer.LockedTransferState{
+			Amount:         big.NewInt(10),
+			Token:          token,
+			Expiration:     100,
+			LockSecretHash: lockSecretHash,
+		},
+		BlockNumber: 10,
+		State:       mediatedtransfer.StateWaitingRegisterSecret,
+		Db:          db,
+	}
+	sm := transfer.NewStateManager(target.StateTransiton, state, target.NameTargetTransition, lockSecretHash, token)
+	key := stateManagerKey(sm)
+	rs.Transfer2StateManager[key] = sm
+	for i := 1; i <= 2; i++ {
+		rs.dispatchWithLog(sm, &transfer.BlockStateChange{BlockNumber: int64(10 + i)})
+	}
+
+	result := rs.debugStateManager(&debugStateManagerReq{})
+	assert.Nil(t, <-result.Result)
+	assert.Len(t, result.Tag.([]*StateManagerInfo), 1)
+	result = rs.debugStateManager(&debugStateManagerReq{Key: key})
+	assert.Nil(t, <-result.Result)
+	info := result.Tag.(*StateManagerInfo)
+	assert.Equal(t, 2, info.StateChangeSeq)
+	if assert.Len(t, info.Steps, 2) {
+		assert.Equal(t, "*transfer.BlockStateChange", info.Steps[1].StateChangeType)
+	}
+
+	result = rs.debugStateManager(&debugStateManagerReq{Key: key, Record: true})
+	assert.Nil(t, <-result.Result)
+	record := result.Tag.(*StateManagerRecord)
+	assert.Equal(t, 1, record.FirstSeq)
+	assert.Len(t, record.StateChanges, 2)
+	steps, err := ReplayStateManagerRecord(record)
+	assert.Nil(t, err)
+	if assert.Len(t, steps, 2) {
+		var s mediatedtransfer.TargetState
+		assert.Nil(t, json.Unmarshal(steps[1].State, &s))
+		assert.EqualValues(t, 12, s.BlockNumber)
+	}
+
+	//停止以后从数据库中的快照和write-ahead log导出
+	record, err = NewStateManagerRecordFromDB(db, key)
+	assert.Nil(t, err)
+	assert.Len(t, record.StateChanges, 2)
+	_, err = NewStateManagerRecordFromDB(db, utils.NewRandomHash())
+	assert.Equal(t, rerr.ErrNotFound.ErrorCode, err.(rerr.StandardError).ErrorCode)
+}
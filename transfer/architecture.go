@@ -103,8 +103,11 @@ type StateManager struct {
 	FuncStateTransition FuncStateTransition
 	CurrentState        State
 	Identifier          common.Hash //transfer identifier
+	TokenAddress        common.Address
 	Name                string
 	LastReceivedMessage encoding.SignedMessager
+	StateChangeSeq      int //已经写入write-ahead log的状态变化序号
+	SnapshotSeq         int //最近一次快照包含的状态变化序号
 }
 
 //MessageTag for save and restore
@@ -119,6 +122,7 @@ func NewStateManager(stateTransition FuncStateTransition, currentState State, na
 		CurrentState:        currentState,
 		Name:                name,
 		Identifier:          identifier,
+		TokenAddress:        tokenAddress,
 	}
 }
 
@@ -27,22 +27,26 @@ type EventSendMediatedTransfer struct {
 	// no matter which channel received a mediated transfer, I have to send another mediated transfer,
 	// because which channel receives MediatedTransfer and leads me to send a new Transfer
 	// If I am the transfer initiator, then FromChannel should be null.
-	FromChannel common.Hash
-	Path        []common.Address //2019-03 消息升级后,带全路径path
+	FromChannel     common.Hash
+	Path            []common.Address //2019-03 消息升级后,带全路径path
+	EncryptedSecret []byte           //keysend交易加密的secret
+	Onion           []byte           //不为空时不再发送Initiator,Target和Path
 }
 
 //NewEventSendMediatedTransfer create EventSendMediatedTransfer
 func NewEventSendMediatedTransfer(transfer *LockedTransferState, receiver common.Address, path []common.Address) *EventSendMediatedTransfer {
 	return &EventSendMediatedTransfer{
-		Token:          transfer.Token,
-		Amount:         new(big.Int).Set(transfer.Amount),
-		LockSecretHash: transfer.LockSecretHash,
-		Initiator:      transfer.Initiator,
-		Target:         transfer.Target,
-		Expiration:     transfer.Expiration,
-		Receiver:       receiver,
-		Fee:            transfer.Fee,
-		Path:           path,
+		Token:           transfer.Token,
+		Amount:          new(big.Int).Set(transfer.Amount),
+		LockSecretHash:  transfer.LockSecretHash,
+		Initiator:       transfer.Initiator,
+		Target:          transfer.Target,
+		Expiration:      transfer.Expiration,
+		Receiver:        receiver,
+		Fee:             transfer.Fee,
+		Path:            path,
+		EncryptedSecret: transfer.EncryptedSecret,
+		Onion:           transfer.Onion,
 	}
 }
 
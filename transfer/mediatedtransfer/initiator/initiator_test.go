@@ -148,6 +148,12 @@ func TestInitWithoutRoutes(t *testing.T) {
 	assert(t, ok, true)
 }
 
+func TestNilStateWithoutInit(t *testing.T) {
+	it := StateTransition(nil, &transfer.BlockStateChange{BlockNumber: utest.UnitBlockNumber})
+	assert(t, len(it.Events), 0)
+	assert(t, it.NewState == nil, true)
+}
+
 func TestStateWaitSecretRequestValid(t *testing.T) {
 	amount := utest.UnitTransferAmount
 	blockNumber := utest.UnitBlockNumber
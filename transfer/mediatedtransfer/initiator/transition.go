@@ -129,16 +129,18 @@ func tryNewRoute(state *mt.InitiatorState) *transfer.TransitionResult {
 		lockExpiration = state.Transfer.Expiration
 	}
 	tr := &mt.LockedTransferState{
-		TargetAmount:   state.Transfer.TargetAmount,
-		Amount:         new(big.Int).Add(state.Transfer.TargetAmount, tryRoute.TotalFee),
-		Token:          state.Transfer.Token,
-		Initiator:      state.Transfer.Initiator,
-		Target:         state.Transfer.Target,
-		Expiration:     lockExpiration,
-		LockSecretHash: state.LockSecretHash,
-		Secret:         state.Secret,
-		Fee:            tryRoute.TotalFee,
-		Data:           state.Transfer.Data,
+		TargetAmount:    state.Transfer.TargetAmount,
+		Amount:          new(big.Int).Add(state.Transfer.TargetAmount, tryRoute.TotalFee),
+		Token:           state.Transfer.Token,
+		Initiator:       state.Transfer.Initiator,
+		Target:          state.Transfer.Target,
+		Expiration:      lockExpiration,
+		LockSecretHash:  state.LockSecretHash,
+		Secret:          state.Secret,
+		Fee:             tryRoute.TotalFee,
+		Data:            state.Transfer.Data,
+		EncryptedSecret: state.Transfer.EncryptedSecret,
+		Onion:           tryRoute.Onion,
 	}
 	msg := mt.NewEventSendMediatedTransfer(tr, tryRoute.HopNode(), tryRoute.Path)
 	if len(state.Routes.CanceledRoutes) > 0 {
@@ -151,7 +153,7 @@ func tryNewRoute(state *mt.InitiatorState) *transfer.TransitionResult {
 	state.Route = tryRoute
 	state.Transfer = tr
 	state.Message = msg
-	log.Trace(fmt.Sprintf("send mediated transfer id=%s,amount=%s,token=%s,target=%s,secret=%s,data=%s", utils.HPex(tr.LockSecretHash), tr.Amount, utils.APex(tr.Token), utils.APex(tr.Target), tr.Secret.String(), tr.Data))
+	log.Trace(fmt.Sprintf("send mediated transfer id=%s,amount=%s,token=%s,target=%s,secret=%s,data=%s", utils.HPex(tr.LockSecretHash), tr.Amount, utils.APex(tr.Token), utils.APex(tr.Target), tr.Secret.String(), tr.Data), logCtx(state)...)
 	events := []transfer.Event{msg}
 	return &transfer.TransitionResult{
 		NewState: state,
@@ -300,7 +302,7 @@ func handleSecretRevealOnChain(state *mt.InitiatorState, st *mt.ContractSecretRe
 		// we should know locksecrethash no matter whether it is token swap, otherwise implementation has problem.
 		panic(fmt.Sprintf("my locksecrethash=%s,received=%s", state.LockSecretHash.String(), st.LockSecretHash.String()))
 	}
-	log.Trace(fmt.Sprintf("Check lock's expiration, state.Transfer.Expiration=%d, st.BlockNumber=%d\n", state.Transfer.Expiration, st.BlockNumber))
+	log.Trace(fmt.Sprintf("Check lock's expiration, state.Transfer.Expiration=%d, st.BlockNumber=%d\n", state.Transfer.Expiration, st.BlockNumber), logCtx(state)...)
 	if state.Transfer.Expiration < st.BlockNumber {
 		//对于我来说这笔交易已经超期了. 应该发出 移除此锁消息.
 		// As to me this transfer expired, should send RemoveExpiredLock message.
@@ -455,13 +457,13 @@ func StateTransition(originalState transfer.State, st transfer.StateChange) *tra
 			if state.RevealSecret == nil {
 				it = handleSecretRequest(state, st2)
 			} else {
-				log.Warn(fmt.Sprintf("recevie secret request but initiator have already sent reveal secret"))
+				log.Warn(fmt.Sprintf("recevie secret request but initiator have already sent reveal secret"), logCtx(state)...)
 			}
 		case *mt.ReceiveAnnounceDisposedStateChange:
 			if state.RevealSecret == nil {
 				it = handleRefund(state, st2)
 			} else {
-				log.Warn(fmt.Sprintf("secret already revealed ,but initiator recevied announce disposed %s", utils.StringInterface(st, 3)))
+				log.Warn(fmt.Sprintf("secret already revealed ,but initiator recevied announce disposed %s", utils.StringInterface(st, 3)), logCtx(state)...)
 			}
 		case *mt.ActionCancelRouteStateChange:
 			if state.RevealSecret == nil {
@@ -480,8 +482,20 @@ func StateTransition(originalState transfer.State, st transfer.StateChange) *tra
 		case *mt.ContractChannelWithdrawStateChange:
 			it = cancelCurrentRoute(state, "partner withdraw on channel with me")
 		default:
-			log.Error(fmt.Sprintf("initiator received unkown state change %s", utils.StringInterface(st, 3)))
+			log.Error(fmt.Sprintf("initiator received unkown state change %s", utils.StringInterface(st, 3)), logCtx(state)...)
 		}
 	}
 	return it
 }
+
+//logCtx 日志中带上交易的lockSecretHash和当前路由的通道
+func logCtx(state *mt.InitiatorState) []interface{} {
+	if state == nil {
+		return nil
+	}
+	channelIdentifier := utils.EmptyHash
+	if state.Route != nil {
+		channelIdentifier = state.Route.ChannelIdentifier
+	}
+	return utils.TransferLogCtx(state.LockSecretHash, channelIdentifier)
+}
@@ -359,6 +359,29 @@ func TestNextTransferPair(t *testing.T) {
 	assert(t, len(routesState.AvailableRoutes), 0)
 }
 
+//onion交易转发的是解密以后的内层onion,initiator和target都为空
+func TestNextTransferPairOnion(t *testing.T) {
+	timeoutBlocks := 47
+	var blockNumber int64 = 3
+	var balance = big.NewInt(10)
+
+	payerRoute := utest.MakeRoute(utest.HOP1, balance, 0, 0, 0, utils.NewRandomHash())
+	payerTransfer := utest.MakeTransfer(balance, utils.EmptyAddress, utils.EmptyAddress, 50, utils.EmptyHash, utils.EmptyHash, utest.UnitTokenAddress)
+	payerTransfer.Onion = []byte("inner onion")
+
+	routes := []*route.State{utest.MakeRoute(utest.HOP2, balance, utest.UnitSettleTimeout, utest.UnitRevealTimeout, 0, utils.NewRandomHash())}
+	routesState := route.NewRoutesState(routes)
+	pair, events, _ := nextTransferPair(payerRoute, payerTransfer, routesState, timeoutBlocks, blockNumber)
+
+	assert(t, pair.PayeeTransfer.Onion, payerTransfer.Onion)
+	assert(t, len(events), 1)
+	tr, ok := events[0].(*mediatedtransfer.EventSendMediatedTransfer)
+	assert(t, ok, true)
+	assert(t, tr.Onion, payerTransfer.Onion)
+	assert(t, tr.Receiver, utest.HOP2)
+	assert(t, tr.Amount, payerTransfer.Amount)
+}
+
 func TestSetPayee(t *testing.T) {
 	pairs := makeTransfersPair(utest.HOP1, []common.Address{utest.HOP2, utest.HOP3, utest.HOP4}, utest.HOP6, 10, utest.UnitSecret, 0, utest.UnitRevealTimeout)
 	assert(t, pairs[0].PayerState, mediatedtransfer.StatePayerPending)
@@ -157,7 +157,8 @@ func getTimeoutBlocks(payerRoute *route.State, payerTransfer *mediatedtransfer.L
 		blocksUntilSettlement = payerTransfer.Expiration - blockNumber
 	}
 	log.Debug(fmt.Sprintf("get transfer lockSecretHash=%s, expiration=%d, now=%d, blocksUntilSettlement=%d",
-		utils.HPex(payerTransfer.LockSecretHash), payerTransfer.Expiration, blockNumber, blocksUntilSettlement))
+		utils.HPex(payerTransfer.LockSecretHash), payerTransfer.Expiration, blockNumber, blocksUntilSettlement),
+		utils.TransferLogCtx(payerTransfer.LockSecretHash, payerRoute.ChannelIdentifier)...)
 	return blocksUntilSettlement
 }
 
@@ -369,15 +370,17 @@ func nextTransferPair(payerRoute *route.State, payerTransfer *mediatedtransfer.L
 	lockTimeout := timeoutBlocks //- payeeRoute.RevealTimeout()
 	lockExpiration := int64(lockTimeout) + blockNumber
 	payeeTransfer := &mediatedtransfer.LockedTransferState{
-		TargetAmount:   payerTransfer.TargetAmount,
-		Amount:         big.NewInt(0).Sub(payerTransfer.Amount, payeeRoute.Fee),
-		Token:          payerTransfer.Token,
-		Initiator:      payerTransfer.Initiator,
-		Target:         payerTransfer.Target,
-		Expiration:     lockExpiration,
-		LockSecretHash: payerTransfer.LockSecretHash,
-		Secret:         payerTransfer.Secret,
-		Fee:            big.NewInt(0).Sub(payerTransfer.Fee, payeeRoute.Fee),
+		TargetAmount:    payerTransfer.TargetAmount,
+		Amount:          big.NewInt(0).Sub(payerTransfer.Amount, payeeRoute.Fee),
+		Token:           payerTransfer.Token,
+		Initiator:       payerTransfer.Initiator,
+		Target:          payerTransfer.Target,
+		Expiration:      lockExpiration,
+		LockSecretHash:  payerTransfer.LockSecretHash,
+		Secret:          payerTransfer.Secret,
+		Fee:             big.NewInt(0).Sub(payerTransfer.Fee, payeeRoute.Fee),
+		EncryptedSecret: payerTransfer.EncryptedSecret,
+		Onion:           payerTransfer.Onion,
 	}
 	if payeeRoute.HopNode() == payeeTransfer.Target {
 		//i'm the last hop,so take the rest of the fee
@@ -789,7 +792,8 @@ func mediateTransfer(state *mediatedtransfer.MediatorState, payerRoute *route.St
 	*/
 	payerChannel := transferPair.PayerRoute.Channel()
 	if len(payerChannel.PartnerState.Lock2PendingLocks)+len(payerChannel.PartnerState.Lock2UnclaimedLocks) > payerChannel.RevealTimeout {
-		log.Warn(fmt.Sprintf("holding too much lock of %s, reject new mediated transfer from him", utils.APex2(payerChannel.PartnerState.Address)))
+		log.Warn(fmt.Sprintf("holding too much lock of %s, reject new mediated transfer from him", utils.APex2(payerChannel.PartnerState.Address)),
+			utils.TransferLogCtx(state.LockSecretHash, payerRoute.ChannelIdentifier)...)
 		return &transfer.TransitionResult{
 			NewState: state,
 			Events:   eventsForRefund(payerRoute, payerTransfer, rerr.ErrRejectTransferBecauseChannelHoldingTooMuchLock),
@@ -816,7 +820,7 @@ func cancelCurrentRoute(state *mediatedtransfer.MediatorState, refundChannelIden
 	}
 	l := len(state.TransfersPair)
 	if l <= 0 {
-		log.Error(fmt.Sprintf("recevie refund ,but has no transfer pair ,must be a attack!!"))
+		log.Error(fmt.Sprintf("recevie refund ,but has no transfer pair ,must be a attack!!"), utils.TransferLogCtx(state.LockSecretHash, utils.EmptyHash)...)
 		return it
 	}
 	transferPair := state.TransfersPair[l-1]
@@ -840,7 +844,7 @@ func cancelCurrentRoute(state *mediatedtransfer.MediatorState, refundChannelIden
 		Refuse by announce disposed.
 	*/
 	if transferPair.PayerRoute.ClosedBlock() != 0 {
-		log.Warn("channel already closed, stop trying new route")
+		log.Warn("channel already closed, stop trying new route", utils.TransferLogCtx(state.LockSecretHash, transferPair.PayerRoute.ChannelIdentifier)...)
 		it.Events = eventsForRefund(transferPair.PayerRoute, transferPair.PayerTransfer, rerr.ErrRejectTransferBecausePayerChannelClosed)
 		return it
 	}
@@ -923,7 +927,7 @@ func handleAnnouceDisposed(state *mediatedtransfer.MediatorState, st *mediatedtr
 	*/
 	l := len(state.TransfersPair)
 	if l <= 0 {
-		log.Error(fmt.Sprintf("recevie refund ,but has no transfer pair ,must be a attack!!"))
+		log.Error(fmt.Sprintf("recevie refund ,but has no transfer pair ,must be a attack!!"), utils.TransferLogCtx(state.LockSecretHash, utils.EmptyHash)...)
 		return it
 	}
 	transferPair := state.TransfersPair[l-1]
@@ -967,7 +971,7 @@ func handleAnnouceDisposed(state *mediatedtransfer.MediatorState, st *mediatedtr
 		} else {
 			log.Warn(fmt.Sprintf("receive expired EventSendAnnounceDisposedResponse,expiration=%d,currentblock=%d,response=%s",
 				payeeTransfer.Expiration, state.BlockNumber, utils.StringInterface(st, 3),
-			))
+			), utils.TransferLogCtx(state.LockSecretHash, payeeRoute.ChannelIdentifier)...)
 		}
 
 	}
@@ -1150,7 +1154,7 @@ func StateTransition(originalState transfer.State, stateChange transfer.StateCha
 			if state.Secret == utils.EmptyHash {
 				it = handleAnnouceDisposed(state, st2)
 			} else {
-				log.Error(fmt.Sprintf("mediator state manager ,already knows secret,but recevied announce disposed, must be a error"))
+				log.Error(fmt.Sprintf("mediator state manager ,already knows secret,but recevied announce disposed, must be a error"), utils.TransferLogCtx(state.LockSecretHash, utils.EmptyHash)...)
 			}
 
 		case *mediatedtransfer.ReceiveSecretRevealStateChange:
@@ -1162,14 +1166,14 @@ func StateTransition(originalState transfer.State, stateChange transfer.StateCha
 				/*
 					有可能是通过链上注册密码事件,上家会发送unlock给我,但是我连接的公链可能还没有收到整个事件,因此是有可能不知道密码的
 				*/
-				log.Warn(fmt.Sprintf("mediated state manager recevie unlock,but i don't know secret,this maybe a error "))
+				log.Warn(fmt.Sprintf("mediated state manager recevie unlock,but i don't know secret,this maybe a error "), utils.TransferLogCtx(state.LockSecretHash, utils.EmptyHash)...)
 			}
 			it = handleBalanceProof(state, st2)
 		case *mediatedtransfer.MediatorReReceiveStateChange:
 			if state.Secret == utils.EmptyHash {
 				it = handleMediatedTransferAgain(state, st2)
 			} else {
-				log.Error(fmt.Sprintf("already known secret,but recevie medaited tranfer again:%s", st2.Message))
+				log.Error(fmt.Sprintf("already known secret,but recevie medaited tranfer again:%s", st2.Message), utils.TransferLogCtx(state.LockSecretHash, utils.EmptyHash)...)
 			}
 		/*
 			only receive from channel with payee,
@@ -1180,7 +1184,7 @@ func StateTransition(originalState transfer.State, stateChange transfer.StateCha
 		case *mediatedtransfer.ContractChannelWithdrawStateChange:
 			it = cancelCurrentRoute(state, st2.ChannelIdentifier.ChannelIdentifier)
 		default:
-			log.Info(fmt.Sprintf("unknown statechange :%s", utils.StringInterface(st2, 3)))
+			log.Info(fmt.Sprintf("unknown statechange :%s", utils.StringInterface(st2, 3)), utils.TransferLogCtx(state.LockSecretHash, utils.EmptyHash)...)
 		}
 	}
 	// this is the place for paranoia
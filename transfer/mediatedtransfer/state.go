@@ -17,16 +17,18 @@ import (
 LockedTransferState is State of a transfer that is time hash locked.
 */
 type LockedTransferState struct {
-	TargetAmount   *big.Int       //amount target should recevied
-	Amount         *big.Int       // Amount of `token` being transferred.
-	Token          common.Address //Token being transferred.
-	Initiator      common.Address //Transfer initiator
-	Target         common.Address //Transfer target address.
-	Expiration     int64          //The absolute block number that the lock expires.
-	LockSecretHash common.Hash    // The hashlock.
-	Secret         common.Hash    //The secret that unlocks the lock, may be None.
-	Fee            *big.Int       // how much fee left for other hop node.
-	Data           string
+	TargetAmount    *big.Int       //amount target should recevied
+	Amount          *big.Int       // Amount of `token` being transferred.
+	Token           common.Address //Token being transferred.
+	Initiator       common.Address //Transfer initiator
+	Target          common.Address //Transfer target address.
+	Expiration      int64          //The absolute block number that the lock expires.
+	LockSecretHash  common.Hash    // The hashlock.
+	Secret          common.Hash    //The secret that unlocks the lock, may be None.
+	Fee             *big.Int       // how much fee left for other hop node.
+	Data            string
+	EncryptedSecret []byte //keysend交易中用target公钥加密的secret,中间节点原样转发
+	Onion           []byte //onion交易中需要转发给下一跳的onion,此时Initiator和Target为空
 }
 
 //AlmostEqual if two state equals?
@@ -42,14 +44,15 @@ func (l *LockedTransferState) AlmostEqual(other *LockedTransferState) bool {
 //LockedTransferFromMessage Create LockedTransferState from a MediatedTransfer message.
 func LockedTransferFromMessage(msg *encoding.MediatedTransfer, tokenAddress common.Address) *LockedTransferState {
 	return &LockedTransferState{
-		TargetAmount:   new(big.Int).Sub(msg.PaymentAmount, msg.Fee),
-		Amount:         new(big.Int).Set(msg.PaymentAmount),
-		Initiator:      msg.Initiator,
-		Target:         msg.Target,
-		Expiration:     msg.Expiration,
-		LockSecretHash: msg.LockSecretHash,
-		Fee:            msg.Fee,
-		Token:          tokenAddress,
+		TargetAmount:    new(big.Int).Sub(msg.PaymentAmount, msg.Fee),
+		Amount:          new(big.Int).Set(msg.PaymentAmount),
+		Initiator:       msg.Initiator,
+		Target:          msg.Target,
+		Expiration:      msg.Expiration,
+		LockSecretHash:  msg.LockSecretHash,
+		Fee:             msg.Fee,
+		Token:           tokenAddress,
+		EncryptedSecret: msg.EncryptedSecret,
 	}
 }
 
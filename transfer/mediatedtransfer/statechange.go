@@ -302,4 +302,10 @@ func init() {
 	gob.Register(&ContractNewChannelStateChange{})
 	gob.Register(&ContractTokenAddedStateChange{})
 	gob.Register(&ContractBalanceProofUpdatedStateChange{})
+	gob.Register(&MediatorReReceiveStateChange{})
+	gob.Register(&ContractUnlockStateChange{})
+	gob.Register(&ContractChannelWithdrawStateChange{})
+	gob.Register(&ContractCooperativeSettledStateChange{})
+	gob.Register(&ContractPunishedStateChange{})
+	gob.Register(&ContractHistoryEventCompleteStateChange{})
 }
@@ -125,6 +125,26 @@ func TestHandleInitTarget(t *testing.T) {
 	assert(t, ev.Receiver, initiator)
 }
 
+/*
+keysend transfer already knows the secret, init must reveal it to the payer instead of sending a secret request.
+*/
+func TestHandleInitTargetKeysend(t *testing.T) {
+	var blockNumber int64 = 1
+	var amount int64 = 1
+	var expire = int64(utest.UnitRevealTimeout) + blockNumber + 1
+	initiator := utest.HOP1
+
+	st := makeInitStateChange(utest.ADDR, amount, blockNumber, initiator, expire)
+	fromTransfer := st.FromTranfer
+	fromTransfer.Secret = utest.UnitSecret
+	it := handleInitTraget(st)
+	assert(t, len(it.Events), 1)
+	ev := it.Events[0].(*mediatedtransfer.EventSendRevealSecret)
+	assert(t, ev.Secret, utest.UnitSecret)
+	assert(t, ev.Receiver, st.FromRoute.HopNode())
+	assert(t, it.NewState.(*mediatedtransfer.TargetState).State, mediatedtransfer.StateRevealSecret)
+}
+
 // Init transfer must do nothing if the expiration is bad.
 func TestHandleInitTargetBadExpiration(t *testing.T) {
 	var blockNumber int64 = 1
@@ -58,6 +58,23 @@ func handleInitTraget(st *mediatedtransfer.ActionInitTargetStateChange) *transfe
 			  if there is not enough time to safely withdraw the token on-chain
 		     silently let the transfer expire.
 	*/
+	if safeToWait && tr.Secret != utils.EmptyHash {
+		/*
+			keysend交易,发起方已经把密码加密给我了,不需要SecretRequest,直接告诉上家密码
+		*/
+		state.State = mediatedtransfer.StateRevealSecret
+		reveal := &mediatedtransfer.EventSendRevealSecret{
+			LockSecretHash: tr.LockSecretHash,
+			Secret:         tr.Secret,
+			Token:          tr.Token,
+			Receiver:       route.HopNode(),
+			Sender:         state.OurAddress,
+		}
+		return &transfer.TransitionResult{
+			NewState: state,
+			Events:   []transfer.Event{reveal},
+		}
+	}
 	if safeToWait {
 		secretRequest := &mediatedtransfer.EventSendSecretRequest{
 			ChannelIdentifier: route.ChannelIdentifier,
@@ -278,7 +295,8 @@ func StateTransiton(originalState transfer.State, stateChange transfer.StateChan
 			// Maybe we can receive unlock message without receiving secret.
 			it = handleBalanceProof(state, st2)
 		default:
-			log.Error(fmt.Sprintf("target state manager receive unkown state change,if this transfer is a token swap ,it's ok.  %s", utils.StringInterface(stateChange, 3)))
+			log.Error(fmt.Sprintf("target state manager receive unkown state change,if this transfer is a token swap ,it's ok.  %s", utils.StringInterface(stateChange, 3)),
+				utils.TransferLogCtx(state.FromTransfer.LockSecretHash, state.FromRoute.ChannelIdentifier)...)
 		}
 	}
 	return clearIfFinalized(it)
@@ -25,6 +25,7 @@ type State struct {
 	Fee               *big.Int         // how much fee to this channel charge charge .
 	TotalFee          *big.Int         // how much fee for all path when initiator use this route
 	Path              []common.Address // 2019-03消息升级,路由中保存该条路径上所有节点,有序
+	Onion             []byte           // 发起方为这条路径创建的onion,为空表示明文发送路径
 }
 
 //NewState create route state
@@ -86,6 +87,11 @@ func (rs *State) State() channeltype.State {
 	return rs.ch.State
 }
 
+//SetChannel 从快照或者write-ahead log恢复的路由没有保存ch,需要根据ChannelIdentifier重新关联
+func (rs *State) SetChannel(ch *channel.Channel) {
+	rs.ch = ch
+}
+
 //SetState for test only,
 func (rs *State) SetState(state channeltype.State) {
 	rs.ch.State = state
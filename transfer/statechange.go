@@ -62,4 +62,7 @@ func init() {
 	gob.Register(&ActionCancelTransferStateChange{})
 	gob.Register(&ActionTransferDirectStateChange{})
 	gob.Register(&ReceiveTransferDirectStateChange{})
+	gob.Register(&CooperativeSettleStateChange{})
+	gob.Register(&WithdrawRequestStateChange{})
+	gob.Register(&StopTransferRightNowStateChange{})
 }
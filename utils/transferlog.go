@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//交易相关日志的关联字段
+const (
+	LogKeyLockSecretHash = "lockSecretHash"
+	LogKeyChannel        = "channel"
+)
+
+/*
+TransferLogCtx 交易经过的PhotonProtocol,状态机,eventhandler和通道的日志都要带上这些字段,
+这样可以按照lockSecretHash找到一个交易的所有日志.channelIdentifier为空的时候只有lockSecretHash
+*/
+func TransferLogCtx(lockSecretHash, channelIdentifier common.Hash) []interface{} {
+	if lockSecretHash == EmptyHash {
+		return nil
+	}
+	ctx := []interface{}{LogKeyLockSecretHash, lockSecretHash.String()}
+	if channelIdentifier != EmptyHash {
+		ctx = append(ctx, LogKeyChannel, channelIdentifier.String())
+	}
+	return ctx
+}
+
+//TransferLogRecord 一条带有lockSecretHash的日志
+type TransferLogRecord struct {
+	Time   time.Time         `json:"t"`
+	Lvl    string            `json:"lvl"`
+	Msg    string            `json:"msg"`
+	Caller string            `json:"caller"`
+	Ctx    map[string]string `json:"ctx"`
+}
+
+/*
+TransferLogStore 在内存中按照lockSecretHash保存最近的交易日志,不受verbosity的限制.
+最多保存maxTransfers个交易,每个交易最多maxRecords条,超出的时候丢弃最早的
+*/
+type TransferLogStore struct {
+	lock         sync.Mutex
+	maxTransfers int
+	maxRecords   int
+	records      map[common.Hash][]*TransferLogRecord
+	order        []common.Hash // 按照第一条日志的时间排序
+}
+
+//NewTransferLogStore :
+func NewTransferLogStore(maxTransfers, maxRecords int) *TransferLogStore {
+	return &TransferLogStore{
+		maxTransfers: maxTransfers,
+		maxRecords:   maxRecords,
+		records:      make(map[common.Hash][]*TransferLogRecord),
+	}
+}
+
+//TransferLogs 节点所有交易的日志
+var TransferLogs = NewTransferLogStore(1000, 500)
+
+//Handler 保存带有lockSecretHash的日志,其他的日志直接忽略
+func (s *TransferLogStore) Handler() log.Handler {
+	return log.FuncHandler(func(r *log.Record) error {
+		var lockSecretHash common.Hash
+		ctx := make(map[string]string)
+		for i := 0; i+1 < len(r.Ctx); i += 2 {
+			k := fmt.Sprint(r.Ctx[i])
+			v := fmt.Sprint(r.Ctx[i+1])
+			if k == LogKeyLockSecretHash {
+				lockSecretHash = common.HexToHash(v)
+			}
+			if k == "caller" {
+				//json格式的日志会在ctx中加上caller
+				continue
+			}
+			ctx[k] = v
+		}
+		if lockSecretHash == EmptyHash {
+			return nil
+		}
+		s.add(lockSecretHash, &TransferLogRecord{
+			Time:   r.Time,
+			Lvl:    r.Lvl.String(),
+			Msg:    r.Msg,
+			Caller: fmt.Sprint(r.Call),
+			Ctx:    ctx,
+		})
+		return nil
+	})
+}
+
+func (s *TransferLogStore) add(lockSecretHash common.Hash, r *TransferLogRecord) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	rs, ok := s.records[lockSecretHash]
+	if !ok {
+		if len(s.order) >= s.maxTransfers {
+			delete(s.records, s.order[0])
+			s.order = s.order[1:]
+		}
+		s.order = append(s.order, lockSecretHash)
+	}
+	if len(rs) >= s.maxRecords {
+		rs = rs[1:]
+	}
+	s.records[lockSecretHash] = append(rs, r)
+}
+
+//Get 一个交易的所有日志,按照时间排序
+func (s *TransferLogStore) Get(lockSecretHash common.Hash) []*TransferLogRecord {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	rs := s.records[lockSecretHash]
+	return append([]*TransferLogRecord{}, rs...)
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/Photon/log"
+)
+
+func TestTransferLogStore(t *testing.T) {
+	s := NewTransferLogStore(2, 2)
+	logger := log.New()
+	logger.SetHandler(s.Handler())
+	h1, h2, h3 := NewRandomHash(), NewRandomHash(), NewRandomHash()
+	channel := NewRandomHash()
+	logger.Info("no lockSecretHash")
+	logger.Info("a", TransferLogCtx(h1, channel)...)
+	logger.Info("b", TransferLogCtx(h1, EmptyHash)...)
+	logger.Info("c", TransferLogCtx(h1, channel)...)
+	rs := s.Get(h1)
+	if len(rs) != 2 || rs[0].Msg != "b" || rs[1].Msg != "c" {
+		t.Errorf("expect last two records of h1, got %v", rs)
+	}
+	if rs[1].Ctx[LogKeyChannel] != channel.String() || rs[1].Ctx[LogKeyLockSecretHash] != h1.String() {
+		t.Errorf("wrong ctx %v", rs[1].Ctx)
+	}
+	if _, ok := rs[0].Ctx[LogKeyChannel]; ok {
+		t.Errorf("record b should not have channel")
+	}
+	logger.Info("d", TransferLogCtx(h2, channel)...)
+	logger.Info("e", TransferLogCtx(h3, channel)...)
+	if len(s.Get(h1)) != 0 {
+		t.Errorf("h1 should be removed")
+	}
+	if len(s.Get(h2)) != 1 || len(s.Get(h3)) != 1 {
+		t.Errorf("h2 and h3 should be kept")
+	}
+	if TransferLogCtx(EmptyHash, channel) != nil {
+		t.Errorf("no ctx without lockSecretHash")
+	}
+}